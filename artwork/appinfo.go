@@ -0,0 +1,36 @@
+package artwork
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// Steam installation whose appinfo.vdf names games, set when the users are
+// loaded.
+var SteamInstallationDir string
+
+// The apps of appinfo.vdf, read once per run.
+var localAppInfo struct {
+	sync.Once
+	apps map[string]steam.AppInfo
+}
+
+// Returns what the local appinfo.vdf knows about an app, and whether it
+// knows it at all.
+func getLocalAppInfo(appID string) (steam.AppInfo, bool) {
+	localAppInfo.Do(func() {
+		if SteamInstallationDir == "" {
+			return
+		}
+		data, err := ioutil.ReadFile(filepath.Join(SteamInstallationDir, "appcache", "appinfo.vdf"))
+		if err != nil {
+			return
+		}
+		localAppInfo.apps, _ = steam.ParseAppInfo(data)
+	})
+	app, ok := localAppInfo.apps[appID]
+	return app, ok && app.Name != ""
+}
@@ -0,0 +1,79 @@
+package artwork
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Applications people add to Steam as shortcuts, by the name of their
+// executable or their Flatpak ID in lower case, with their name on
+// SteamGridDB, which has entries for them besides games.
+var knownApplications = map[string]string{
+	"spotify":                        "Spotify",
+	"com.spotify.client":             "Spotify",
+	"firefox":                        "Firefox",
+	"org.mozilla.firefox":            "Firefox",
+	"chrome":                         "Google Chrome",
+	"google-chrome":                  "Google Chrome",
+	"google-chrome-stable":           "Google Chrome",
+	"com.google.chrome":              "Google Chrome",
+	"msedge":                         "Microsoft Edge",
+	"microsoft-edge":                 "Microsoft Edge",
+	"com.microsoft.edge":             "Microsoft Edge",
+	"discord":                        "Discord",
+	"com.discordapp.discord":         "Discord",
+	"dolphin":                        "Dolphin Emulator",
+	"dolphin-emu":                    "Dolphin Emulator",
+	"org.dolphinemu.dolphin-emu":     "Dolphin Emulator",
+	"retroarch":                      "RetroArch",
+	"org.libretro.retroarch":         "RetroArch",
+	"pcsx2":                          "PCSX2",
+	"pcsx2-qt":                       "PCSX2",
+	"net.pcsx2.pcsx2":                "PCSX2",
+	"duckstation":                    "DuckStation",
+	"duckstation-qt":                 "DuckStation",
+	"org.duckstation.duckstation":    "DuckStation",
+	"ppsspp":                         "PPSSPP",
+	"ppssppwindows64":                "PPSSPP",
+	"org.ppsspp.ppsspp":              "PPSSPP",
+	"ryujinx":                        "Ryujinx",
+	"cemu":                           "Cemu",
+	"info.cemu.cemu":                 "Cemu",
+	"rpcs3":                          "RPCS3",
+	"net.rpcs3.rpcs3":                "RPCS3",
+	"vlc":                            "VLC media player",
+	"org.videolan.vlc":               "VLC media player",
+	"obs":                            "OBS Studio",
+	"obs64":                          "OBS Studio",
+	"com.obsproject.studio":          "OBS Studio",
+	"kodi":                           "Kodi",
+	"tv.kodi.kodi":                   "Kodi",
+	"moonlight":                      "Moonlight",
+	"com.moonlight_stream.moonlight": "Moonlight",
+	"heroic":                         "Heroic Games Launcher",
+	"com.heroicgameslauncher.hgl":    "Heroic Games Launcher",
+	"lutris":                         "Lutris",
+	"net.lutris.lutris":              "Lutris",
+	"steam-rom-manager":              "Steam ROM Manager",
+}
+
+// Returns the SteamGridDB name of the application a shortcut launches, or ""
+// for games and unknown applications. Flatpaks are launched by flatpak, with
+// their ID in the launch options: flatpak run com.spotify.Client.
+func applicationName(exe string, launchOptions string) string {
+	exe = strings.Trim(exe, `"`)
+	base := strings.ToLower(strings.TrimSuffix(filepath.Base(exe), filepath.Ext(exe)))
+	// Windows paths on other systems, and the other way around.
+	if i := strings.LastIndexAny(base, `\/`); i >= 0 {
+		base = base[i+1:]
+	}
+	if base == "flatpak" {
+		for _, field := range strings.Fields(launchOptions) {
+			if name, ok := knownApplications[strings.ToLower(field)]; ok {
+				return name
+			}
+		}
+		return ""
+	}
+	return knownApplications[base]
+}
@@ -0,0 +1,70 @@
+package artwork
+
+import (
+	"strings"
+
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Types of apps that aren't played and never have artwork of their own:
+// DLC, soundtracks, SDKs, dedicated servers and the like. Steam lists them
+// with the games all the same.
+var NonGameAppTypes = []string{"dlc", "music", "tool", "config", "video", "media", "series", "episode", "advertising", "hardware"}
+
+// Returns the type of a Steam app, lowercase, or "" when it's unknown. The
+// local appinfo.vdf is asked first; the store only for apps without a name,
+// the ones found in the local config rather than in the profile, which is
+// where DLC and tools come from. Fills in the name when found.
+func steamAppType(game *Game) string {
+	if app, ok := getLocalAppInfo(game.ID); ok {
+		if game.Name == "" {
+			game.Name = app.Name
+		}
+		return strings.ToLower(app.Type)
+	}
+	if game.Name != "" || sources.OfflineMode {
+		return ""
+	}
+	details, err := sources.GetStoreAppDetails(game.ID)
+	if err != nil || details == nil {
+		return ""
+	}
+	game.Name = details.Name
+	return strings.ToLower(details.Type)
+}
+
+// Removes the Steam apps that aren't games, but the types asked for with
+// -includetypes, unless specific appIDs were asked for. Returns how many were
+// removed.
+func skipNonGames(games map[string]*Game, options *Options) int {
+	if options.AppIDs != "" {
+		return 0
+	}
+	includeTypes := options.includedAppTypes()
+	nSkipped := 0
+	for gameID, game := range games {
+		if game.Custom {
+			continue
+		}
+		appType := steamAppType(game)
+		if containsString(NonGameAppTypes, appType) && !containsString(includeTypes, appType) {
+			delete(games, gameID)
+			nSkipped++
+		}
+	}
+	return nSkipped
+}
+
+// Returns the app types of -includetypes, lowercase, with "all" for every
+// one of them.
+func (options *Options) includedAppTypes() []string {
+	var types []string
+	for _, appType := range strings.Split(options.IncludeTypes, ",") {
+		appType = strings.ToLower(strings.TrimSpace(appType))
+		if appType == "all" {
+			return NonGameAppTypes
+		}
+		types = append(types, appType)
+	}
+	return types
+}
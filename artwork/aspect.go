@@ -0,0 +1,67 @@
+package artwork
+
+import (
+	"errors"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// How far, in percent, images may be from the aspect ratio of their art style
+// by default. Covers and heroes are also on SteamGridDB at 660x930 and
+// 1600x650, which Steam crops without deforming the grid.
+var defaultAspectTolerances = map[string]float64{
+	"Banner": 5,
+	"Cover":  10,
+	"Hero":   25,
+}
+
+// Parses a tolerance of -aspecttolerance, like 5 or 5%.
+func parseAspectTolerance(given string) (float64, error) {
+	tolerance, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(given), "%"), 64)
+	if err != nil || tolerance < 0 {
+		return 0, errors.New("expected a percentage like 5%, got " + given)
+	}
+	return tolerance, nil
+}
+
+// Returns how far, in percent, images of an art style may be from its aspect
+// ratio, from -aspecttolerance-<style> or else -aspecttolerance. ok is false
+// for art styles of any shape. 0 only rejects images turned the wrong way.
+func (options *Options) aspectTolerance(artStyle string) (tolerance float64, ok bool) {
+	tolerance, ok = defaultAspectTolerances[artStyle]
+	if !ok {
+		return 0, false
+	}
+	given := map[string]string{
+		"Banner": options.AspectToleranceBanner,
+		"Cover":  options.AspectToleranceCover,
+		"Hero":   options.AspectToleranceHero,
+	}[artStyle]
+	if given == "" {
+		given = options.AspectTolerance
+	}
+	if given != "" {
+		tolerance, _ = parseAspectTolerance(given)
+	}
+	return tolerance, true
+}
+
+// Tells if an image is the shape of its art style, within tolerance, so that
+// mislabeled images, like a cover uploaded as a banner, aren't saved.
+func hasArtStyleAspect(artStyle string, size image.Point, options *Options) bool {
+	tolerance, ok := options.aspectTolerance(artStyle)
+	if !ok || size.X <= 0 || size.Y <= 0 {
+		return true
+	}
+	canonical := canonicalSizes[artStyle][0]
+	if tolerance == 0 && canonical.X > canonical.Y {
+		return size.X >= size.Y
+	} else if tolerance == 0 {
+		return size.X <= size.Y
+	}
+	aspect := float64(size.X) / float64(size.Y)
+	canonicalAspect := float64(canonical.X) / float64(canonical.Y)
+	return math.Abs(aspect-canonicalAspect)/canonicalAspect <= tolerance/100
+}
@@ -0,0 +1,342 @@
+package artwork
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// BackupGame if a game has a custom image, backs it up by appending "(original)" to the
+// file name.
+func backupGame(gridDir string, game *Game, artStyleExtensions []string) error {
+	if game.CleanImageBytes != nil {
+		_, err := writeFileIfChanged(getBackupPath(gridDir, game, artStyleExtensions), game.CleanImageBytes)
+		return err
+	}
+	return nil
+}
+
+func getBackupPath(gridDir string, game *Game, artStyleExtensions []string) string {
+	hexHash := ImageHash(game.OverlayImageBytes)
+	var extension string
+	if strings.Contains(game.ImageExt, ".webp") {
+		extension = ".png"
+	} else {
+		extension = game.ImageExt
+	}
+	return filepath.Join(gridDir, "originals", game.ID+artStyleExtensions[0]+" "+hexHash+extension)
+}
+
+// Removes the backups of an image other than the one of the image just saved.
+func removeStaleBackups(gridDir string, game *Game, artStyleExtensions []string) {
+	current := ""
+	if game.CleanImageBytes != nil {
+		current = getBackupPath(gridDir, game, artStyleExtensions)
+	}
+	backups, _ := filepath.Glob(filepath.Join(gridDir, "originals", game.ID+artStyleExtensions[0]+" *.*"))
+	for _, backup := range FilterForImages(backups) {
+		if backup != current {
+			os.Remove(backup)
+		}
+	}
+}
+
+func removeExisting(gridDir string, gameID string, artStyleExtensions []string) error {
+	images, err := filepath.Glob(filepath.Join(gridDir, gameID+artStyleExtensions[0]+".*"))
+	if err != nil {
+		return err
+	}
+	images = FilterForImages(images)
+
+	backups, err := filepath.Glob(filepath.Join(gridDir, "originals", gameID+artStyleExtensions[0]+" *.*"))
+	if err != nil {
+		return err
+	}
+	backups = FilterForImages(backups)
+
+	all := append(images, backups...)
+	for _, path := range all {
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadImage(game *Game, sourceName string, imagePath string) error {
+	imageBytes, err := ioutil.ReadFile(imagePath)
+	if err == nil {
+		game.ImageExt = filepath.Ext(imagePath)
+		game.CleanImageBytes = imageBytes
+		game.ImageSource = sourceName
+	}
+	return err
+}
+
+// https://wenzr.wordpress.com/2018/04/09/go-glob-case-insensitive/
+func insensitiveFilepath(path string) string {
+	if runtime.GOOS == "windows" {
+		return path
+	}
+
+	p := ""
+	for _, r := range path {
+		if unicode.IsLetter(r) {
+			p += fmt.Sprintf("[%c%c]", unicode.ToLower(r), unicode.ToUpper(r))
+		} else {
+			p += string(r)
+		}
+	}
+	return p
+}
+
+// FilterForImages keeps the paths of the images SteamGrid writes: PNG, JPEG
+// and ICO files.
+func FilterForImages(paths []string) []string {
+	var matchedPaths []string
+	for _, path := range paths {
+		ext := filepath.Ext(path)
+		switch ext {
+		case ".png":
+			matchedPaths = append(matchedPaths, path)
+		case ".jpg":
+			matchedPaths = append(matchedPaths, path)
+		case ".jpeg":
+			matchedPaths = append(matchedPaths, path)
+		case ".ico":
+			matchedPaths = append(matchedPaths, path)
+		}
+	}
+	return matchedPaths
+}
+
+func loadExisting(overridePath string, gridDir string, game *Game, artStyleExtensions []string, ignoreBackup bool, ignoreManual bool) {
+	overridenIDs, _ := filepath.Glob(filepath.Join(overridePath, game.ID+artStyleExtensions[0]+".*"))
+	if len(overridenIDs) > 0 {
+		loadImage(game, "local file in directory 'games'", overridenIDs[0])
+		return
+	}
+
+	if globName, ok := gameNameGlob(game.Name); ok {
+		overridenNames, _ := filepath.Glob(filepath.Join(overridePath, insensitiveFilepath(globName)+artStyleExtensions[1]+".*"))
+		if len(overridenNames) > 0 {
+			loadImage(game, "local file in directory games/", overridenNames[0])
+			return
+		}
+	}
+
+	// If there are any old-style backups (without hash), load them over the existing (with overlay) images.
+	oldBackups, err := filepath.Glob(filepath.Join(gridDir, game.ID+artStyleExtensions[0]+" (original)*"))
+	if err == nil && len(oldBackups) > 0 {
+		err = loadImage(game, "legacy backup (now converted)", oldBackups[0])
+		if err == nil {
+			os.Remove(oldBackups[0])
+			return
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(gridDir, game.ID+artStyleExtensions[0]+".*"))
+	files = FilterForImages(files)
+	if err == nil && len(files) > 0 {
+		if !ignoreManual {
+			err = loadImage(game, "manual customization", files[0])
+			if err == nil && !ignoreBackup {
+				// set as overlay to check for hash in getBackupPath()
+				game.OverlayImageBytes = game.CleanImageBytes
+
+				// See if there exists a backup image with no overlays or modifications.
+				loadImage(game, "backup", getBackupPath(gridDir, game, artStyleExtensions))
+
+				// remove overlay
+				game.OverlayImageBytes = nil
+			}
+		}
+	}
+
+}
+
+// ParseGridFileName splits the name of an image in the grid directory, like
+// "440p.png", into the game ID and the art style it was saved for.
+func ParseGridFileName(fileName string, artStyles map[string][]string) (gameID string, artStyle string, ok bool) {
+	stem := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	isID := func(id string) bool {
+		if id == "" {
+			return false
+		}
+		for _, r := range id {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+		return true
+	}
+
+	for style, artStyleExtensions := range artStyles {
+		if artStyleExtensions[0] != "" && strings.HasSuffix(stem, artStyleExtensions[0]) {
+			id := strings.TrimSuffix(stem, artStyleExtensions[0])
+			if isID(id) {
+				return id, style, true
+			}
+		}
+	}
+	if _, ok := artStyles["Banner"]; ok && isID(stem) {
+		return stem, "Banner", true
+	}
+	return "", "", false
+}
+
+// ParseBackupFileName splits the name of a backup in the originals directory,
+// like
+// "440p 9f86d0….png", into the grid file name it belonged to (without
+// extension) and the hash of the image with overlays.
+func ParseBackupFileName(fileName string) (gridName string, hash string, ok bool) {
+	stem := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	i := strings.LastIndex(stem, " ")
+	if i < 0 {
+		return "", "", false
+	}
+	return stem[:i], stem[i+1:], true
+}
+
+// ImageHash returns the hash used to name backups of an image.
+func ImageHash(imageBytes []byte) string {
+	hash := sha256.Sum256(imageBytes)
+	// [:] is required to convert a fixed length byte array to a byte slice.
+	return hex.EncodeToString(hash[:])
+}
+
+// GridImagesByHash reads every image in the grid directory, returning the path
+// of each one by the hash of its contents.
+func GridImagesByHash(gridDir string) (map[string][]string, error) {
+	images, err := filepath.Glob(filepath.Join(gridDir, "*.*"))
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[string][]string)
+	for _, path := range FilterForImages(images) {
+		imageBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		hash := ImageHash(imageBytes)
+		byHash[hash] = append(byHash[hash], path)
+	}
+	return byHash, nil
+}
+
+// GridBackups returns the backups in the originals directory.
+func GridBackups(gridDir string) ([]string, error) {
+	backups, err := filepath.Glob(filepath.Join(gridDir, "originals", "* *.*"))
+	if err != nil {
+		return nil, err
+	}
+	return FilterForImages(backups), nil
+}
+
+// RestoreBackups puts the original images back in place of the ones with
+// overlays and removes their backups. Only games in appIDs are restored,
+// unless it's empty. Locked images are left alone unless force is set. Returns
+// the number of images restored.
+func RestoreBackups(gridDir string, appIDs []string, force bool) (int, error) {
+	state, err := LoadGridState(gridDir)
+	if err != nil {
+		return 0, err
+	}
+	byHash, err := GridImagesByHash(gridDir)
+	if err != nil {
+		return 0, err
+	}
+	backups, err := GridBackups(gridDir)
+	if err != nil {
+		return 0, err
+	}
+
+	artStyles := MakeArtStyles("", "", "", "", "")
+	nRestored := 0
+	for _, backup := range backups {
+		gridName, hash, ok := ParseBackupFileName(filepath.Base(backup))
+		if !ok {
+			continue
+		}
+		gameID, artStyle, ok := ParseGridFileName(gridName, artStyles)
+		if !ok || (len(appIDs) > 0 && !containsString(appIDs, gameID)) {
+			continue
+		}
+		if state.isLocked(gameID, artStyle) && !force {
+			continue
+		}
+
+		// The image with overlays, and the legacy Big Picture copy for banners.
+		paths := byHash[hash]
+		if len(paths) == 0 {
+			continue
+		}
+
+		originalBytes, err := ioutil.ReadFile(backup)
+		if err != nil {
+			return nRestored, err
+		}
+		for _, path := range paths {
+			if filepath.Ext(path) == filepath.Ext(backup) {
+				currentBytes, err := ioutil.ReadFile(path)
+				if err == nil && bytes.Equal(currentBytes, originalBytes) {
+					// No overlay was applied to this one.
+					continue
+				}
+			}
+			restoredPath := strings.TrimSuffix(path, filepath.Ext(path)) + filepath.Ext(backup)
+			err = ioutil.WriteFile(restoredPath, originalBytes, 0666)
+			if err != nil {
+				return nRestored, err
+			}
+			if restoredPath != path {
+				os.Remove(path)
+			}
+			nRestored++
+		}
+
+		err = os.Remove(backup)
+		if err != nil {
+			return nRestored, err
+		}
+	}
+	return nRestored, nil
+}
+
+// GridImageStatus describes the artwork of a game for one art style: missing,
+// original, or with overlays when a backup of the original exists.
+func GridImageStatus(gridDir string, gameID string, artStyleExtensions []string) string {
+	images, err := filepath.Glob(filepath.Join(gridDir, gameID+artStyleExtensions[0]+".*"))
+	if err != nil {
+		return "missing"
+	}
+	images = FilterForImages(images)
+	if len(images) == 0 {
+		return "missing"
+	}
+
+	imageBytes, err := ioutil.ReadFile(images[0])
+	if err != nil {
+		return "unreadable"
+	}
+	// Backups are kept for every image SteamGrid writes, but only differ from
+	// the image when an overlay was applied.
+	backups, _ := filepath.Glob(filepath.Join(gridDir, "originals", gameID+artStyleExtensions[0]+" "+ImageHash(imageBytes)+".*"))
+	if len(backups) > 0 {
+		backupBytes, err := ioutil.ReadFile(backups[0])
+		if err == nil && !bytes.Equal(backupBytes, imageBytes) {
+			return "overlay"
+		}
+	}
+	return "original"
+}
@@ -0,0 +1,154 @@
+package artwork
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// A text drawn in a pill on a corner of the artwork, as an overlay that
+// doesn't need to be made by hand. The text can have {category}, the first
+// category of the game, and {playtime}, how long it was played. Size is the
+// height of the text in percent of the shortest side of the image.
+type textBadge struct {
+	Text       string
+	Corner     string
+	Size       float64
+	Background color.NRGBA
+	Color      color.NRGBA
+}
+
+var badgeCorners = []string{"topleft", "topright", "bottomleft", "bottomright"}
+
+// Parses colors like "#1e90ff" or "#000000b0", with alpha.
+func parseHexColor(text string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(text, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %v, expected #rrggbb or #rrggbbaa", text)
+	}
+	return color.NRGBA{uint8(value >> 24), uint8(value >> 16), uint8(value >> 8), uint8(value)}, nil
+}
+
+// Formats minutes played as Steam does.
+func formatPlaytime(minutes int) string {
+	if minutes < 60 {
+		return fmt.Sprintf("%v min", minutes)
+	}
+	return fmt.Sprintf("%.1f h", float64(minutes)/60)
+}
+
+// Returns the text of the badge for a game, or "" when a placeholder has
+// nothing to show, like {playtime} for games never played.
+func (badge textBadge) textFor(game *Game) string {
+	text := badge.Text
+	if strings.Contains(text, "{category}") {
+		if len(game.Tags) == 0 {
+			return ""
+		}
+		text = strings.Replace(text, "{category}", game.Tags[0], -1)
+	}
+	if strings.Contains(text, "{playtime}") {
+		if game.Playtime == 0 {
+			return ""
+		}
+		text = strings.Replace(text, "{playtime}", formatPlaytime(game.Playtime), -1)
+	}
+	return strings.TrimSpace(text)
+}
+
+// Writes text with the built-in bitmap font, to be scaled to the size asked
+// for.
+func bitmapText(text string, textColor color.NRGBA) *image.NRGBA {
+	face := basicfont.Face7x13
+	small := image.NewNRGBA(image.Rect(0, 0, font.MeasureString(face, text).Ceil(), face.Height))
+	drawer := font.Drawer{Dst: small, Src: image.NewUniform(textColor), Face: face, Dot: fixed.P(0, face.Ascent)}
+	drawer.DrawString(text)
+	return small
+}
+
+// Draws the badge on a static image. Works on game.OverlayImageBytes, after
+// the overlays, so the backup keeps the image as it was found. Returns
+// whether a badge was drawn.
+func drawTextBadge(game *Game, badge textBadge, encoding overlay.StillEncoding) (bool, error) {
+	text := badge.textFor(game)
+	if text == "" {
+		return false, nil
+	}
+	imageBytes := game.OverlayImageBytes
+	if overlay.IsAnimatedPNG(imageBytes) || overlay.IsAnimatedWebp(imageBytes) {
+		return false, errors.New("text badge skipped, the image is animated")
+	}
+	decoded, format, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return false, err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, decoded.Bounds().Dx(), decoded.Bounds().Dy()))
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	shortest := img.Bounds().Dx()
+	if img.Bounds().Dy() < shortest {
+		shortest = img.Bounds().Dy()
+	}
+
+	small := bitmapText(text, badge.Color)
+	scale := math.Max(8, badge.Size/100*float64(shortest)) / float64(small.Bounds().Dy())
+	textWidth, textHeight := int(float64(small.Bounds().Dx())*scale), int(float64(small.Bounds().Dy())*scale)
+
+	// The pill is as high as the text with some padding, and round at the
+	// ends.
+	padding := textHeight / 3
+	pillWidth, pillHeight := textWidth+2*padding+textHeight/2, textHeight+padding
+	if pillWidth > img.Bounds().Dx() {
+		pillWidth = img.Bounds().Dx()
+	}
+	margin := shortest / 30
+	x, y := margin, margin
+	if strings.Contains(badge.Corner, "right") {
+		x = img.Bounds().Dx() - pillWidth - margin
+	}
+	if strings.HasPrefix(badge.Corner, "bottom") {
+		y = img.Bounds().Dy() - pillHeight - margin
+	}
+
+	pill := image.NewNRGBA(image.Rect(0, 0, pillWidth, pillHeight))
+	radius := float64(pillHeight) / 2
+	for py := 0; py < pillHeight; py++ {
+		for px := 0; px < pillWidth; px++ {
+			// Distance from the segment between the centers of both ends,
+			// anti-aliased over a pixel.
+			cx := math.Max(radius, math.Min(float64(pillWidth)-radius, float64(px)+0.5))
+			distance := math.Hypot(float64(px)+0.5-cx, float64(py)+0.5-radius)
+			coverage := math.Max(0, math.Min(1, radius-distance+0.5))
+			background := badge.Background
+			background.A = uint8(float64(background.A) * coverage)
+			pill.SetNRGBA(px, py, background)
+		}
+	}
+	draw.Draw(img, image.Rect(x, y, x+pillWidth, y+pillHeight), pill, image.Point{}, draw.Over)
+
+	textX, textY := x+(pillWidth-textWidth)/2, y+(pillHeight-textHeight)/2
+	draw.ApproxBiLinear.Scale(img, image.Rect(textX, textY, textX+textWidth, textY+textHeight), small, small.Bounds(), draw.Over, nil)
+
+	buf := new(bytes.Buffer)
+	err = encoding.Encode(buf, img, format == "jpeg")
+	if err != nil {
+		return false, err
+	}
+	game.OverlayImageBytes = buf.Bytes()
+	return true, nil
+}
@@ -0,0 +1,128 @@
+package artwork
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// A library collection as synced by the new Steam client.
+type cloudCollection struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Added   []int  `json:"added"`
+	Removed []int  `json:"removed"`
+}
+
+// One key of the cloud storage file. The value is itself JSON, as a string.
+type cloudStorageEntry struct {
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// Loads the collections the Steam client keeps in
+// config/cloudstorage/cloud-storage-namespace-1.json, by ID. Steam writes the
+// file as a list of [key, entry] pairs. Deleted and unreadable collections
+// are left out, and a missing file gives no collections.
+func loadCloudCollections(user User) map[string]*cloudCollection {
+	collections := make(map[string]*cloudCollection)
+
+	storageBytes, err := ioutil.ReadFile(filepath.Join(user.Dir, "config", "cloudstorage", "cloud-storage-namespace-1.json"))
+	if err != nil {
+		return collections
+	}
+	var pairs [][]json.RawMessage
+	if json.Unmarshal(storageBytes, &pairs) != nil {
+		return collections
+	}
+
+	for _, pair := range pairs {
+		if len(pair) != 2 {
+			continue
+		}
+		var entry cloudStorageEntry
+		if json.Unmarshal(pair[1], &entry) != nil || entry.IsDeleted || !strings.HasPrefix(entry.Key, "user-collections.") {
+			continue
+		}
+		var collection cloudCollection
+		if json.Unmarshal([]byte(entry.Value), &collection) != nil {
+			continue
+		}
+		collections[collection.ID] = &collection
+	}
+	return collections
+}
+
+// Returns the appIDs in a collection, as strings like the game IDs.
+func (collection *cloudCollection) appIDs() map[string]bool {
+	appIDs := make(map[string]bool)
+	if collection == nil {
+		return appIDs
+	}
+	for _, appID := range collection.Added {
+		appIDs[fmt.Sprint(appID)] = true
+	}
+	for _, appID := range collection.Removed {
+		delete(appIDs, fmt.Sprint(appID))
+	}
+	return appIDs
+}
+
+// Games the user marked as private in the Steam client. Steam keeps them in
+// the "private" collection.
+func privateGames(user User) map[string]bool {
+	return loadCloudCollections(user)["private"].appIDs()
+}
+
+// Tags the games with the collections they are in, for the overlays by
+// category and -skipcategory, as addUnknownGames does with the categories of
+// older clients, which current ones don't update anymore. Steam's own
+// collections have no name and go by their ID, like "favorite". Steam games
+// not found before are added without a name with addNew.
+func addCollectionTags(user User, games map[string]*Game, skipCategory string, addNew bool) {
+	for id, collection := range loadCloudCollections(user) {
+		tag := collection.Name
+		if tag == "" {
+			tag = id
+		}
+		if id == "private" || id == "hidden" {
+			// Not categories, they only hide games.
+			continue
+		}
+
+		for gameID := range collection.appIDs() {
+			game, ok := games[gameID]
+			if !ok {
+				// Shortcuts not found were left out on purpose, they have
+				// the high bit set.
+				if id, err := strconv.ParseUint(gameID, 10, 64); !addNew || err != nil || id >= 0x80000000 {
+					continue
+				}
+				game = &Game{Game: sources.Game{ID: gameID}, Tags: []string{}}
+				games[gameID] = game
+			}
+			if !ContainsTag(game.Tags, tag) {
+				game.Tags = append(game.Tags, tag)
+			}
+			if len(skipCategory) > 0 && strings.Contains(strings.ToLower(tag), strings.ToLower(skipCategory)) {
+				delete(games, gameID)
+			}
+		}
+	}
+}
+
+// ContainsTag tells if tags has tag, ignoring case like Steam categories.
+func ContainsTag(tags []string, tag string) bool {
+	for _, existing := range tags {
+		if strings.EqualFold(existing, tag) {
+			return true
+		}
+	}
+	return false
+}
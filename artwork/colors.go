@@ -0,0 +1,404 @@
+package artwork
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/kmicki/steamgrid/overlay"
+)
+
+// Metadata found in a still image: its ICC color profile, if any, the
+// orientation from its EXIF data (1 when upright) and whether there's
+// anything to strip at all.
+type imageMetadata struct {
+	iccProfile  []byte
+	orientation int
+	present     bool
+}
+
+// Reads the metadata segments of a JPEG, up to the image data.
+func jpegMetadata(imageBytes []byte) imageMetadata {
+	metadata := imageMetadata{orientation: 1}
+	iccChunks := make(map[byte][]byte)
+	for i := 2; i+4 <= len(imageBytes) && imageBytes[i] == 0xff; {
+		marker := imageBytes[i+1]
+		length := int(binary.BigEndian.Uint16(imageBytes[i+2:]))
+		if marker == 0xda || i+2+length > len(imageBytes) {
+			// Start of scan, the rest is the image.
+			break
+		}
+		segment := imageBytes[i+4 : i+2+length]
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			metadata.orientation = exifOrientation(segment[6:])
+			metadata.present = true
+		case marker == 0xe2 && bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00")) && len(segment) > 14:
+			// Profiles can be split over several segments, numbered from 1.
+			iccChunks[segment[12]] = segment[14:]
+			metadata.present = true
+		case marker == 0xe1, marker == 0xed, marker == 0xfe:
+			// XMP, Photoshop data and comments.
+			metadata.present = true
+		}
+		i += 2 + length
+	}
+
+	var numbers []int
+	for number := range iccChunks {
+		numbers = append(numbers, int(number))
+	}
+	sort.Ints(numbers)
+	for _, number := range numbers {
+		metadata.iccProfile = append(metadata.iccProfile, iccChunks[byte(number)]...)
+	}
+	return metadata
+}
+
+// Reads the metadata chunks of a PNG, up to the image data.
+func pngMetadata(imageBytes []byte) imageMetadata {
+	metadata := imageMetadata{orientation: 1}
+	for i := 8; i+8 <= len(imageBytes); {
+		length := int(binary.BigEndian.Uint32(imageBytes[i:]))
+		chunkType := string(imageBytes[i+4 : i+8])
+		if i+8+length > len(imageBytes) {
+			break
+		}
+		chunk := imageBytes[i+8 : i+8+length]
+		switch chunkType {
+		case "iCCP":
+			// Profile name, a null, the compression method, then the profile.
+			if nameEnd := bytes.IndexByte(chunk, 0); nameEnd >= 0 && nameEnd+2 <= len(chunk) {
+				reader, err := zlib.NewReader(bytes.NewReader(chunk[nameEnd+2:]))
+				if err == nil {
+					metadata.iccProfile, _ = ioutil.ReadAll(reader)
+				}
+			}
+			metadata.present = true
+		case "eXIf":
+			metadata.orientation = exifOrientation(chunk)
+			metadata.present = true
+		case "gAMA", "cHRM", "sRGB", "tEXt", "zTXt", "iTXt", "tIME":
+			metadata.present = true
+		}
+		i += 12 + length
+	}
+	return metadata
+}
+
+// Returns the orientation tag of EXIF data, from 1 (upright) to 8.
+func exifOrientation(exif []byte) int {
+	if len(exif) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if string(exif[:2]) == "II" {
+		order = binary.LittleEndian
+	}
+	ifd := int(order.Uint32(exif[4:]))
+	if ifd+2 > len(exif) {
+		return 1
+	}
+	entries := int(order.Uint16(exif[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(exif) {
+			break
+		}
+		if order.Uint16(exif[entry:]) == 0x0112 {
+			if orientation := int(order.Uint16(exif[entry+8:])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+		}
+	}
+	return 1
+}
+
+// Turns an image upright according to its EXIF orientation.
+func orientImage(img *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	// Orientations 5 to 8 are rotated by 90 degrees.
+	outWidth, outHeight := width, height
+	if orientation >= 5 {
+		outWidth, outHeight = height, width
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, outWidth, outHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var outX, outY int
+			switch orientation {
+			case 2:
+				outX, outY = width-1-x, y
+			case 3:
+				outX, outY = width-1-x, height-1-y
+			case 4:
+				outX, outY = x, height-1-y
+			case 5:
+				outX, outY = y, x
+			case 6:
+				outX, outY = height-1-y, x
+			case 7:
+				outX, outY = height-1-y, width-1-x
+			case 8:
+				outX, outY = y, width-1-x
+			}
+			copy(out.Pix[out.PixOffset(outX, outY):][:4], img.Pix[img.PixOffset(x, y):][:4])
+		}
+	}
+	return out
+}
+
+// Converts a profile to linear light, channel by channel, then to the D50
+// XYZ of ICC profiles with a matrix.
+type iccTransform struct {
+	curves [3][256]float64
+	matrix [3][3]float64
+}
+
+// Reads an RGB profile made of a matrix and tone curves, which is what
+// cameras, Adobe RGB, Display P3 and their kind use. Other profiles are
+// reported as unsupported.
+func parseICCProfile(profile []byte) (*iccTransform, error) {
+	if len(profile) < 132 || string(profile[36:40]) != "acsp" {
+		return nil, errors.New("not an ICC profile")
+	}
+	if string(profile[16:20]) != "RGB " {
+		return nil, errors.New("only RGB color profiles can be converted")
+	}
+	tags := make(map[string][]byte)
+	nTags := int(binary.BigEndian.Uint32(profile[128:]))
+	for i := 0; i < nTags && 132+i*12+12 <= len(profile); i++ {
+		entry := profile[132+i*12:]
+		offset, size := int(binary.BigEndian.Uint32(entry[4:])), int(binary.BigEndian.Uint32(entry[8:]))
+		if offset+size <= len(profile) {
+			tags[string(entry[:4])] = profile[offset : offset+size]
+		}
+	}
+
+	transform := &iccTransform{}
+	for i, name := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		tag := tags[name]
+		if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+			return nil, errors.New("only color profiles with a matrix can be converted")
+		}
+		for row := 0; row < 3; row++ {
+			transform.matrix[row][i] = s15Fixed16(tag[8+row*4:])
+		}
+	}
+	for i, name := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, err := parseICCCurve(tags[name])
+		if err != nil {
+			return nil, err
+		}
+		transform.curves[i] = curve
+	}
+	return transform, nil
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// Reads a tone curve, a "curv" table or gamma or a "para" function, into the
+// linear value of every 8-bit level.
+func parseICCCurve(tag []byte) ([256]float64, error) {
+	var levels [256]float64
+	if len(tag) < 12 {
+		return levels, errors.New("color profile without tone curves")
+	}
+	switch string(tag[:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(tag[8:]))
+		if len(tag) < 12+count*2 {
+			return levels, errors.New("broken tone curve in color profile")
+		}
+		for v := range levels {
+			x := float64(v) / 255
+			switch count {
+			case 0:
+				levels[v] = x
+			case 1:
+				levels[v] = math.Pow(x, float64(binary.BigEndian.Uint16(tag[12:]))/256)
+			default:
+				// A table, interpolated.
+				position := x * float64(count-1)
+				i := int(position)
+				if i >= count-1 {
+					i = count - 2
+				}
+				low := float64(binary.BigEndian.Uint16(tag[12+i*2:])) / 65535
+				high := float64(binary.BigEndian.Uint16(tag[14+i*2:])) / 65535
+				levels[v] = low + (high-low)*(position-float64(i))
+			}
+		}
+	case "para":
+		functionType := int(binary.BigEndian.Uint16(tag[8:]))
+		nParameters := []int{1, 3, 4, 5, 7}
+		if functionType > 4 || len(tag) < 12+nParameters[functionType]*4 {
+			return levels, errors.New("unknown tone curve in color profile")
+		}
+		var p [7]float64
+		for i := 0; i < nParameters[functionType]; i++ {
+			p[i] = s15Fixed16(tag[12+i*4:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		for v := range levels {
+			x := float64(v) / 255
+			switch functionType {
+			case 0:
+				levels[v] = math.Pow(x, g)
+			case 1:
+				if x >= -b/a {
+					levels[v] = math.Pow(a*x+b, g)
+				}
+			case 2:
+				levels[v] = c
+				if x >= -b/a {
+					levels[v] = math.Pow(a*x+b, g) + c
+				}
+			case 3:
+				levels[v] = c * x
+				if x >= d {
+					levels[v] = math.Pow(a*x+b, g)
+				}
+			case 4:
+				levels[v] = c*x + f
+				if x >= d {
+					levels[v] = math.Pow(a*x+b, g) + e
+				}
+			}
+		}
+	default:
+		return levels, errors.New("unknown tone curve in color profile")
+	}
+	return levels, nil
+}
+
+// From the D50 XYZ of ICC profiles to linear sRGB.
+var xyzD50ToLinearSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// Whether a profile is sRGB already, give or take rounding, so the image only
+// needs its metadata stripped.
+func (transform *iccTransform) isSRGB() bool {
+	srgb := [3][3]float64{
+		{0.4360747, 0.3850649, 0.1430804},
+		{0.2225045, 0.7168786, 0.0606169},
+		{0.0139322, 0.0971045, 0.7141733},
+	}
+	for row := range srgb {
+		for column := range srgb[row] {
+			if math.Abs(srgb[row][column]-transform.matrix[row][column]) > 0.003 {
+				return false
+			}
+		}
+	}
+	for i := range transform.curves {
+		if math.Abs(transform.curves[i][128]-srgbToLinear(128.0/255)) > 0.005 {
+			return false
+		}
+	}
+	return true
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// Converts the colors of an image from a profile to sRGB, in place.
+func (transform *iccTransform) toSRGB(img *image.NRGBA) {
+	var matrix [3][3]float64
+	for row := 0; row < 3; row++ {
+		for column := 0; column < 3; column++ {
+			for k := 0; k < 3; k++ {
+				matrix[row][column] += xyzD50ToLinearSRGB[row][k] * transform.matrix[k][column]
+			}
+		}
+	}
+	// Encoding back to sRGB from a table of linear values.
+	var encode [4096]uint8
+	for i := range encode {
+		encode[i] = uint8(math.Round(255 * linearToSRGB(float64(i)/4095)))
+	}
+
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b := transform.curves[0][img.Pix[i]], transform.curves[1][img.Pix[i+1]], transform.curves[2][img.Pix[i+2]]
+		for channel := 0; channel < 3; channel++ {
+			linear := matrix[channel][0]*r + matrix[channel][1]*g + matrix[channel][2]*b
+			img.Pix[i+channel] = encode[int(math.Round(4095*math.Max(0, math.Min(1, linear))))]
+		}
+	}
+}
+
+// Converts a still JPEG or PNG with a color profile to sRGB and writes it
+// again without its metadata (color profile, EXIF, comments, ...), turning it
+// upright first if its EXIF data says it's rotated. Images without metadata
+// are left alone. Where images came from is kept in the state file, not in
+// the images. Works on game.CleanImageBytes, before any overlay. Returns
+// whether the image changed.
+func normalizeColors(game *Game, encoding overlay.StillEncoding) (bool, error) {
+	imageBytes := game.CleanImageBytes
+	var metadata imageMetadata
+	if bytes.HasPrefix(imageBytes, []byte("\xff\xd8")) {
+		metadata = jpegMetadata(imageBytes)
+	} else if bytes.HasPrefix(imageBytes, []byte("\x89PNG")) && !overlay.IsAnimatedPNG(imageBytes) {
+		metadata = pngMetadata(imageBytes)
+	}
+	if !metadata.present {
+		return false, nil
+	}
+
+	var transform *iccTransform
+	if len(metadata.iccProfile) > 0 {
+		var err error
+		transform, err = parseICCProfile(metadata.iccProfile)
+		if err != nil {
+			return false, errors.New("colors not normalized, " + err.Error())
+		}
+		if transform.isSRGB() {
+			transform = nil
+		}
+	}
+
+	decoded, format, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return false, err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, decoded.Bounds().Dx(), decoded.Bounds().Dy()))
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+	if transform != nil {
+		transform.toSRGB(img)
+	}
+	img = orientImage(img, metadata.orientation)
+
+	buf := new(bytes.Buffer)
+	err = encoding.Encode(buf, img, strings.Contains(format, "jpeg"))
+	if err != nil {
+		return false, err
+	}
+	game.CleanImageBytes = buf.Bytes()
+	return true, nil
+}
@@ -0,0 +1,165 @@
+package artwork
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kmicki/steamgrid/overlay"
+)
+
+// A file in the grid directory or its backups.
+type gridFile struct {
+	Path string
+	Size int64
+}
+
+// GridFiles lists the files in the grid directory and its originals directory,
+// largest first, with their total size.
+func GridFiles(gridDir string) ([]gridFile, int64, error) {
+	var files []gridFile
+	var total int64
+	for _, dir := range []string{gridDir, filepath.Join(gridDir, "originals")} {
+		infos, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		for _, info := range infos {
+			if info.IsDir() {
+				continue
+			}
+			files = append(files, gridFile{filepath.Join(dir, info.Name()), info.Size()})
+			total += info.Size()
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Size > files[j].Size
+	})
+	return files, total, nil
+}
+
+// FormatSize formats a number of bytes in MB or KB.
+func FormatSize(size int64) string {
+	if size >= 1024*1024 {
+		return fmt.Sprintf("%.1f MB", float64(size)/1024/1024)
+	}
+	return fmt.Sprintf("%v KB", size/1024)
+}
+
+// Encodes a static PNG again with the best compression, which keeps every
+// pixel. Returns nil for animations and images that don't get smaller.
+func recompressPNG(imageBytes []byte) []byte {
+	if overlay.IsAnimatedPNG(imageBytes) || len(imageBytes) < 8 || string(imageBytes[1:4]) != "PNG" {
+		return nil
+	}
+	img, err := png.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if encoder.Encode(buf, img) != nil || buf.Len() >= len(imageBytes) {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// Encodes a JPEG again at a quality Steam's small images don't need more
+// than. Returns nil when it doesn't get smaller.
+func recompressJPEG(imageBytes []byte) []byte {
+	img, err := jpeg.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	if jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}) != nil || buf.Len() >= len(imageBytes) {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// CompressGrid recompresses the images of a grid directory: PNGs losslessly
+// and JPEGs over maxJPEG bytes, other than backups, at quality 90. Backups are
+// named after the hash of the image they belong to, so they are renamed along
+// with it, and the state file keeps the new hash. Returns how many bytes were
+// saved.
+func CompressGrid(gridDir string, maxJPEG int64) (int64, error) {
+	state, err := LoadGridState(gridDir)
+	if err != nil {
+		return 0, err
+	}
+	files, _, err := GridFiles(gridDir)
+	if err != nil {
+		return 0, err
+	}
+	// Backups first, as compressing an image renames its backup.
+	isBackup := func(path string) bool {
+		return filepath.Base(filepath.Dir(path)) == "originals"
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return isBackup(files[i].Path) && !isBackup(files[j].Path)
+	})
+
+	var saved int64
+	for _, file := range filterGridFiles(files) {
+		imageBytes, err := ioutil.ReadFile(file.Path)
+		if err != nil {
+			return saved, err
+		}
+
+		var compressed []byte
+		switch strings.ToLower(filepath.Ext(file.Path)) {
+		case ".png":
+			compressed = recompressPNG(imageBytes)
+		case ".jpg", ".jpeg":
+			// Backups are kept as they were downloaded.
+			if file.Size > maxJPEG && !isBackup(file.Path) {
+				compressed = recompressJPEG(imageBytes)
+			}
+		}
+		if compressed == nil {
+			continue
+		}
+
+		err = ioutil.WriteFile(file.Path, compressed, 0666)
+		if err != nil {
+			return saved, err
+		}
+		saved += int64(len(imageBytes) - len(compressed))
+
+		if isBackup(file.Path) {
+			continue
+		}
+		oldHash, newHash := ImageHash(imageBytes), ImageHash(compressed)
+		gridName := strings.TrimSuffix(filepath.Base(file.Path), filepath.Ext(file.Path))
+		backups, _ := filepath.Glob(filepath.Join(gridDir, "originals", gridName+" "+oldHash+".*"))
+		for _, backup := range backups {
+			os.Rename(backup, filepath.Join(gridDir, "originals", gridName+" "+newHash+filepath.Ext(backup)))
+		}
+		for _, entry := range state.Images {
+			if entry.File == filepath.Base(file.Path) && entry.Hash == oldHash {
+				entry.Hash = newHash
+			}
+		}
+	}
+	return saved, state.Save()
+}
+
+// Keeps the images among the files of a grid directory.
+func filterGridFiles(files []gridFile) []gridFile {
+	var images []gridFile
+	for _, file := range files {
+		if len(FilterForImages([]string{file.Path})) > 0 {
+			images = append(images, file)
+		}
+	}
+	return images
+}
@@ -0,0 +1,98 @@
+package artwork
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Animations are converted on a few workers in the background, so the next
+// games are downloaded meanwhile. Each conversion prints to its own log, and
+// is saved with its log printed in the order it was queued, on the main
+// goroutine, which alone touches the grid directory and the summary. Saving
+// stops at the first conversion that fails to be saved.
+type conversionQueue struct {
+	workers int
+	jobs    chan *conversionJob
+	pending []*conversionJob
+}
+
+type conversionJob struct {
+	log     bytes.Buffer
+	convert func(log io.Writer) func() error
+	save    func() error
+	done    chan struct{}
+}
+
+// Starts a queue with a number of workers, converting right away with none.
+// At most as many conversions as workers wait for one, after which queueing
+// blocks.
+func newConversionQueue(workers int) *conversionQueue {
+	queue := &conversionQueue{workers: workers}
+	if workers <= 0 {
+		return queue
+	}
+	queue.jobs = make(chan *conversionJob, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range queue.jobs {
+				job.save = job.convert(&job.log)
+				close(job.done)
+			}
+		}()
+	}
+	return queue
+}
+
+// Queues a conversion, which returns how to save its result. Conversions
+// done by then are saved first, returning the error of the first one that
+// couldn't be, in which case nothing is queued.
+func (queue *conversionQueue) add(convert func(log io.Writer) func() error) error {
+	err := queue.flush(false)
+	if err != nil {
+		return err
+	}
+	job := &conversionJob{convert: convert, done: make(chan struct{})}
+	queue.pending = append(queue.pending, job)
+	queue.jobs <- job
+	return nil
+}
+
+// Saves the conversions done, in order, stopping at the first one still
+// running unless waiting for all of them, or at the first one that can't be
+// saved.
+func (queue *conversionQueue) flush(wait bool) error {
+	for len(queue.pending) > 0 {
+		job := queue.pending[0]
+		if wait {
+			<-job.done
+		} else {
+			select {
+			case <-job.done:
+			default:
+				return nil
+			}
+		}
+		fmt.Print(job.log.String())
+		queue.pending = queue.pending[1:]
+		err := job.save()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tells if no conversion is waiting or running.
+func (queue *conversionQueue) idle() bool {
+	return len(queue.pending) == 0
+}
+
+// Waits for the conversions left and stops the workers.
+func (queue *conversionQueue) close() error {
+	err := queue.flush(true)
+	if queue.jobs != nil {
+		close(queue.jobs)
+	}
+	return err
+}
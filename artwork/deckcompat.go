@@ -0,0 +1,72 @@
+package artwork
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Steam Deck compatibility report of a game, as shown on its store page.
+const deckCompatibilityURL = "https://store.steampowered.com/saleaction/ajaxgetdeckappcompatibilityreport?nAppID=%v"
+
+// Labels and colors of the Steam Deck compatibility categories, as in the
+// store. Category 0 is unknown, the game wasn't tested.
+var deckCategories = map[int]struct {
+	label      string
+	background string
+	color      string
+}{
+	1: {"UNSUPPORTED", "#23262e", "#dcdedf"},
+	2: {"PLAYABLE", "#ffc82c", "#000000"},
+	3: {"VERIFIED", "#59bf40", "#000000"},
+}
+
+// Returns the Steam Deck compatibility category of a Steam game, or 0 when it
+// wasn't tested. Categories are kept in the API cache, like ProtonDB ratings.
+func deckCategory(appID string) (int, error) {
+	status, body, err := sources.CachedGet(fmt.Sprintf(deckCompatibilityURL, appID))
+	if err != nil {
+		return 0, errors.New("Steam store " + err.Error())
+	} else if status == http.StatusNotFound {
+		return 0, nil
+	}
+
+	var report struct {
+		Success int `json:"success"`
+		Results struct {
+			ResolvedCategory int `json:"resolved_category"`
+		} `json:"results"`
+	}
+	err = json.Unmarshal(body, &report)
+	if err != nil {
+		return 0, err
+	}
+	if report.Success != 1 {
+		return 0, nil
+	}
+	return report.Results.ResolvedCategory, nil
+}
+
+// Draws the Steam Deck compatibility of a Steam game, verified, playable or
+// unsupported, on a corner of its artwork. Returns whether a badge was drawn.
+func drawDeckBadge(game *Game, corner string, encoding overlay.StillEncoding) (bool, error) {
+	if game.Custom {
+		return false, nil
+	}
+	category, err := deckCategory(game.ID)
+	if err != nil {
+		return false, err
+	}
+	look, ok := deckCategories[category]
+	if !ok {
+		return false, nil
+	}
+	background, _ := parseHexColor(look.background)
+	textColor, _ := parseHexColor(look.color)
+	badge := textBadge{Text: look.label, Corner: corner, Size: 5, Background: background, Color: textColor}
+	return drawTextBadge(game, badge, encoding)
+}
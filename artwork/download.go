@@ -0,0 +1,232 @@
+package artwork
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Tries to load the grid image for a game from a number of alternative
+// sources. Returns the final response received and a flag indicating if it was
+// from a Google search (useful because we want to log the lower quality
+// images).
+func getImageAlternatives(state *gridState, game *Game, artStyle string, artStyleExtensions []string, options *Options) (response *http.Response, from string, err error) {
+	// Pinned assets come before every source, falling back to them when the
+	// asset is gone.
+	if id := pinnedSteamGridDBID(game, artStyle, options); id != 0 && options.SteamGridDBApiKey != "" {
+		url, err := getPinnedSteamGridDBImage(game, artStyleExtensions, id, options)
+		if err == nil {
+			response, err = sources.TryDownload(url)
+		}
+		if err == nil && response != nil {
+			game.SteamGridDBID = id
+			return response, "SteamGridDB", nil
+		} else if err != nil {
+			fmt.Println(err.Error())
+		}
+	}
+
+	for _, name := range options.sources(artStyle) {
+		source := options.imageSource(name, state)
+		if source == nil {
+			continue
+		}
+		if options.OnlyMissingArtwork && (name == "librarycache" || name == "steam") && (sources.LibraryCache{InstallationDir: SteamInstallationDir}).Path(&game.Game, artStyle) != "" {
+			// Steam cached it, so the official servers have it.
+			return nil, "", nil
+		}
+		response, err = source.Find(&game.Game, artStyle, artStyleExtensions)
+		if err != nil {
+			return nil, "", err
+		} else if response == nil {
+			continue
+		}
+		if options.OnlyMissingArtwork && name == "steam" {
+			// Abort if image is available
+			response.Body.Close()
+			return nil, "", nil
+		}
+		return response, sourceImageSources[name], nil
+	}
+
+	return nil, "", nil
+}
+
+// Returns the source of a name of -sources-<style>, or nil when it's not set
+// up, like SteamGridDB without an API key.
+func (options *Options) imageSource(name string, state *gridState) sources.Source {
+	switch name {
+	case "librarycache":
+		return sources.LibraryCache{InstallationDir: SteamInstallationDir}
+	case "steam":
+		return sources.SteamCDN{Language: options.steamLanguage()}
+	case "steamgriddb":
+		if options.SteamGridDBApiKey == "" {
+			return nil
+		}
+		source := options.SteamGridDB()
+		if options.Interactive {
+			source.Choose = func(game *sources.Game, artStyle string, artStyleExtensions []string) (string, int, error) {
+				return chooseSteamGridDBImage(state, game, artStyle, artStyleExtensions, source, options)
+			}
+		}
+		return source
+	case "igdb":
+		if options.IGDBClient == "" || options.IGDBSecret == "" {
+			return nil
+		}
+		return sources.IGDB{Client: options.IGDBClient, Secret: options.IGDBSecret, SkipApplications: options.Apps}
+	case "itch":
+		return sources.Itch{}
+	case "gog":
+		return sources.GOG{MinMatch: options.MinMatch}
+	case "rawg":
+		if options.RAWGApiKey == "" {
+			return nil
+		}
+		return sources.RAWG{APIKey: options.RAWGApiKey, MinMatch: options.MinMatch}
+	case "thegamesdb":
+		if options.TheGamesDBApiKey == "" {
+			return nil
+		}
+		return sources.TheGamesDB{APIKey: options.TheGamesDBApiKey, MinMatch: options.MinMatch}
+	case "launchbox":
+		if !options.LaunchBox {
+			return nil
+		}
+		return sources.LaunchBox{}
+	case "google":
+		return sources.ImageSearch{Provider: options.SearchProvider, BingKey: options.BingApiKey, SearXNG: options.SearXNG}
+	}
+	return nil
+}
+
+// SteamGridDB returns SteamGridDB as set up by the options.
+func (options *Options) SteamGridDB() sources.SteamGridDB {
+	language, _ := options.languageCode()
+	return sources.SteamGridDB{
+		APIKey:     options.SteamGridDBApiKey,
+		MinScore:   options.SteamGridDBMinScore,
+		MinUpvotes: options.SteamGridDBMinUpvotes,
+		MinMatch:   options.MinMatch,
+		Language:   language,
+		Webm:       overlay.CanConvertWebm(),
+	}
+}
+
+// DownloadImage tries to download the game images, saving it in game.ImageBytes. Returns
+// flags indicating if the operation succeeded and if the image downloaded was
+// from a search.
+func DownloadImage(gridDir string, state *gridState, game *Game, artStyle string, artStyleExtensions []string, options *Options) (string, error) {
+	if loadPackImage(game, artStyle, options) {
+		return "pack", nil
+	}
+	if loadMirrorImage(game, artStyle, options) {
+		return "mirror", nil
+	}
+	if sources.OfflineMode {
+		return "", nil
+	}
+	ApplyNameOverride(game, options)
+
+	for attempt := 1; ; attempt++ {
+		response, from, err := getImageAlternatives(state, game, artStyle, artStyleExtensions, options)
+		if response == nil || err != nil {
+			return "", err
+		}
+
+		contentType := response.Header.Get("Content-Type")
+		urlExt := filepath.Ext(response.Request.URL.Path)
+		if contentType != "" {
+			game.ImageExt = "." + strings.Split(contentType, "/")[1]
+		} else if urlExt != "" {
+			game.ImageExt = urlExt
+		} else {
+			// Steam is forgiving on image extensions.
+			game.ImageExt = "jpg"
+		}
+
+		if game.ImageExt == ".jpeg" {
+			// The new library ignores .jpeg
+			game.ImageExt = ".jpg"
+		} else if game.ImageExt == ".octet-stream" {
+			// Amazonaws (steamgriddb) gives us an .octet-stream
+			game.ImageExt = ".png"
+		} else if game.ImageExt == ".x-icon" || game.ImageExt == ".vnd.microsoft.icon" {
+			game.ImageExt = ".ico"
+		}
+
+		imageBytes, err := sources.ReadDownload(response)
+		if err != nil {
+			return "", err
+		}
+
+		// WEBM videos are converted to animations Steam can show.
+		if overlay.IsWebm(imageBytes) {
+			imageBytes, game.ImageExt, err = overlay.ConvertWebm(imageBytes, options.convertsToApng(artStyle), options.maxConvertMemory(), options.usesFFmpeg())
+			if err != nil {
+				fmt.Printf("Animation from %v skipped: %v\n", from, err)
+				return "", nil
+			}
+			contentType = "image/" + strings.TrimPrefix(game.ImageExt, ".")
+		}
+
+		// catch false aspect ratios. ICO files can't be decoded, but only icons
+		// come in that format.
+		if game.ImageExt != ".ico" {
+			imgSize, err := overlay.ImageSize(imageBytes, strings.Contains(contentType, "webp"))
+			if err != nil {
+				return "", err
+			}
+			if !hasArtStyleAspect(artStyle, imgSize, options) {
+				if from == "SteamGridDB" && !options.Interactive && pinnedSteamGridDBID(game, artStyle, options) == 0 && attempt < overlay.MaxAnimationAttempts {
+					fmt.Printf("SteamGridDB image %v is %vx%v, not the shape of a %v, trying another one\n", game.SteamGridDBID, imgSize.X, imgSize.Y, strings.ToLower(artStyle))
+					game.RejectedSteamGridDBIDs = append(game.RejectedSteamGridDBIDs, game.SteamGridDBID)
+					continue
+				}
+				fmt.Printf("Image from %v is %vx%v, not the shape of a %v, skipped\n", from, imgSize.X, imgSize.Y, strings.ToLower(artStyle))
+				return "", nil
+			}
+		}
+
+		// Animations over the limits are only kept when SteamGridDB has
+		// nothing better, and when they were picked by hand.
+		if from == "SteamGridDB" && !options.Interactive && pinnedSteamGridDBID(game, artStyle, options) == 0 && attempt < overlay.MaxAnimationAttempts && overlay.ExceedsAnimationLimits(imageBytes, options.MaxLoop, options.MaxFPS) {
+			fmt.Printf("SteamGridDB image %v is too long or too fast an animation, trying another one\n", game.SteamGridDBID)
+			game.RejectedSteamGridDBIDs = append(game.RejectedSteamGridDBIDs, game.SteamGridDBID)
+			continue
+		}
+		if artStyle == "Logo" && strings.ToLower(options.OpaqueLogos) == "reject" && isOpaqueLogo(imageBytes) {
+			if from == "SteamGridDB" && !options.Interactive && pinnedSteamGridDBID(game, artStyle, options) == 0 && attempt < overlay.MaxAnimationAttempts {
+				fmt.Printf("SteamGridDB logo %v has no transparency, trying another one\n", game.SteamGridDBID)
+				game.RejectedSteamGridDBIDs = append(game.RejectedSteamGridDBIDs, game.SteamGridDBID)
+				continue
+			}
+			fmt.Printf("Logo from %v has no transparency, skipped\n", from)
+			return "", nil
+		}
+
+		game.ImageSource = from
+		game.ImageURL = response.Request.URL.String()
+
+		game.CleanImageBytes = imageBytes
+		return from, nil
+	}
+}
+
+// GetGameName gets the name of a game from Steam's local cache of the store,
+// or from the store itself as last resort.
+func GetGameName(gameID string) string {
+	if app, ok := getLocalAppInfo(gameID); ok {
+		return app.Name
+	}
+	details, err := sources.GetStoreAppDetails(gameID)
+	if err != nil || details == nil {
+		return ""
+	}
+	return details.Name
+}
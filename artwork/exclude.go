@@ -0,0 +1,85 @@
+package artwork
+
+import (
+	"errors"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// App IDs to leave out of a run, given by -excludeappids.
+type appIDSet struct {
+	ids    map[uint64]bool
+	ranges [][2]uint64
+}
+
+// ParseAppIDSet parses a comma separated list of app IDs and ranges like
+// 1000-2000. Other items are files with more of them, separated by commas or
+// lines, where lines starting with # are comments.
+func ParseAppIDSet(list string) (appIDSet, error) {
+	set := appIDSet{ids: make(map[uint64]bool)}
+	err := set.add(list, 0)
+	return set, err
+}
+
+func (set *appIDSet) add(list string, depth int) error {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+
+		if id, err := strconv.ParseUint(item, 10, 64); err == nil {
+			set.ids[id] = true
+			continue
+		}
+
+		if parts := strings.SplitN(item, "-", 2); len(parts) == 2 {
+			first, errFirst := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 64)
+			last, errLast := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+			if errFirst == nil && errLast == nil {
+				if first > last {
+					return errors.New("backwards app ID range " + item)
+				}
+				set.ranges = append(set.ranges, [2]uint64{first, last})
+				continue
+			}
+		}
+
+		// Files can't list themselves, or each other, forever.
+		if depth > 0 {
+			return errors.New("invalid app ID or range " + item)
+		}
+		fileBytes, err := ioutil.ReadFile(item)
+		if err != nil {
+			return errors.New("not an app ID, a range or a readable file: " + item)
+		}
+		var lines []string
+		for _, line := range strings.Split(string(fileBytes), "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+				lines = append(lines, line)
+			}
+		}
+		err = set.add(strings.Join(lines, ","), depth+1)
+		if err != nil {
+			return errors.New(item + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (set appIDSet) contains(gameID string) bool {
+	id, err := strconv.ParseUint(gameID, 10, 64)
+	if err != nil {
+		return false
+	}
+	if set.ids[id] {
+		return true
+	}
+	for _, r := range set.ranges {
+		if id >= r[0] && id <= r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package artwork
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/kmicki/steamgrid/overlay"
+)
+
+// Ways of making animations smaller for -maxfilesize, tried in turn: the
+// quality of WEBP frames, which APNG ignores, and how many frames are kept,
+// one in every keepEvery.
+var animationShrinkSteps = []struct {
+	quality   float32
+	keepEvery int
+}{
+	{80, 1},
+	{60, 2},
+	{40, 4},
+}
+
+// Qualities still JPEGs are encoded with again for -maxfilesize.
+var jpegShrinkQualities = []int{85, 70, 50}
+
+// Drops frames of the final image of a game, if animated, to play it at
+// most at maxFPS and with at most maxFrames frames. Zero limits are ignored.
+// Returns whether frames were dropped.
+func capAnimation(log io.Writer, game *Game, maxFPS float64, maxFrames int) (bool, error) {
+	imageBytes := game.OverlayImageBytes
+	isWebp := overlay.IsAnimatedWebp(imageBytes)
+	if (maxFPS <= 0 && maxFrames <= 0) || !(isWebp || overlay.IsAnimatedPNG(imageBytes)) {
+		return false, nil
+	}
+	frames, loopCount, err := overlay.DecodeAnimationFrames(imageBytes, isWebp)
+	if err != nil {
+		return false, err
+	}
+	kept := frames
+	if maxFPS > 0 {
+		kept = overlay.CapFrameRate(kept, int(1000/maxFPS+0.5))
+	}
+	if maxFrames > 0 && len(kept) > maxFrames {
+		kept = overlay.KeepEveryFrames(kept, (len(kept)+maxFrames-1)/maxFrames)
+	}
+	if len(kept) == len(frames) {
+		return false, nil
+	}
+	capped, err := overlay.EncodeAnimationFrames(kept, loopCount, 0, isWebp)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(log, "Kept %v of the %v frames of the animation\n", len(kept), len(frames))
+	game.OverlayImageBytes = capped
+	return true, nil
+}
+
+// Encodes the final image of a game again until it's at most maxSize bytes:
+// with more compression, fewer frames, and as a still of the first frame as
+// a last resort. Returns whether it was shrunk.
+func shrinkImage(log io.Writer, game *Game, maxSize uint64) (bool, error) {
+	imageBytes := game.OverlayImageBytes
+	if maxSize == 0 || uint64(len(imageBytes)) <= maxSize || game.ImageExt == ".ico" {
+		return false, nil
+	}
+	originalSize := len(imageBytes)
+
+	isWebp := overlay.IsAnimatedWebp(imageBytes)
+	if isWebp || overlay.IsAnimatedPNG(imageBytes) {
+		frames, loopCount, err := overlay.DecodeAnimationFrames(imageBytes, isWebp)
+		if err != nil {
+			return false, err
+		}
+		for _, step := range animationShrinkSteps {
+			shrunk, err := overlay.EncodeAnimationFrames(overlay.KeepEveryFrames(frames, step.keepEvery), loopCount, step.quality, isWebp)
+			if err != nil {
+				return false, err
+			}
+			if uint64(len(shrunk)) <= maxSize {
+				fmt.Fprintf(log, "Shrunk the animation from %v to %v bytes\n", originalSize, len(shrunk))
+				game.OverlayImageBytes = shrunk
+				return true, nil
+			}
+		}
+		buf := new(bytes.Buffer)
+		err = png.Encode(buf, frames[0].Image)
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintf(log, "Animation still over %v bytes when shrunk, kept its first frame only\n", maxSize)
+		game.OverlayImageBytes = buf.Bytes()
+		game.ImageExt = ".png"
+		return true, nil
+	}
+
+	if bytes.HasPrefix(imageBytes, []byte("\xff\xd8")) {
+		decoded, err := jpeg.Decode(bytes.NewReader(imageBytes))
+		if err != nil {
+			return false, err
+		}
+		buf := new(bytes.Buffer)
+		for _, quality := range jpegShrinkQualities {
+			buf.Reset()
+			err = jpeg.Encode(buf, decoded, &jpeg.Options{Quality: quality})
+			if err != nil {
+				return false, err
+			}
+			if uint64(buf.Len()) <= maxSize {
+				break
+			}
+		}
+		if buf.Len() >= originalSize {
+			return false, nil
+		}
+		fmt.Fprintf(log, "Shrunk the image from %v to %v bytes\n", originalSize, buf.Len())
+		game.OverlayImageBytes = buf.Bytes()
+		return true, nil
+	}
+
+	fmt.Fprintf(log, "Image over %v bytes, but still PNGs can't be made smaller\n", maxSize)
+	return false, nil
+}
@@ -0,0 +1,372 @@
+package artwork
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/sources"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// Game in a steam library. May or may not be installed.
+type Game struct {
+	// What the sources know the game by.
+	sources.Game
+	// Tags, including user-created category and Steam's "Favorite" tag.
+	Tags []string
+	// Image format (.jpg, .jpeg, or .png).
+	ImageExt string
+	// Raw bytes of the encoded image (jpg or png) without overlays.
+	CleanImageBytes []byte
+	// Raw bytes of the encoded image (jpg or png) with overlays.
+	OverlayImageBytes []byte
+	// Description of where the image was found (backup, official, search).
+	ImageSource string
+	// LegacyID used in BigPicture
+	LegacyID uint64
+	// ID derived from the target and name of a shortcut, when it's not the
+	// appid Steam wrote down. Some Steam versions look for artwork under it.
+	CRCID string
+	// URL the image was downloaded from, if it was downloaded.
+	ImageURL string
+	// ID or slug of the Lutris game a non-Steam shortcut launches.
+	LutrisGame string
+	// Minutes played, as Steam remembers it locally.
+	Playtime int
+	// Steam game not installed in any library, when -notinstalled asks.
+	NotInstalled bool
+	// Overlays of the overlay rules the game matches.
+	RuleOverlays []string
+}
+
+// Pattern of game declarations in the public profile. It's actually JSON
+// inside Javascript, but this way is easier to extract.
+const profileGamePattern = `\{"appid":\s*(\d+),\s*"name":\s*"(.+?)"`
+
+// Fetches the list of games from the public user profile. This is better than
+// looking locally because the profiles give the full game name, which can be
+// used for image searches later on.
+func addGamesFromProfile(user User, games map[string]*Game) (err error) {
+	profile, err := GetProfile(user)
+	if err != nil {
+		return
+	}
+
+	// Fetch game list from public profile.
+	pattern := regexp.MustCompile(profileGamePattern)
+	for _, groups := range pattern.FindAllStringSubmatch(profile, -1) {
+		gameID := groups[1]
+		gameName := groups[2]
+		tags := []string{""}
+		games[gameID] = &Game{Game: sources.Game{ID: gameID, Name: gameName}, Tags: tags}
+	}
+
+	return
+}
+
+// Steam Web API method listing the games of an account, with their names
+// and playtime.
+const ownedGamesURL = "https://api.steampowered.com/IPlayerService/GetOwnedGames/v1/?include_appinfo=1&include_played_free_games=1&key=%v&steamid=%v"
+
+// AddOwnedGames adds the games of an account as the Steam Web API lists them,
+// with the names Steam gives them and the minutes played. Unlike the public
+// profile, it works for private profiles with the key of their owner.
+func AddOwnedGames(steamID string, games map[string]*Game, apiKey string) error {
+	response, err := sources.HTTPGet(fmt.Sprintf(ownedGamesURL, url.QueryEscape(apiKey), steamID))
+	if err != nil {
+		// Without the URL, which has the key in it.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+		return errors.New("the Steam Web API key is wrong")
+	} else if response.StatusCode != http.StatusOK {
+		return errors.New("the Steam Web API answered " + response.Status)
+	}
+
+	var owned struct {
+		Response struct {
+			Games []struct {
+				AppID           int    `json:"appid"`
+				Name            string `json:"name"`
+				PlaytimeForever int    `json:"playtime_forever"`
+			} `json:"games"`
+		} `json:"response"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&owned)
+	if err != nil {
+		return err
+	}
+	// Accounts whose game details are private to others answer nothing.
+	if len(owned.Response.Games) == 0 {
+		return errors.New("no games listed, the game details of " + steamID + " may be private to another account")
+	}
+	for _, ownedGame := range owned.Response.Games {
+		gameID := strconv.Itoa(ownedGame.AppID)
+		games[gameID] = &Game{Game: sources.Game{ID: gameID, Name: ownedGame.Name}, Tags: []string{""}, Playtime: ownedGame.PlaytimeForever}
+	}
+	return nil
+}
+
+// Adds the games other accounts lend to the user through Steam Family
+// Sharing, which are in neither the profile nor the Web API answer of the
+// user. Only the installed ones are known, by their app manifests.
+func addSharedGames(user User, games map[string]*Game) {
+	shared, err := steam.SharedGames(user)
+	if err != nil {
+		fmt.Println("Could not look for games shared with " + user.Name + ": " + err.Error())
+		return
+	}
+	for _, sharedGame := range shared {
+		if _, ok := games[sharedGame.AppID]; !ok {
+			games[sharedGame.AppID] = &Game{Game: sources.Game{ID: sharedGame.AppID, Name: sharedGame.Name}, Tags: []string{""}}
+		}
+	}
+}
+
+// Loads the categories list. This finds the categories for the games loaded
+// from the profile and sometimes find new games, although without names.
+func addUnknownGames(user User, games map[string]*Game, skipCategory string) {
+	// Fetch game categories from local file.
+	sharedConfFile := filepath.Join(user.Dir, "7", "remote", "sharedconfig.vdf")
+	if _, err := os.Stat(sharedConfFile); err != nil {
+		// No categories file found, skipping this part.
+		return
+	}
+	sharedConfBytes, err := ioutil.ReadFile(sharedConfFile)
+	if err != nil {
+		return
+	}
+
+	sharedConf := string(sharedConfBytes)
+	// VDF pattern: "steamid" { "tags { "0" "category" } }
+	gamePattern := regexp.MustCompile(`"([0-9]+)"\s*{[^}]+?"tags"\s*{([^}]+?)}`)
+	tagsPattern := regexp.MustCompile(`"[0-9]+"\s*"(.+?)"`)
+	for _, gameGroups := range gamePattern.FindAllStringSubmatch(sharedConf, -1) {
+		gameID := gameGroups[1]
+		tagsText := gameGroups[2]
+
+		for _, tagGroups := range tagsPattern.FindAllStringSubmatch(tagsText, -1) {
+			tag := tagGroups[1]
+
+			game, ok := games[gameID]
+			if ok {
+				game.Tags = append(game.Tags, tag)
+			} else {
+				// If for some reason it wasn't included in the profile, create a new
+				// entry for it now. Unfortunately we don't have a name.
+				gameName := ""
+				games[gameID] = &Game{Game: sources.Game{ID: gameID, Name: gameName}, Tags: []string{tag}}
+			}
+
+			if len(skipCategory) > 0 && strings.Contains(strings.ToLower(tag), strings.ToLower(skipCategory)) {
+				delete(games, gameID)
+				break
+			}
+		}
+	}
+}
+
+// AddNonSteamGames adds non-Steam games that have been registered locally.
+// This information is in the file config/shortcuts.vdf, in binary format.
+// It contains the non-Steam games with names, target (exe location) and
+// tags/categories. Shortcuts without an appid get the one Steam computes
+// from their target and label, see shortcutCRCID. Hidden shortcuts, like the
+// ones Steam creates for Remote Play and Steam Link apps, are only added with
+// includeHidden.
+func AddNonSteamGames(user User, games map[string]*Game, skipCategory string, includeHidden bool) {
+	shortcutsVdf := filepath.Join(user.Dir, "config", "shortcuts.vdf")
+	if _, err := os.Stat(shortcutsVdf); err != nil {
+		return
+	}
+	shortcutBytes, err := ioutil.ReadFile(shortcutsVdf)
+	if err != nil {
+		return
+	}
+
+	root, err := steam.ParseBinaryVDF(shortcutBytes)
+	if err != nil {
+		fmt.Println("Could not read the non-Steam games: " + err.Error())
+	}
+	for _, shortcut := range root.Child("shortcuts").Children {
+		if hidden, _ := shortcut.ChildInt("IsHidden"); hidden != 0 && !includeHidden {
+			continue
+		}
+
+		gameID, LegacyID := ShortcutID(shortcut)
+		game := Game{Game: sources.Game{ID: gameID, Name: shortcut.ChildString("AppName"), Custom: true}, Tags: []string{}, LegacyID: LegacyID}
+		if crcID := fmt.Sprint(LegacyID); crcID != gameID {
+			game.CRCID = crcID
+		}
+		game.Target = shortcut.ChildString("Exe") + " " + shortcut.ChildString("LaunchOptions")
+		game.Platform, game.PlatformID = sources.PlatformGameID(game.Target)
+		game.LutrisGame = lutrisGameRef(shortcut.ChildString("Exe"), shortcut.ChildString("LaunchOptions"))
+		if game.LutrisGame == "" {
+			game.ApplicationName = applicationName(shortcut.ChildString("Exe"), shortcut.ChildString("LaunchOptions"))
+		}
+		games[gameID] = &game
+
+		for _, tag := range shortcut.Child("tags").Children {
+			game.Tags = append(game.Tags, tag.String)
+
+			if len(skipCategory) > 0 && strings.Contains(strings.ToLower(tag.String), strings.ToLower(skipCategory)) {
+				delete(games, gameID)
+				break
+			}
+		}
+	}
+}
+
+// Reads how long every Steam game was played from the localconfig.vdf of the
+// user.
+func addPlaytimes(user User, games map[string]*Game) {
+	localConfigBytes, err := ioutil.ReadFile(filepath.Join(user.Dir, "config", "localconfig.vdf"))
+	if err != nil {
+		return
+	}
+	root, err := steam.ParseTextVDF(localConfigBytes)
+	if err != nil {
+		return
+	}
+	apps := root.Child("UserLocalConfigStore").Child("Software").Child("Valve").Child("Steam").Child("apps")
+	if apps == nil {
+		return
+	}
+	for _, app := range apps.Children {
+		// The Steam Web API may know of more, played on other computers.
+		if game, ok := games[app.Key]; ok {
+			if playtime, err := strconv.Atoi(app.ChildString("Playtime")); err == nil && playtime > game.Playtime {
+				game.Playtime = playtime
+			}
+		}
+	}
+}
+
+// Returns the ID Steam derives for a shortcut from its target and name: the
+// CRC32 of both with the high bit set, a negative number when read as the
+// signed 32-bit appid of shortcuts.vdf. Big Picture's legacy ID is the same
+// number shifted left by 32, with 0x02000000 added.
+func shortcutCRCID(shortcut *steam.VDFNode) uint32 {
+	return crc32.ChecksumIEEE([]byte(shortcut.ChildString("Exe")+shortcut.ChildString("AppName"))) | 0x80000000
+}
+
+// ShortcutID returns the ID of a shortcut in shortcuts.vdf and its legacy ID,
+// which BigPicture is still using. The appid Steam wrote down wins over the
+// derived one, as shortcuts keep it when renamed.
+func ShortcutID(shortcut *steam.VDFNode) (string, uint64) {
+	LegacyID := uint64(shortcutCRCID(shortcut))
+
+	appID, ok := shortcut.ChildInt("appid")
+	if !ok {
+		appID = LegacyID
+	}
+	return fmt.Sprint(uint32(appID)), LegacyID
+}
+
+// GameFilter is which games GetGames returns. The zero value is every Steam
+// game and shortcut of the user but the private and hidden ones.
+type GameFilter struct {
+	// Only these comma separated appIDs, when given, without looking for any
+	// other game.
+	appIDs         string
+	excluded       appIDSet
+	nonSteamOnly   bool
+	installedOnly  bool
+	skipCategory   string
+	IncludePrivate bool
+	IncludeHidden  bool
+	IncludeShared  bool
+}
+
+// GetGames returns all games from a given user, using both the public profile
+// and local files to gather the data. With a Steam Web API key, the games are
+// listed by the API instead of the profile. Returns a map of game by ID, of
+// the games the filter keeps.
+func GetGames(user User, filter GameFilter, steamAPIKey string) map[string]*Game {
+	games := make(map[string]*Game, 0)
+
+	if filter.appIDs != "" {
+		for _, appID := range strings.Split(filter.appIDs, ",") {
+			if !filter.excluded.contains(appID) {
+				games[appID] = &Game{Game: sources.Game{ID: appID}, Tags: []string{}}
+			}
+		}
+		return games
+	}
+
+	if !filter.nonSteamOnly {
+		if !sources.OfflineMode && steamAPIKey != "" {
+			err := AddOwnedGames(user.SteamID64, games, steamAPIKey)
+			if err != nil {
+				fmt.Println("Could not get the games of " + user.Name + " from the Steam Web API, reading the public profile instead: " + err.Error())
+				addGamesFromProfile(user, games)
+			}
+		} else if !sources.OfflineMode {
+			addGamesFromProfile(user, games)
+		}
+		addUnknownGames(user, games, filter.skipCategory)
+		if filter.IncludeShared {
+			addSharedGames(user, games)
+		}
+
+		if filter.installedOnly {
+			installed, err := steam.InstalledGames(user)
+			if err != nil {
+				fmt.Println("Can't tell which games are installed, processing all of them: " + err.Error())
+			}
+			for gameID := range games {
+				if err == nil && !installed[gameID] {
+					delete(games, gameID)
+				}
+			}
+		}
+	}
+	AddNonSteamGames(user, games, filter.skipCategory, filter.IncludeHidden)
+	addCollectionTags(user, games, filter.skipCategory, !filter.nonSteamOnly && !filter.installedOnly)
+	addPlaytimes(user, games)
+
+	if !filter.IncludePrivate {
+		for gameID := range privateGames(user) {
+			delete(games, gameID)
+		}
+	}
+	for gameID := range games {
+		if filter.excluded.contains(gameID) {
+			delete(games, gameID)
+		}
+	}
+
+	return games
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package artwork
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+
+	"github.com/kmicki/steamgrid/overlay"
+)
+
+// GIF delays of 0 or 1 hundredth of a second are shown by browsers as this,
+// in milliseconds.
+const minGifDelay = 100
+
+// Tells if bytes are a GIF.
+func isGif(imageBytes []byte) bool {
+	return bytes.HasPrefix(imageBytes, []byte("GIF8"))
+}
+
+// Converts a GIF image of a game, which Steam shows still at best, to an
+// animated WEBP, or an APNG with -webpasapng, or to a PNG when it's still.
+// Returns whether it was converted.
+func convertGif(game *Game, artStyle string, options *Options) (bool, error) {
+	if !isGif(game.CleanImageBytes) {
+		return false, nil
+	}
+	animation, err := gif.DecodeAll(bytes.NewReader(game.CleanImageBytes))
+	if err != nil {
+		return false, err
+	}
+	bounds := image.Rect(0, 0, animation.Config.Width, animation.Config.Height)
+	if len(animation.Image) == 1 {
+		buf := new(bytes.Buffer)
+		still := image.NewRGBA(bounds)
+		draw.Draw(still, bounds, animation.Image[0], image.Point{}, draw.Over)
+		err = options.stillEncoding().Encode(buf, still, false)
+		if err != nil {
+			return false, err
+		}
+		game.CleanImageBytes = buf.Bytes()
+		game.ImageExt = ".png"
+		return true, nil
+	}
+
+	// GIF loops once with -1 and one more time than its count otherwise,
+	// APNG and WEBP loop forever with 0.
+	loopCount := animation.LoopCount
+	if loopCount < 0 {
+		loopCount = 1
+	} else if loopCount > 0 {
+		loopCount++
+	}
+	toApng := options.convertsToApng(artStyle)
+	spooled := false
+	if toApng {
+		toApng, spooled = overlay.ApngConversionFits(bounds.Dx(), bounds.Dy(), len(animation.Image), options.maxConvertMemory())
+		if !toApng {
+			fmt.Println("GIF animation too big to convert to APNG. Converting to WEBP.")
+		} else if spooled {
+			fmt.Println("GIF animation too big to convert to APNG in memory. Converting through a temporary file.")
+		}
+	}
+	if toApng && options.usesFFmpeg() {
+		converted, err := overlay.FFmpegToApng(game.CleanImageBytes, nil, loopCount)
+		if err == nil {
+			game.CleanImageBytes = converted
+			game.ImageExt = ".png"
+			return true, nil
+		}
+		fmt.Println(err.Error() + ", converting without it")
+	}
+	encoder, err := overlay.NewAnimationEncoder(bounds.Dx(), bounds.Dy(), len(animation.Image), loopCount, toApng, spooled)
+	if err != nil {
+		return false, err
+	}
+	defer encoder.Release()
+
+	// Frames are drawn over what the previous ones left, depending on how
+	// each is disposed of.
+	canvas := image.NewRGBA(bounds)
+	for i, frame := range animation.Image {
+		var previous *image.RGBA
+		disposal := byte(gif.DisposalNone)
+		if i < len(animation.Disposal) {
+			disposal = animation.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		result := image.NewRGBA(bounds)
+		copy(result.Pix, canvas.Pix)
+		delay := 0
+		if i < len(animation.Delay) {
+			delay = animation.Delay[i] * 10
+		}
+		if delay < 20 {
+			delay = minGifDelay
+		}
+		err = encoder.Add(result, delay)
+		if err != nil {
+			return false, err
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	converted, ext, err := encoder.Encode()
+	if err != nil {
+		return false, err
+	}
+	game.CleanImageBytes = converted
+	game.ImageExt = ext
+	return true, nil
+}
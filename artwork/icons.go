@@ -0,0 +1,38 @@
+package artwork
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Points the icon of every non-Steam game to the one SteamGrid wrote to the
+// grid directory, in shortcuts.vdf. Icons picked in Steam, anywhere else than
+// the grid directory, are left alone. Steam reads the file when it starts and
+// writes it when it exits, so the new icons only show after a restart.
+// Returns how many shortcuts were changed.
+func updateShortcutIcons(user User, gridDir string, state *gridState) (int, error) {
+	root, err := readShortcutsForWriting(user)
+	if root == nil || err != nil {
+		return 0, err
+	}
+
+	nChanged := 0
+	for _, shortcut := range root.Child("shortcuts").Children {
+		gameID, _ := ShortcutID(shortcut)
+		entry := state.Entry(gameID, "Icon")
+		if entry == nil {
+			continue
+		}
+		iconPath := filepath.Join(gridDir, entry.File)
+		current := shortcut.ChildString("icon")
+		if current == iconPath || (current != "" && !strings.EqualFold(filepath.Dir(current), gridDir)) {
+			continue
+		}
+		shortcut.SetString("icon", iconPath)
+		nChanged++
+	}
+	if nChanged == 0 {
+		return 0, nil
+	}
+	return nChanged, writeShortcuts(user, root)
+}
@@ -0,0 +1,94 @@
+package artwork
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/steam"
+	"golang.org/x/image/draw"
+)
+
+// Effects setting the games that aren't installed apart.
+var notInstalledEffects = []string{"greyscale", "darken", "ribbon"}
+
+// Marks the Steam games that aren't installed in any library. Shortcuts are
+// always taken as installed.
+func markNotInstalled(user User, games map[string]*Game) {
+	installed, err := steam.InstalledGames(user)
+	if err != nil {
+		fmt.Println("Can't tell which games are installed, none are marked: " + err.Error())
+		return
+	}
+	for gameID, game := range games {
+		game.NotInstalled = !game.Custom && !installed[gameID]
+	}
+}
+
+// Draws a band with "NOT INSTALLED" across the lower part of an image.
+func drawNotInstalledRibbon(img *image.NRGBA) {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	bandHeight := height / 8
+	if width < height {
+		bandHeight = width / 8
+	}
+	y := height*4/5 - bandHeight/2
+	band := image.Rect(0, y, width, y+bandHeight)
+	draw.Draw(img, band, image.NewUniform(color.NRGBA{0, 0, 0, 0xb0}), image.Point{}, draw.Over)
+
+	text := bitmapText("NOT INSTALLED", color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	textHeight := bandHeight * 3 / 5
+	textWidth := text.Bounds().Dx() * textHeight / text.Bounds().Dy()
+	if textWidth > width*9/10 {
+		textWidth = width * 9 / 10
+		textHeight = text.Bounds().Dy() * textWidth / text.Bounds().Dx()
+	}
+	textX, textY := (width-textWidth)/2, y+(bandHeight-textHeight)/2
+	draw.ApproxBiLinear.Scale(img, image.Rect(textX, textY, textX+textWidth, textY+textHeight), text, text.Bounds(), draw.Over, nil)
+}
+
+// Applies an effect, greyscale, darken or ribbon, to the static artwork of a
+// game that isn't installed. Works on game.OverlayImageBytes, so the backup
+// keeps the image as it was found. Returns whether the effect was applied.
+func applyNotInstalledEffect(game *Game, effect string, encoding overlay.StillEncoding) (bool, error) {
+	if !game.NotInstalled {
+		return false, nil
+	}
+	imageBytes := game.OverlayImageBytes
+	if overlay.IsAnimatedPNG(imageBytes) || overlay.IsAnimatedWebp(imageBytes) {
+		return false, errors.New("not installed effect skipped, the image is animated")
+	}
+	decoded, format, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return false, err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, decoded.Bounds().Dx(), decoded.Bounds().Dy()))
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	switch effect {
+	case "greyscale":
+		for i := 0; i < len(img.Pix); i += 4 {
+			luma := uint8((299*int(img.Pix[i]) + 587*int(img.Pix[i+1]) + 114*int(img.Pix[i+2])) / 1000)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2] = luma, luma, luma
+		}
+	case "darken":
+		for i := 0; i < len(img.Pix); i += 4 {
+			for channel := 0; channel < 3; channel++ {
+				img.Pix[i+channel] = uint8(int(img.Pix[i+channel]) * 2 / 5)
+			}
+		}
+	case "ribbon":
+		drawNotInstalledRibbon(img)
+	}
+
+	buf := new(bytes.Buffer)
+	err = encoding.Encode(buf, img, format == "jpeg")
+	if err != nil {
+		return false, err
+	}
+	game.OverlayImageBytes = buf.Bytes()
+	return true, nil
+}
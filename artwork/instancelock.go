@@ -0,0 +1,167 @@
+package artwork
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Name of the file that tells other runs a Steam installation is being
+// worked on, in the installation directory.
+const InstanceLockFileName = "steamgrid.lock"
+
+// Locks of runs on other computers, like on a shared drive, are taken as
+// left behind by a crash once this old.
+const instanceLockMaxAge = 24 * time.Hour
+
+// The lock file held by this run, if any.
+var instanceLockPath string
+
+// Who holds a lock, from its file: the process, when it took the lock, and
+// on which computer.
+type instanceLock struct {
+	pid     int
+	started time.Time
+	host    string
+	// The file as it was read
+	contents string
+}
+
+func readInstanceLock(path string) (instanceLock, error) {
+	lockBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return instanceLock{}, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(lockBytes)), "\n")
+	lock := instanceLock{contents: string(lockBytes)}
+	lock.pid, err = strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return lock, errors.New("unreadable lock file " + path)
+	}
+	if len(lines) > 1 {
+		lock.started, _ = time.Parse(time.RFC3339, strings.TrimSpace(lines[1]))
+	}
+	// Older versions didn't write the computer.
+	if len(lines) > 2 {
+		lock.host = strings.TrimSpace(lines[2])
+	}
+	return lock, nil
+}
+
+// Tells if a lock was left behind by a run that crashed or was killed: its
+// process isn't running anymore, or, for other computers, it's too old.
+func (lock instanceLock) stale() bool {
+	host, _ := os.Hostname()
+	if lock.host != "" && lock.host != host {
+		return !lock.started.IsZero() && time.Since(lock.started) > instanceLockMaxAge
+	}
+	return !processRunning(lock.pid)
+}
+
+// Tells if a process is running. Windows can't find processes that ended,
+// elsewhere they're sent the signal 0, which only checks they exist.
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	} else if runtime.GOOS == "windows" {
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// LockInstallation makes sure no other SteamGrid run works on the same Steam
+// installation at the same time, since their backups and overlays would get
+// mixed up. When another run holds the lock, waits up to maxWait for it to
+// finish. Locks left behind by runs that crashed are removed.
+func LockInstallation(installationDir string, maxWait time.Duration) error {
+	path := filepath.Join(installationDir, InstanceLockFileName)
+	deadline := time.Now().Add(maxWait)
+	waiting := false
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			host, _ := os.Hostname()
+			fmt.Fprintf(file, "%v\n%v\n%v\n", os.Getpid(), time.Now().Format(time.RFC3339), host)
+			file.Close()
+			instanceLockPath = path
+			return nil
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+
+		lock, err := readInstanceLock(path)
+		if err == nil && lock.stale() {
+			fmt.Printf("Removing the lock left by SteamGrid process %v, which crashed or was killed\n", lock.pid)
+			err = removeStaleLock(path, lock)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			owner := "another SteamGrid"
+			if err == nil {
+				owner = fmt.Sprintf("another SteamGrid (process %v", lock.pid)
+				if lock.host != "" {
+					owner += " on " + lock.host
+				}
+				if !lock.started.IsZero() {
+					owner += ", started " + lock.started.Format("2006-01-02 15:04")
+				}
+				owner += ")"
+			}
+			return errors.New(owner + " is working on " + installationDir + ", try again when it's done or use -wait. If it isn't running anymore, delete " + path)
+		}
+		if !waiting {
+			fmt.Println("Waiting for another SteamGrid working on " + installationDir + " to finish...")
+			waiting = true
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Removes a lock left behind by a run that crashed. Another run may find it
+// too, remove it and take the lock in the meantime, so the file is first moved
+// aside under a name of its own and only deleted if it's still the lock that
+// was read. Otherwise it's the lock of the other run, which is put back where
+// nothing took its place since, and left aside with an error if something did.
+func removeStaleLock(path string, lock instanceLock) error {
+	aside := fmt.Sprintf("%v.%v-%v.stale", path, os.Getpid(), time.Now().UnixNano())
+	err := os.Rename(path, aside)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	asideBytes, err := ioutil.ReadFile(aside)
+	if err == nil && string(asideBytes) == lock.contents {
+		return os.Remove(aside)
+	}
+	// Linked back rather than renamed, which would replace a lock taken
+	// since.
+	err = os.Link(aside, path)
+	if err == nil {
+		return os.Remove(aside)
+	}
+	return errors.New("another SteamGrid took the lock " + path + " while a stale one was removed, and it couldn't be put back. Make sure no other SteamGrid is running, then delete " + aside)
+}
+
+// UnlockInstallation lets the next runs work on the Steam installation.
+func UnlockInstallation() {
+	if instanceLockPath != "" {
+		os.Remove(instanceLockPath)
+		instanceLockPath = ""
+	}
+}
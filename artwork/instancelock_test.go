@@ -0,0 +1,42 @@
+package artwork
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveStaleLock(t *testing.T) {
+	stale := instanceLock{pid: 1, contents: "1\n2020-01-01T00:00:00Z\nhost\n"}
+	live := "2\n2020-01-02T00:00:00Z\nhost\n"
+
+	// Still the stale lock that was read.
+	path := filepath.Join(t.TempDir(), InstanceLockFileName)
+	ioutil.WriteFile(path, []byte(stale.contents), 0666)
+	if err := removeStaleLock(path, stale); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("stale lock not removed")
+	}
+
+	// Another run took the lock since, it's kept.
+	path = filepath.Join(t.TempDir(), InstanceLockFileName)
+	ioutil.WriteFile(path, []byte(live), 0666)
+	if err := removeStaleLock(path, stale); err != nil {
+		t.Fatal(err)
+	}
+	if lockBytes, _ := ioutil.ReadFile(path); string(lockBytes) != live {
+		t.Errorf("lock of the other run replaced by %q", lockBytes)
+	}
+	if asides, _ := filepath.Glob(path + ".*.stale"); len(asides) > 0 {
+		t.Errorf("%v left aside", asides)
+	}
+
+	// Already removed by another run.
+	path = filepath.Join(t.TempDir(), InstanceLockFileName)
+	if err := removeStaleLock(path, stale); err != nil {
+		t.Errorf("removing a lock already gone: %v", err)
+	}
+}
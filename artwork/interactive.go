@@ -0,0 +1,153 @@
+package artwork
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Answers typed by the user in interactive mode.
+var interactiveInput = bufio.NewReader(os.Stdin)
+
+// Downloads a URL into the temporary directory so the user can look at it.
+func downloadForPreview(url string, name string) (string, error) {
+	response, err := sources.TryDownload(url)
+	if err != nil {
+		return "", err
+	} else if response == nil {
+		return "", fmt.Errorf("%v not found", url)
+	}
+	imageBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(os.TempDir(), name+filepath.Ext(response.Request.URL.Path))
+	return path, ioutil.WriteFile(path, imageBytes, 0666)
+}
+
+// Downloads an animated candidate and writes a contact sheet of its frames
+// to the temporary directory.
+func previewCandidate(candidate sources.SteamGridDBImage) (string, error) {
+	response, err := sources.TryDownload(candidate.URL)
+	if err != nil {
+		return "", err
+	} else if response == nil {
+		return "", fmt.Errorf("%v not found", candidate.URL)
+	}
+	imageBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("steamgrid-%v.preview.png", candidate.ID))
+	info, err := overlay.WriteAnimationPreview(imageBytes, path)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("   %v\n", info)
+	return path, nil
+}
+
+// Lets the user pick one of the best SteamGridDB images for a game, or skip
+// it. Choices are remembered in the grid state, so the next runs use the same
+// image without asking. Returns the URL and ID of the chosen image.
+func chooseSteamGridDBImage(state *gridState, game *sources.Game, artStyle string, artStyleExtensions []string, source sources.SteamGridDB, options *Options) (string, int, error) {
+	if choice := state.choice(game.ID, artStyle); choice != nil {
+		if choice.Skipped {
+			fmt.Printf("%v skipped in a previous run\n", artStyle)
+			return "", 0, nil
+		}
+		return choice.URL, choice.SteamGridDBID, nil
+	}
+
+	images, err := source.Images(game, artStyleExtensions)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(images) == 0 {
+		return "", 0, nil
+	}
+	if options.Candidates > 0 && len(images) > options.Candidates {
+		images = images[:options.Candidates]
+	}
+
+	fmt.Printf("\nSteamGridDB %v candidates for %v (id %v):\n", strings.ToLower(artStyle), game.Name, game.ID)
+	for i, candidate := range images {
+		fmt.Printf("%3d) %v\n", i+1, candidate)
+		if options.Preview && candidate.IsAnimated() {
+			path, err := previewCandidate(candidate)
+			if err != nil {
+				fmt.Println("   No preview: " + err.Error())
+			} else {
+				fmt.Println("   Preview: " + path)
+			}
+		}
+	}
+
+	for {
+		fmt.Printf("Pick a number, t<number> to download its thumbnail, p<number> to preview an animation, s to skip, or enter for 1: ")
+		answer, err := interactiveInput.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if err != nil && answer == "" {
+			// No more input, keep the best candidate without remembering it.
+			fmt.Println()
+			return images[0].URL, images[0].ID, nil
+		}
+
+		switch {
+		case answer == "":
+			answer = "1"
+		case answer == "s":
+			state.setChoice(game.ID, artStyle, &stateChoice{Skipped: true})
+			state.Save()
+			return "", 0, nil
+		}
+
+		command := ""
+		if strings.HasPrefix(answer, "t") || strings.HasPrefix(answer, "p") {
+			command = answer[:1]
+			answer = answer[1:]
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(answer))
+		if err != nil || n < 1 || n > len(images) {
+			fmt.Println("Not one of the candidates.")
+			continue
+		}
+		candidate := images[n-1]
+
+		switch command {
+		case "t":
+			path, err := downloadForPreview(candidate.Thumb, fmt.Sprintf("steamgrid-%v.thumb", candidate.ID))
+			if err != nil {
+				fmt.Println(err.Error())
+			} else {
+				fmt.Println("Thumbnail: " + path)
+			}
+		case "p":
+			if !candidate.IsAnimated() {
+				fmt.Println("Not an animation, use t to see its thumbnail.")
+				continue
+			}
+			path, err := previewCandidate(candidate)
+			if err != nil {
+				fmt.Println(err.Error())
+			} else {
+				fmt.Println("Preview: " + path)
+			}
+		default:
+			state.setChoice(game.ID, artStyle, &stateChoice{SteamGridDBID: candidate.ID, URL: candidate.URL})
+			state.Save()
+			return candidate.URL, candidate.ID, nil
+		}
+	}
+}
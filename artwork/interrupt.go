@@ -0,0 +1,94 @@
+package artwork
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// Ctrl+C, or SIGTERM, stops a run once the image being processed is done:
+// the state file, the summary and the report are written as usual for what
+// was processed, and -resume continues with the rest. A second Ctrl+C quits
+// right away.
+
+// Set once the run was asked to stop.
+var stopRequested int32
+
+// Stop asks the runs to stop once the image being processed is done.
+func Stop() {
+	atomic.StoreInt32(&stopRequested, 1)
+}
+
+// Stopping tells if the run was asked to stop.
+func Stopping() bool {
+	return atomic.LoadInt32(&stopRequested) == 1
+}
+
+// Name of the file remembering how far an interrupted run got, in the Steam
+// installation directory.
+const resumeFileName = "steamgrid-resume.json"
+
+// How far an interrupted run got: the games processed, by SteamID32 of their
+// user, and the users it was done with.
+type runProgress struct {
+	path  string
+	Games map[string][]string `json:"games"`
+	Users []string            `json:"users,omitempty"`
+}
+
+// Loads the progress of the last interrupted run with -resume, or starts a
+// new one.
+func loadRunProgress(installationDir string, resume bool) *runProgress {
+	progress := &runProgress{path: filepath.Join(installationDir, resumeFileName), Games: map[string][]string{}}
+	if !resume {
+		return progress
+	}
+	progressBytes, err := ioutil.ReadFile(progress.path)
+	if err == nil {
+		err = json.Unmarshal(progressBytes, progress)
+	}
+	if err != nil {
+		fmt.Println("No interrupted run to resume, processing everything.")
+		progress.Games, progress.Users = map[string][]string{}, nil
+	} else if progress.Games == nil {
+		progress.Games = map[string][]string{}
+	}
+	return progress
+}
+
+func (progress *runProgress) userDone(user User) bool {
+	return containsString(progress.Users, user.SteamID32)
+}
+
+func (progress *runProgress) gameDone(user User, gameID string) bool {
+	return containsString(progress.Games[user.SteamID32], gameID)
+}
+
+func (progress *runProgress) addUser(user User) {
+	progress.Users = append(progress.Users, user.SteamID32)
+	delete(progress.Games, user.SteamID32)
+}
+
+func (progress *runProgress) addGame(user User, gameID string) {
+	progress.Games[user.SteamID32] = append(progress.Games[user.SteamID32], gameID)
+}
+
+// Writes the progress of an interrupted run, or removes the file once a run
+// got to the end.
+func (progress *runProgress) save(interrupted bool) error {
+	if !interrupted {
+		err := os.Remove(progress.path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	progressBytes, err := json.MarshalIndent(progress, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(progress.path, progressBytes, 0666)
+}
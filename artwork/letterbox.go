@@ -0,0 +1,183 @@
+package artwork
+
+import (
+	"bytes"
+	"errors"
+	"image"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"golang.org/x/image/draw"
+)
+
+// Size heroes are extended to.
+const heroWidth, heroHeight = 1920, 620
+
+// Bars thinner than this share of the image are left for the content.
+const letterboxMinBar = 0.02
+
+// Heroes this much narrower or wider than 1920x620 are extended.
+const letterboxAspectTolerance = 0.1
+
+// Radius of the blur over the extended edges, in pixels of the hero.
+const letterboxBlurRadius = 24
+
+// Whether a row or column of pixels is part of a bar: nearly all of it black,
+// or transparent.
+func isLetterboxLine(img *image.NRGBA, line image.Rectangle) bool {
+	nPixels, nBar := 0, 0
+	for y := line.Min.Y; y < line.Max.Y; y++ {
+		for x := line.Min.X; x < line.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			luma := (299*int(img.Pix[i]) + 587*int(img.Pix[i+1]) + 114*int(img.Pix[i+2])) / 1000
+			if luma <= 24 || img.Pix[i+3] < 16 {
+				nBar++
+			}
+			nPixels++
+		}
+	}
+	return float64(nBar) >= 0.98*float64(nPixels)
+}
+
+// Returns the part of an image inside its letterbox or pillarbox bars.
+func letterboxContent(img *image.NRGBA) image.Rectangle {
+	bounds := img.Bounds()
+	content := bounds
+	for content.Min.Y < content.Max.Y && isLetterboxLine(img, image.Rect(bounds.Min.X, content.Min.Y, bounds.Max.X, content.Min.Y+1)) {
+		content.Min.Y++
+	}
+	for content.Max.Y > content.Min.Y && isLetterboxLine(img, image.Rect(bounds.Min.X, content.Max.Y-1, bounds.Max.X, content.Max.Y)) {
+		content.Max.Y--
+	}
+	for content.Min.X < content.Max.X && isLetterboxLine(img, image.Rect(content.Min.X, content.Min.Y, content.Min.X+1, content.Max.Y)) {
+		content.Min.X++
+	}
+	for content.Max.X > content.Min.X && isLetterboxLine(img, image.Rect(content.Max.X-1, content.Min.Y, content.Max.X, content.Max.Y)) {
+		content.Max.X--
+	}
+
+	// Dark edges of the art itself aren't bars.
+	minX, minY := int(letterboxMinBar*float64(bounds.Dx())), int(letterboxMinBar*float64(bounds.Dy()))
+	if content.Min.X-bounds.Min.X < minX && bounds.Max.X-content.Max.X < minX {
+		content.Min.X, content.Max.X = bounds.Min.X, bounds.Max.X
+	}
+	if content.Min.Y-bounds.Min.Y < minY && bounds.Max.Y-content.Max.Y < minY {
+		content.Min.Y, content.Max.Y = bounds.Min.Y, bounds.Max.Y
+	}
+	return content
+}
+
+// Blurs an image in place with three box blurs, which look close enough to
+// a gaussian one.
+func boxBlur(img *image.NRGBA, radius int) {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	line := make([]int, 4*(width+height))
+	blurLine := func(offset int, step int, length int) {
+		for i := 0; i < length; i++ {
+			for channel := 0; channel < 4; channel++ {
+				line[i*4+channel] = int(img.Pix[offset+i*step+channel])
+			}
+		}
+		for channel := 0; channel < 4; channel++ {
+			sum, count := 0, 0
+			for i := 0; i < radius && i < length; i++ {
+				sum += line[i*4+channel]
+				count++
+			}
+			for i := 0; i < length; i++ {
+				if i+radius < length {
+					sum += line[(i+radius)*4+channel]
+					count++
+				}
+				if i-radius-1 >= 0 {
+					sum -= line[(i-radius-1)*4+channel]
+					count--
+				}
+				img.Pix[offset+i*step+channel] = uint8(sum / count)
+			}
+		}
+	}
+	for pass := 0; pass < 3; pass++ {
+		for y := 0; y < height; y++ {
+			blurLine(y*img.Stride, 4, width)
+		}
+		for x := 0; x < width; x++ {
+			blurLine(x*4, img.Stride, height)
+		}
+	}
+}
+
+// Mirrors a coordinate outside of [min, max) back into it.
+func mirrorCoordinate(v int, min int, max int) int {
+	size := max - min
+	if size <= 1 {
+		return min
+	}
+	period := 2 * size
+	offset := ((v-min)%period + period) % period
+	if offset >= size {
+		offset = period - 1 - offset
+	}
+	return min + offset
+}
+
+// Extends static heroes with letterbox bars, or too narrow or wide for the
+// 1920x620 header of the library, to that size: the content is fit in the
+// middle and the rest is filled with its edges, mirrored and blurred. Works on
+// game.OverlayImageBytes, so the backup keeps the image as it was found.
+// Returns whether the hero was extended.
+func extendHero(game *Game, encoding overlay.StillEncoding) (bool, error) {
+	imageBytes := game.OverlayImageBytes
+	if overlay.IsAnimatedPNG(imageBytes) || overlay.IsAnimatedWebp(imageBytes) {
+		return false, errors.New("hero not extended, the image is animated")
+	}
+	decoded, format, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return false, err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, decoded.Bounds().Dx(), decoded.Bounds().Dy()))
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	content := letterboxContent(img)
+	if content.Empty() {
+		return false, nil
+	}
+	aspect := float64(content.Dx()) / float64(content.Dy())
+	heroAspect := float64(heroWidth) / float64(heroHeight)
+	if content == img.Bounds() && aspect > heroAspect*(1-letterboxAspectTolerance) && aspect < heroAspect*(1+letterboxAspectTolerance) {
+		return false, nil
+	}
+
+	// The content, fit in the middle of the hero.
+	width, height := heroWidth, int(float64(heroWidth)/aspect)
+	if aspect < heroAspect {
+		width, height = int(float64(heroHeight)*aspect), heroHeight
+	}
+	placed := image.Rect((heroWidth-width)/2, (heroHeight-height)/2, (heroWidth-width)/2+width, (heroHeight-height)/2+height)
+	hero := image.NewNRGBA(image.Rect(0, 0, heroWidth, heroHeight))
+	draw.CatmullRom.Scale(hero, placed, img, content, draw.Src, nil)
+
+	// The rest mirrors the content, blurred and a bit darker so the art
+	// stands out.
+	background := image.NewNRGBA(hero.Bounds())
+	for y := 0; y < heroHeight; y++ {
+		for x := 0; x < heroWidth; x++ {
+			from := hero.PixOffset(mirrorCoordinate(x, placed.Min.X, placed.Max.X), mirrorCoordinate(y, placed.Min.Y, placed.Max.Y))
+			copy(background.Pix[background.PixOffset(x, y):][:4], hero.Pix[from:from+4])
+		}
+	}
+	boxBlur(background, letterboxBlurRadius)
+	for i := 0; i < len(background.Pix); i += 4 {
+		for channel := 0; channel < 3; channel++ {
+			background.Pix[i+channel] = uint8(int(background.Pix[i+channel]) * 4 / 5)
+		}
+	}
+	draw.Draw(background, placed, hero, placed.Min, draw.Src)
+
+	buf := new(bytes.Buffer)
+	err = encoding.Encode(buf, background, format == "jpeg")
+	if err != nil {
+		return false, err
+	}
+	game.OverlayImageBytes = buf.Bytes()
+	return true, nil
+}
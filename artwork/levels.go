@@ -0,0 +1,101 @@
+package artwork
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/kmicki/steamgrid/overlay"
+)
+
+// Share of the darkest and brightest pixels ignored when stretching the
+// levels, so a few specks don't prevent it.
+const autoLevelsClip = 0.005
+
+// Images with a smaller range of luma values are left alone, there's nothing
+// to stretch without making noise out of a flat color.
+const autoLevelsMinRange = 10
+
+// Stretches the levels of a static image so its darkest pixels are black and
+// its brightest white, then, when target is above zero, corrects its gamma so
+// the average brightness gets to target (from 0 to 1). Works on
+// game.OverlayImageBytes, so the backup keeps the image as it was found.
+func autoLevelImage(game *Game, target float64, encoding overlay.StillEncoding) error {
+	imageBytes := game.OverlayImageBytes
+	if overlay.IsAnimatedPNG(imageBytes) || overlay.IsAnimatedWebp(imageBytes) {
+		return errors.New("auto levels skipped, the image is animated")
+	}
+	decoded, format, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return err
+	}
+	img := image.NewNRGBA(decoded.Bounds())
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	luma := func(i int) int {
+		return (299*int(img.Pix[i]) + 587*int(img.Pix[i+1]) + 114*int(img.Pix[i+2])) / 1000
+	}
+
+	var histogram [256]int
+	nPixels := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] == 0 {
+			continue
+		}
+		histogram[luma(i)]++
+		nPixels++
+	}
+	if nPixels == 0 {
+		return nil
+	}
+
+	low, high := 0, 255
+	for count := 0; low < 255 && float64(count+histogram[low]) <= autoLevelsClip*float64(nPixels); low++ {
+		count += histogram[low]
+	}
+	for count := 0; high > 0 && float64(count+histogram[high]) <= autoLevelsClip*float64(nPixels); high-- {
+		count += histogram[high]
+	}
+	if high-low < autoLevelsMinRange {
+		return nil
+	}
+
+	var levels [256]float64
+	for v := range levels {
+		levels[v] = math.Max(0, math.Min(1, float64(v-low)/float64(high-low)))
+	}
+
+	gamma := 1.0
+	if target > 0 && target < 1 {
+		// Average brightness after stretching, to find the gamma that moves it
+		// to the target. Kept within limits so images don't get washed out.
+		mean := 0.0
+		for v, count := range histogram {
+			mean += levels[v] * float64(count)
+		}
+		mean /= float64(nPixels)
+		if mean > 0 && mean < 1 {
+			gamma = math.Max(0.5, math.Min(2, math.Log(target)/math.Log(mean)))
+		}
+	}
+
+	var lut [256]uint8
+	for v := range lut {
+		lut[v] = uint8(math.Round(255 * math.Pow(levels[v], gamma)))
+	}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = lut[img.Pix[i]]
+		img.Pix[i+1] = lut[img.Pix[i+1]]
+		img.Pix[i+2] = lut[img.Pix[i+2]]
+	}
+
+	buf := new(bytes.Buffer)
+	err = encoding.Encode(buf, img, format == "jpeg")
+	if err != nil {
+		return err
+	}
+	game.OverlayImageBytes = buf.Bytes()
+	return nil
+}
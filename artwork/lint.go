@@ -0,0 +1,167 @@
+package artwork
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kmicki/steamgrid/overlay"
+)
+
+// Checks run by -lint when -lintchecks isn't given.
+const DefaultLintChecks = "animation,logo,brightness"
+
+// A possible inconsistency in the artwork of the library.
+type lintFinding struct {
+	User     string `json:"user"`
+	GameID   string `json:"gameId"`
+	Name     string `json:"name"`
+	ArtStyle string `json:"artStyle"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+}
+
+// Average luma of an image, from 0 (black) to 1 (white), sampling a grid of
+// pixels. Animations count by their first frame.
+func imageBrightness(imageBytes []byte) (float64, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return 0, err
+	}
+	bounds := img.Bounds()
+	step := (bounds.Dx()*bounds.Dy())/10000 + 1
+	total, n := 0.0, 0
+	for i := 0; i < bounds.Dx()*bounds.Dy(); i += step {
+		r, g, b, _ := img.At(bounds.Min.X+i%bounds.Dx(), bounds.Min.Y+i/bounds.Dx()).RGBA()
+		total += (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return total / float64(n), nil
+}
+
+// LintGrid looks for artwork that doesn't fit with the rest of the library:
+//
+// - animation: a few animated images among mostly static ones of the same
+// art style, or the other way around. Only styles where the minority is
+// below options.LintMinority are flagged.
+// - logo: covers that likely have a logo baked in, because they didn't come
+// from SteamGridDB, when the no_logo style was asked for.
+// - brightness: heroes whose brightness differs from the median by more than
+// options.LintBrightness.
+func LintGrid(user User, gridDir string, games map[string]*Game, artStyles map[string][]string, options *Options) []*lintFinding {
+	checks := make(map[string]bool)
+	for _, check := range strings.Split(options.LintChecks, ",") {
+		checks[strings.TrimSpace(check)] = true
+	}
+	state, _ := LoadGridState(gridDir)
+
+	var findings []*lintFinding
+	flag := func(game *Game, artStyle string, check string, message string) {
+		findings = append(findings, &lintFinding{User: user.Name, GameID: game.ID, Name: ResolveGameName(game), ArtStyle: artStyle, Check: check, Message: message})
+	}
+
+	var gameIDs []string
+	for gameID := range games {
+		gameIDs = append(gameIDs, gameID)
+	}
+	sort.Strings(gameIDs)
+
+	for artStyle, artStyleExtensions := range artStyles {
+		animated := make(map[string]bool)
+		brightness := make(map[string]float64)
+		for _, gameID := range gameIDs {
+			game := games[gameID]
+			images, _ := filepath.Glob(filepath.Join(gridDir, game.ID+artStyleExtensions[0]+".*"))
+			images = FilterForImages(images)
+			if len(images) == 0 {
+				continue
+			}
+			imageBytes, err := ioutil.ReadFile(images[0])
+			if err != nil {
+				continue
+			}
+
+			animated[gameID] = overlay.IsAnimatedPNG(imageBytes) || overlay.IsAnimatedWebp(imageBytes)
+
+			if checks["logo"] && artStyle == "Cover" && strings.Contains(options.Styles, "no_logo") {
+				if entry := state.Entry(gameID, artStyle); entry != nil && entry.Source != "SteamGridDB" {
+					flag(game, artStyle, "logo", fmt.Sprintf("cover from %v probably has a logo, but no_logo was asked for", entry.Source))
+				}
+			}
+
+			if checks["brightness"] && artStyle == "Hero" {
+				if value, err := imageBrightness(imageBytes); err == nil {
+					brightness[gameID] = value
+				}
+			}
+		}
+
+		if checks["animation"] && len(animated) > 0 {
+			nAnimated := 0
+			for _, isAnimated := range animated {
+				if isAnimated {
+					nAnimated++
+				}
+			}
+			minorityAnimated := nAnimated*2 < len(animated)
+			minority := nAnimated
+			if !minorityAnimated {
+				minority = len(animated) - nAnimated
+			}
+			if minority > 0 && float64(minority)/float64(len(animated)) < options.LintMinority {
+				for _, gameID := range gameIDs {
+					isAnimated, ok := animated[gameID]
+					if !ok || isAnimated != minorityAnimated {
+						continue
+					}
+					if isAnimated {
+						flag(games[gameID], artStyle, "animation", fmt.Sprintf("animated, while %v of %v are static", len(animated)-nAnimated, len(animated)))
+					} else {
+						flag(games[gameID], artStyle, "animation", fmt.Sprintf("static, while %v of %v are animated", nAnimated, len(animated)))
+					}
+				}
+			}
+		}
+
+		if checks["brightness"] && len(brightness) > 2 {
+			var values []float64
+			for _, value := range brightness {
+				values = append(values, value)
+			}
+			sort.Float64s(values)
+			median := values[len(values)/2]
+			for _, gameID := range gameIDs {
+				value, ok := brightness[gameID]
+				if ok && math.Abs(value-median) > options.LintBrightness {
+					flag(games[gameID], artStyle, "brightness", fmt.Sprintf("brightness %.0f%%, while most are around %.0f%%", value*100, median*100))
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].ArtStyle < findings[j].ArtStyle
+	})
+	return findings
+}
+
+// PrintLintFindings prints the findings of LintGrid.
+func PrintLintFindings(findings []*lintFinding) {
+	if len(findings) == 0 {
+		fmt.Printf("No inconsistencies found in the artwork.\n\n")
+		return
+	}
+	fmt.Printf("%v images may not fit with the rest of the library:\n", len(findings))
+	for _, finding := range findings {
+		fmt.Printf("- %v (id %v, %v): %v\n", finding.Name, finding.GameID, finding.ArtStyle, finding.Message)
+	}
+	fmt.Printf("\n\n")
+}
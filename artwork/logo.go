@@ -0,0 +1,53 @@
+package artwork
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Where Steam places the logo over the hero, as saved by its "adjust logo
+// position" menu in grid/<appid>.json.
+type logoLayout struct {
+	Version      int `json:"nVersion"`
+	LogoPosition struct {
+		PinnedPosition string  `json:"pinnedPosition"`
+		WidthPct       float64 `json:"nWidthPct"`
+		HeightPct      float64 `json:"nHeightPct"`
+	} `json:"logoPosition"`
+}
+
+// Positions accepted by -logoposition, with their names in Steam's file.
+var logoPinnedPositions = map[string]string{
+	"bottomleft":   "BottomLeft",
+	"upperleft":    "UpperLeft",
+	"centercenter": "CenterCenter",
+	"uppercenter":  "UpperCenter",
+	"bottomcenter": "BottomCenter",
+}
+
+// Writes the position of a game's logo next to it, unless the game already
+// has one, like a placement adjusted in Steam, or -force was given.
+func writeLogoPosition(gridDir string, gameID string, options *Options) error {
+	pinned, ok := logoPinnedPositions[strings.ToLower(options.LogoPosition)]
+	if !ok {
+		// "none", or the flag doesn't belong to the command.
+		return nil
+	}
+	path := filepath.Join(gridDir, gameID+".json")
+	if _, err := os.Stat(path); err == nil && !options.Force {
+		return nil
+	}
+
+	layout := logoLayout{Version: 1}
+	layout.LogoPosition.PinnedPosition = pinned
+	layout.LogoPosition.WidthPct = options.LogoWidth
+	layout.LogoPosition.HeightPct = options.LogoHeight
+	layoutBytes, err := json.Marshal(layout)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, layoutBytes, 0666)
+}
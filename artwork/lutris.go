@@ -0,0 +1,88 @@
+package artwork
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// A game of the Lutris library.
+type lutrisGame struct {
+	ID   int    `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	// Like "wine", "dosbox" or "snes9x".
+	Runner string `json:"runner"`
+}
+
+// How Steam shortcuts created by Lutris launch their game, by ID or by slug:
+// lutris lutris:rungameid/12, or through flatpak run net.lutris.Lutris.
+var lutrisLaunchPattern = regexp.MustCompile(`lutris:rungame(id)?/([^\s"]+)`)
+
+// Commands listing the Lutris library as JSON, native first.
+var lutrisListCommands = [][]string{
+	{"lutris", "-l", "-j"},
+	{"flatpak", "run", "net.lutris.Lutris", "-l", "-j"},
+}
+
+// The Lutris library, read once per run and only when a shortcut needs it.
+var lutrisLibrary struct {
+	sync.Once
+	games []lutrisGame
+}
+
+// Returns the Lutris game a shortcut launches, by ID or slug, or "".
+func lutrisGameRef(exe string, launchOptions string) string {
+	groups := lutrisLaunchPattern.FindStringSubmatch(exe + " " + launchOptions)
+	if groups == nil {
+		return ""
+	}
+	return groups[2]
+}
+
+// Reads the Lutris library with Lutris itself, which knows where its
+// database is and how it's laid out. Lutris logs before the list, so the
+// output is read from the first "[".
+func loadLutrisGames() []lutrisGame {
+	for _, command := range lutrisListCommands {
+		output, err := exec.Command(command[0], command[1:]...).Output()
+		if err != nil {
+			continue
+		}
+		start := bytes.IndexByte(output, '[')
+		if start < 0 {
+			continue
+		}
+		var games []lutrisGame
+		if json.Unmarshal(output[start:], &games) == nil {
+			return games
+		}
+	}
+	return nil
+}
+
+// Returns the name to search for a shortcut of a Lutris game: its name in
+// Lutris, or its slug as words when Lutris doesn't know it, like
+// "the-witcher-3-wild-hunt". Slugs come from lutris.net, so they're the
+// proper name of the game rather than what the shortcut was called.
+func lutrisSearchName(ref string) string {
+	lutrisLibrary.Do(func() {
+		lutrisLibrary.games = loadLutrisGames()
+	})
+	id, err := strconv.Atoi(ref)
+	for _, game := range lutrisLibrary.games {
+		if (err == nil && game.ID == id) || game.Slug == ref {
+			return sources.CleanGameName(game.Name)
+		}
+	}
+	if err == nil {
+		return ""
+	}
+	return strings.Replace(ref, "-", " ", -1)
+}
@@ -0,0 +1,88 @@
+package artwork
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Returns the local mirror of artwork: the -mirror directory, or mirror/
+// next to the executable.
+func mirrorDir(options *Options) string {
+	if options.Mirror != "" {
+		return options.Mirror
+	}
+	return filepath.Join(filepath.Dir(os.Args[0]), "mirror")
+}
+
+// Looks for an image of the game in the mirror, which is organized by appID
+// and art style: mirror/440/cover/<any name>.png, or mirror/440/cover.png.
+// When a style directory has several images, the most recent one is used.
+// Returns true when the image was found, with the game fields set as for a
+// download.
+func loadMirrorImage(game *Game, artStyle string, options *Options) bool {
+	gameDir := filepath.Join(mirrorDir(options), game.ID)
+	style := strings.ToLower(artStyle)
+
+	candidates, _ := filepath.Glob(filepath.Join(gameDir, style, "*.*"))
+	candidates = filterForMirrorImages(candidates)
+	sort.Slice(candidates, func(i, j int) bool {
+		infoI, errI := os.Stat(candidates[i])
+		infoJ, errJ := os.Stat(candidates[j])
+		return errI == nil && errJ == nil && infoI.ModTime().After(infoJ.ModTime())
+	})
+	flat, _ := filepath.Glob(filepath.Join(gameDir, style+".*"))
+	candidates = append(candidates, filterForMirrorImages(flat)...)
+
+	for _, path := range candidates {
+		imageBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		game.ImageSource = "mirror"
+		game.ImageExt = strings.ToLower(filepath.Ext(path))
+		if game.ImageExt == ".jpeg" {
+			// The new library ignores .jpeg
+			game.ImageExt = ".jpg"
+		}
+		game.CleanImageBytes = imageBytes
+		return true
+	}
+	return false
+}
+
+// The mirror keeps downloads as they came, so WEBP animations and GIFs too.
+func filterForMirrorImages(paths []string) []string {
+	var matchedPaths []string
+	for _, path := range paths {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".png", ".jpg", ".jpeg", ".webp", ".gif":
+			matchedPaths = append(matchedPaths, path)
+		}
+	}
+	return matchedPaths
+}
+
+// Saves a downloaded image, before overlays, into the mirror so later runs,
+// other users or other computers can use it without downloading it again.
+// SteamGridDB images are named by their ID, others by the hash of their
+// bytes.
+func saveMirrorImage(game *Game, artStyle string, options *Options) error {
+	name := ImageHash(game.CleanImageBytes)[:16]
+	if game.SteamGridDBID != 0 {
+		name = strconv.Itoa(game.SteamGridDBID)
+	}
+	dir := filepath.Join(mirrorDir(options), game.ID, strings.ToLower(artStyle))
+	path := filepath.Join(dir, name+game.ImageExt)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, game.CleanImageBytes, 0666)
+}
@@ -0,0 +1,508 @@
+package artwork
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Options shared by the commands. Each command registers the groups of flags
+// it needs on its own flag set.
+type Options struct {
+	// Sources
+	SteamGridDBApiKey string
+	IGDBSecret        string
+	IGDBClient        string
+	TheGamesDBApiKey  string
+	RAWGApiKey        string
+	SkipSteam         bool
+	SkipGoogle        bool
+	SteamGridDBOnly   bool
+	// Use the LaunchBox Games Database, downloading its metadata dump
+	LaunchBox bool
+	// Web image search used for banners: google, bing, duckduckgo or
+	// searxng, with the Bing key or the SearXNG instance they need
+	SearchProvider string
+	BingApiKey     string
+	SearXNG        string
+	// Preferred language of the artwork, as an ISO code or Steam's name
+	Language string
+	// Only download artworks missing on the official servers
+	OnlyMissingArtwork bool
+	// SteamGridDB images with a lower score or fewer upvotes are skipped
+	SteamGridDBMinScore   sources.OptionalInt
+	SteamGridDBMinUpvotes sources.OptionalInt
+	// Games found by name on SteamGridDB less similar than this, from 0 to
+	// 1, count as not found
+	MinMatch float64
+	// Ask which SteamGridDB image to use, listing this many candidates
+	Interactive bool
+	Candidates  int
+	// Write contact sheets of animated candidates while asking
+	Preview bool
+	// SteamGridDB animations with longer loops or more frames per second are
+	// avoided when there are others
+	MaxLoop time.Duration
+	MaxFPS  float64
+	// What to do with logos without transparency: warn, clear or reject
+	OpaqueLogos string
+	// Steam Deck model whose preset fills in the options not given
+	Deck string
+	// Comma separated artwork packs, ranked above the online sources
+	Packs string
+	// Directory of artwork organized by appID and art style, ranked above
+	// the online sources. Defaults to mirror/ next to the executable.
+	Mirror string
+	// Save every downloaded image into the mirror
+	SaveMirror bool
+	// JSON file of names to search some games with. Defaults to
+	// name-overrides.json next to the executable.
+	NameOverrides string
+	// Comma separated RetroArch playlists and EmulationStation gamelists,
+	// files or directories
+	Playlists string
+	// JSON file of SteamGridDB assets to use for some games. Defaults to
+	// pins.json next to the executable.
+	Pins string
+	// Comma separated sources to try for each art style, in order, instead
+	// of the ones given by the flags above
+	SourcesBanner string
+	SourcesCover  string
+	SourcesHero   string
+	SourcesLogo   string
+	SourcesIcon   string
+
+	// SteamGridDB filters
+	Styles           string
+	LogoStyles       string
+	HeroStyles       string
+	IconStyles       string
+	Types            string
+	Nsfw             string
+	Humor            string
+	BannerDimensions string
+	CoverDimensions  string
+	HeroDimensions   string
+
+	// Library selection
+	SteamDir     string
+	SkipBanner   bool
+	SkipCover    bool
+	SkipHero     bool
+	SkipLogo     bool
+	SkipIcon     bool
+	NonSteamOnly bool
+	// Comma separated types of apps that aren't games to process anyway,
+	// like dlc or music, or all
+	IncludeTypes   string
+	InstalledOnly  bool
+	Apps           bool
+	RetryQueue     bool
+	AppIDs         string
+	ExcludeAppIDs  string
+	SkipCategory   string
+	NameFilter     string
+	IgnoreBackup   bool
+	IgnoreManual   bool
+	IncludePrivate bool
+	IncludeHidden  bool
+	// Also process the games other accounts share through Family Sharing
+	IncludeShared bool
+	// Tidy the names of non-Steam games in shortcuts.vdf
+	NormalizeNames bool
+	// Continue the last interrupted run
+	Resume bool
+	// Only process the images the state file has nothing recent about
+	Incremental bool
+	MaxAge      time.Duration
+	// Keep running, processing new games as they're added
+	Watch         bool
+	WatchInterval time.Duration
+	// Steam Web API key listing the games of the users
+	SteamApiKey string
+	// Download all artwork again, even when present or locked in the state
+	// file
+	Force bool
+	// Download again the artwork that came from this source
+	ForceSource string
+	// Where Steam shows new logos over the heroes, as a share of their size
+	LogoPosition string
+	LogoWidth    float64
+	LogoHeight   float64
+	// How long to wait for another run on the same installation
+	Wait time.Duration
+
+	// Where to write the JSON report of the run, if anywhere
+	ReportPath string
+	// Where to send the names not found or found by searching, if anywhere
+	ShareMatchesURL string
+	// Look for artwork that doesn't fit with the rest, with these checks
+	Lint           bool
+	LintChecks     string
+	LintMinority   float64
+	LintBrightness float64
+
+	// Categories in the order their overlays are stacked, from bottom to top,
+	// and how many of them are kept
+	OverlayOrder string
+	MaxOverlays  int
+	// YAML file of rules putting overlays on games by their store data.
+	// Defaults to overlay-rules.yaml next to the executable.
+	OverlayRules string
+	// Where and how the overlays are put on the images
+	OverlayMode    string
+	OverlayAnchor  string
+	OverlayMargin  float64
+	OverlayScale   float64
+	OverlayOpacity float64
+	// Text to write in a pill on a corner of covers and banners
+	TextBadge           string
+	TextBadgeCorner     string
+	TextBadgeSize       float64
+	TextBadgeBackground string
+	TextBadgeColor      string
+	// Effect set on the games that aren't installed: greyscale, darken or
+	// ribbon
+	NotInstalled string
+	// Draw the ProtonDB tier of Steam games on a corner of covers and banners
+	ProtonDB       bool
+	ProtonDBCorner string
+	// Draw the Steam Deck compatibility of Steam games on a corner of covers
+	// and banners
+	DeckBadge       bool
+	DeckBadgeCorner string
+	// Convert images with color profiles to sRGB and strip their metadata
+	NormalizeColors bool
+	// Conversion
+	ConvertWebpToApng              bool
+	ConvertWebpToApngCoversBanners bool
+	MaxMemoryForConvert            int
+	// Memory the whole run should stay under, like 1500MB
+	MaxMem string
+	// Animations converted at once in the background, 0 to convert them
+	// before going on
+	ConvertWorkers int
+	// Leave conversions to APNG to ffmpeg, when it's on the PATH
+	UseFFmpeg bool
+	// Quality of JPEGs and compression of PNGs encoded again
+	JpegQuality    int
+	PngCompression string
+	// Command upscaling downloads smaller than the size of their art style
+	UpscaleCmd string
+	// Leave downloads slightly off the size of their art style as they are
+	NoResize bool
+	// Frame rate and number of frames animations are cut down to
+	CapFPS    float64
+	CapFrames int
+	// Largest file written, like 5MB, for every art style or for one
+	MaxFileSize       string
+	MaxFileSizeBanner string
+	MaxFileSizeCover  string
+	MaxFileSizeHero   string
+	MaxFileSizeLogo   string
+	// How far images may be from the aspect ratio of their art style, like
+	// 5%, for every art style or for one
+	AspectTolerance       string
+	AspectToleranceBanner string
+	AspectToleranceCover  string
+	AspectToleranceHero   string
+	// Fill the letterbox bars and the missing sides of static heroes
+	ExtendHeroes bool
+	// Normalize the levels of static heroes, to this average brightness
+	AutoLevels       bool
+	AutoLevelsTarget float64
+}
+
+// Sources each art style can be downloaded from. The library cache has the
+// official artwork Steam already downloaded, itch.io the covers of
+// shortcuts installed by the itch app, IGDB mostly covers, GOG
+// covers and backgrounds of non-Steam games, RAWG backgrounds, TheGamesDB
+// everything but icons of retro games, LaunchBox covers and logos of them,
+// and Google searches are only good for banners.
+var artStyleSources = map[string][]string{
+	"Banner": {"librarycache", "steam", "steamgriddb", "itch", "thegamesdb", "google"},
+	"Cover":  {"librarycache", "steam", "steamgriddb", "itch", "gog", "igdb", "thegamesdb", "launchbox"},
+	"Hero":   {"librarycache", "steam", "steamgriddb", "gog", "rawg", "thegamesdb"},
+	"Logo":   {"librarycache", "steam", "steamgriddb", "thegamesdb", "launchbox"},
+	"Icon":   {"steamgriddb"},
+}
+
+// How each source is recorded in Game.ImageSource and the state file.
+var sourceImageSources = map[string]string{
+	"librarycache": "steam library cache",
+	"steam":        "steam server",
+	"steamgriddb":  "SteamGridDB",
+	"igdb":         "IGDB",
+	"itch":         "itch.io",
+	"gog":          "GOG",
+	"rawg":         "RAWG",
+	"thegamesdb":   "TheGamesDB",
+	"launchbox":    "LaunchBox",
+	"google":       "search",
+}
+
+// Returns whether an image written before has to be downloaded again
+// because of -force-source.
+func (options *Options) forcesSource(entry *stateEntry) bool {
+	return entry != nil && options.ForceSource != "" && entry.Source == sourceImageSources[strings.ToLower(options.ForceSource)]
+}
+
+// Returns the sources to try for an art style, in order: the ones given with
+// -sources-<style>, or those allowed by -skipsteam, -skipgoogle and
+// -steamgriddbonly.
+func (options *Options) sources(artStyle string) []string {
+	custom := map[string]string{
+		"Banner": options.SourcesBanner,
+		"Cover":  options.SourcesCover,
+		"Hero":   options.SourcesHero,
+		"Logo":   options.SourcesLogo,
+		"Icon":   options.SourcesIcon,
+	}[artStyle]
+	if custom != "" {
+		var sources []string
+		for _, source := range strings.Split(custom, ",") {
+			sources = append(sources, strings.ToLower(strings.TrimSpace(source)))
+		}
+		return sources
+	}
+
+	var sources []string
+	for _, source := range artStyleSources[artStyle] {
+		switch {
+		case (source == "librarycache" || source == "steam") && (options.SkipSteam || options.SteamGridDBOnly):
+		case source == "google" && (options.SkipGoogle || options.SteamGridDBOnly):
+		case (source == "igdb" || source == "itch" || source == "gog" || source == "rawg" || source == "thegamesdb" || source == "launchbox") && options.SteamGridDBOnly:
+		default:
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// ArtStyles returns the art styles to process, with the SteamGridDB filters
+// built from the options and without the skipped styles.
+func (options *Options) ArtStyles() (map[string][]string, error) {
+	artStyles := MakeArtStyles(
+		steamGridDBFilter(options.Styles, options.Types, options.Nsfw, options.Humor, options.BannerDimensions),
+		steamGridDBFilter(options.Styles, options.Types, options.Nsfw, options.Humor, options.CoverDimensions),
+		steamGridDBFilter(options.HeroStyles, options.Types, options.Nsfw, options.Humor, options.HeroDimensions),
+		steamGridDBFilter(options.LogoStyles, options.Types, options.Nsfw, options.Humor, ""),
+		steamGridDBFilter(options.IconStyles, options.Types, options.Nsfw, options.Humor, ""),
+	)
+
+	if options.SkipBanner {
+		delete(artStyles, "Banner")
+	}
+	if options.SkipCover {
+		delete(artStyles, "Cover")
+	}
+	if options.SkipHero {
+		delete(artStyles, "Hero")
+	}
+	if options.SkipLogo {
+		delete(artStyles, "Logo")
+	}
+	if options.SkipIcon {
+		delete(artStyles, "Icon")
+	}
+	if len(artStyles) == 0 {
+		return nil, errors.New("no artStyles, nothing to do…")
+	}
+
+	if _, ok := logoPinnedPositions[strings.ToLower(options.LogoPosition)]; options.LogoPosition != "" && options.LogoPosition != "none" && !ok {
+		return nil, fmt.Errorf("unknown logo position %v, expected one of bottomleft, upperleft, centercenter, uppercenter, bottomcenter, none", options.LogoPosition)
+	}
+	if options.OverlayMode != "" && !containsString(overlay.OverlayModes, strings.ToLower(options.OverlayMode)) {
+		return nil, fmt.Errorf("unknown overlay mode %v, expected one of %v", options.OverlayMode, strings.Join(overlay.OverlayModes, ", "))
+	}
+	if options.OverlayAnchor != "" && !containsString(overlay.OverlayAnchors, strings.ToLower(options.OverlayAnchor)) {
+		return nil, fmt.Errorf("unknown overlay anchor %v, expected one of %v", options.OverlayAnchor, strings.Join(overlay.OverlayAnchors, ", "))
+	}
+	if _, err := overlay.ParseByteSize(options.MaxMem); options.MaxMem != "" && err != nil {
+		return nil, errors.New("-maxmem: " + err.Error())
+	}
+	for name, size := range map[string]string{"": options.MaxFileSize, "-banner": options.MaxFileSizeBanner, "-cover": options.MaxFileSizeCover, "-hero": options.MaxFileSizeHero, "-logo": options.MaxFileSizeLogo} {
+		if _, err := overlay.ParseByteSize(size); size != "" && err != nil {
+			return nil, errors.New("-maxfilesize" + name + ": " + err.Error())
+		}
+	}
+	if options.Watch && options.WatchInterval <= 0 {
+		return nil, errors.New("-watchinterval must be above 0")
+	}
+	if err := checkUpscaleCommand(options.UpscaleCmd); options.UpscaleCmd != "" && err != nil {
+		return nil, errors.New("-upscalecmd: " + err.Error())
+	}
+	for name, tolerance := range map[string]string{"": options.AspectTolerance, "-banner": options.AspectToleranceBanner, "-cover": options.AspectToleranceCover, "-hero": options.AspectToleranceHero} {
+		if _, err := parseAspectTolerance(tolerance); tolerance != "" && err != nil {
+			return nil, errors.New("-aspecttolerance" + name + ": " + err.Error())
+		}
+	}
+	for _, appType := range options.includedAppTypes() {
+		if appType != "" && !containsString(NonGameAppTypes, appType) {
+			return nil, fmt.Errorf("unknown app type %v, expected some of %v or all", appType, strings.Join(NonGameAppTypes, ", "))
+		}
+	}
+	if options.OpaqueLogos != "" && !containsString(opaqueLogoModes, strings.ToLower(options.OpaqueLogos)) {
+		return nil, fmt.Errorf("unknown mode %v for opaque logos, expected one of %v", options.OpaqueLogos, strings.Join(opaqueLogoModes, ", "))
+	}
+	if options.NotInstalled != "" && !containsString(notInstalledEffects, strings.ToLower(options.NotInstalled)) {
+		return nil, fmt.Errorf("unknown effect %v for games not installed, expected one of %v", options.NotInstalled, strings.Join(notInstalledEffects, ", "))
+	}
+	if options.ProtonDB && !containsString(badgeCorners, strings.ToLower(options.ProtonDBCorner)) {
+		return nil, fmt.Errorf("unknown ProtonDB corner %v, expected one of topleft, topright, bottomleft, bottomright", options.ProtonDBCorner)
+	}
+	if options.DeckBadge && !containsString(badgeCorners, strings.ToLower(options.DeckBadgeCorner)) {
+		return nil, fmt.Errorf("unknown Steam Deck compatibility corner %v, expected one of topleft, topright, bottomleft, bottomright", options.DeckBadgeCorner)
+	}
+	if _, ok := sources.ImageSearchProviders[strings.ToLower(options.SearchProvider)]; options.SearchProvider != "" && !ok {
+		return nil, fmt.Errorf("unknown search provider %v, expected one of google, bing, duckduckgo, searxng", options.SearchProvider)
+	}
+	if strings.EqualFold(options.SearchProvider, "bing") && options.BingApiKey == "" {
+		return nil, errors.New("-searchprovider bing needs a Bing Image Search API key, given with -bingkey")
+	}
+	if strings.EqualFold(options.SearchProvider, "searxng") && options.SearXNG == "" {
+		return nil, errors.New("-searchprovider searxng needs the URL of an instance, given with -searxng")
+	}
+	if _, ok := options.languageCode(); !ok {
+		return nil, fmt.Errorf("unknown language %v, expected an ISO code like ja or zh-tw, or Steam's name of a language like japanese", options.Language)
+	}
+	if _, ok := sourceImageSources[strings.ToLower(options.ForceSource)]; options.ForceSource != "" && !ok {
+		return nil, fmt.Errorf("unknown source %v for -force-source, expected one of librarycache, steam, steamgriddb, itch, gog, igdb, rawg, thegamesdb, launchbox, google", options.ForceSource)
+	}
+	for artStyle := range artStyles {
+		for _, source := range options.sources(artStyle) {
+			if !containsString(artStyleSources[artStyle], source) {
+				return nil, fmt.Errorf("unknown source %v for %v, expected one of %v", source, strings.ToLower(artStyle), strings.Join(artStyleSources[artStyle], ", "))
+			}
+		}
+	}
+	return artStyles, nil
+}
+
+// Returns where and how overlays are put on the images.
+func (options *Options) overlayPlacement() overlay.OverlayPlacement {
+	placement := overlay.OverlayPlacement{Mode: strings.ToLower(options.OverlayMode), Anchor: options.OverlayAnchor, Margin: options.OverlayMargin, Scale: options.OverlayScale, Opacity: options.OverlayOpacity}
+	if placement.Opacity <= 0 || placement.Opacity > 100 {
+		placement.Opacity = 100
+	}
+	return placement
+}
+
+// Quality of the JPEGs encoded again when -jpegquality isn't given, or by
+// commands without it, like download resizing images.
+const DefaultJpegQuality = 95
+
+// Returns how still images are encoded again, checked when parsing the flags.
+func (options *Options) stillEncoding() overlay.StillEncoding {
+	quality := options.JpegQuality
+	if quality == 0 {
+		quality = DefaultJpegQuality
+	}
+	return overlay.StillEncoding{JpegQuality: quality, PNGCompression: overlay.PNGCompressionLevels[strings.ToLower(options.PngCompression)]}
+}
+
+// GameFilter returns which games of the library flags are processed, besides
+// the excluded ones, parsed from -excludeappids.
+func (options *Options) GameFilter(excluded appIDSet) GameFilter {
+	return GameFilter{
+		appIDs:         options.AppIDs,
+		excluded:       excluded,
+		nonSteamOnly:   options.NonSteamOnly,
+		installedOnly:  options.InstalledOnly,
+		skipCategory:   options.SkipCategory,
+		IncludePrivate: options.IncludePrivate,
+		IncludeHidden:  options.IncludeHidden,
+		IncludeShared:  options.IncludeShared,
+	}
+}
+
+// Returns the text badge to draw, checking its colors, or nil for none.
+func (options *Options) textBadge() (*textBadge, error) {
+	if options.TextBadge == "" {
+		return nil, nil
+	}
+	corner := strings.ToLower(options.TextBadgeCorner)
+	if !containsString(badgeCorners, corner) {
+		return nil, fmt.Errorf("unknown text badge corner %v, expected one of topleft, topright, bottomleft, bottomright", options.TextBadgeCorner)
+	}
+	background, err := parseHexColor(options.TextBadgeBackground)
+	if err != nil {
+		return nil, err
+	}
+	textColor, err := parseHexColor(options.TextBadgeColor)
+	if err != nil {
+		return nil, err
+	}
+	return &textBadge{options.TextBadge, corner, options.TextBadgeSize, background, textColor}, nil
+}
+
+// Tells if animations of an art style are converted to APNG, with
+// -webpasapng, or -coverwebpasapng for covers and banners.
+func (options *Options) convertsToApng(artStyle string) bool {
+	return options.ConvertWebpToApng || (options.ConvertWebpToApngCoversBanners && (artStyle == "Cover" || artStyle == "Banner"))
+}
+
+// Tells if conversions to APNG are left to ffmpeg.
+func (options *Options) usesFFmpeg() bool {
+	return options.UseFFmpeg && overlay.HasFFmpeg()
+}
+
+// Returns the memory limit for WEBP to APNG conversions in bytes, 0 meaning
+// no limit. Without -convertmaxmem, conversions get half of -maxmem.
+func (options *Options) maxConvertMemory() uint64 {
+	if options.MaxMemoryForConvert > 0 {
+		return uint64(options.MaxMemoryForConvert) * 1024 * 1024 * 1024
+	}
+	return options.maxMemory() / 2
+}
+
+// Returns the largest file size of an art style in bytes, from
+// -maxfilesize-<style> or else -maxfilesize, 0 meaning no limit.
+func (options *Options) maxFileSize(artStyle string) uint64 {
+	size := map[string]string{
+		"Banner": options.MaxFileSizeBanner,
+		"Cover":  options.MaxFileSizeCover,
+		"Hero":   options.MaxFileSizeHero,
+		"Logo":   options.MaxFileSizeLogo,
+	}[artStyle]
+	if size == "" {
+		size = options.MaxFileSize
+	}
+	limit, _ := overlay.ParseByteSize(size)
+	return limit
+}
+
+// Returns the -maxmem limit for the whole run in bytes, 0 meaning no limit.
+// The size is checked with the art styles.
+func (options *Options) maxMemory() uint64 {
+	if options.MaxMem == "" {
+		return 0
+	}
+	limit, _ := overlay.ParseByteSize(options.MaxMem)
+	return limit
+}
+
+// Returns the ISO code of -language, given as a code or as Steam's name of
+// the language, and whether it's known.
+func (options *Options) languageCode() (string, bool) {
+	language := strings.ToLower(strings.Replace(options.Language, "_", "-", -1))
+	if _, ok := sources.SteamLanguages[language]; ok {
+		return language, true
+	}
+	for code, name := range sources.SteamLanguages {
+		if name != "" && name == language {
+			return code, true
+		}
+	}
+	return "", language == ""
+}
+
+// Returns Steam's name of -language, or "" for English or none.
+func (options *Options) steamLanguage() string {
+	code, _ := options.languageCode()
+	return sources.SteamLanguages[code]
+}
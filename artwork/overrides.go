@@ -0,0 +1,80 @@
+package artwork
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Name of the overrides file looked for next to the executable.
+const nameOverridesFileName = "name-overrides.json"
+
+// Overrides loaded in this run, by file, so a broken file is only reported
+// once.
+var loadedNameOverrides = make(map[string]map[string]sources.NameOverride)
+
+// ApplyNameOverride fills in how to search for a game from the name overrides
+// file, the -nameoverrides file or name-overrides.json next to the executable.
+// Games are found by appID, or by name for shortcuts whose ID changes:
+// {"3000000001": "Final Fantasy VII Remake", "FF7R INTERGRADE": {"steamGridDBGameId": 5256}}
+// Shortcuts of Lutris games are searched by their name in Lutris, and ROMs of
+// emulators by their title in the RetroArch or EmulationStation playlists
+// they're in. With -apps,
+// shortcuts of known applications are searched by their name on
+// SteamGridDB. Other names are looked up in the built-in aliases, and ROM
+// names of shortcuts are cleaned up.
+func ApplyNameOverride(game *Game, options *Options) {
+	path := options.NameOverrides
+	if path == "" {
+		path = filepath.Join(filepath.Dir(os.Args[0]), nameOverridesFileName)
+	}
+	overrides, ok := loadedNameOverrides[path]
+	if !ok {
+		overridesBytes, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(overridesBytes, &overrides)
+		}
+		if err != nil && (options.NameOverrides != "" || !os.IsNotExist(err)) {
+			fmt.Println("Could not load the name overrides: " + err.Error())
+		}
+		loadedNameOverrides[path] = overrides
+	}
+
+	override, ok := overrides[game.ID]
+	if !ok {
+		for key, value := range overrides {
+			if game.Name != "" && strings.EqualFold(key, game.Name) {
+				override, ok = value, true
+				break
+			}
+		}
+	}
+	if !ok && game.LutrisGame != "" {
+		if name := lutrisSearchName(game.LutrisGame); name != "" {
+			override, ok = sources.NameOverride{Name: name}, true
+		}
+	}
+	if !ok && game.Target != "" {
+		if title := playlistTitle(game.Target, options); title != "" {
+			override, ok = sources.NameOverride{Name: title}, true
+		}
+	}
+	if !ok && options.Apps && game.ApplicationName != "" {
+		override, ok = sources.NameOverride{Name: game.ApplicationName}, true
+	}
+	if !ok && game.Name != "" {
+		override, ok = sources.LookupAlias(game.Name)
+	}
+	if ok {
+		game.SearchName = override.Name
+		game.SteamGridDBGameID = override.SteamGridDBGameID
+		game.IGDBGameID = override.IGDBGameID
+	} else if cleaned := sources.CleanGameName(game.Name); game.Custom && cleaned != game.Name {
+		game.SearchName = cleaned
+	}
+}
@@ -0,0 +1,208 @@
+package artwork
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Name of the manifest at the root of an artwork pack.
+const PackManifestName = "steamgrid-pack.json"
+
+// Manifest of a community artwork pack:
+//
+//	{"name": "Pixel art", "games": {"440": {"cover": "tf2/cover.png", "hero": "tf2/hero.png"}}}
+//
+// Paths are relative to the manifest and art styles are matched ignoring
+// case.
+type PackManifest struct {
+	Name  string                       `json:"name"`
+	Games map[string]map[string]string `json:"games"`
+}
+
+// A pack loaded from a directory or a zip file.
+type artPack struct {
+	manifest PackManifest
+	// Reads a file of the pack, by its path relative to the manifest.
+	read func(name string) ([]byte, error)
+}
+
+// Packs already loaded in this run, by location. Nil for packs that failed to
+// load, so they are only reported once.
+var loadedPacks = make(map[string]*artPack)
+
+// GitHub repositories are downloaded as the zip of their default branch.
+var gitHubRepoPattern = regexp.MustCompile(`^https://github\.com/([^/]+)/([^/]+?)(\.git)?/?$`)
+
+// Returns the zip URL of a pack given as a GitHub repository or a zip URL.
+func packZipURL(location string) string {
+	if match := gitHubRepoPattern.FindStringSubmatch(location); match != nil {
+		return fmt.Sprintf("https://github.com/%v/%v/archive/HEAD.zip", match[1], match[2])
+	}
+	return location
+}
+
+// Downloads a pack into the packs/ directory next to the executable, unless
+// it was downloaded before. Delete the file to get a newer version.
+func downloadPack(location string) (string, error) {
+	hash := sha256.Sum256([]byte(location))
+	packPath := filepath.Join(filepath.Dir(os.Args[0]), "packs", hex.EncodeToString(hash[:8])+".zip")
+	if _, err := os.Stat(packPath); err == nil {
+		return packPath, nil
+	}
+
+	fmt.Println("Downloading artwork pack " + location)
+	response, err := sources.TryDownload(packZipURL(location))
+	if err != nil {
+		return "", err
+	} else if response == nil {
+		return "", errors.New("pack " + location + " not found")
+	}
+	defer response.Body.Close()
+	packBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(filepath.Dir(packPath), 0777)
+	if err != nil {
+		return "", err
+	}
+	return packPath, ioutil.WriteFile(packPath, packBytes, 0666)
+}
+
+// Opens a pack from a local directory, a local zip file, a zip URL or a GitHub
+// repository.
+func openPack(location string) (*artPack, error) {
+	packPath := location
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		var err error
+		packPath, err = downloadPack(location)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := os.Stat(packPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &artPack{}
+	var manifestBytes []byte
+	if info.IsDir() {
+		manifestBytes, err = ioutil.ReadFile(filepath.Join(packPath, PackManifestName))
+		if err != nil {
+			return nil, err
+		}
+		pack.read = func(name string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(packPath, filepath.FromSlash(name)))
+		}
+	} else {
+		// Kept open for the whole run, images are read as games need them.
+		archive, err := zip.OpenReader(packPath)
+		if err != nil {
+			return nil, err
+		}
+		files := make(map[string]*zip.File)
+		var manifest *zip.File
+		for _, file := range archive.File {
+			files[file.Name] = file
+			// Zips of GitHub repositories have everything in a top directory.
+			if path.Base(file.Name) == PackManifestName && (manifest == nil || len(file.Name) < len(manifest.Name)) {
+				manifest = file
+			}
+		}
+		if manifest != nil {
+			manifestBytes, err = readZipFile(manifest)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if manifestBytes == nil {
+			return nil, errors.New(PackManifestName + " not found in " + location)
+		}
+		manifestDir := path.Dir(manifest.Name)
+		pack.read = func(name string) ([]byte, error) {
+			file, ok := files[path.Join(manifestDir, name)]
+			if !ok {
+				return nil, errors.New(name + " not found in " + location)
+			}
+			return readZipFile(file)
+		}
+	}
+
+	err = json.Unmarshal(manifestBytes, &pack.manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v in %v: %v", PackManifestName, location, err)
+	}
+	if pack.manifest.Name == "" {
+		pack.manifest.Name = location
+	}
+	return pack, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// Looks for an image of the game in the packs given with -packs, in order,
+// loading them the first time. Packs rank above every online source. Returns
+// true when the image was found, with the game fields set as for a download.
+func loadPackImage(game *Game, artStyle string, options *Options) bool {
+	if options.Packs == "" {
+		return false
+	}
+
+	for _, location := range strings.Split(options.Packs, ",") {
+		location = strings.TrimSpace(location)
+		pack, ok := loadedPacks[location]
+		if !ok {
+			var err error
+			pack, err = openPack(location)
+			if err != nil {
+				fmt.Println("Could not load artwork pack: " + err.Error())
+			}
+			loadedPacks[location] = pack
+		}
+		if pack == nil {
+			continue
+		}
+
+		for style, name := range pack.manifest.Games[game.ID] {
+			if !strings.EqualFold(style, artStyle) {
+				continue
+			}
+			imageBytes, err := pack.read(name)
+			if err != nil {
+				fmt.Println(err.Error())
+				continue
+			}
+			game.ImageSource = "pack " + pack.manifest.Name
+			game.ImageExt = strings.ToLower(path.Ext(name))
+			if game.ImageExt == ".jpeg" {
+				// The new library ignores .jpeg
+				game.ImageExt = ".jpg"
+			}
+			game.CleanImageBytes = imageBytes
+			return true
+		}
+	}
+	return false
+}
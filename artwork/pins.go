@@ -0,0 +1,107 @@
+package artwork
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// SteamGridDB assets picked for games, by appID and art style, from a file
+// like {"440": {"cover": 12345, "hero": 6789}}.
+type pinnedAssets map[string]map[string]int
+
+// Name of the pins file looked for next to the executable.
+const pinsFileName = "pins.json"
+
+// Pins loaded in this run, by file, so a broken file is only reported once.
+var loadedPins = make(map[string]pinnedAssets)
+
+// Returns the pins file: the -pins file, or pins.json next to the executable.
+func pinsPath(options *Options) string {
+	if options.Pins != "" {
+		return options.Pins
+	}
+	return filepath.Join(filepath.Dir(os.Args[0]), pinsFileName)
+}
+
+// Returns the SteamGridDB asset pinned to an image of a game, or 0.
+func pinnedSteamGridDBID(game *Game, artStyle string, options *Options) int {
+	path := pinsPath(options)
+	pins, ok := loadedPins[path]
+	if !ok {
+		pinsBytes, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(pinsBytes, &pins)
+		}
+		if err != nil && (options.Pins != "" || !os.IsNotExist(err)) {
+			fmt.Println("Could not load the pinned SteamGridDB assets: " + err.Error())
+		}
+		loadedPins[path] = pins
+	}
+
+	for style, id := range pins[game.ID] {
+		if strings.EqualFold(style, artStyle) {
+			return id
+		}
+	}
+	return 0
+}
+
+// Returns the URL of a pinned SteamGridDB asset, found among all the images
+// of the game, whatever the filters.
+func getPinnedSteamGridDBImage(game *Game, artStyleExtensions []string, id int, options *Options) (string, error) {
+	unfiltered := []string{artStyleExtensions[0], artStyleExtensions[1], artStyleExtensions[2], "?types=static,animated&nsfw=any&humor=any"}
+	images, err := sources.GetSteamGridDBImages(&game.Game, unfiltered, options.SteamGridDBApiKey, 0)
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range images {
+		if candidate.ID == id {
+			return candidate.URL, nil
+		}
+	}
+	return "", fmt.Errorf("pinned SteamGridDB asset %v is not among the images of %v", id, game.Name)
+}
+
+// PinSteamGridDBAsset pins a SteamGridDB asset to an image of a game in the
+// pins file, in place of the one pinned before.
+func PinSteamGridDBAsset(gameID string, artStyle string, id int, options *Options) error {
+	path := pinsPath(options)
+	pins := pinnedAssets{}
+	pinsBytes, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(pinsBytes, &pins)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return errors.New("could not load the pinned SteamGridDB assets: " + err.Error())
+	}
+
+	styles := pins[gameID]
+	if styles == nil {
+		styles = map[string]int{}
+		pins[gameID] = styles
+	}
+	for style := range styles {
+		if strings.EqualFold(style, artStyle) {
+			delete(styles, style)
+		}
+	}
+	styles[strings.ToLower(artStyle)] = id
+
+	pinsBytes, err = json.MarshalIndent(pins, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, pinsBytes, 0666)
+	if err != nil {
+		return err
+	}
+	loadedPins[path] = pins
+	return nil
+}
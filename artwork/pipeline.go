@@ -0,0 +1,958 @@
+// Package artwork downloads the artwork of the games of a Steam library and
+// applies the overlays, as the steamgrid command does. A Pipeline runs through
+// the libraries of the users of a Steam installation, or works on one game;
+// the rest is what it works with: the games, the grid directories with their
+// backups and state, and the options.
+package artwork
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// Default SteamGridDB dimension filters for each art style.
+const (
+	DefaultBannerDimensions = "460x215,920x430"
+	DefaultCoverDimensions  = "600x900,342x482,660x930"
+	DefaultHeroDimensions   = "1920x620,3840x1240,1600x650"
+)
+
+// Builds the SteamGridDB query string for one art style. Logos have no
+// dimension filter, so an empty dimensions value is left out of the query.
+func steamGridDBFilter(styles string, types string, nsfw string, humor string, dimensions string) string {
+	filter := "?styles=" + styles + "&types=" + types + "&nsfw=" + nsfw + "&humor=" + humor
+	if dimensions != "" {
+		filter += "&dimensions=" + dimensions
+	}
+	return filter
+}
+
+// MakeArtStyles returns the table of supported art styles with their file name
+// extensions, official Steam asset names and SteamGridDB filters.
+func MakeArtStyles(bannerFilter string, coverFilter string, heroFilter string, logoFilter string, iconFilter string) map[string][]string {
+	return map[string][]string{
+		// artStyle: ["idExtension", "nameExtension", steamUrlExtension, steamGridDbFilter]
+		"Banner": {"", ".banner", "header.jpg", bannerFilter},
+		"Cover":  {"p", ".cover", "library_600x900_2x.jpg", coverFilter},
+		"Hero":   {"_hero", ".hero", "library_hero.jpg", heroFilter},
+		"Logo":   {"_logo", ".logo", "logo.png", logoFilter},
+		// Steam has no fixed name for icons, they only come from SteamGridDB.
+		"Icon": {"_icon", ".icon", "", iconFilter},
+	}
+}
+
+// OpenInstallation finds the Steam installation of the options, locks it for
+// this run and loads its users. Fails when there is nobody to work for.
+func OpenInstallation(options *Options) ([]User, error) {
+	fmt.Println("Looking for Steam directory...\nIf SteamGrid doesn´t find the directory automatically, launch it with an argument linking to the Steam directory.")
+	installationDir, err := steam.GetSteamInstallation(options.SteamDir)
+	if err != nil {
+		return nil, err
+	}
+	SteamInstallationDir = installationDir
+
+	err = LockInstallation(installationDir, options.Wait)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("Loading users...")
+	users, err := steam.GetUsers(installationDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errors.New("no users found at Steam/userdata. Have you used Steam before in this computer, or is this the right userdata copy?")
+	}
+	return users, nil
+}
+
+// ResolveGameName fills in the name of a game if it's missing, returning the
+// name to show the user.
+func ResolveGameName(game *Game) string {
+	if game.Name == "" {
+		game.Name = GetGameName(game.ID)
+	}
+
+	if game.Name != "" {
+		return game.Name
+	}
+	return "unknown game with id " + game.ID
+}
+
+// Games found in each source, and the ones that failed, grouped by art style
+// for the summary at the end of a run.
+type runSummary struct {
+	nOverlaysApplied int
+	nUnchanged       int
+	nDownloaded      int
+	nLeaked          int
+	notFounds        map[string][]*Game
+	steamGridDB      map[string][]*Game
+	IGDB             map[string][]*Game
+	searchedGames    map[string][]*Game
+	failedGames      map[string][]*Game
+	errorMessages    []string
+	// For the -report file, with the user being processed.
+	started time.Time
+	user    string
+	// Images of the user to download when SteamGridDB is back.
+	retry   *retryQueue
+	entries []*ReportEntry
+	lint    []*lintFinding
+}
+
+func newGamesByArtStyle() map[string][]*Game {
+	return map[string][]*Game{
+		"Banner": {},
+		"Cover":  {},
+		"Hero":   {},
+		"Logo":   {},
+		"Icon":   {},
+	}
+}
+
+func newRunSummary() *runSummary {
+	return &runSummary{
+		notFounds:     newGamesByArtStyle(),
+		steamGridDB:   newGamesByArtStyle(),
+		IGDB:          newGamesByArtStyle(),
+		searchedGames: newGamesByArtStyle(),
+		failedGames:   newGamesByArtStyle(),
+		started:       time.Now(),
+	}
+}
+
+// Pipeline downloads the missing artwork of the games of a Steam
+// installation and applies the overlays, badges and effects to it, as set up
+// by its options. Run goes through every game of every user, and ProcessGame
+// through a single game, for interfaces that work game by game.
+type Pipeline struct {
+	options       *Options
+	download      bool
+	applyOverlays bool
+	artStyles     map[string][]string
+	overlays      map[string]*overlay.CategoryOverlay
+	rules         []overlayRule
+	excluded      appIDSet
+}
+
+// NewPipeline checks the options and loads the overlays and overlay rules. A
+// pipeline that doesn't download only works on the artwork already there, and
+// one that doesn't apply overlays leaves the existing images as they are.
+func NewPipeline(options *Options, download bool, applyOverlays bool) (*Pipeline, error) {
+	artStyles, err := options.ArtStyles()
+	if err != nil {
+		return nil, err
+	}
+
+	if download && options.SkipSteam && options.OnlyMissingArtwork {
+		return nil, errors.New("can't check if official artwork is missing with steam turned off")
+	}
+
+	overlay.ApplyMemoryLimit(options.maxMemory())
+
+	pipeline := &Pipeline{options: options, download: download, applyOverlays: applyOverlays, artStyles: artStyles, overlays: map[string]*overlay.CategoryOverlay{}}
+	if applyOverlays {
+		overlay.PlacedOverlayDir = filepath.Join(sources.CacheDir(), "overlays")
+		fmt.Println("Loading overlays...")
+		pipeline.overlays, err = overlay.LoadOverlays(filepath.Join(filepath.Dir(os.Args[0]), "overlays by category"), artStyles)
+		if err != nil {
+			return nil, err
+		}
+		overlay.PrescaleOverlays(pipeline.overlays, artStyles, options.overlayPlacement())
+		if len(pipeline.overlays) == 0 {
+			fmt.Println("No category overlays found. You can put overlay images in the folder 'overlays by category', where the filename is the game category.\n\nYou can find many user-created overlays at https://www.reddit.com/r/steamgrid/wiki/overlays .\n\nContinuing without overlays...")
+		} else {
+			fmt.Printf("Loaded %v overlays. \n\nYou can find many user-created overlays at https://www.reddit.com/r/steamgrid/wiki/overlays .\n\n", len(pipeline.overlays))
+		}
+
+		pipeline.rules, err = loadOverlayRules(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pipeline.excluded, err = ParseAppIDSet(options.ExcludeAppIDs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := options.textBadge(); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// ArtStyles returns the art styles the pipeline works on, with their file
+// name extensions, official Steam asset names and SteamGridDB filters.
+func (pipeline *Pipeline) ArtStyles() map[string][]string {
+	return pipeline.artStyles
+}
+
+// Run goes through every game of every user, downloading the missing artwork
+// and/or applying the overlays, and prints a summary at the end. The
+// installation is locked while it runs. After Stop, it ends once the image
+// being processed is done, and the next run with Options.Resume continues
+// where it stopped. Returns an error when it can't go on, like when the
+// originals can't be backed up.
+func (pipeline *Pipeline) Run() error {
+	options, artStyles, overlays, rules, download, applyOverlays := pipeline.options, pipeline.artStyles, pipeline.overlays, pipeline.rules, pipeline.download, pipeline.applyOverlays
+	users, err := OpenInstallation(options)
+	if err != nil {
+		return err
+	}
+	defer UnlockInstallation()
+	summary := newRunSummary()
+	progress := loadRunProgress(SteamInstallationDir, options.Resume)
+
+	// Conversions at once would each take the memory -maxmem leaves for one.
+	workers := options.ConvertWorkers
+	if options.maxMemory() > 0 {
+		workers = 0
+	}
+	queue := newConversionQueue(workers)
+
+	for _, user := range users {
+		if Stopping() {
+			break
+		} else if progress.userDone(user) {
+			fmt.Println("Skipping " + user.Name + ", done before the run was interrupted")
+			continue
+		}
+		fmt.Println("Loading games for " + user.Name)
+		gridDir := filepath.Join(user.Dir, "config", "grid")
+		summary.user = user.Name
+
+		err = os.MkdirAll(filepath.Join(gridDir, "originals"), 0777)
+		if err != nil {
+			queue.close()
+			return err
+		}
+
+		if options.NormalizeNames {
+			nRenamed, err := normalizeShortcutNames(user)
+			if err != nil {
+				fmt.Println("Could not tidy the names of non-Steam games: " + err.Error())
+			} else if nRenamed > 0 {
+				fmt.Printf("Tidied the names of %v non-Steam games, restart Steam to see them.\n", nRenamed)
+			}
+		}
+		games := GetGames(user, options.GameFilter(pipeline.excluded), options.SteamApiKey)
+		if nSkipped := skipNonGames(games, options); nSkipped > 0 {
+			fmt.Printf("Skipped %v DLC, soundtracks, tools and other apps that aren't games, use -includetypes to keep them.\n", nSkipped)
+		}
+		if applyOverlays && options.NotInstalled != "" {
+			markNotInstalled(user, games)
+		}
+		if len(rules) > 0 {
+			fmt.Println("Matching overlay rules...")
+			applyOverlayRules(rules, games)
+		}
+
+		fmt.Println("Loading existing images and backups...")
+		state, err := LoadGridState(gridDir)
+		if err != nil {
+			// Going on would overwrite locked artwork and lose the locks.
+			fmt.Println("Skipping " + user.Name + ", could not read " + filepath.Join(gridDir, StateFileName) + ", fix or delete it: " + err.Error())
+			continue
+		}
+		// With -appids, every other game would look removed.
+		if options.AppIDs == "" {
+			relinkShortcuts(user, gridDir, state, games)
+		}
+
+		summary.retry, err = LoadRetryQueue(gridDir)
+		if err != nil {
+			fmt.Println("Could not read " + RetryQueueFileName + ", starting a new one: " + err.Error())
+		}
+		if options.RetryQueue {
+			for gameID := range games {
+				if !summary.retry.containsGame(gameID) {
+					delete(games, gameID)
+				}
+			}
+		}
+
+		i := 0
+		for _, game := range games {
+			i++
+			if Stopping() {
+				break
+			} else if progress.gameDone(user, game.ID) {
+				continue
+			}
+
+			name := ResolveGameName(game)
+			if len(options.NameFilter) > 0 && !strings.Contains(name, options.NameFilter) {
+				continue
+			}
+
+			fmt.Printf("Processing %v (%v/%v)\n", name, i, len(games))
+
+			for artStyle, artStyleExtensions := range artStyles {
+				if Stopping() {
+					break
+				}
+				// Steam only shows custom icons for non-Steam games.
+				if artStyle == "Icon" && !game.Custom {
+					continue
+				}
+				if options.RetryQueue && !summary.retry.contains(game.ID, artStyle) {
+					continue
+				}
+				// Queued again if SteamGridDB is still down.
+				if download {
+					summary.retry.remove(game.ID, artStyle)
+				}
+				err = processGameImage(options, gridDir, state, game, artStyle, artStyleExtensions, overlays, download, applyOverlays, summary, queue)
+				if err != nil {
+					queue.close()
+					return err
+				}
+			}
+			// Conversions in the background still use theirs.
+			if queue.idle() {
+				summary.nLeaked += overlay.ReleaseLeakedWebpResources()
+			}
+			// Games stopped halfway are processed again with -resume.
+			if !Stopping() {
+				progress.addGame(user, game.ID)
+			}
+		}
+		err = queue.flush(true)
+		if err != nil {
+			queue.close()
+			return err
+		}
+		summary.nLeaked += overlay.ReleaseLeakedWebpResources()
+
+		err = state.Save()
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		err = summary.retry.save()
+		if err != nil {
+			fmt.Println(err.Error())
+		} else if len(summary.retry.Items) > 0 {
+			fmt.Printf("%v images left for when SteamGridDB is back, run again with -retryqueue to download only those.\n", len(summary.retry.Items))
+		}
+		if _, ok := artStyles["Icon"]; ok {
+			nChanged, err := updateShortcutIcons(user, gridDir, state)
+			if err != nil {
+				fmt.Println("Could not set the icons of non-Steam games: " + err.Error())
+			} else if nChanged > 0 {
+				fmt.Printf("Set the icons of %v non-Steam games, restart Steam to see them.\n", nChanged)
+			}
+		}
+
+		if options.Lint {
+			summary.lint = append(summary.lint, LintGrid(user, gridDir, games, artStyles, options)...)
+		}
+		if !Stopping() {
+			progress.addUser(user)
+		}
+	}
+
+	err = queue.close()
+	if err != nil {
+		return err
+	}
+	err = progress.save(Stopping())
+	if err != nil {
+		fmt.Println("Could not write " + resumeFileName + ": " + err.Error())
+	}
+
+	summary.print()
+	if options.Lint {
+		PrintLintFindings(summary.lint)
+	}
+
+	if options.ReportPath != "" {
+		err = summary.writeReport(options.ReportPath)
+		if err != nil {
+			fmt.Println("Could not write the report: " + err.Error())
+		}
+	}
+
+	if options.ShareMatchesURL != "" {
+		nShared, err := summary.shareMatches(options.ShareMatchesURL)
+		if err != nil {
+			fmt.Println("Could not share the matches: " + err.Error())
+		} else {
+			fmt.Printf("Shared %v matches with %v, thanks!\n", nShared, options.ShareMatchesURL)
+		}
+	}
+	if Stopping() {
+		fmt.Println("Run interrupted, run again with -resume to continue where it stopped.")
+	}
+	return nil
+}
+
+// Games returns the games of a user that Run works on, as selected by the
+// library options.
+func (pipeline *Pipeline) Games(user User) map[string]*Game {
+	games := GetGames(user, pipeline.options.GameFilter(pipeline.excluded), pipeline.options.SteamApiKey)
+	skipNonGames(games, pipeline.options)
+	return games
+}
+
+// ProcessGame works on the artwork of a game of a user in some art styles, as
+// Run would, downloading it again when forced. Returns what happened to each
+// image. Unlike Run, it doesn't lock the installation.
+func (pipeline *Pipeline) ProcessGame(user User, game *Game, artStyles []string, force bool) ([]*ReportEntry, error) {
+	games := map[string]*Game{game.ID: game}
+	options := *pipeline.options
+	options.Force = options.Force || force
+	if pipeline.applyOverlays && options.NotInstalled != "" {
+		markNotInstalled(user, games)
+	}
+	if len(pipeline.rules) > 0 {
+		applyOverlayRules(pipeline.rules, games)
+	}
+
+	gridDir := filepath.Join(user.Dir, "config", "grid")
+	err := os.MkdirAll(filepath.Join(gridDir, "originals"), 0777)
+	if err != nil {
+		return nil, err
+	}
+	state, err := LoadGridState(gridDir)
+	if err != nil {
+		return nil, errors.New("could not read " + StateFileName + ", fix or delete it: " + err.Error())
+	}
+	summary := newRunSummary()
+	summary.user = user.Name
+	summary.retry, err = LoadRetryQueue(gridDir)
+	if err != nil {
+		fmt.Println("Could not read " + RetryQueueFileName + ", starting a new one: " + err.Error())
+	}
+
+	fmt.Printf("Processing %v for %v\n", ResolveGameName(game), user.Name)
+	queue := newConversionQueue(0)
+	for _, artStyle := range artStyles {
+		if artStyle == "Icon" && !game.Custom {
+			continue
+		}
+		if pipeline.download {
+			summary.retry.remove(game.ID, artStyle)
+		}
+		err = processGameImage(&options, gridDir, state, game, artStyle, pipeline.artStyles[artStyle], pipeline.overlays, pipeline.download, pipeline.applyOverlays, summary, queue)
+		if err != nil {
+			break
+		}
+	}
+	if closeErr := queue.close(); err == nil {
+		err = closeErr
+	}
+	summary.nLeaked += overlay.ReleaseLeakedWebpResources()
+	if err != nil {
+		// What was saved before is still recorded.
+		state.Save()
+		return nil, err
+	}
+
+	err = state.Save()
+	if err != nil {
+		return nil, err
+	}
+	err = summary.retry.save()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := pipeline.artStyles["Icon"]; ok && game.Custom {
+		_, err = updateShortcutIcons(user, gridDir, state)
+		if err != nil {
+			fmt.Println("Could not set the icons of non-Steam games: " + err.Error())
+		}
+	}
+	return summary.entries, nil
+}
+
+// Loads or downloads one image of a game, applies the overlays and saves the
+// result to the grid directory. Returns an error when the grid directory
+// can't be worked on anymore, like when backups can't be written; problems
+// with the image itself only go to the summary.
+func processGameImage(options *Options, gridDir string, state *gridState, game *Game, artStyle string, artStyleExtensions []string, overlays map[string]*overlay.CategoryOverlay, download bool, applyOverlays bool, summary *runSummary, queue *conversionQueue) error {
+	entry := summary.newEntry(game, artStyle)
+	if state.isLocked(game.ID, artStyle) && !options.Force {
+		fmt.Printf("%v locked, skipping\n", artStyle)
+		entry.Status = "locked"
+		return nil
+	}
+
+	// Clear for multiple runs:
+	game.ImageSource = ""
+	game.ImageExt = ""
+	game.CleanImageBytes = nil
+	game.OverlayImageBytes = nil
+	game.ImageURL = ""
+	game.SteamGridDBID = 0
+	game.RejectedSteamGridDBIDs = nil
+
+	// Forced images are downloaded again as if the grid directory didn't
+	// have them. Images in the games directory are still used.
+	forced := download && (options.Force || options.forcesSource(state.Entry(game.ID, artStyle)))
+	if options.Incremental && !forced && state.isCurrent(gridDir, game.ID, artStyle, options.MaxAge) {
+		fmt.Printf("%v processed in an earlier run, skipping\n", artStyle)
+		entry.Status = "present"
+		return nil
+	}
+	overridePath := filepath.Join(filepath.Dir(os.Args[0]), "games")
+	loadExisting(overridePath, gridDir, game, artStyleExtensions, options.IgnoreBackup || forced, options.IgnoreManual || forced)
+	if game.ImageSource != "" && !applyOverlays {
+		// Only looking for missing images, keep this one as it is.
+		fmt.Printf("%v already present, skipping\n", artStyle)
+		entry.Status = "present"
+		return nil
+	} else if game.ImageSource == "" && !download {
+		// Nothing local to apply overlays to.
+		fmt.Printf("%v not present, skipping\n", artStyle)
+		entry.Status = "missing"
+		return nil
+	}
+
+	// This cleans up unused backups and images for the same game but with different extensions.
+	// Forced images are only removed once a new one was found. Images
+	// found here are cleaned up once saved, so that they aren't written
+	// again when nothing changed.
+	var err error
+	if !forced && game.ImageSource == "" {
+		err = removeExisting(gridDir, game.ID, artStyleExtensions)
+		if err != nil {
+			fmt.Println(err.Error())
+			entry.addError(err)
+		}
+	}
+
+	///////////////////////
+	// Download if missing.
+	///////////////////////
+	if game.ImageSource == "" && download {
+		from, err := DownloadImage(gridDir, state, game, artStyle, artStyleExtensions, options)
+		if err != nil && err.Error() == " SteamGridDB authorization token is missing or invalid" {
+			// Wrong api key
+			options.SteamGridDBApiKey = ""
+			fmt.Println(err.Error())
+		} else if err != nil {
+			fmt.Println(err.Error())
+		}
+		if err != nil {
+			entry.addError(err)
+		}
+		if errors.Is(err, sources.ErrSteamGridDBUnavailable) {
+			summary.retry.add(game.ID, artStyle)
+		}
+		entry.searchName = game.SearchTerm()
+		kept := false
+		if game.ImageSource == "" && forced {
+			// Nothing new, keep what was there.
+			loadExisting(overridePath, gridDir, game, artStyleExtensions, options.IgnoreBackup, options.IgnoreManual)
+			kept = game.ImageSource != ""
+		}
+		if forced && game.ImageSource != "" {
+			removeErr := removeExisting(gridDir, game.ID, artStyleExtensions)
+			if removeErr != nil {
+				fmt.Println(removeErr.Error())
+				entry.addError(removeErr)
+			}
+		}
+
+		if game.ImageSource == "" {
+			summary.notFounds[artStyle] = append(summary.notFounds[artStyle], game)
+			fmt.Printf("%v not found\n", artStyle)
+			entry.Status = "not found"
+			// Game has no image, skip it.
+			return nil
+		} else if kept {
+			fmt.Printf("No new %v found, keeping the existing one\n", artStyle)
+		} else {
+			if err == nil {
+				summary.nDownloaded++
+			}
+			entry.Status = "downloaded"
+
+			if options.SaveMirror && from != "mirror" && from != "pack" {
+				err = saveMirrorImage(game, artStyle, options)
+				if err != nil {
+					fmt.Printf("Failed to save %v to the mirror: %v\n", artStyle, err.Error())
+				}
+			}
+
+			switch from {
+			case "IGDB":
+				summary.IGDB[artStyle] = append(summary.IGDB[artStyle], game)
+			case "SteamGridDB":
+				summary.steamGridDB[artStyle] = append(summary.steamGridDB[artStyle], game)
+			case "search":
+				summary.searchedGames[artStyle] = append(summary.searchedGames[artStyle], game)
+			}
+		}
+	}
+	fmt.Printf("%v found from %v\n", artStyle, game.ImageSource)
+	if entry.Status == "" {
+		entry.Status = "existing"
+	}
+
+	// Steam doesn't animate GIFs.
+	if converted, err := convertGif(game, artStyle, options); err != nil {
+		fmt.Println("GIF not converted: " + err.Error())
+	} else if converted {
+		fmt.Println("Converted the GIF to " + strings.TrimPrefix(game.ImageExt, "."))
+	}
+
+	// Small downloads would be blurry, before the overlays are drawn on them.
+	if entry.Status == "downloaded" && options.UpscaleCmd != "" {
+		if upscaled, err := upscaleImage(game, artStyle, options.UpscaleCmd); err != nil {
+			fmt.Println("Not upscaled: " + err.Error())
+		} else if upscaled {
+			fmt.Printf("Upscaled the %v\n", strings.ToLower(artStyle))
+		}
+	}
+
+	// Downloads slightly off the size Steam shows would be letterboxed.
+	if entry.Status == "downloaded" && !options.NoResize {
+		if resized, err := resizeToCanonical(game, artStyle, options.maxConvertMemory(), options.stillEncoding()); err != nil {
+			fmt.Println("Not resized: " + err.Error())
+		} else if resized {
+			fmt.Printf("Resized the %v to the size Steam shows\n", strings.ToLower(artStyle))
+		}
+	}
+
+	if options.NormalizeColors {
+		normalized, err := normalizeColors(game, options.stillEncoding())
+		if err != nil {
+			fmt.Println(err.Error())
+		} else if normalized {
+			fmt.Println("Converted to sRGB and stripped metadata")
+		}
+	}
+
+	if artStyle == "Logo" && strings.ToLower(options.OpaqueLogos) == "clear" {
+		cleared, err := clearOpaqueLogo(game)
+		if err != nil {
+			fmt.Println(err.Error())
+		} else if cleared {
+			fmt.Println("Cleared the background of the logo")
+		}
+	} else if artStyle == "Logo" && isOpaqueLogo(game.CleanImageBytes) {
+		fmt.Println("The logo has no transparency and will look like a box over the hero, -opaquelogos clear or reject can fix it")
+	}
+
+	// Animations take long to convert, and are converted in the background
+	// while the next games are downloaded. Everything else is done right
+	// away.
+	if (applyOverlays || options.maxFileSize(artStyle) > 0 || options.CapFPS > 0 || options.CapFrames > 0) && queue.workers > 0 && (overlay.IsAnimatedWebp(game.CleanImageBytes) || overlay.IsAnimatedPNG(game.CleanImageBytes)) {
+		fmt.Printf("%v queued for conversion\n", artStyle)
+		job := *game
+		game.CleanImageBytes = nil
+		return queue.add(func(log io.Writer) func() error {
+			overlaid, err := decorateGameImage(log, options, &job, artStyle, artStyleExtensions, overlays, applyOverlays, entry)
+			return func() error {
+				return saveGameImage(options, gridDir, state, &job, artStyle, artStyleExtensions, entry, summary, overlaid, err)
+			}
+		})
+	}
+	overlaid, err := decorateGameImage(os.Stdout, options, game, artStyle, artStyleExtensions, overlays, applyOverlays, entry)
+	return saveGameImage(options, gridDir, state, game, artStyle, artStyleExtensions, entry, summary, overlaid, err)
+}
+
+// Applies the overlays, badges and effects to an image of a game, the frame
+// caps and -maxfilesize, printing to log. Returns whether overlays were
+// applied, and why they couldn't be.
+func decorateGameImage(log io.Writer, options *Options, game *Game, artStyle string, artStyleExtensions []string, overlays map[string]*overlay.CategoryOverlay, applyOverlays bool, entry *ReportEntry) (bool, error) {
+	var overlayErr error
+
+	///////////////////////
+	// Apply overlay.
+	//
+	// Expecting name.artExt.imgExt:
+	// Banner: favorites.png
+	// Cover: favorites.p.png
+	// Hero: favorites.hero.png
+	// Icon: favorites.icon.png
+	// Logo: favorites.logo.png
+	///////////////////////
+	if applyOverlays {
+		// Overlays of the rules the game matches go over the ones of its
+		// categories.
+		tags := append(append([]string{}, game.Tags...), game.RuleOverlays...)
+		game.OverlayImageBytes, game.ImageExt, overlayErr = overlay.ApplyOverlay(game.CleanImageBytes, game.ImageExt, tags, overlays, artStyleExtensions, strings.Split(options.OverlayOrder, ","), options.MaxOverlays, options.overlayPlacement(), options.ConvertWebpToApng, options.ConvertWebpToApngCoversBanners, options.maxConvertMemory(), options.usesFFmpeg(), options.stillEncoding(), log)
+		if overlayErr != nil {
+			fmt.Fprintln(log, overlayErr.Error())
+		}
+	}
+	overlaid := game.OverlayImageBytes != nil
+	if overlaid {
+		entry.Overlay = true
+	} else {
+		game.OverlayImageBytes = game.CleanImageBytes
+	}
+	if applyOverlays && options.NotInstalled != "" && (artStyle == "Cover" || artStyle == "Banner") {
+		applied, err := applyNotInstalledEffect(game, strings.ToLower(options.NotInstalled), options.stillEncoding())
+		if err != nil {
+			fmt.Fprintln(log, err.Error())
+		} else if applied {
+			entry.Overlay = true
+		}
+	}
+	if badge, _ := options.textBadge(); applyOverlays && badge != nil && (artStyle == "Cover" || artStyle == "Banner") {
+		drawn, err := drawTextBadge(game, *badge, options.stillEncoding())
+		if err != nil {
+			fmt.Fprintln(log, err.Error())
+		} else if drawn {
+			entry.Overlay = true
+		}
+	}
+	if applyOverlays && options.ProtonDB && (artStyle == "Cover" || artStyle == "Banner") {
+		drawn, err := drawProtonDBBadge(game, strings.ToLower(options.ProtonDBCorner), options.stillEncoding())
+		if err != nil {
+			fmt.Fprintln(log, "No ProtonDB rating: "+err.Error())
+		} else if drawn {
+			entry.Overlay = true
+		}
+	}
+	if applyOverlays && options.DeckBadge && (artStyle == "Cover" || artStyle == "Banner") {
+		drawn, err := drawDeckBadge(game, strings.ToLower(options.DeckBadgeCorner), options.stillEncoding())
+		if err != nil {
+			fmt.Fprintln(log, "No Steam Deck compatibility: "+err.Error())
+		} else if drawn {
+			entry.Overlay = true
+		}
+	}
+	if applyOverlays && options.ExtendHeroes && artStyle == "Hero" {
+		extended, err := extendHero(game, options.stillEncoding())
+		if err != nil {
+			fmt.Fprintln(log, err.Error())
+		} else if extended {
+			fmt.Fprintln(log, "Extended the hero to 1920x620")
+		}
+	}
+	if applyOverlays && options.AutoLevels && artStyle == "Hero" {
+		err := autoLevelImage(game, options.AutoLevelsTarget, options.stillEncoding())
+		if err != nil {
+			fmt.Fprintln(log, err.Error())
+		}
+	}
+	if _, err := capAnimation(log, game, options.CapFPS, options.CapFrames); err != nil {
+		fmt.Fprintln(log, "Could not drop frames of the animation: "+err.Error())
+	}
+	if _, err := shrinkImage(log, game, options.maxFileSize(artStyle)); err != nil {
+		fmt.Fprintln(log, "Could not make the image smaller: "+err.Error())
+	}
+	return overlaid, overlayErr
+}
+
+// Writes an image of a game, decorated, to the grid directory and records
+// it. Returns an error only when the original couldn't be backed up, which
+// would be lost by going on.
+func saveGameImage(options *Options, gridDir string, state *gridState, game *Game, artStyle string, artStyleExtensions []string, entry *ReportEntry, summary *runSummary, overlaid bool, overlayErr error) error {
+	if overlayErr != nil {
+		summary.failedGames[artStyle] = append(summary.failedGames[artStyle], game)
+		summary.errorMessages = append(summary.errorMessages, overlayErr.Error())
+		entry.addError(overlayErr)
+	}
+	if overlaid {
+		summary.nOverlaysApplied++
+	}
+
+	///////////////////////
+	// Save result.
+	///////////////////////
+	err := backupGame(gridDir, game, artStyleExtensions)
+	if err != nil {
+		entry.Status = "failed"
+		entry.addError(err)
+		return err
+	}
+
+	if strings.Contains(game.ImageExt, "webp") {
+		game.ImageExt = ".png"
+	}
+
+	changed, err := writeGridImage(gridDir, game, artStyle, artStyleExtensions)
+	if err == nil && !changed {
+		summary.nUnchanged++
+		entry.Unchanged = true
+	}
+	if err != nil {
+		fmt.Printf("Failed to write image for %v (%v) because: %v\n", game.Name, artStyle, err.Error())
+		entry.Status = "failed"
+		entry.addError(err)
+	} else {
+		removeStaleBackups(gridDir, game, artStyleExtensions)
+		state.record(game, artStyle, game.ID+artStyleExtensions[0]+game.ImageExt)
+		entry.setImage(game, filepath.Join(gridDir, game.ID+artStyleExtensions[0]+game.ImageExt))
+		if artStyle == "Logo" {
+			err = writeLogoPosition(gridDir, game.ID, options)
+			if err == nil && game.CRCID != "" {
+				err = writeLogoPosition(gridDir, game.CRCID, options)
+			}
+			if err != nil {
+				fmt.Printf("Failed to write the logo position for %v: %v\n", game.Name, err.Error())
+				entry.addError(err)
+			}
+		}
+	}
+
+	game.OverlayImageBytes = nil
+	game.CleanImageBytes = nil
+	return nil
+}
+
+// Writes game.OverlayImageBytes to the grid directory, plus a copy of banners
+// with the legacy naming used by Big Picture mode. Shortcuts whose appid
+// isn't the one derived from their target and name get copies under both.
+// Returns whether any file changed.
+func writeGridImage(gridDir string, game *Game, artStyle string, artStyleExtensions []string) (bool, error) {
+	changed, err := writeGridCopy(gridDir, game.ID, game, artStyleExtensions)
+	if err == nil && game.CRCID != "" {
+		var crcChanged bool
+		crcChanged, err = writeGridCopy(gridDir, game.CRCID, game, artStyleExtensions)
+		changed = changed || crcChanged
+	}
+	if err != nil {
+		return changed, err
+	}
+
+	// Copy with legacy naming for Big Picture mode
+	if artStyle == "Banner" {
+		// use appID
+		id, errInternal := strconv.ParseUint(game.ID, 10, 64)
+		if game.LegacyID != 0 {
+			// old target+exe format for custom shortcuts
+			id = game.LegacyID
+		}
+		if errInternal == nil {
+			imagePath := filepath.Join(gridDir, strconv.FormatUint(id<<32|0x02000000, 10)+artStyleExtensions[0]+game.ImageExt)
+			var legacyChanged bool
+			legacyChanged, errInternal = writeFileIfChanged(imagePath, game.OverlayImageBytes)
+			changed = changed || legacyChanged
+		}
+		err = errInternal
+	}
+	return changed, err
+}
+
+// Writes game.OverlayImageBytes to the grid directory under an ID. Returns
+// whether the file changed.
+func writeGridCopy(gridDir string, id string, game *Game, artStyleExtensions []string) (bool, error) {
+	imagePath := filepath.Join(gridDir, id+artStyleExtensions[0]+game.ImageExt)
+	changed, err := writeFileIfChanged(imagePath, game.OverlayImageBytes)
+
+	// An image with another extension, like a still JPEG that became
+	// animated, would be used instead.
+	others, _ := filepath.Glob(filepath.Join(gridDir, id+artStyleExtensions[0]+".*"))
+	for _, other := range FilterForImages(others) {
+		if other != imagePath {
+			os.Remove(other)
+			changed = true
+		}
+	}
+	return changed, err
+}
+
+// Writes a file unless it already holds the same bytes, so that running
+// again with nothing new doesn't rewrite every image. Returns whether it was
+// written.
+func writeFileIfChanged(path string, data []byte) (bool, error) {
+	if info, err := os.Stat(path); err == nil && info.Size() == int64(len(data)) {
+		existing, err := ioutil.ReadFile(path)
+		if err == nil && ImageHash(existing) == ImageHash(data) {
+			return false, nil
+		}
+	}
+	return true, ioutil.WriteFile(path, data, 0666)
+}
+
+// Returns the number of games in all art styles.
+func countGames(gamesByArtStyle map[string][]*Game) int {
+	n := 0
+	for _, games := range gamesByArtStyle {
+		n += len(games)
+	}
+	return n
+}
+
+// Prints how many images were downloaded and lists the games whose images
+// came from less reliable sources or weren't found at all.
+func (summary *runSummary) print() {
+	searchedGames := summary.searchedGames
+	IGDB := summary.IGDB
+	steamGridDB := summary.steamGridDB
+	notFounds := summary.notFounds
+	failedGames := summary.failedGames
+
+	fmt.Printf("\n\n%v images downloaded and %v overlays applied.\n\n", summary.nDownloaded, summary.nOverlaysApplied)
+	if summary.nUnchanged > 0 {
+		fmt.Printf("%v images were unchanged and weren't written again.\n\n", summary.nUnchanged)
+	}
+	if summary.nLeaked > 0 {
+		fmt.Printf("%v WEBP decoders or encoders were not released and had to be cleaned up. Please report it, with the lines starting with \"Released a leaked WEBP\".\n\n", summary.nLeaked)
+	}
+	if countGames(searchedGames) >= 1 {
+		fmt.Printf("%v images were found with a Google search and may not be accurate:\n", countGames(searchedGames))
+		for artStyle, games := range searchedGames {
+			for _, game := range games {
+				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+
+	if len(IGDB["Banner"])+len(IGDB["Cover"]) >= 1 {
+		fmt.Printf("%v images were found on IGDB and may not be in full quality or accurate:\n", len(IGDB["Banner"])+len(IGDB["Cover"]))
+		for artStyle, games := range IGDB {
+			for _, game := range games {
+				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+
+	if countGames(steamGridDB) >= 1 {
+		fmt.Printf("%v images were found on SteamGridDB and may not be in full quality or accurate:\n", countGames(steamGridDB))
+		for artStyle, games := range steamGridDB {
+			for _, game := range games {
+				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+
+	if countGames(notFounds) >= 1 {
+		fmt.Printf("%v images could not be found anywhere:\n", countGames(notFounds))
+		for artStyle, games := range notFounds {
+			for _, game := range games {
+				fmt.Printf("- %v (id %v, %v)\n", game.Name, game.ID, artStyle)
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+
+	if countGames(failedGames) >= 1 {
+		fmt.Printf("%v images were found but had errors and could not be overlaid:\n", countGames(failedGames))
+		for artStyle, games := range failedGames {
+			var i = 0
+			for _, game := range games {
+				fmt.Printf("- %v (id %v, %v) (%v)\n", game.Name, game.ID, artStyle, summary.errorMessages[i])
+				i++
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+}
@@ -0,0 +1,164 @@
+package artwork
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// A game of a RetroArch playlist or an EmulationStation gamelist, by the
+// file name of its ROM.
+type playlistEntry struct {
+	RomFile string
+	Title   string
+}
+
+// The entries of the playlists, read once per run.
+var loadedPlaylists struct {
+	sync.Once
+	entries []playlistEntry
+}
+
+// Where RetroArch and EmulationStation (and ES-DE) keep their playlists,
+// looked in when -playlists isn't given.
+func defaultPlaylistDirs() []string {
+	var dirs []string
+	if currentUser, err := user.Current(); err == nil {
+		dirs = append(dirs,
+			filepath.Join(currentUser.HomeDir, ".config", "retroarch", "playlists"),
+			filepath.Join(currentUser.HomeDir, ".var", "app", "org.libretro.RetroArch", "config", "retroarch", "playlists"),
+			filepath.Join(currentUser.HomeDir, ".emulationstation", "gamelists"),
+			filepath.Join(currentUser.HomeDir, "ES-DE", "gamelists"))
+	}
+	if appData := os.Getenv("APPDATA"); appData != "" {
+		dirs = append(dirs, filepath.Join(appData, "RetroArch", "playlists"))
+	}
+	return dirs
+}
+
+// Returns the file name of a ROM path, written with either separator.
+func romFileName(path string) string {
+	path = strings.Trim(path, `"`)
+	if i := strings.LastIndexAny(path, `\/`); i >= 0 {
+		path = path[i+1:]
+	}
+	// Playlist entries of archives point inside them: game.zip#game.sfc.
+	if i := strings.Index(path, "#"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// Reads a RetroArch playlist, either JSON or the six lines per entry of
+// versions before 1.7.6: path, label, core path, core name, CRC and database.
+func parseRetroArchPlaylist(data []byte) ([]playlistEntry, error) {
+	var entries []playlistEntry
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		var playlist struct {
+			Items []struct {
+				Path  string `json:"path"`
+				Label string `json:"label"`
+			} `json:"items"`
+		}
+		err := json.Unmarshal(data, &playlist)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range playlist.Items {
+			entries = append(entries, playlistEntry{romFileName(item.Path), sources.CleanGameName(item.Label)})
+		}
+		return entries, nil
+	}
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	for i := 0; i+1 < len(lines); i += 6 {
+		entries = append(entries, playlistEntry{romFileName(lines[i]), sources.CleanGameName(lines[i+1])})
+	}
+	return entries, nil
+}
+
+// Reads an EmulationStation gamelist.xml, whose names are already the ones
+// scrapers found.
+func parseGamelist(data []byte) ([]playlistEntry, error) {
+	var gamelist struct {
+		Games []struct {
+			Path string `xml:"path"`
+			Name string `xml:"name"`
+		} `xml:"game"`
+	}
+	err := xml.Unmarshal(data, &gamelist)
+	if err != nil {
+		return nil, err
+	}
+	var entries []playlistEntry
+	for _, game := range gamelist.Games {
+		entries = append(entries, playlistEntry{romFileName(game.Path), game.Name})
+	}
+	return entries, nil
+}
+
+// Reads the playlists and gamelists of a file or directory, with the
+// gamelists of EmulationStation in a directory per system.
+func readPlaylists(path string) ([]playlistEntry, error) {
+	var entries []playlistEntry
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		var parse func([]byte) ([]playlistEntry, error)
+		if strings.EqualFold(filepath.Ext(path), ".lpl") {
+			parse = parseRetroArchPlaylist
+		} else if strings.EqualFold(info.Name(), "gamelist.xml") {
+			parse = parseGamelist
+		} else {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		found, err := parse(data)
+		if err != nil {
+			fmt.Printf("Could not read the playlist %v: %v\n", path, err.Error())
+			return nil
+		}
+		entries = append(entries, found...)
+		return nil
+	})
+	return entries, err
+}
+
+// Returns the title of the playlist entry a shortcut launches, found by the
+// file name of its ROM in the shortcut's target and launch options, or "".
+// Shortcuts of emulators are named after the emulator or the ROM file, like
+// "retroarch.exe -L snes9x", and search much better by the title.
+func playlistTitle(target string, options *Options) string {
+	loadedPlaylists.Do(func() {
+		paths := defaultPlaylistDirs()
+		if options.Playlists != "" {
+			paths = strings.Split(options.Playlists, ",")
+		}
+		for _, path := range paths {
+			entries, err := readPlaylists(strings.TrimSpace(path))
+			if err != nil && (options.Playlists != "" || !os.IsNotExist(err)) {
+				fmt.Println("Could not read the playlists: " + err.Error())
+			}
+			loadedPlaylists.entries = append(loadedPlaylists.entries, entries...)
+		}
+	})
+	target = strings.ToLower(target)
+	for _, entry := range loadedPlaylists.entries {
+		// Without an extension, the name would match too much.
+		if entry.Title != "" && filepath.Ext(entry.RomFile) != "" && strings.Contains(target, strings.ToLower(entry.RomFile)) {
+			return entry.Title
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package artwork
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Summary of the compatibility reports of a game on ProtonDB.
+const protonDBSummaryURL = "https://www.protondb.com/api/v1/reports/summaries/%v.json"
+
+// Colors of the ProtonDB tiers, as on the site.
+var protonDBTierColors = map[string]string{
+	"platinum": "#b4c7dc",
+	"gold":     "#cfb53b",
+	"silver":   "#a6a6a6",
+	"bronze":   "#cd7f32",
+	"borked":   "#ff0000",
+}
+
+// Returns the ProtonDB tier of a Steam game, like "gold", or "" when it has
+// no rating yet. Ratings are kept in the API cache, like SteamGridDB answers.
+func protonDBTier(appID string) (string, error) {
+	status, body, err := sources.CachedGet(fmt.Sprintf(protonDBSummaryURL, appID))
+	if err != nil {
+		return "", errors.New("ProtonDB " + err.Error())
+	} else if status == http.StatusNotFound {
+		return "", nil
+	}
+
+	var summary struct {
+		Tier string `json:"tier"`
+	}
+	err = json.Unmarshal(body, &summary)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := protonDBTierColors[summary.Tier]; !ok {
+		// Pending, not enough reports yet.
+		return "", nil
+	}
+	return summary.Tier, nil
+}
+
+// Draws the ProtonDB tier of a Steam game on a corner of its artwork, in the
+// color of the tier. Returns whether a badge was drawn.
+func drawProtonDBBadge(game *Game, corner string, encoding overlay.StillEncoding) (bool, error) {
+	if game.Custom {
+		return false, nil
+	}
+	tier, err := protonDBTier(game.ID)
+	if err != nil || tier == "" {
+		return false, err
+	}
+	background, _ := parseHexColor(protonDBTierColors[tier])
+	textColor, _ := parseHexColor("#000000")
+	if tier == "borked" {
+		textColor, _ = parseHexColor("#ffffff")
+	}
+	badge := textBadge{Text: strings.ToUpper(tier), Corner: corner, Size: 5, Background: background, Color: textColor}
+	return drawTextBadge(game, badge, encoding)
+}
@@ -0,0 +1,168 @@
+package artwork
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// IsShortcutID tells if an ID is of a shortcut. Shortcut IDs always have the
+// highest bit set, which no Steam appID has.
+func IsShortcutID(gameID string) bool {
+	id, err := strconv.ParseUint(gameID, 10, 32)
+	return err == nil && id&0x80000000 != 0
+}
+
+// NameFingerprint identifies a game by name, ignoring case, spaces and
+// punctuation, so
+// "Half-Life 2" and "half life 2" are the same game.
+func NameFingerprint(name string) string {
+	return strings.ToLower(nameSeparators.ReplaceAllString(name, ""))
+}
+
+// Returns the IDs of every shortcut in shortcuts.vdf, hidden or not, by both
+// the appid Steam gives it and the one derived from its target and name.
+func shortcutIDs(user User) (map[string]bool, error) {
+	shortcutBytes, err := ioutil.ReadFile(filepath.Join(user.Dir, "config", "shortcuts.vdf"))
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	root, err := steam.ParseBinaryVDF(shortcutBytes)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, shortcut := range root.Child("shortcuts").Children {
+		gameID, _ := ShortcutID(shortcut)
+		ids[gameID] = true
+		ids[fmt.Sprint(shortcutCRCID(shortcut))] = true
+	}
+	return ids, nil
+}
+
+// Deleting a shortcut and creating it again gives it a new ID, leaving its
+// artwork behind under the old one. Links the artwork, backups and state of
+// shortcuts that are gone to new shortcuts with the same name and no artwork
+// of their own, so they don't need to be downloaded again. Only shortcuts
+// SteamGrid wrote artwork for are known by name. Gone means missing from
+// shortcuts.vdf, not only from the games being processed, which leaves out
+// hidden, private and excluded ones.
+func relinkShortcuts(user User, gridDir string, state *gridState, games map[string]*Game) {
+	if state == nil {
+		return
+	}
+	existing, err := shortcutIDs(user)
+	if err != nil {
+		fmt.Println("Could not read the shortcuts, not linking the artwork of removed ones: " + err.Error())
+		return
+	}
+	artStyles := MakeArtStyles("", "", "", "", "")
+
+	// Shortcuts with artwork in the state that are not in the library anymore.
+	orphans := make(map[string]string)
+	for _, entry := range state.Images {
+		if existing[entry.GameID] || !IsShortcutID(entry.GameID) || entry.Name == "" {
+			continue
+		}
+		orphans[NameFingerprint(entry.Name)] = entry.GameID
+	}
+	if len(orphans) == 0 {
+		return
+	}
+
+	for _, game := range games {
+		oldID, ok := orphans[NameFingerprint(game.Name)]
+		if !game.Custom || game.Name == "" || !ok {
+			continue
+		}
+		hasArtwork := false
+		for _, artStyleExtensions := range artStyles {
+			if GridImageStatus(gridDir, game.ID, artStyleExtensions) != "missing" {
+				hasArtwork = true
+			}
+		}
+		if hasArtwork {
+			continue
+		}
+
+		fmt.Printf("Linking the artwork of the removed shortcut %v (id %v) to id %v\n", game.Name, oldID, game.ID)
+		err := moveGameArtwork(gridDir, state, artStyles, oldID, game.ID)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		delete(orphans, NameFingerprint(game.Name))
+	}
+}
+
+// Renames the images and backups of a game to a new ID and moves what the
+// state knows about them.
+func moveGameArtwork(gridDir string, state *gridState, artStyles map[string][]string, oldID string, newID string) error {
+	backups, err := GridBackups(gridDir)
+	if err != nil {
+		return err
+	}
+	images, err := filepath.Glob(filepath.Join(gridDir, oldID+"*.*"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range append(FilterForImages(images), backups...) {
+		gridName := filepath.Base(path)
+		suffix := ""
+		if filepath.Base(filepath.Dir(path)) == "originals" {
+			var hash string
+			var ok bool
+			gridName, hash, ok = ParseBackupFileName(gridName)
+			if !ok {
+				continue
+			}
+			suffix = " " + hash + filepath.Ext(path)
+		}
+		gameID, _, ok := ParseGridFileName(gridName, artStyles)
+		if !ok || gameID != oldID {
+			continue
+		}
+
+		newName := newID + strings.TrimPrefix(gridName, oldID) + suffix
+		err = os.Rename(path, filepath.Join(filepath.Dir(path), newName))
+		if err != nil {
+			return err
+		}
+	}
+
+	// The position of the logo goes with it.
+	err = os.Rename(filepath.Join(gridDir, oldID+".json"), filepath.Join(gridDir, newID+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for artStyle := range artStyles {
+		oldKey, newKey := stateKey(oldID, artStyle), stateKey(newID, artStyle)
+		if entry, ok := state.Images[oldKey]; ok {
+			entry.GameID = newID
+			entry.File = newID + strings.TrimPrefix(entry.File, oldID)
+			state.Images[newKey] = entry
+			delete(state.Images, oldKey)
+		}
+		if choice, ok := state.Choices[oldKey]; ok {
+			state.Choices[newKey] = choice
+			delete(state.Choices, oldKey)
+		}
+		if state.Locked[oldKey] {
+			state.Locked[newKey] = true
+			delete(state.Locked, oldKey)
+		}
+	}
+	if state.Locked[oldID] {
+		state.Locked[newID] = true
+		delete(state.Locked, oldID)
+	}
+	return state.Save()
+}
@@ -0,0 +1,92 @@
+package artwork
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/kmicki/steamgrid/overlay"
+)
+
+// ReportEntry is what happened to one image of one game during a run, as
+// written to the -report file.
+type ReportEntry struct {
+	User     string `json:"user"`
+	GameID   string `json:"gameId"`
+	Name     string `json:"name"`
+	ArtStyle string `json:"artStyle"`
+	// One of "downloaded", "existing", "not found", "present", "missing",
+	// "locked" or "failed".
+	Status        string   `json:"status"`
+	Source        string   `json:"source,omitempty"`
+	URL           string   `json:"url,omitempty"`
+	SteamGridDBID int      `json:"steamGridDBId,omitempty"`
+	Width         int      `json:"width,omitempty"`
+	Height        int      `json:"height,omitempty"`
+	Overlay       bool     `json:"overlay,omitempty"`
+	Unchanged     bool     `json:"unchanged,omitempty"`
+	File          string   `json:"file,omitempty"`
+	Errors        []string `json:"errors,omitempty"`
+	// Kept out of the report, for -sharematches.
+	custom     bool
+	searchName string
+}
+
+// Contents of the -report file.
+type runReport struct {
+	Started         time.Time      `json:"started"`
+	Finished        time.Time      `json:"finished"`
+	Downloaded      int            `json:"downloaded"`
+	OverlaysApplied int            `json:"overlaysApplied"`
+	Unchanged       int            `json:"unchanged"`
+	Entries         []*ReportEntry `json:"entries"`
+	Lint            []*lintFinding `json:"lint,omitempty"`
+}
+
+// Starts the report entry of an image. The entry is filled in as the image
+// is processed.
+func (summary *runSummary) newEntry(game *Game, artStyle string) *ReportEntry {
+	entry := &ReportEntry{User: summary.user, GameID: game.ID, Name: game.Name, ArtStyle: artStyle, custom: game.Custom}
+	summary.entries = append(summary.entries, entry)
+	return entry
+}
+
+// Completes an entry with what ended up in the grid directory.
+func (entry *ReportEntry) setImage(game *Game, fileName string) {
+	entry.Source = game.ImageSource
+	entry.URL = game.ImageURL
+	entry.SteamGridDBID = game.SteamGridDBID
+	entry.File = fileName
+	// The extension of converted WEBP animations is already .png.
+	isWebp := len(game.CleanImageBytes) >= 12 && string(game.CleanImageBytes[8:12]) == "WEBP"
+	size, err := overlay.ImageSize(game.CleanImageBytes, isWebp)
+	if err == nil {
+		entry.Width = size.X
+		entry.Height = size.Y
+	}
+}
+
+func (entry *ReportEntry) addError(err error) {
+	entry.Errors = append(entry.Errors, err.Error())
+}
+
+// Writes every entry of the run to a JSON file, for scripts.
+func (summary *runSummary) writeReport(path string) error {
+	report := runReport{
+		Started:         summary.started,
+		Finished:        time.Now(),
+		Downloaded:      summary.nDownloaded,
+		OverlaysApplied: summary.nOverlaysApplied,
+		Unchanged:       summary.nUnchanged,
+		Entries:         summary.entries,
+		Lint:            summary.lint,
+	}
+	if report.Entries == nil {
+		report.Entries = []*ReportEntry{}
+	}
+	reportBytes, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, reportBytes, 0666)
+}
@@ -0,0 +1,160 @@
+package artwork
+
+import (
+	"bytes"
+	"image"
+	"math"
+
+	"github.com/kmicki/steamgrid/overlay"
+	"golang.org/x/image/draw"
+)
+
+// Sizes Steam shows each art style at. Banners have two, the old one and
+// the one for high resolution screens.
+var canonicalSizes = map[string][]image.Point{
+	"Banner": {{460, 215}, {920, 430}},
+	"Cover":  {{600, 900}},
+	"Hero":   {{1920, 620}},
+}
+
+// Images this much narrower or wider than their art style are left as they
+// are, as cropping them would cut too much.
+const resizeAspectTolerance = 0.1
+
+// Returns the canonical size of an art style closest to the size of an
+// image, if its aspect ratio is close enough to be cropped to it.
+func canonicalSize(artStyle string, size image.Point) (image.Point, bool) {
+	var closest image.Point
+	for _, canonical := range canonicalSizes[artStyle] {
+		if closest == (image.Point{}) || math.Abs(float64(size.X-canonical.X)) < math.Abs(float64(size.X-closest.X)) {
+			closest = canonical
+		}
+	}
+	if closest == (image.Point{}) || size == closest || size.X <= 0 || size.Y <= 0 {
+		return closest, false
+	}
+	aspect := float64(size.X) / float64(size.Y)
+	canonicalAspect := float64(closest.X) / float64(closest.Y)
+	return closest, aspect > canonicalAspect*(1-resizeAspectTolerance) && aspect < canonicalAspect*(1+resizeAspectTolerance)
+}
+
+// Returns the part of an image of the aspect ratio of size to keep: all of
+// it along one side and, along the other, the window with the most detail,
+// so faces and titles aren't cut off where a centered crop would.
+func smartCrop(img image.Image, size image.Point) image.Rectangle {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	cropWidth, cropHeight := width, int(float64(width)*float64(size.Y)/float64(size.X)+0.5)
+	horizontal := false
+	if cropHeight > height {
+		cropWidth, cropHeight = int(float64(height)*float64(size.X)/float64(size.Y)+0.5), height
+		horizontal = true
+	}
+
+	// Detail of every column or row: how much neighbouring pixels differ.
+	length := height
+	if horizontal {
+		length = width
+	}
+	detail := make([]float64, length+1)
+	luminance := func(x int, y int) float64 {
+		r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+		return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+	}
+	for y := 0; y+1 < height; y++ {
+		for x := 0; x+1 < width; x++ {
+			here := luminance(x, y)
+			difference := math.Abs(luminance(x+1, y)-here) + math.Abs(luminance(x, y+1)-here)
+			if horizontal {
+				detail[x+1] += difference
+			} else {
+				detail[y+1] += difference
+			}
+		}
+	}
+	for i := 1; i <= length; i++ {
+		detail[i] += detail[i-1]
+	}
+
+	window := cropHeight
+	if horizontal {
+		window = cropWidth
+	}
+	// Centered unless another window has more detail.
+	best := (length - window) / 2
+	bestDetail := detail[best+window] - detail[best]
+	for start := 0; start+window <= length; start++ {
+		if sum := detail[start+window] - detail[start]; sum > bestDetail {
+			best, bestDetail = start, sum
+		}
+	}
+	if horizontal {
+		return image.Rect(bounds.Min.X+best, bounds.Min.Y, bounds.Min.X+best+cropWidth, bounds.Max.Y)
+	}
+	return image.Rect(bounds.Min.X, bounds.Min.Y+best, bounds.Max.X, bounds.Min.Y+best+cropHeight)
+}
+
+// Crops and scales a part of an image to size.
+func cropAndScale(img image.Image, crop image.Rectangle, size image.Point) *image.RGBA {
+	result := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	draw.CatmullRom.Scale(result, result.Bounds(), img, crop, draw.Src, nil)
+	return result
+}
+
+// Crops and resizes a downloaded image of a game slightly off the size Steam
+// shows its art style at, which it would letterbox, to that size. Animations
+// too big to hold in maxMem (0 for no limit) are left as they are. Returns
+// whether the image was resized.
+func resizeToCanonical(game *Game, artStyle string, maxMem uint64, encoding overlay.StillEncoding) (bool, error) {
+	imageBytes := game.CleanImageBytes
+	if _, ok := canonicalSizes[artStyle]; !ok || game.ImageExt == ".ico" {
+		return false, nil
+	}
+	isWebp := overlay.IsAnimatedWebp(imageBytes)
+	if isWebp || overlay.IsAnimatedPNG(imageBytes) {
+		size, err := overlay.ImageSize(imageBytes, isWebp)
+		if err != nil {
+			return false, err
+		}
+		target, ok := canonicalSize(artStyle, size)
+		frames, _, _ := overlay.AnimationTiming(imageBytes)
+		if !ok || (maxMem > 0 && uint64(size.X)*uint64(size.Y)*4*uint64(frames) > maxMem) {
+			return false, nil
+		}
+		decoded, loopCount, err := overlay.DecodeAnimationFrames(imageBytes, isWebp)
+		if err != nil {
+			return false, err
+		}
+		// The same part of every frame is kept.
+		crop := smartCrop(decoded[0].Image, target)
+		for i := range decoded {
+			decoded[i].Image = cropAndScale(decoded[i].Image, crop, target)
+		}
+		resized, err := overlay.EncodeAnimationFrames(decoded, loopCount, 0, isWebp)
+		if err != nil {
+			return false, err
+		}
+		game.CleanImageBytes = resized
+		return true, nil
+	}
+
+	decoded, format, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return false, err
+	}
+	target, ok := canonicalSize(artStyle, decoded.Bounds().Size())
+	if !ok {
+		return false, nil
+	}
+	resized := cropAndScale(decoded, smartCrop(decoded, target), target)
+	buf := new(bytes.Buffer)
+	err = encoding.Encode(buf, resized, format == "jpeg")
+	if format != "jpeg" {
+		game.ImageExt = ".png"
+	}
+	if err != nil {
+		return false, err
+	}
+	game.CleanImageBytes = buf.Bytes()
+	return true, nil
+}
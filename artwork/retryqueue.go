@@ -0,0 +1,87 @@
+package artwork
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Name of the file with the images to retry, in the grid directory.
+const RetryQueueFileName = "steamgrid-retry.json"
+
+// An image that couldn't be downloaded because SteamGridDB was down.
+type retryItem struct {
+	GameID   string `json:"gameId"`
+	ArtStyle string `json:"artStyle"`
+}
+
+// Images of a user left for later with -retryqueue.
+type retryQueue struct {
+	path  string
+	Items []retryItem `json:"items"`
+}
+
+// LoadRetryQueue loads the images to retry of a grid directory. A missing
+// file is an empty queue.
+func LoadRetryQueue(gridDir string) (*retryQueue, error) {
+	queue := &retryQueue{path: filepath.Join(gridDir, RetryQueueFileName)}
+	queueBytes, err := ioutil.ReadFile(queue.path)
+	if os.IsNotExist(err) {
+		return queue, nil
+	} else if err != nil {
+		return queue, err
+	}
+	return queue, json.Unmarshal(queueBytes, queue)
+}
+
+// Writes the queue, or removes the file when nothing is left.
+func (queue *retryQueue) save() error {
+	if len(queue.Items) == 0 {
+		err := os.Remove(queue.path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	queueBytes, err := json.MarshalIndent(queue, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(queue.path, queueBytes, 0666)
+}
+
+func (queue *retryQueue) contains(gameID string, artStyle string) bool {
+	for _, item := range queue.Items {
+		if item.GameID == gameID && item.ArtStyle == artStyle {
+			return true
+		}
+	}
+	return false
+}
+
+// Tells if any image of a game is queued.
+func (queue *retryQueue) containsGame(gameID string) bool {
+	for _, item := range queue.Items {
+		if item.GameID == gameID {
+			return true
+		}
+	}
+	return false
+}
+
+func (queue *retryQueue) add(gameID string, artStyle string) {
+	if !queue.contains(gameID, artStyle) {
+		queue.Items = append(queue.Items, retryItem{gameID, artStyle})
+	}
+}
+
+func (queue *retryQueue) remove(gameID string, artStyle string) {
+	var kept []retryItem
+	for _, item := range queue.Items {
+		if item.GameID != gameID || item.ArtStyle != artStyle {
+			kept = append(kept, item)
+		}
+	}
+	queue.Items = kept
+}
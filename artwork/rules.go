@@ -0,0 +1,217 @@
+package artwork
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Name of the overlay rules file looked for next to the executable.
+const overlayRulesFileName = "overlay-rules.yaml"
+
+// Puts an overlay on the games matching every condition given. Store
+// conditions (genre, category, vr, type and years) only match Steam games
+// the store knows.
+type overlayRule struct {
+	Overlay  string
+	Genre    string
+	Category string
+	Tag      string
+	Type     string
+	VR       *bool
+	// In hours
+	MinPlaytime *float64
+	MaxPlaytime *float64
+	MinYear     int
+	MaxYear     int
+}
+
+// Parses the YAML rules are written in: a list of maps of plain values, like
+//
+//   - overlay: early-access
+//     genre: Early Access
+func parseRulesYAML(data []byte) ([]map[string]string, error) {
+	var items []map[string]string
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if comment := strings.Index(trimmed, " #"); comment >= 0 {
+			trimmed = strings.TrimSpace(trimmed[:comment])
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			items = append(items, make(map[string]string))
+			trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			if trimmed == "" {
+				continue
+			}
+		}
+		colon := strings.Index(trimmed, ":")
+		if colon < 0 || len(items) == 0 {
+			return nil, fmt.Errorf("line %v: expected a list of key: value maps", i+1)
+		}
+		value := strings.TrimSpace(trimmed[colon+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		items[len(items)-1][strings.ToLower(strings.TrimSpace(trimmed[:colon]))] = value
+	}
+	return items, nil
+}
+
+// Reads the rules of a rules file.
+func parseOverlayRules(data []byte) ([]overlayRule, error) {
+	items, err := parseRulesYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	var rules []overlayRule
+	for i, item := range items {
+		rule := overlayRule{}
+		for key, value := range item {
+			switch key {
+			case "overlay":
+				rule.Overlay = value
+			case "genre":
+				rule.Genre = value
+			case "category":
+				rule.Category = value
+			case "tag":
+				rule.Tag = value
+			case "type":
+				rule.Type = value
+			case "vr":
+				vr, parseErr := strconv.ParseBool(value)
+				rule.VR, err = &vr, parseErr
+			case "minplaytime", "maxplaytime":
+				hours, parseErr := strconv.ParseFloat(value, 64)
+				if key == "minplaytime" {
+					rule.MinPlaytime = &hours
+				} else {
+					rule.MaxPlaytime = &hours
+				}
+				err = parseErr
+			case "minyear":
+				rule.MinYear, err = strconv.Atoi(value)
+			case "maxyear":
+				rule.MaxYear, err = strconv.Atoi(value)
+			default:
+				err = errors.New("unknown condition " + key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("rule %v: %v", i+1, err.Error())
+			}
+		}
+		if rule.Overlay == "" {
+			return nil, fmt.Errorf("rule %v has no overlay", i+1)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Loads the -overlayrules file, or overlay-rules.yaml next to the executable
+// if there's one.
+func loadOverlayRules(options *Options) ([]overlayRule, error) {
+	path := options.OverlayRules
+	if path == "" {
+		path = filepath.Join(filepath.Dir(os.Args[0]), overlayRulesFileName)
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && options.OverlayRules == "" {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	rules, err := parseOverlayRules(data)
+	if err != nil {
+		return nil, errors.New(filepath.Base(path) + ": " + err.Error())
+	}
+	return rules, nil
+}
+
+// Whether a rule needs the store data of the games.
+func (rule overlayRule) needsStore() bool {
+	return rule.Genre != "" || rule.Category != "" || rule.Type != "" || rule.VR != nil || rule.MinYear != 0 || rule.MaxYear != 0
+}
+
+// Whether a list has a value, whatever the case.
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a game, with its store data if needed, matches a rule.
+func (rule overlayRule) matches(game *Game, details *sources.StoreAppDetails) bool {
+	if rule.Tag != "" && !containsFold(game.Tags, rule.Tag) {
+		return false
+	}
+	hours := float64(game.Playtime) / 60
+	if (rule.MinPlaytime != nil && hours < *rule.MinPlaytime) || (rule.MaxPlaytime != nil && hours > *rule.MaxPlaytime) {
+		return false
+	}
+	if !rule.needsStore() {
+		return true
+	}
+	if details == nil {
+		return false
+	}
+	if rule.Genre != "" && !containsFold(details.Genres, rule.Genre) {
+		return false
+	}
+	if rule.Category != "" && !containsFold(details.Categories, rule.Category) {
+		return false
+	}
+	if rule.Type != "" && !strings.EqualFold(details.Type, rule.Type) {
+		return false
+	}
+	if rule.VR != nil {
+		vr := false
+		for _, category := range details.Categories {
+			vr = vr || strings.HasPrefix(category, "VR ")
+		}
+		if vr != *rule.VR {
+			return false
+		}
+	}
+	if (rule.MinYear != 0 && details.ReleaseYear < rule.MinYear) || (rule.MaxYear != 0 && details.ReleaseYear > rule.MaxYear) {
+		return false
+	}
+	return true
+}
+
+// Gives the games the overlays of the rules they match, fetching the store
+// data of Steam games only when a rule needs it.
+func applyOverlayRules(rules []overlayRule, games map[string]*Game) {
+	needsStore := false
+	for _, rule := range rules {
+		needsStore = needsStore || rule.needsStore()
+	}
+	for _, game := range games {
+		game.RuleOverlays = nil
+		var details *sources.StoreAppDetails
+		if needsStore && !game.Custom {
+			var err error
+			details, err = sources.GetStoreAppDetails(game.ID)
+			if err != nil {
+				fmt.Printf("No store data for %v: %v\n", game.ID, err.Error())
+			}
+		}
+		for _, rule := range rules {
+			if rule.matches(game, details) && !containsString(game.RuleOverlays, rule.Overlay) {
+				game.RuleOverlays = append(game.RuleOverlays, rule.Overlay)
+			}
+		}
+	}
+}
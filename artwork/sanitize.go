@@ -0,0 +1,66 @@
+package artwork
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Most filesystems limit a file name to 255 bytes. Leave room for the art
+// style and image extensions and the backup hash.
+const maxFileNameBytes = 160
+
+// Characters Windows doesn't allow in file names, on top of the path
+// separators everyone forbids.
+const reservedFileNameChars = `<>:"/\|?*`
+
+// Names Windows reserves for devices, with any extension.
+var reservedFileNames = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[0-9]|lpt[0-9])(\..*)?$`)
+
+// Runs of anything but letters and digits, in any script, like the spaces and
+// punctuation between the words of a game name.
+var nameSeparators = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// SanitizeFileName turns a game name into something safe to use as a file name
+// on every system: reserved and control characters become spaces, trailing
+// dots and spaces are dropped, and very long names are cut at a character
+// boundary. CJK, accents and emoji are kept as they are.
+func SanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(reservedFileNameChars, r) || r == utf8.RuneError {
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+
+	if len(name) > maxFileNameBytes {
+		cut := maxFileNameBytes
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = name[:cut]
+	}
+
+	name = strings.TrimRight(name, ". ")
+	if reservedFileNames.MatchString(name) {
+		name = "_" + name
+	}
+	if name == "" {
+		name = "_"
+	}
+	return name
+}
+
+// Glob pattern matching the file names users give their custom images for a
+// game: every run of punctuation or spaces in the name may be anything. Unicode
+// letters and digits are kept, so games named in other scripts don't match
+// every file. Returns false for names without any letter or digit.
+func gameNameGlob(name string) (string, bool) {
+	glob := nameSeparators.ReplaceAllString(name, "*")
+	if strings.Trim(glob, "*") == "" {
+		return "", false
+	}
+	return glob, true
+}
@@ -0,0 +1,85 @@
+package artwork
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Half-Life 2", "Half-Life 2"},
+		{"ファイナルファンタジーXIV", "ファイナルファンタジーXIV"},
+		{"英雄联盟", "英雄联盟"},
+		{"Pokémon 🎮 Edition", "Pokémon 🎮 Edition"},
+		{"AC/DC Live: Rock Band", "AC DC Live Rock Band"},
+		{`Back\slash "Quoted" <Angle>|Pipe?*`, "Back slash Quoted Angle Pipe"},
+		{"Tabs\tand\nnew lines", "Tabs and new lines"},
+		{"Trailing dots...", "Trailing dots"},
+		{"Trailing space. . ", "Trailing space"},
+		{"CON", "_CON"},
+		{"nul.txt", "_nul.txt"},
+		{"Com1", "_Com1"},
+		{"LPT9.png", "_LPT9.png"},
+		{"Console", "Console"},
+		{"", "_"},
+		{"///", "_"},
+		{"...", "_"},
+		{"Broken \xff byte", "Broken byte"},
+	}
+	for _, test := range tests {
+		if got := SanitizeFileName(test.name); got != test.want {
+			t.Errorf("sanitizeFileName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSanitizeFileNameLong(t *testing.T) {
+	tests := []string{
+		strings.Repeat("a", 300),
+		strings.Repeat("日本語", 100),
+		strings.Repeat("🎮", 100),
+		"x" + strings.Repeat("日本", 100),
+	}
+	for _, name := range tests {
+		got := SanitizeFileName(name)
+		if len(got) > maxFileNameBytes {
+			t.Errorf("sanitizeFileName(%q...) is %v bytes, want at most %v", name[:10], len(got), maxFileNameBytes)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("sanitizeFileName(%q...) cut a character in half: %q", name[:10], got)
+		}
+		if !strings.HasPrefix(name, got) {
+			t.Errorf("sanitizeFileName(%q...) = %q, want a prefix of the name", name[:10], got)
+		}
+	}
+}
+
+func TestGameNameGlob(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"Half-Life 2", "Half*Life*2", true},
+		{"Half-Life 2: Episode One", "Half*Life*2*Episode*One", true},
+		{"ファイナルファンタジー", "ファイナルファンタジー", true},
+		{"英雄 联盟", "英雄*联盟", true},
+		{"Pokémon", "Pokémon", true},
+		{"🎮 Party 🎉", "*Party*", true},
+		{"AC/DC", "AC*DC", true},
+		{"[Brackets] *and* ?marks?", "*Brackets*and*marks*", true},
+		{"🎮🎉", "", false},
+		{"/// ...", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		got, ok := gameNameGlob(test.name)
+		if got != test.want || ok != test.ok {
+			t.Errorf("gameNameGlob(%q) = %q, %v, want %q, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
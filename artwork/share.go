@@ -0,0 +1,76 @@
+package artwork
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// A name match shared with -sharematches. Only what helps telling which game
+// a name stands for: no user names, paths, or IDs of non-Steam games, which
+// are made from the path of their executable.
+type sharedMatch struct {
+	Name       string `json:"name"`
+	SearchName string `json:"searchName,omitempty"`
+	// Only for Steam games
+	AppID    string `json:"appId,omitempty"`
+	ArtStyle string `json:"artStyle"`
+	// "not found", or "downloaded" with the source and the SteamGridDB image
+	// chosen.
+	Status        string `json:"status"`
+	Source        string `json:"source,omitempty"`
+	SteamGridDBID int    `json:"steamGridDBId,omitempty"`
+}
+
+// Sources that find games by name, whose matches can be wrong.
+var searchedSources = map[string]bool{"SteamGridDB": true, "IGDB": true, "GOG": true, "RAWG": true, "TheGamesDB": true, "LaunchBox": true, "search": true}
+
+// Picks the entries of a run worth sharing: the images not found, and the
+// ones found by searching for the name of the game.
+func (summary *runSummary) sharedMatches() []sharedMatch {
+	var matches []sharedMatch
+	for _, entry := range summary.entries {
+		if entry.Status != "not found" && !(entry.Status == "downloaded" && searchedSources[entry.Source]) {
+			continue
+		}
+		match := sharedMatch{Name: entry.Name, ArtStyle: entry.ArtStyle, Status: entry.Status, Source: entry.Source, SteamGridDBID: entry.SteamGridDBID}
+		if entry.searchName != entry.Name {
+			match.SearchName = entry.searchName
+		}
+		if !entry.custom {
+			match.AppID = entry.GameID
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// Sends the matches of the run to the endpoint given with -sharematches, to
+// help build a better database of game names. Returns how many were sent.
+func (summary *runSummary) shareMatches(url string) (int, error) {
+	matches := summary.sharedMatches()
+	if len(matches) == 0 {
+		return 0, nil
+	}
+	body, err := json.Marshal(map[string]interface{}{"matches": matches})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	response, err := sources.DoRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	if response.StatusCode >= 300 {
+		return 0, errors.New("sharing the matches failed: " + response.Status)
+	}
+	return len(matches), nil
+}
@@ -0,0 +1,128 @@
+package artwork
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// Name of the copy of shortcuts.vdf kept before SteamGrid changes it.
+const shortcutsBackupSuffix = ".steamgrid-backup"
+
+// Reads the shortcuts.vdf of a user to change it, or returns nil when there
+// is none. Files the writer wouldn't give back byte for byte, with something
+// it doesn't know, are refused rather than risking the shortcuts.
+func readShortcutsForWriting(user User) (*steam.VDFNode, error) {
+	shortcutsVdf := filepath.Join(user.Dir, "config", "shortcuts.vdf")
+	shortcutBytes, err := ioutil.ReadFile(shortcutsVdf)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	root, err := steam.ParseBinaryVDF(shortcutBytes)
+	if err != nil {
+		return nil, err
+	}
+	// Files may end without closing the root, which is written anyway.
+	rewritten := steam.WriteBinaryVDF(root)
+	if !bytes.Equal(rewritten, shortcutBytes) && !bytes.Equal(rewritten, append(shortcutBytes, steam.VDFEnd)) {
+		return nil, errors.New("shortcuts.vdf has data SteamGrid can't write back, it was left alone")
+	}
+	return root, nil
+}
+
+// Writes the shortcuts of a user, keeping the file as it was before
+// SteamGrid first changed it in shortcuts.vdf.steamgrid-backup. Steam reads
+// the file when it starts and writes it when it exits, so changes only show
+// after a restart.
+func writeShortcuts(user User, root *steam.VDFNode) error {
+	shortcutsVdf := filepath.Join(user.Dir, "config", "shortcuts.vdf")
+	err := backupShortcuts(shortcutsVdf)
+	if err != nil {
+		return err
+	}
+
+	// Written next to it first, so a failure doesn't leave Steam with half a
+	// file.
+	tmpPath := shortcutsVdf + ".steamgrid"
+	err = ioutil.WriteFile(tmpPath, steam.WriteBinaryVDF(root), 0666)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpPath, shortcutsVdf)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Copies shortcuts.vdf to its backup, unless there already is one, which is
+// the original and must not be replaced by a file SteamGrid changed.
+func backupShortcuts(shortcutsVdf string) error {
+	original, err := ioutil.ReadFile(shortcutsVdf)
+	if err != nil {
+		return err
+	}
+	backup, err := os.OpenFile(shortcutsVdf+shortcutsBackupSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if os.IsExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = backup.Write(original)
+	if closeErr := backup.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Half a backup would be kept as the original.
+		os.Remove(backup.Name())
+	}
+	return err
+}
+
+// Leftovers of how shortcuts were added: trademark signs, the extension of
+// the executable and Windows' " - Shortcut".
+var shortcutNameJunk = regexp.MustCompile(`(?i)[™®©]|(\.exe|\.lnk|\.bat|\.sh|\.desktop| - shortcut)$`)
+
+// Returns the tidy name of a shortcut.
+func normalizeShortcutName(name string) string {
+	name = shortcutNameJunk.ReplaceAllString(name, "")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Tidies the names of the non-Steam games of a user, like "Celeste.exe" or
+// "Hades™ - Shortcut". Shortcuts without an appid get the one they had
+// written down, so renaming them doesn't change it and leave their artwork
+// behind. Returns how many shortcuts were renamed.
+func normalizeShortcutNames(user User) (int, error) {
+	root, err := readShortcutsForWriting(user)
+	if root == nil || err != nil {
+		return 0, err
+	}
+	nChanged := 0
+	for _, shortcut := range root.Child("shortcuts").Children {
+		name := shortcut.ChildString("AppName")
+		normalized := normalizeShortcutName(name)
+		if normalized == name || normalized == "" {
+			continue
+		}
+		if _, ok := shortcut.ChildInt("appid"); !ok {
+			_, legacyID := ShortcutID(shortcut)
+			shortcut.Children = append(shortcut.Children, &steam.VDFNode{Key: "appid", Type: steam.VDFInt32, Int: legacyID})
+		}
+		shortcut.SetString("AppName", normalized)
+		nChanged++
+	}
+	if nChanged == 0 {
+		return 0, nil
+	}
+	return nChanged, writeShortcuts(user, root)
+}
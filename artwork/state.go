@@ -0,0 +1,171 @@
+package artwork
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Name of the file in each grid directory where SteamGrid remembers where
+// every image it wrote came from.
+const StateFileName = "steamgrid-state.json"
+
+// What SteamGrid knows about one image it wrote to the grid directory.
+type stateEntry struct {
+	GameID   string `json:"gameId"`
+	Name     string `json:"name,omitempty"`
+	ArtStyle string `json:"artStyle"`
+	// Description of where the image was found, as in Game.ImageSource.
+	Source        string `json:"source"`
+	URL           string `json:"url,omitempty"`
+	SteamGridDBID int    `json:"steamGridDBId,omitempty"`
+	// File name in the grid directory and the hash of what was written to it.
+	File    string    `json:"file"`
+	Hash    string    `json:"hash"`
+	Updated time.Time `json:"updated"`
+}
+
+// An image picked by hand in interactive mode, or the decision to skip it.
+type stateChoice struct {
+	SteamGridDBID int    `json:"steamGridDBId,omitempty"`
+	URL           string `json:"url,omitempty"`
+	Skipped       bool   `json:"skipped,omitempty"`
+}
+
+// Contents of the state file of a grid directory.
+type gridState struct {
+	path    string
+	Images  map[string]*stateEntry  `json:"images"`
+	Choices map[string]*stateChoice `json:"choices,omitempty"`
+	// Images SteamGrid must leave alone, by key ("440/Cover"), or whole
+	// games by ID ("440").
+	Locked map[string]bool `json:"locked,omitempty"`
+}
+
+// Key of an image in the state file, readable enough to be edited by hand.
+func stateKey(gameID string, artStyle string) string {
+	return gameID + "/" + artStyle
+}
+
+// LoadGridState loads the state of a grid directory. A missing file is an
+// empty state.
+func LoadGridState(gridDir string) (*gridState, error) {
+	state := &gridState{path: filepath.Join(gridDir, StateFileName), Images: map[string]*stateEntry{}, Choices: map[string]*stateChoice{}, Locked: map[string]bool{}}
+
+	stateBytes, err := ioutil.ReadFile(state.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(stateBytes, state)
+	if state.Images == nil {
+		state.Images = map[string]*stateEntry{}
+	}
+	if state.Choices == nil {
+		state.Choices = map[string]*stateChoice{}
+	}
+	if state.Locked == nil {
+		state.Locked = map[string]bool{}
+	}
+	return state, err
+}
+
+// Save writes the state back to its grid directory.
+func (state *gridState) Save() error {
+	if state == nil {
+		return nil
+	}
+	stateBytes, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(state.path, stateBytes, 0666)
+}
+
+// Entry returns what is known about an image, or nil.
+func (state *gridState) Entry(gameID string, artStyle string) *stateEntry {
+	if state == nil {
+		return nil
+	}
+	return state.Images[stateKey(gameID, artStyle)]
+}
+
+// Tells if an image was processed in an earlier run, is still in the grid
+// directory and, unless maxAge is 0, was processed less than maxAge ago.
+func (state *gridState) isCurrent(gridDir string, gameID string, artStyle string, maxAge time.Duration) bool {
+	entry := state.Entry(gameID, artStyle)
+	if entry == nil || (maxAge > 0 && time.Since(entry.Updated) > maxAge) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(gridDir, entry.File))
+	return err == nil
+}
+
+// Returns the image picked by hand for a game, or nil.
+func (state *gridState) choice(gameID string, artStyle string) *stateChoice {
+	if state == nil {
+		return nil
+	}
+	return state.Choices[stateKey(gameID, artStyle)]
+}
+
+// Remembers the image picked by hand for a game. Without a state, as in the
+// get command, the choice is simply not remembered.
+func (state *gridState) setChoice(gameID string, artStyle string, choice *stateChoice) {
+	if state == nil {
+		return
+	}
+	state.Choices[stateKey(gameID, artStyle)] = choice
+}
+
+// Tells if an image, or the whole game, was locked by the user.
+func (state *gridState) isLocked(gameID string, artStyle string) bool {
+	if state == nil {
+		return false
+	}
+	return state.Locked[gameID] || state.Locked[stateKey(gameID, artStyle)]
+}
+
+// SetLocked locks or unlocks an image, or a whole game when artStyle is empty.
+func (state *gridState) SetLocked(gameID string, artStyle string, locked bool) {
+	key := gameID
+	if artStyle != "" {
+		key = stateKey(gameID, artStyle)
+	}
+	if locked {
+		state.Locked[key] = true
+	} else {
+		delete(state.Locked, key)
+	}
+}
+
+// Remembers the image just written for a game.
+func (state *gridState) record(game *Game, artStyle string, fileName string) {
+	entry := &stateEntry{
+		GameID:   game.ID,
+		Name:     game.Name,
+		ArtStyle: artStyle,
+		Source:   game.ImageSource,
+		URL:      game.ImageURL,
+		File:     fileName,
+		Hash:     ImageHash(game.OverlayImageBytes),
+		Updated:  time.Now(),
+	}
+	if game.ImageSource == "SteamGridDB" {
+		entry.SteamGridDBID = game.SteamGridDBID
+	}
+
+	// Images SteamGrid wrote before and loaded back from disk (the backup, or
+	// the image itself when it had no overlay) keep where they came from.
+	previous := state.Entry(game.ID, artStyle)
+	if previous != nil && game.ImageURL == "" && (game.ImageSource == "backup" || ImageHash(game.CleanImageBytes) == previous.Hash) {
+		entry.Source = previous.Source
+		entry.URL = previous.URL
+		entry.SteamGridDBID = previous.SteamGridDBID
+	}
+	state.Images[stateKey(game.ID, artStyle)] = entry
+}
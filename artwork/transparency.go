@@ -0,0 +1,144 @@
+package artwork
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/png"
+
+	"github.com/kmicki/steamgrid/overlay"
+)
+
+// What to do with logos that have no transparency.
+var opaqueLogoModes = []string{"warn", "clear", "reject"}
+
+// Logos with less than this share of transparent pixels are taken as baked
+// onto a background.
+const opaqueLogoMaxTransparent = 0.01
+
+// How far, per channel, a pixel can be from the color of a corner to be
+// cleared with the background. Up to twice as far, the pixels on the edge of
+// the logo are made partly transparent to keep it smooth.
+const logoBackgroundTolerance = 24
+
+// Clearing more than this share of the image means the fill went through the
+// logo itself.
+const logoBackgroundMaxCleared = 0.9
+
+// Decodes a still JPEG or PNG logo, or returns nil for animations and other
+// formats.
+func decodeStillLogo(imageBytes []byte) *image.NRGBA {
+	isJPEG := bytes.HasPrefix(imageBytes, []byte("\xff\xd8"))
+	isPNG := bytes.HasPrefix(imageBytes, []byte("\x89PNG")) && !overlay.IsAnimatedPNG(imageBytes)
+	if !isJPEG && !isPNG {
+		return nil
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return nil
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, decoded.Bounds().Dx(), decoded.Bounds().Dy()))
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+	return img
+}
+
+// Share of the pixels of an image that are mostly transparent.
+func transparentShare(img *image.NRGBA) float64 {
+	nTransparent := 0
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] < 128 {
+			nTransparent++
+		}
+	}
+	return float64(nTransparent) / float64(len(img.Pix)/4)
+}
+
+// Whether a logo is a still image without transparency, which looks like a
+// box over the hero. Animations aren't checked.
+func isOpaqueLogo(imageBytes []byte) bool {
+	img := decodeStillLogo(imageBytes)
+	return img != nil && transparentShare(img) < opaqueLogoMaxTransparent
+}
+
+// Largest difference between the channels of two pixels.
+func colorDistance(a []uint8, b []uint8) int {
+	distance := 0
+	for channel := 0; channel < 3; channel++ {
+		d := int(a[channel]) - int(b[channel])
+		if d < 0 {
+			d = -d
+		}
+		if d > distance {
+			distance = d
+		}
+	}
+	return distance
+}
+
+// Makes the background of a logo transparent: flood fills from each corner
+// the pixels close to its color. Returns the share of the image cleared.
+func clearLogoBackground(img *image.NRGBA) float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	visited := make([]bool, width*height)
+	nCleared := 0
+	corners := []image.Point{{0, 0}, {width - 1, 0}, {0, height - 1}, {width - 1, height - 1}}
+	for _, corner := range corners {
+		if visited[corner.Y*width+corner.X] {
+			continue
+		}
+		seed := append([]uint8(nil), img.Pix[img.PixOffset(corner.X, corner.Y):][:4]...)
+		stack := []image.Point{corner}
+		visited[corner.Y*width+corner.X] = true
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			i := img.PixOffset(p.X, p.Y)
+			distance := colorDistance(img.Pix[i:i+4], seed)
+			if distance > logoBackgroundTolerance {
+				// The edge of the logo, kept partly.
+				img.Pix[i+3] = uint8(int(img.Pix[i+3]) * (distance - logoBackgroundTolerance) / logoBackgroundTolerance)
+				continue
+			}
+			img.Pix[i+3] = 0
+			nCleared++
+			for _, next := range []image.Point{{p.X - 1, p.Y}, {p.X + 1, p.Y}, {p.X, p.Y - 1}, {p.X, p.Y + 1}} {
+				if next.X < 0 || next.Y < 0 || next.X >= width || next.Y >= height || visited[next.Y*width+next.X] {
+					continue
+				}
+				visited[next.Y*width+next.X] = true
+				j := img.PixOffset(next.X, next.Y)
+				if colorDistance(img.Pix[j:j+4], seed) <= 2*logoBackgroundTolerance {
+					stack = append(stack, next)
+				}
+			}
+		}
+	}
+	return float64(nCleared) / float64(width*height)
+}
+
+// Clears the solid background of a logo without transparency, making it a
+// PNG. Works on game.CleanImageBytes, like normalizeColors. Returns whether
+// the logo was opaque and cleared.
+func clearOpaqueLogo(game *Game) (bool, error) {
+	img := decodeStillLogo(game.CleanImageBytes)
+	if img == nil || transparentShare(img) >= opaqueLogoMaxTransparent {
+		return false, nil
+	}
+	cleared := clearLogoBackground(img)
+	if cleared < opaqueLogoMaxTransparent {
+		return false, errors.New("the logo has no transparency and no solid background to clear")
+	} else if cleared > logoBackgroundMaxCleared {
+		return false, errors.New("the logo has no transparency and clearing its background would clear the logo too")
+	}
+
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, img)
+	if err != nil {
+		return false, err
+	}
+	game.CleanImageBytes = buf.Bytes()
+	game.ImageExt = ".png"
+	return true, nil
+}
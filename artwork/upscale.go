@@ -0,0 +1,113 @@
+package artwork
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kmicki/steamgrid/overlay"
+)
+
+// Splits a command line into its arguments at spaces, keeping quoted
+// arguments, like paths with spaces, whole.
+func splitCommand(command string) []string {
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	started := false
+	for _, c := range command {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+			started = true
+		case quote == 0 && (c == ' ' || c == '\t'):
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			arg.WriteRune(c)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// Checks an -upscalecmd command line.
+func checkUpscaleCommand(command string) error {
+	args := splitCommand(command)
+	if len(args) == 0 {
+		return errors.New("no command given")
+	} else if !strings.Contains(command, "{in}") || !strings.Contains(command, "{out}") {
+		return errors.New("the command needs {in} and {out}, the paths of the image and of the upscaled one")
+	}
+	return nil
+}
+
+// Runs an external upscaler, the command of -upscalecmd, on the downloaded
+// image of a game smaller than the size Steam shows its art style at. {in}
+// and {out} are replaced with the paths of the image and of the upscaled one,
+// a PNG. Animations are left as they are. Returns whether it was upscaled.
+func upscaleImage(game *Game, artStyle string, command string) (bool, error) {
+	imageBytes := game.CleanImageBytes
+	sizes, ok := canonicalSizes[artStyle]
+	if command == "" || !ok || game.ImageExt == ".ico" || overlay.IsAnimatedWebp(imageBytes) || overlay.IsAnimatedPNG(imageBytes) {
+		return false, nil
+	}
+	size, err := overlay.ImageSize(imageBytes, false)
+	if err != nil {
+		return false, err
+	}
+	if size.X >= sizes[0].X && size.Y >= sizes[0].Y {
+		return false, nil
+	}
+
+	dir, err := ioutil.TempDir("", "steamgrid-upscale")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in"+game.ImageExt)
+	out := filepath.Join(dir, "out.png")
+	err = ioutil.WriteFile(in, imageBytes, 0666)
+	if err != nil {
+		return false, err
+	}
+
+	args := splitCommand(command)
+	for i, arg := range args {
+		args[i] = strings.Replace(strings.Replace(arg, "{in}", in, -1), "{out}", out, -1)
+	}
+	output, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return false, errors.New("upscaler failed: " + strings.TrimSpace(err.Error()+"\n"+string(output)))
+	}
+	upscaled, err := ioutil.ReadFile(out)
+	if err != nil {
+		return false, errors.New("upscaler wrote no image: " + err.Error())
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(upscaled))
+	if err != nil {
+		return false, errors.New("upscaler wrote no image: " + err.Error())
+	} else if config.Width <= size.X && config.Height <= size.Y {
+		return false, errors.New("upscaler gave an image no bigger than the original")
+	}
+
+	game.CleanImageBytes = upscaled
+	game.ImageExt = ".png"
+	if format == "jpeg" {
+		game.ImageExt = ".jpg"
+	}
+	return true, nil
+}
@@ -0,0 +1,48 @@
+package artwork
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/kmicki/steamgrid/sources"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// User in the local steam installation.
+type User = steam.User
+
+// URL to get the game list from the SteamId64.
+const profilePermalinkFormat = `http://steamcommunity.com/profiles/%v/games?tab=all`
+
+// The Steam website has the terrible habit of returning 200 OK when requests
+// fail, and signaling the error in HTML. So we have to parse the request to
+// check if it has failed, and cross our fingers that they don't change the
+// message.
+const steamProfileErrorMessage = `The specified profile could not be found.`
+
+// GetProfile returns the HTML profile from a user from their SteamId32.
+func GetProfile(user User) (string, error) {
+	response, err := sources.HTTPGet(fmt.Sprintf(profilePermalinkFormat, user.SteamID64))
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode >= 400 {
+		return "", errors.New("profile not found. Make sure you have a public Steam profile")
+	}
+
+	contentBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	profile := string(contentBytes)
+	if strings.Contains(profile, steamProfileErrorMessage) {
+		return "", errors.New("profile not found")
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kmicki/steamgrid/artwork"
+)
+
+// Restores the original artwork of every user, removing the overlays.
+func restoreCommand(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	options := &artwork.Options{}
+	registerInstallationFlags(flags, options)
+	flags.StringVar(&options.AppIDs, "appids", "", "Comma separated list of appIds that should be restored")
+	flags.BoolVar(&options.Force, "force", false, "Also restore the artwork locked with the lock command")
+	parseOptions(flags, options, args)
+
+	var appIDs []string
+	if options.AppIDs != "" {
+		appIDs = strings.Split(options.AppIDs, ",")
+	}
+
+	for _, user := range loadUsers(options) {
+		gridDir := filepath.Join(user.Dir, "config", "grid")
+		nRestored, err := artwork.RestoreBackups(gridDir, appIDs, options.Force)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		fmt.Printf("%v images restored for %v\n", nRestored, user.Name)
+	}
+}
+
+// Lists which artwork each game has, without changing anything.
+func reportCommand(args []string) {
+	reportOrAudit("report", false, args)
+}
+
+// Like report, but always looking for artwork that doesn't fit.
+func auditCommand(args []string) {
+	reportOrAudit("audit", true, args)
+}
+
+func reportOrAudit(name string, lint bool, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	options := &artwork.Options{}
+	registerInstallationFlags(flags, options)
+	registerLibraryFlags(flags, options)
+	registerLintFlags(flags, options)
+	flags.StringVar(&options.Styles, "styles", "", "Cover styles you asked SteamGridDB for, for the logo check of -lint")
+	parseOptions(flags, options, args)
+	options.Lint = options.Lint || lint
+
+	artStyles, err := options.ArtStyles()
+	if err != nil {
+		errorAndExit(err)
+	}
+	var styleNames []string
+	for artStyle := range artStyles {
+		styleNames = append(styleNames, artStyle)
+	}
+	sort.Strings(styleNames)
+
+	excluded, err := artwork.ParseAppIDSet(options.ExcludeAppIDs)
+	if err != nil {
+		errorAndExit(err)
+	}
+
+	for _, user := range loadUsers(options) {
+		fmt.Println("Loading games for " + user.Name)
+		gridDir := filepath.Join(user.Dir, "config", "grid")
+		games := artwork.GetGames(user, options.GameFilter(excluded), options.SteamApiKey)
+
+		missing := map[string]int{}
+		for _, game := range games {
+			name := artwork.ResolveGameName(game)
+			if len(options.NameFilter) > 0 && !strings.Contains(name, options.NameFilter) {
+				continue
+			}
+
+			var statuses []string
+			for _, artStyle := range styleNames {
+				status := artwork.GridImageStatus(gridDir, game.ID, artStyles[artStyle])
+				if status == "missing" {
+					missing[artStyle]++
+				}
+				statuses = append(statuses, artStyle+" "+status)
+			}
+			fmt.Printf("- %v (id %v): %v\n", name, game.ID, strings.Join(statuses, ", "))
+		}
+
+		fmt.Printf("\n%v games for %v.", len(games), user.Name)
+		for _, artStyle := range styleNames {
+			fmt.Printf(" %v missing: %v.", artStyle, missing[artStyle])
+		}
+		fmt.Printf("\n\n")
+
+		if options.Lint {
+			artwork.PrintLintFindings(artwork.LintGrid(user, gridDir, games, artStyles, options))
+		}
+	}
+}
+
+// Removes the backups of images that have since been replaced or deleted, so
+// nothing would ever restore them.
+func cleanCommand(args []string) {
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	options := &artwork.Options{}
+	registerInstallationFlags(flags, options)
+	dryRun := flags.Bool("dryrun", false, "Only list the backups that would be removed")
+	parseOptions(flags, options, args)
+
+	for _, user := range loadUsers(options) {
+		gridDir := filepath.Join(user.Dir, "config", "grid")
+		byHash, err := artwork.GridImagesByHash(gridDir)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		backups, err := artwork.GridBackups(gridDir)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+
+		nRemoved := 0
+		var freed int64
+		for _, backup := range backups {
+			_, hash, ok := artwork.ParseBackupFileName(filepath.Base(backup))
+			if !ok || len(byHash[hash]) > 0 {
+				continue
+			}
+
+			info, err := os.Stat(backup)
+			if err != nil {
+				continue
+			}
+			fmt.Println("Unused backup " + backup)
+			if !*dryRun {
+				err = os.Remove(backup)
+				if err != nil {
+					fmt.Println(err.Error())
+					continue
+				}
+			}
+			nRemoved++
+			freed += info.Size()
+		}
+
+		if *dryRun {
+			fmt.Printf("%v unused backups (%v MiB) would be removed for %v\n", nRemoved, bToMb(uint64(freed)), user.Name)
+		} else {
+			fmt.Printf("%v unused backups (%v MiB) removed for %v\n", nRemoved, bToMb(uint64(freed)), user.Name)
+		}
+	}
+}
+
+// Marks games, or single art styles of them, as locked in the state file of
+// every user, or unlocks them.
+func setLocks(name string, args []string, locked bool) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	options := &artwork.Options{}
+	registerInstallationFlags(flags, options)
+	flags.StringVar(&options.AppIDs, "appids", "", "Comma separated list of appIds, required")
+	style := flags.String("style", "", "Only this art style (banner, cover, hero, logo or icon) instead of all of them")
+	parseOptions(flags, options, args)
+	if options.AppIDs == "" {
+		fmt.Fprintln(os.Stderr, "No games given, use -appids.")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	artStyle := ""
+	if *style != "" {
+		var ok bool
+		artStyle, _, ok = findArtStyle(artwork.MakeArtStyles("", "", "", "", ""), *style)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown art style %v.\n", *style)
+			os.Exit(2)
+		}
+	}
+
+	for _, user := range loadUsers(options) {
+		gridDir := filepath.Join(user.Dir, "config", "grid")
+		state, err := artwork.LoadGridState(gridDir)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		for _, gameID := range strings.Split(options.AppIDs, ",") {
+			state.SetLocked(strings.TrimSpace(gameID), artStyle, locked)
+		}
+		err = state.Save()
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		fmt.Printf("%v locked games and images for %v\n", len(state.Locked), user.Name)
+	}
+}
+
+func lockCommand(args []string) {
+	setLocks("lock", args, true)
+}
+
+func unlockCommand(args []string) {
+	setLocks("unlock", args, false)
+}
+
+// Reports how much space the grid directory of every user takes and its
+// largest files, and with -compress makes them smaller.
+func sizeCommand(args []string) {
+	flags := flag.NewFlagSet("size", flag.ExitOnError)
+	options := &artwork.Options{}
+	registerInstallationFlags(flags, options)
+	top := flags.Int("top", 10, "Number of largest files to list")
+	compress := flags.Bool("compress", false, "Recompress PNGs without losing anything, and JPEGs over -maxjpeg")
+	maxJPEG := flags.Int("maxjpeg", 500, "Size in KB over which -compress encodes JPEGs again, at quality 90")
+	parseOptions(flags, options, args)
+
+	for _, user := range loadUsers(options) {
+		gridDir := filepath.Join(user.Dir, "config", "grid")
+		files, total, err := artwork.GridFiles(gridDir)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		fmt.Printf("\n%v: %v in %v files\n", user.Name, artwork.FormatSize(total), len(files))
+		for i, file := range files {
+			if i >= *top {
+				break
+			}
+			relPath, _ := filepath.Rel(gridDir, file.Path)
+			fmt.Printf("%10v  %v\n", artwork.FormatSize(file.Size), relPath)
+		}
+
+		if *compress {
+			fmt.Println("Compressing...")
+			saved, err := artwork.CompressGrid(gridDir, int64(*maxJPEG)*1024)
+			if err != nil {
+				fmt.Println(err.Error())
+			}
+			fmt.Printf("Saved %v\n", artwork.FormatSize(saved))
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/sources"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// Completion scripts for each shell. They all ask the executable what can
+// come next with "steamgrid __complete <words>", the word being completed
+// last, which prints one candidate per line, optionally followed by a tab
+// and a description. Without candidates, file names are completed.
+var completionScripts = map[string]string{
+	"bash": `_steamgrid() {
+    local IFS=$'\n'
+    local candidates=($(steamgrid __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    COMPREPLY=()
+    local candidate
+    for candidate in "${candidates[@]}"; do
+        COMPREPLY+=("${candidate%%$'\t'*}")
+    done
+}
+complete -o default -F _steamgrid steamgrid
+`,
+	"zsh": `#compdef steamgrid
+_steamgrid() {
+    local -a candidates
+    candidates=("${(@f)$(steamgrid __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    candidates=("${(@)candidates//:/\\:}")
+    candidates=("${(@)candidates//$'\t'/:}")
+    if [[ -n "${candidates[1]}" ]]; then
+        _describe 'steamgrid' candidates
+    else
+        _files
+    fi
+}
+compdef _steamgrid steamgrid
+`,
+	"fish": `function __steamgrid_complete
+    set -l words (commandline -opc)[2..-1] (commandline -ct)
+    steamgrid __complete $words 2>/dev/null
+end
+complete -c steamgrid -a '(__steamgrid_complete)'
+`,
+	"powershell": `Register-ArgumentCompleter -Native -CommandName steamgrid,steamgrid.exe -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '""' }
+    steamgrid __complete @words 2>$null | ForEach-Object {
+        $value, $description = $_ -split "` + "`" + `t", 2
+        if (-not $description) { $description = $value }
+        [System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $description)
+    }
+}
+`,
+}
+
+// Prints the completion script of a shell.
+func completionCommand(args []string) {
+	flags := flag.NewFlagSet("completion", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: steamgrid completion bash|zsh|fish|powershell\n\nFor bash, add to ~/.bashrc: source <(steamgrid completion bash)")
+		flags.PrintDefaults()
+	}
+	parseFlags(flags, args)
+	script, ok := completionScripts[flags.Arg(0)]
+	if flags.NArg() != 1 || !ok {
+		flags.Usage()
+		os.Exit(2)
+	}
+	fmt.Print(script)
+}
+
+// The word being completed and the one before it, set while answering
+// "steamgrid __complete".
+var completing *struct {
+	current  string
+	previous string
+}
+
+// Answers the completion scripts: prints what can follow the words of a
+// command line, the last one being the one completed.
+func completeCommandLine(words []string) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	// PowerShell can't pass empty arguments.
+	if current == `""` {
+		current = ""
+	}
+
+	if len(words) == 1 && !strings.HasPrefix(current, "-") {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if strings.HasPrefix(name, current) {
+				fmt.Printf("%v\t%v\n", name, commands[name].description)
+			}
+		}
+		return
+	}
+
+	run := startApplication
+	if command, ok := commands[words[0]]; ok && len(words) > 1 {
+		run = command.run
+		words = words[1:]
+	}
+	completing = &struct {
+		current  string
+		previous string
+	}{current: current}
+	if len(words) >= 2 {
+		completing.previous = words[len(words)-2]
+	}
+	// Stops in parseFlags, once the flags of the command are known.
+	run(nil)
+}
+
+// Prints the completions of the flags of a command, or of the value of one,
+// and quits.
+func completeFlags(flags *flag.FlagSet) {
+	current, previous := completing.current, completing.previous
+	var candidates []string
+
+	valueOf := flags.Lookup(strings.TrimLeft(previous, "-"))
+	isBool := false
+	if valueOf != nil {
+		boolFlag, ok := valueOf.Value.(interface{ IsBoolFlag() bool })
+		isBool = ok && boolFlag.IsBoolFlag()
+	}
+
+	if strings.HasPrefix(current, "-") {
+		flags.VisitAll(func(f *flag.Flag) {
+			// Only the first line of the usage, the rest are examples.
+			usage := strings.SplitN(f.Usage, "\n", 2)[0]
+			candidates = append(candidates, "-"+f.Name+"\t"+usage)
+		})
+	} else if strings.HasPrefix(previous, "-") && valueOf != nil && !isBool && !strings.Contains(previous, "=") {
+		// Lists complete their last item.
+		prefix := ""
+		if i := strings.LastIndex(current, ","); i >= 0 {
+			prefix = current[:i+1]
+		}
+		for _, value := range flagValues(valueOf.Name) {
+			candidates = append(candidates, prefix+value)
+		}
+	}
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Println(candidate)
+		}
+	}
+	os.Exit(0)
+}
+
+// Returns the values a flag takes, for the ones with a known set, with
+// descriptions after a tab.
+func flagValues(name string) []string {
+	switch {
+	case name == "appids" || name == "excludeappids" || name == "appid":
+		return libraryAppIDs()
+	case name == "style":
+		return []string{"banner", "cover", "hero", "logo", "icon"}
+	case name == "force-source" || strings.HasPrefix(name, "sources-"):
+		return []string{"librarycache", "steam", "steamgriddb", "itch", "gog", "igdb", "rawg", "thegamesdb", "launchbox", "google"}
+	case name == "searchprovider":
+		return []string{"google", "bing", "duckduckgo", "searxng"}
+	case name == "language":
+		var codes []string
+		for code := range sources.SteamLanguages {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		return codes
+	case name == "deck":
+		return []string{"lcd", "oled"}
+	case name == "logoposition":
+		return []string{"bottomleft", "upperleft", "centercenter", "uppercenter", "bottomcenter", "none"}
+	case name == "types":
+		return []string{"static", "animated"}
+	case name == "nsfw" || name == "humor":
+		return []string{"false", "true", "any"}
+	}
+	return nil
+}
+
+// Lists the games of the local Steam installation, with their names when
+// known, without touching the network.
+func libraryAppIDs() []string {
+	installationDir, err := steam.GetSteamInstallation("")
+	if err != nil {
+		return nil
+	}
+	// Loading games may print errors, which would end up among the
+	// candidates.
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() {
+		os.Stdout = stdout
+	}()
+	sources.OfflineMode = true
+	userDirs, err := steam.FindUserDirs(installationDir)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var values []string
+	for _, userDir := range userDirs {
+		for id, game := range artwork.GetGames(artwork.User{Dir: userDir}, artwork.GameFilter{IncludePrivate: true, IncludeHidden: true}, "") {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			values = append(values, strings.TrimSpace(id+"\t"+game.Name))
+		}
+	}
+	sort.Strings(values)
+	return values
+}
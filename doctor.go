@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// Prints the outcome of one check of the doctor command, returning whether
+// it passed.
+func doctorCheck(what string, err error) bool {
+	if err != nil {
+		fmt.Printf("[FAIL] %v: %v\n", what, err.Error())
+		return false
+	}
+	fmt.Printf("[ OK ] %v\n", what)
+	return true
+}
+
+// Tells if a file can be created in a directory, creating it if needed.
+func checkWritable(dir string) error {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(dir, ".steamgrid-doctor")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// Checks everything a run depends on, without changing anything: the Steam
+// installation and its users, the directories SteamGrid writes to, the
+// network and the API keys.
+func doctorCommand(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	options := &artwork.Options{}
+	registerInstallationFlags(flags, options)
+	flags.StringVar(&options.SteamGridDBApiKey, "steamgriddb", "", "Your personal SteamGridDB api key, to check it")
+	flags.StringVar(&options.IGDBClient, "igdbclient", "", "Your personal IGDB client ID, to check it")
+	flags.StringVar(&options.IGDBSecret, "igdbsecret", "", "Your personal IGDB client secret, to check it")
+	flags.StringVar(&options.TheGamesDBApiKey, "thegamesdb", "", "Your personal TheGamesDB api key, to check it")
+	flags.StringVar(&options.RAWGApiKey, "rawgkey", "", "Your personal RAWG api key, to check it")
+	flags.StringVar(&options.SteamApiKey, "steamapikey", "", "Your Steam Web API key, to check it with every user")
+	parseOptions(flags, options, args)
+
+	healthy := true
+	var users []steam.User
+	installationDir, err := steam.GetSteamInstallation(options.SteamDir)
+	healthy = doctorCheck("Steam installation found "+installationDir, err) && healthy
+	if err == nil {
+		lockPath := filepath.Join(installationDir, artwork.InstanceLockFileName)
+		if _, err := os.Stat(lockPath); err == nil {
+			healthy = doctorCheck("No other SteamGrid running", errors.New(lockPath+" exists, delete it if no SteamGrid is running")) && healthy
+		}
+
+		users, err = steam.GetUsers(installationDir)
+		if err == nil && len(users) == 0 {
+			err = errors.New("no users in userdata")
+		}
+		healthy = doctorCheck(strconv.Itoa(len(users))+" users found", err) && healthy
+		for _, user := range users {
+			gridDir := filepath.Join(user.Dir, "config", "grid")
+			healthy = doctorCheck("Grid directory of "+user.Name+" is writable", checkWritable(gridDir)) && healthy
+			if _, err := artwork.LoadGridState(gridDir); err != nil {
+				healthy = doctorCheck("State file of "+user.Name+" is readable", err) && healthy
+			}
+			if queue, err := artwork.LoadRetryQueue(gridDir); err == nil && len(queue.Items) > 0 {
+				fmt.Printf("[INFO] %v images of %v wait for -retryqueue\n", len(queue.Items), user.Name)
+			}
+		}
+	}
+
+	overlays, err := overlay.LoadOverlays(filepath.Join(filepath.Dir(os.Args[0]), "overlays by category"), artwork.MakeArtStyles("", "", "", "", ""))
+	if err == nil {
+		fmt.Printf("[INFO] %v overlays found\n", len(overlays))
+	}
+	healthy = doctorCheck("Cache directory "+sources.CacheDir()+" is writable", checkWritable(sources.CacheDir())) && healthy
+	if overlay.HasFFmpeg() {
+		fmt.Println("[INFO] ffmpeg found, WEBM animations are converted and -useffmpeg works")
+	} else {
+		fmt.Println("[INFO] ffmpeg not on the PATH, WEBM animations are skipped")
+	}
+
+	if sources.OfflineMode {
+		fmt.Println("[INFO] Offline, skipping the network checks")
+	} else {
+		response, err := sources.TryDownload(fmt.Sprintf(sources.AkamaiURLFormat+"header.jpg", "440"))
+		if err == nil && response == nil {
+			err = errors.New("the Team Fortress 2 banner is missing")
+		} else if err == nil {
+			response.Body.Close()
+		}
+		healthy = doctorCheck("Steam servers reachable", err) && healthy
+
+		if options.SteamGridDBApiKey != "" {
+			_, err = sources.SteamGridDBGetRequest(sources.SteamGridDBBaseURL+"/games/steam/440", options.SteamGridDBApiKey)
+			if err != nil && err.Error() == "401" {
+				err = errors.New("the API key is wrong")
+			}
+			healthy = doctorCheck("SteamGridDB API key works", err) && healthy
+		}
+		if options.IGDBClient != "" && options.IGDBSecret != "" {
+			_, err = sources.GetIGDBToken(options.IGDBSecret, options.IGDBClient)
+			healthy = doctorCheck("IGDB client ID and secret work", err) && healthy
+		}
+		if options.TheGamesDBApiKey != "" {
+			_, err = sources.TheGamesDBGameID("Super Metroid", options.TheGamesDBApiKey, 0)
+			healthy = doctorCheck("TheGamesDB API key works", err) && healthy
+		}
+		if options.RAWGApiKey != "" {
+			_, err = sources.GetRAWGImage(&sources.Game{Name: "Portal"}, options.RAWGApiKey, 0)
+			healthy = doctorCheck("RAWG API key works", err) && healthy
+		}
+		if options.SteamApiKey != "" {
+			for _, user := range users {
+				err = artwork.AddOwnedGames(user.SteamID64, map[string]*artwork.Game{}, options.SteamApiKey)
+				healthy = doctorCheck("Steam Web API key works for "+user.Name, err) && healthy
+			}
+		}
+	}
+
+	if !healthy {
+		artwork.UnlockInstallation()
+		os.Exit(1)
+	}
+	fmt.Println("\nEverything looks fine.")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kmicki/steamgrid/artwork"
+)
+
+// Collects the artwork of a grid directory as pack files, by path in the
+// pack: the backups without overlays when there are any, the images
+// themselves otherwise. Fills in the manifest.
+func collectPackFiles(gridDir string, manifest *artwork.PackManifest) (map[string][]byte, error) {
+	artStyles := artwork.MakeArtStyles("", "", "", "", "")
+	infos, err := ioutil.ReadDir(gridDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, info := range infos {
+		gameID, artStyle, ok := artwork.ParseGridFileName(info.Name(), artStyles)
+		if info.IsDir() || !ok || len(artwork.FilterForImages([]string{info.Name()})) == 0 {
+			continue
+		}
+		imageBytes, err := ioutil.ReadFile(filepath.Join(gridDir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ext := filepath.Ext(info.Name())
+
+		gridName := strings.TrimSuffix(info.Name(), ext)
+		backups, _ := filepath.Glob(filepath.Join(gridDir, "originals", gridName+" "+artwork.ImageHash(imageBytes)+".*"))
+		if backups = artwork.FilterForImages(backups); len(backups) > 0 {
+			if backupBytes, err := ioutil.ReadFile(backups[0]); err == nil {
+				imageBytes, ext = backupBytes, filepath.Ext(backups[0])
+			}
+		}
+
+		name := path.Join(gameID, strings.ToLower(artStyle)+ext)
+		files[name] = imageBytes
+		if manifest.Games[gameID] == nil {
+			manifest.Games[gameID] = make(map[string]string)
+		}
+		manifest.Games[gameID][strings.ToLower(artStyle)] = name
+	}
+	return files, nil
+}
+
+// Writes an artwork pack to a directory, or to a zip file when the path ends
+// in .zip.
+func writePack(out string, manifest artwork.PackManifest, files map[string][]byte) error {
+	manifestBytes, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	files[artwork.PackManifestName] = manifestBytes
+
+	if !strings.EqualFold(filepath.Ext(out), ".zip") {
+		for name, fileBytes := range files {
+			filePath := filepath.Join(out, filepath.FromSlash(name))
+			err = os.MkdirAll(filepath.Dir(filePath), 0777)
+			if err == nil {
+				err = ioutil.WriteFile(filePath, fileBytes, 0666)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	zipFile, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(zipFile)
+	for name, fileBytes := range files {
+		// Images are compressed already.
+		writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+		if err == nil {
+			_, err = writer.Write(fileBytes)
+		}
+		if err != nil {
+			zipFile.Close()
+			return err
+		}
+	}
+	err = archive.Close()
+	if closeErr := zipFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Exports the artwork of every user as a pack, to move it to another
+// computer or share it, and use it there with -packs.
+func exportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	options := &artwork.Options{}
+	registerInstallationFlags(flags, options)
+	out := flags.String("out", "", "Directory or .zip file to write the pack to, required. With several users, their names are added to it")
+	name := flags.String("name", "", "Name of the pack, in its manifest")
+	parseOptions(flags, options, args)
+	if *out == "" {
+		errorAndExit(errors.New("no pack to write, use -out"))
+	}
+
+	users := loadUsers(options)
+	for _, user := range users {
+		manifest := artwork.PackManifest{Name: *name, Games: make(map[string]map[string]string)}
+		if manifest.Name == "" {
+			manifest.Name = "Artwork of " + user.Name
+		}
+		files, err := collectPackFiles(filepath.Join(user.Dir, "config", "grid"), &manifest)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+
+		userOut := *out
+		if len(users) > 1 {
+			ext := filepath.Ext(userOut)
+			if !strings.EqualFold(ext, ".zip") {
+				ext = ""
+			}
+			userOut = strings.TrimSuffix(userOut, ext) + "-" + artwork.SanitizeFileName(user.Name) + ext
+		}
+		err = writePack(userOut, manifest, files)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		fmt.Printf("Exported %v images of %v games for %v to %v\n", len(files)-1, len(manifest.Games), user.Name, userOut)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Registers the flags selecting where and how images are searched.
+func registerSourceFlags(flags *flag.FlagSet, options *artwork.Options) {
+	flags.StringVar(&options.SteamGridDBApiKey, "steamgriddb", "", "Your personal SteamGridDB api key, get one here: https://www.steamgriddb.com/profile/preferences")
+	flags.StringVar(&options.IGDBSecret, "igdbsecret", "", "Your personal IGDB api key, get one here: https://api.igdb.com/signup")
+	flags.StringVar(&options.IGDBClient, "igdbclient", "", "Your personal IGDB api key, get one here: https://api.igdb.com/signup")
+	flags.StringVar(&options.RAWGApiKey, "rawgkey", "", "Your personal RAWG api key, get one here: https://rawg.io/apidocs")
+	flags.BoolVar(&options.LaunchBox, "launchbox", false, "Also get covers and logos from the LaunchBox Games Database, whose metadata (over 100 MB) is downloaded and kept in the cache directory for a week")
+	flags.StringVar(&options.TheGamesDBApiKey, "thegamesdb", "", "Your personal TheGamesDB api key, request one here: https://api.thegamesdb.net/key.php")
+	// "alternate" "blurred" "white_logo" "material" "no_logo"
+	flags.StringVar(&options.Styles, "styles", "alternate", "Comma separated list of styles to download from SteamGridDB.\nExample: \"white_logo,material\"")
+	flags.StringVar(&options.LogoStyles, "logostyles", "official", "Comma separated list of styles to download from SteamGridDB.\nExample: \"white,black\"")
+	flags.StringVar(&options.HeroStyles, "herostyles", "alternate", "Comma separated list of styles to download from SteamGridDB.\nExample: \"material,blurred\"")
+	flags.StringVar(&options.IconStyles, "iconstyles", "official,custom", "Comma separated list of icon styles to download from SteamGridDB.\nExample: \"official\"")
+	// "static" "animated"
+	flags.StringVar(&options.Types, "types", "static", "Comma separated list of types to download from SteamGridDB.\nExample: \"static,animated\"")
+	flags.StringVar(&options.Nsfw, "nsfw", "false", "Set to false to filter out nsfw, true to only include nsfw, any to include both.")
+	flags.StringVar(&options.Humor, "humor", "false", "Set to false to filter out humor, true to only include humor, any to include both.")
+	flags.StringVar(&options.BannerDimensions, "bannerdimensions", artwork.DefaultBannerDimensions, "Filter results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
+	flags.StringVar(&options.CoverDimensions, "coverdimensions", artwork.DefaultCoverDimensions, "Filter results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
+	flags.StringVar(&options.HeroDimensions, "herodimensions", artwork.DefaultHeroDimensions, "Filter results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
+	flags.Var(&options.SteamGridDBMinScore, "minscore", "Skip SteamGridDB images with a lower score. Images are picked by score, highest first.")
+	flags.Var(&options.SteamGridDBMinUpvotes, "minupvotes", "Skip SteamGridDB images with fewer upvotes.")
+	flags.Float64Var(&options.MinMatch, "minmatch", 0.5, "How similar, from 0 to 1, the name of the game SteamGridDB finds must be to the one searched for. Less similar games count as not found, 0 takes any")
+	flags.BoolVar(&options.Interactive, "interactive", false, "Ask which SteamGridDB image to use for each game and art style. Choices are remembered for the next runs.")
+	flags.IntVar(&options.Candidates, "candidates", 5, "Number of SteamGridDB images to choose from in interactive mode")
+	flags.BoolVar(&options.Preview, "preview", false, "Write a contact sheet of frames for animations: for every animated candidate in interactive mode, and next to the output file of get (name.preview.png)")
+	flags.DurationVar(&options.MaxLoop, "maxloop", 0, "Prefer SteamGridDB animations whose loop is at most this long, like 8s")
+	flags.Float64Var(&options.MaxFPS, "maxfps", 0, "Prefer SteamGridDB animations with at most this many frames per second")
+	flags.StringVar(&options.OpaqueLogos, "opaquelogos", "warn", "What to do with still logos that have no transparency and look like boxes over the heroes: warn, clear to make their solid background transparent, or reject to try another SteamGridDB logo")
+	flags.StringVar(&options.Deck, "deck", "", "Steam Deck preset for the animation and brightness options not given: lcd or oled")
+	flags.BoolVar(&options.SkipSteam, "skipsteam", false, "Skip downloads from Steam servers")
+	flags.BoolVar(&options.SkipGoogle, "skipgoogle", false, "Skip search and downloads from google")
+	flags.StringVar(&options.SearchProvider, "searchprovider", "google", "Web image search for banners nothing else has: google, bing (with -bingkey), duckduckgo or searxng (with -searxng)")
+	flags.StringVar(&options.BingApiKey, "bingkey", "", "Your Bing Image Search API key, for -searchprovider bing")
+	flags.StringVar(&options.SearXNG, "searxng", "", "URL of the SearXNG instance for -searchprovider searxng, like https://searx.example.org, with the JSON format enabled")
+	flags.StringVar(&options.Language, "language", "", "Prefer artwork in this language, like ja, zh-cn or koreana: localized Steam assets and SteamGridDB images tagged with it")
+	flags.StringVar(&options.SourcesBanner, "sources-banner", "", "Comma separated sources to try for banners, in order: librarycache, steam, steamgriddb, itch, thegamesdb, google")
+	flags.StringVar(&options.SourcesCover, "sources-cover", "", "Comma separated sources to try for covers, in order: librarycache, steam, steamgriddb, itch, gog, igdb, thegamesdb, launchbox")
+	flags.StringVar(&options.SourcesHero, "sources-hero", "", "Comma separated sources to try for heroes, in order: librarycache, steam, steamgriddb, gog, rawg, thegamesdb")
+	flags.StringVar(&options.SourcesLogo, "sources-logo", "", "Comma separated sources to try for logos, in order: librarycache, steam, steamgriddb, thegamesdb, launchbox")
+	flags.StringVar(&options.SourcesIcon, "sources-icon", "", "Comma separated sources to try for icons, in order: steamgriddb")
+	flags.StringVar(&options.Packs, "packs", "", "Comma separated artwork packs to use before searching online: directories, zip files, zip URLs or GitHub repositories with a "+artwork.PackManifestName+" manifest")
+	flags.StringVar(&options.Mirror, "mirror", "", "Directory of artwork to use before searching online, organized as <appid>/<style>/<image> (default mirror/ next to the executable)")
+	flags.StringVar(&options.NameOverrides, "nameoverrides", "", "JSON file of names to search some games with, by appID or name, like {\"FF7R INTERGRADE\": \"Final Fantasy VII Remake\"} or {\"440\": {\"steamGridDBGameId\": 123}} (default name-overrides.json next to the executable)")
+	flags.StringVar(&options.Playlists, "playlists", "", "Comma separated RetroArch playlists (.lpl) and EmulationStation gamelists (gamelist.xml), or directories of them, whose titles are searched for the ROMs non-Steam shortcuts launch (default the usual RetroArch and EmulationStation folders)")
+	flags.StringVar(&options.Pins, "pins", "", "JSON file of SteamGridDB asset IDs to use for some games instead of picking one, like {\"440\": {\"cover\": 12345}} (default pins.json next to the executable)")
+	flags.BoolVar(&options.SaveMirror, "savemirror", false, "Save every downloaded image, before overlays, into the mirror for later runs, other users and other computers")
+	flags.BoolVar(&options.SteamGridDBOnly, "steamgriddbonly", false, "Search for artwork only in SteamGridDB")
+	flags.BoolVar(&options.OnlyMissingArtwork, "onlymissingartwork", false, "Only download artworks missing on the official servers")
+}
+
+// Registers the flag selecting the Steam installation.
+func registerInstallationFlags(flags *flag.FlagSet, options *artwork.Options) {
+	flags.StringVar(&options.SteamDir, "steamdir", "", "Path to your steam installation, or to a copy of its userdata directory or of a single user directory in it")
+	flags.DurationVar(&options.Wait, "wait", 0, "How long to wait for another SteamGrid working on the same Steam installation to finish, instead of quitting right away")
+}
+
+// Registers the flags about the output of a run.
+func registerReportFlags(flags *flag.FlagSet, options *artwork.Options) {
+	flags.StringVar(&options.ReportPath, "report", "", "Write what happened to every image to this JSON file")
+	flags.StringVar(&options.ShareMatchesURL, "sharematches", "", "Opt in to send the names of the games whose artwork wasn't found or was found by searching, with nothing about you, to this community endpoint to improve name matching")
+	registerLintFlags(flags, options)
+}
+
+// Registers the flags of the artwork consistency checks.
+func registerLintFlags(flags *flag.FlagSet, options *artwork.Options) {
+	flags.BoolVar(&options.Lint, "lint", false, "Look for artwork that doesn't fit with the rest of the library")
+	flags.StringVar(&options.LintChecks, "lintchecks", artwork.DefaultLintChecks, "Comma separated checks of -lint: animation (a few animated images among static ones, or the opposite), logo (covers with a logo when no_logo was asked for) and brightness (heroes much darker or brighter than the rest)")
+	flags.Float64Var(&options.LintMinority, "lintminority", 0.2, "Share of the images of an art style below which animated (or static) ones are flagged")
+	flags.Float64Var(&options.LintBrightness, "lintbrightness", 0.25, "How far from the usual brightness, between 0 and 1, a hero may be before it's flagged")
+}
+
+// Registers the flags selecting the games and art styles to work on.
+func registerLibraryFlags(flags *flag.FlagSet, options *artwork.Options) {
+	flags.BoolVar(&options.SkipBanner, "skipbanner", false, "Skip search and processing banner artwork")
+	flags.BoolVar(&options.SkipCover, "skipcover", false, "Skip search and processing cover artwork")
+	flags.BoolVar(&options.SkipHero, "skiphero", false, "Skip search and processing hero artwork")
+	flags.BoolVar(&options.SkipLogo, "skiplogo", false, "Skip search and processing logo artwork")
+	flags.BoolVar(&options.SkipIcon, "skipicon", false, "Skip search and processing icons of non-Steam games")
+	flags.BoolVar(&options.NonSteamOnly, "nonsteamonly", false, "Only search artwork for Non-Steam-Games")
+	flags.StringVar(&options.IncludeTypes, "includetypes", "", "Comma separated types of Steam apps that aren't games to process anyway, skipped by default: "+strings.Join(artwork.NonGameAppTypes, ", ")+", or all")
+	flags.BoolVar(&options.NormalizeNames, "normalizenames", false, "Tidy the names of non-Steam games in shortcuts.vdf, like \"Hades™ - Shortcut\" or \"Celeste.exe\", keeping a backup of the file")
+	flags.BoolVar(&options.InstalledOnly, "installedonly", false, "Only search artwork for installed Steam games, and Non-Steam-Games")
+	flags.StringVar(&options.AppIDs, "appids", "", "Comma separated list of appIds that should be processed")
+	flags.StringVar(&options.SteamApiKey, "steamapikey", "", "Your Steam Web API key, get one here: https://steamcommunity.com/dev/apikey. Games are then listed by Steam, with their names and playtime, even with a private profile, instead of read from the public profile")
+	flags.BoolVar(&options.Apps, "apps", false, "Match shortcuts of applications that aren't games, like Spotify, Firefox or emulators, against their SteamGridDB entries")
+	flags.BoolVar(&options.RetryQueue, "retryqueue", false, "Only process the images left for later because SteamGridDB was unavailable")
+	flags.BoolVar(&options.Resume, "resume", false, "Continue the last run stopped with Ctrl+C where it stopped, skipping the games it processed")
+	flags.BoolVar(&options.Incremental, "incremental", false, "Only process new games and the images that were deleted since the last run, skipping the ones "+artwork.StateFileName+" has")
+	flags.DurationVar(&options.MaxAge, "maxage", 0, "With -incremental, also process the images last processed longer ago than this, like 720h for a month")
+	flags.StringVar(&options.ExcludeAppIDs, "excludeappids", "", "Comma separated list of appIds to skip, ranges like 1000-2000, or files listing them")
+	flags.StringVar(&options.SkipCategory, "skipcategory", "", "Name of the category with games to skip during processing")
+	flags.StringVar(&options.NameFilter, "namefilter", "", "Process only games with name that contains this value")
+	flags.BoolVar(&options.IgnoreBackup, "ignorebackup", false, "Ignore backups when looking for artwork")
+	flags.BoolVar(&options.IgnoreManual, "ignoremanual", false, "Ignore manual customization when looking for artwork")
+	flags.BoolVar(&options.IncludePrivate, "includeprivate", false, "Also process the games marked as private in Steam")
+	flags.BoolVar(&options.IncludeHidden, "includehidden", false, "Also process hidden non-Steam shortcuts, like the ones Steam creates for Remote Play and Steam Link apps")
+	flags.BoolVar(&options.IncludeShared, "includeshared", false, "Also process the installed games lent by the accounts that authorized this computer for Family Sharing")
+	flags.BoolVar(&options.Force, "force", false, "Download all artwork again and overwrite it, even when present or locked with the lock command")
+	flags.StringVar(&options.LogoPosition, "logoposition", "bottomleft", "Where Steam shows the logos SteamGrid installs over the heroes: bottomleft, upperleft, centercenter, uppercenter, bottomcenter, or none to leave it to Steam")
+	flags.Float64Var(&options.LogoWidth, "logowidth", 50, "Maximum width of installed logos, in percent of the hero")
+	flags.Float64Var(&options.LogoHeight, "logoheight", 50, "Maximum height of installed logos, in percent of the hero")
+	flags.StringVar(&options.ForceSource, "force-source", "", "Download again the artwork that came from this source (librarycache, steam, steamgriddb, itch, gog, igdb, rawg, thegamesdb, launchbox or google), ignoring its backups")
+}
+
+// Registers the flags controlling how overlays are applied and animations
+// are written.
+func registerConversionFlags(flags *flag.FlagSet, options *artwork.Options) {
+	flags.StringVar(&options.OverlayOrder, "overlayorder", "", "Comma separated categories in the order their overlays are stacked, from bottom to top, over the other ones.\nExample: \"backlog,favorites\"")
+	flags.StringVar(&options.OverlayMode, "overlaymode", "stretch", "How overlays are put on the images: stretch over the whole image, fit inside it, tile, or badge to make them small")
+	flags.StringVar(&options.OverlayAnchor, "overlayanchor", "topright", "Where fit overlays and badges go: topleft, top, topright, left, center, right, bottomleft, bottom or bottomright")
+	flags.Float64Var(&options.OverlayMargin, "overlaymargin", 3, "Space between fit overlays or badges and the edges, in percent of the shortest side of the image")
+	flags.Float64Var(&options.OverlayScale, "overlayscale", 25, "Width of badges, in percent of the width of the image")
+	flags.Float64Var(&options.OverlayOpacity, "overlayopacity", 100, "Opacity of the overlays, from 1 to 100")
+	flags.StringVar(&options.OverlayRules, "overlayrules", "", "YAML file of rules putting overlays on games by genre, store category, VR support, tag, playtime or release year, listed in the README (default overlay-rules.yaml next to the executable)")
+	flags.IntVar(&options.MaxOverlays, "maxoverlays", 0, "Apply only this many overlays, the top ones, when a game is in several categories. By default all of them are stacked")
+	flags.StringVar(&options.TextBadge, "textbadge", "", "Text to write on a corner of static covers and banners, instead of making overlays. {category} is replaced by the first category of the game and {playtime} by how long it was played.\nExample: \"{playtime}\"")
+	flags.StringVar(&options.TextBadgeCorner, "textbadgecorner", "topleft", "Corner of the text badge: topleft, topright, bottomleft or bottomright")
+	flags.Float64Var(&options.TextBadgeSize, "textbadgesize", 6, "Height of the text of the badge, in percent of the shortest side of the image")
+	flags.StringVar(&options.TextBadgeBackground, "textbadgebackground", "#000000b0", "Color of the pill behind the text badge, as #rrggbb or #rrggbbaa")
+	flags.StringVar(&options.TextBadgeColor, "textbadgecolor", "#ffffff", "Color of the text of the badge, as #rrggbb or #rrggbbaa")
+	flags.StringVar(&options.NotInstalled, "notinstalled", "", "Set the static covers and banners of Steam games that aren't installed apart from the rest: greyscale, darken, or ribbon to write \"NOT INSTALLED\" across them")
+	flags.BoolVar(&options.ProtonDB, "protondb", false, "Draw the ProtonDB rating of Steam games (platinum, gold, silver, bronze or borked) on a corner of static covers and banners")
+	flags.StringVar(&options.ProtonDBCorner, "protondbcorner", "topright", "Corner of the ProtonDB rating: topleft, topright, bottomleft or bottomright")
+	flags.BoolVar(&options.DeckBadge, "deckbadge", false, "Draw the Steam Deck compatibility of Steam games (verified, playable or unsupported) on a corner of static covers and banners")
+	flags.StringVar(&options.DeckBadgeCorner, "deckbadgecorner", "bottomleft", "Corner of the Steam Deck compatibility: topleft, topright, bottomleft or bottomright")
+	flags.BoolVar(&options.NormalizeColors, "normalizecolors", false, "Convert still JPEGs and PNGs with a color profile to sRGB, turn rotated ones upright and strip their metadata, so they don't look washed out in Steam")
+	flags.BoolVar(&options.ConvertWebpToApng, "webpasapng", false, "Convert WEBP animations to APNG.\nMakes them load faster in Steam but takes longer to apply.")
+	flags.BoolVar(&options.ConvertWebpToApngCoversBanners, "coverwebpasapng", false, "Convert only WEBP animations to APNG (only covers and banners)\nAvoid Hero and Logo which may be too memory and time consuming to apply.")
+	flags.IntVar(&options.MaxMemoryForConvert, "convertmaxmem", 0, "Convert only those animations that will use less memory (in GB) than specified here. By default there is no limit.")
+	flags.StringVar(&options.MaxMem, "maxmem", "", "Keep the run under this much memory, like 1500MB or 2GB, for devices with little of it: overlays are placed and animations converted one at a time, and animations too big to convert in memory are converted through temporary files")
+	flags.BoolVar(&options.UseFFmpeg, "useffmpeg", false, "Convert animations to APNG with ffmpeg, when it's on the PATH: much faster and lighter on memory. What ffmpeg can't convert is converted without it")
+	flags.IntVar(&options.JpegQuality, "jpegquality", artwork.DefaultJpegQuality, "Quality, from 1 to 100, of JPEGs encoded again, like with overlays. Lower is smaller")
+	flags.StringVar(&options.PngCompression, "pngcompression", "default", "Compression of PNGs encoded again, like with overlays: default, none, fast or best. best is smallest but slowest")
+	flags.StringVar(&options.UpscaleCmd, "upscalecmd", "", "Command upscaling downloads smaller than the size Steam shows their art style at, like \"realesrgan-ncnn-vulkan -i {in} -o {out}\". {in} and {out} are replaced with the paths of the image and of the upscaled PNG")
+	flags.BoolVar(&options.NoResize, "noresize", false, "Leave downloads slightly off the size Steam shows their art style at (460x215 or 920x430, 600x900, 1920x620) as they are, instead of cropping and resizing them to it")
+	flags.Float64Var(&options.CapFPS, "capfps", 0, "Drop frames of animations to play them at most at this many frames per second, for smaller files that Steam loads faster")
+	flags.IntVar(&options.CapFrames, "capframes", 0, "Drop frames of animations with more than this many, spread over the whole animation")
+	flags.StringVar(&options.MaxFileSize, "maxfilesize", "", "Encode images bigger than this again, like 5MB, as Steam loads big animations slowly: with more compression, fewer frames, or the first frame only")
+	flags.StringVar(&options.MaxFileSizeBanner, "maxfilesize-banner", "", "-maxfilesize for banners")
+	flags.StringVar(&options.MaxFileSizeCover, "maxfilesize-cover", "", "-maxfilesize for covers")
+	flags.StringVar(&options.MaxFileSizeHero, "maxfilesize-hero", "", "-maxfilesize for heroes")
+	flags.StringVar(&options.MaxFileSizeLogo, "maxfilesize-logo", "", "-maxfilesize for logos")
+	flags.StringVar(&options.AspectTolerance, "aspecttolerance", "", "How far, in percent, downloads may be from the aspect ratio of their art style before they're rejected as mislabeled: 5% for banners, 10% for covers and 25% for heroes by default. 0 only rejects images turned the wrong way")
+	flags.StringVar(&options.AspectToleranceBanner, "aspecttolerance-banner", "", "-aspecttolerance for banners, of 460x215")
+	flags.StringVar(&options.AspectToleranceCover, "aspecttolerance-cover", "", "-aspecttolerance for covers, of 600x900")
+	flags.StringVar(&options.AspectToleranceHero, "aspecttolerance-hero", "", "-aspecttolerance for heroes, of 1920x620")
+	flags.IntVar(&options.ConvertWorkers, "convertworkers", 2, "Number of animations converted to APNG, with their overlays, in the background while the next games are downloaded. 0 converts them before going on, as -maxmem does")
+	flags.BoolVar(&options.ExtendHeroes, "extendheroes", false, "Extend static heroes with black bars, or too narrow or wide, to the full 1920x620 with their edges mirrored and blurred")
+	flags.BoolVar(&options.AutoLevels, "autolevels", false, "Stretch the levels of static heroes and even out their brightness, so rows don't alternate between blinding white and pitch black")
+	flags.Float64Var(&options.AutoLevelsTarget, "autolevelstarget", 0.35, "Average brightness, between 0 and 1, -autolevels brings heroes to. 0 only stretches the levels")
+}
+
+// Parses the command line, accepting the Steam directory as the only
+// positional argument, like dragging the folder onto the executable.
+func parseOptions(flags *flag.FlagSet, options *artwork.Options, args []string) {
+	parseFlags(flags, args)
+	applyDeckPreset(flags, options)
+	checkEncodingFlags(flags, options)
+	if flags.NArg() == 1 {
+		options.SteamDir = flags.Arg(0)
+	} else if flags.NArg() == 0 {
+		options.SteamDir = os.Getenv(envPrefix + "STEAM_DIR")
+	} else if flags.NArg() >= 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+}
+
+// Values of the -deck presets, by flag name. Both models run animations at
+// up to 60 fps and have little memory to spare; OLED screens also get the
+// heroes' brightness evened out, so bright images don't glare in HDR.
+var deckPresets = map[string]map[string]string{
+	"lcd": {
+		"maxfps":        "60",
+		"maxloop":       "8s",
+		"convertmaxmem": "2",
+	},
+	"oled": {
+		"maxfps":           "60",
+		"maxloop":          "8s",
+		"convertmaxmem":    "2",
+		"autolevels":       "true",
+		"autolevelstarget": "0.3",
+	},
+}
+
+// Fills in the options of the -deck preset that weren't given, among the
+// flags of the command.
+func applyDeckPreset(flags *flag.FlagSet, options *artwork.Options) {
+	if options.Deck == "" {
+		return
+	}
+	preset, ok := deckPresets[strings.ToLower(options.Deck)]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Unknown Steam Deck model "+options.Deck+", expected lcd or oled")
+		os.Exit(2)
+	}
+
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range preset {
+		if !given[name] && flags.Lookup(name) != nil {
+			flags.Set(name, value)
+		}
+	}
+}
+
+// Checks the quality of JPEGs and the compression of PNGs, among the flags of
+// the command. Only the commands encoding images again have them.
+func checkEncodingFlags(flags *flag.FlagSet, options *artwork.Options) {
+	if flags.Lookup("jpegquality") == nil {
+		return
+	}
+	if options.JpegQuality < 1 || options.JpegQuality > 100 {
+		fmt.Fprintf(os.Stderr, "JPEG quality %v out of range, expected 1 to 100\n", options.JpegQuality)
+		os.Exit(2)
+	}
+	if _, ok := overlay.PNGCompressionLevels[strings.ToLower(options.PngCompression)]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown PNG compression %v, expected one of default, none, fast, best\n", options.PngCompression)
+		os.Exit(2)
+	}
+}
+
+// Registers the flags every command has, which set global settings. Returns
+// where -stdin-config goes.
+func registerGlobalFlags(flags *flag.FlagSet) *bool {
+	stdinConfig := flags.Bool("stdin-config", false, "Read options from a JSON object on stdin, with flag names as keys")
+	flags.BoolVar(&headless, "headless", headless, "Never wait for enter and quit with an error status on errors, on by default when stdin is not a terminal or in Docker")
+	flags.StringVar(&sources.CacheDirOverride, "cachedir", "", "Directory for the API cache, tokens and partial downloads, instead of the user cache directory")
+	flags.BoolVar(&sources.ShowHTTPStats, "stats", false, "Print the number of requests, error rates and response times of every server at the end")
+	flags.IntVar(&sources.HTTPRetries, "retries", sources.HTTPRetries, "How many times to retry downloads after server errors, timeouts and dropped connections")
+	flags.DurationVar(&sources.HTTPRetryDelay, "retrydelay", sources.HTTPRetryDelay, "Wait before the first retry, doubled for every next one")
+	flags.DurationVar(&sources.APICacheTTL, "cachettl", sources.APICacheTTL, "How long to reuse SteamGridDB and IGDB answers and missing Steam images before asking again, 0 to turn the cache off")
+	flags.BoolVar(&sources.OfflineMode, "offline", false, "Never touch the network: only use backups of original images, the games directory, packs already downloaded and the mirror")
+	flags.Float64Var(&sources.SteamGridDBLimiter.PerSecond, "sgdbrate", sources.SteamGridDBLimiter.PerSecond, "Maximum SteamGridDB API requests per second, 0 for no limit")
+	return stdinConfig
+}
+
+// Parses the command line. Options not given there are taken from
+// STEAMGRID_<FLAG> environment variables, for containers. With
+// -stdin-config, options are also read from a JSON document on stdin, so GUI
+// wrappers can pass secrets without them showing up in process listings.
+// The document is an object with flag names as keys, like
+// {"steamgriddb": "key", "skipgoogle": true}. Lists may be given as arrays.
+// Flags given on the command line or the environment take precedence.
+func parseFlags(flags *flag.FlagSet, args []string) {
+	stdinConfig := registerGlobalFlags(flags)
+	if completing != nil {
+		completeFlags(flags)
+	}
+	flags.Parse(args)
+	err := applyEnvConfig(flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid option in the environment: "+err.Error())
+		os.Exit(2)
+	}
+	if *stdinConfig {
+		err = applyJSONConfig(flags, os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid options on stdin: "+err.Error())
+			os.Exit(2)
+		}
+	}
+
+	if sources.HTTPRetries < 0 || sources.HTTPRetryDelay < 0 {
+		fmt.Fprintln(os.Stderr, "-retries and -retrydelay can't be negative")
+		os.Exit(2)
+	}
+}
+
+// Sets the flags found in a JSON object, except those already given.
+func applyJSONConfig(flags *flag.FlagSet, reader io.Reader) error {
+	var config map[string]interface{}
+	err := json.NewDecoder(reader).Decode(&config)
+	if err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	for name, value := range config {
+		if flags.Lookup(name) == nil {
+			return errors.New("unknown option " + name)
+		}
+		if given[name] {
+			continue
+		}
+
+		var text string
+		switch v := value.(type) {
+		case []interface{}:
+			var items []string
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			text = strings.Join(items, ",")
+		case float64:
+			// JSON numbers are floats, but the flags want integers.
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			text = fmt.Sprint(v)
+		}
+
+		err = flags.Set(name, text)
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Prefix of the environment variables that set options.
+const envPrefix = "STEAMGRID_"
+
+// Name of the environment variable for a flag: -steamgriddb is
+// STEAMGRID_STEAMGRIDDB, -force-source is STEAMGRID_FORCE_SOURCE.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// Sets the flags that have an environment variable, except those given on
+// the command line.
+func applyEnvConfig(flags *flag.FlagSet) error {
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || given[f.Name] || err != nil {
+			return
+		}
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%v: %v", envName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// Registers every flag used by a full run.
+func registerRunFlags(flags *flag.FlagSet, options *artwork.Options) {
+	registerSourceFlags(flags, options)
+	registerInstallationFlags(flags, options)
+	registerLibraryFlags(flags, options)
+	registerConversionFlags(flags, options)
+	registerReportFlags(flags, options)
+}
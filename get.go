@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Prints an error for scripts calling the get command and quits with a
+// non-zero status. Unlike errorAndExit it doesn't wait for the user.
+func getFailed(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	sources.FinishHTTPStats()
+	os.Exit(1)
+}
+
+// Finds the art style matching a user supplied name, ignoring case.
+func findArtStyle(artStyles map[string][]string, name string) (string, []string, bool) {
+	for artStyle, artStyleExtensions := range artStyles {
+		if strings.EqualFold(artStyle, name) {
+			return artStyle, artStyleExtensions, true
+		}
+	}
+	return "", nil, false
+}
+
+// Resolves and downloads the best image for a single game and art style to a
+// given path, without looking for a Steam installation or applying overlays.
+// Meant as a building block for scripts and launchers:
+//
+//	steamgrid get -appid 440 -style hero -out hero.png
+//
+// The paths of the written files are the only thing printed to stdout.
+func getCommand(args []string) {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	appID := flags.String("appid", "", "Steam appID of the game")
+	name := flags.String("name", "", "Name of the game, used for searches (required for non-Steam games)")
+	style := flags.String("style", "cover", "Art style to download: banner, cover, hero, logo or icon")
+	out := flags.String("out", "", "Output file. The image extension is appended when missing. Defaults to the Steam grid file name in the current directory")
+	options := &artwork.Options{}
+	registerSourceFlags(flags, options)
+	parseFlags(flags, args)
+
+	if *appID == "" && *name == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	artStyles, err := options.ArtStyles()
+	if err != nil {
+		getFailed(err)
+	}
+	artStyle, artStyleExtensions, ok := findArtStyle(artStyles, *style)
+	if !ok {
+		getFailed(errors.New("unknown art style " + *style + ", expected banner, cover, hero, logo or icon"))
+	}
+
+	// Without an appID there's nothing to ask Steam for, so treat it like a
+	// custom shortcut and rely on name searches.
+	game := &artwork.Game{Game: sources.Game{ID: *appID, Name: *name, Custom: *appID == ""}, Tags: []string{}}
+	if game.Name == "" {
+		game.Name = artwork.GetGameName(game.ID)
+	}
+
+	_, err = artwork.DownloadImage("", nil, game, artStyle, artStyleExtensions, options)
+	if err != nil {
+		getFailed(err)
+	}
+	if game.ImageSource == "" {
+		getFailed(fmt.Errorf("%v not found for %v", artStyle, game.Name))
+	}
+
+	outPath := *out
+	if outPath == "" {
+		id := game.ID
+		if id == "" {
+			id = artwork.SanitizeFileName(game.Name)
+		}
+		outPath = id + artStyleExtensions[0] + game.ImageExt
+	} else if filepath.Ext(outPath) == "" {
+		outPath += game.ImageExt
+	}
+
+	err = ioutil.WriteFile(outPath, game.CleanImageBytes, 0666)
+	if err != nil {
+		getFailed(err)
+	}
+	fmt.Println(outPath)
+
+	if options.Preview {
+		previewPath := strings.TrimSuffix(outPath, filepath.Ext(outPath)) + ".preview.png"
+		info, err := overlay.WriteAnimationPreview(game.CleanImageBytes, previewPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "No preview: "+err.Error())
+			return
+		}
+		fmt.Fprintln(os.Stderr, "Animation: "+info.String())
+		fmt.Println(previewPath)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bufio"
+	"os"
+)
+
+// Set by -headless, or when stdin is not a terminal or SteamGrid runs in a
+// Docker container: it never waits for enter and errors quit with a non-zero
+// status, as nobody is watching.
+var headless = !stdinIsTerminal() || inDocker()
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func inDocker() bool {
+	_, err := os.Stat("/.dockerenv")
+	return err == nil
+}
+
+// Keeps the console window open until the user presses enter, so people who
+// double clicked the executable can read what happened.
+func waitForEnter() {
+	if headless {
+		return
+	}
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/sources"
+)
+
+// Pages of SteamGridDB assets, by the API endpoint they were found in.
+var steamGridDBAssetPages = map[string]string{
+	"Banner": "https://www.steamgriddb.com/grid/%v",
+	"Cover":  "https://www.steamgriddb.com/grid/%v",
+	"Hero":   "https://www.steamgriddb.com/hero/%v",
+	"Logo":   "https://www.steamgriddb.com/logo/%v",
+	"Icon":   "https://www.steamgriddb.com/icon/%v",
+}
+
+// Big Picture banners are named after id<<32|0x02000000, where id is the
+// appID of Steam games or the legacy CRC of shortcuts.
+func parseLegacyBannerID(gridName string) (id uint64, ok bool) {
+	legacyID, err := strconv.ParseUint(gridName, 10, 64)
+	if err != nil || legacyID&0xffffffff != 0x02000000 {
+		return 0, false
+	}
+	return legacyID >> 32, true
+}
+
+// Reports which game an image in the grid directory (or a backup in
+// grid/originals) belongs to and where SteamGrid got it from.
+func lookupCommand(args []string) {
+	flags := flag.NewFlagSet("lookup", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: steamgrid lookup <image in Steam/userdata/<user>/config/grid>")
+		flags.PrintDefaults()
+	}
+	parseFlags(flags, args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	err := lookupGridImage(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+// Prints what is known about one image: the game, art style and provenance.
+func lookupGridImage(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	imageBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	gridDir := filepath.Dir(path)
+	gridName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	isBackup := false
+	if filepath.Base(gridDir) == "originals" {
+		gridDir = filepath.Dir(gridDir)
+		name, _, ok := artwork.ParseBackupFileName(filepath.Base(path))
+		if !ok {
+			return errors.New("not a SteamGrid backup: " + path)
+		}
+		gridName = name
+		isBackup = true
+	}
+
+	artStyles := artwork.MakeArtStyles("", "", "", "", "")
+	gameID, artStyle, ok := artwork.ParseGridFileName(gridName, artStyles)
+	if !ok {
+		return errors.New("not named like a grid image: " + path)
+	}
+
+	// The grid directory is Steam/userdata/<user>/config/grid.
+	userDir := filepath.Dir(filepath.Dir(gridDir))
+	user := artwork.User{Name: filepath.Base(userDir), SteamID32: filepath.Base(userDir), Dir: userDir}
+	shortcuts := make(map[string]*artwork.Game)
+	artwork.AddNonSteamGames(user, shortcuts, "", true)
+
+	var game *artwork.Game
+	isLegacyCopy := false
+	if legacyID, ok := parseLegacyBannerID(gridName); ok {
+		isLegacyCopy = true
+		for _, shortcut := range shortcuts {
+			if shortcut.LegacyID == legacyID {
+				game = shortcut
+			}
+		}
+		if game == nil {
+			gameID = strconv.FormatUint(legacyID, 10)
+		}
+	}
+	if game == nil {
+		game = shortcuts[gameID]
+	}
+	if game == nil {
+		// A copy under the ID derived from the shortcut's target and name.
+		for _, shortcut := range shortcuts {
+			if shortcut.CRCID == gameID {
+				game = shortcut
+			}
+		}
+	}
+	if game == nil {
+		game = &artwork.Game{Game: sources.Game{ID: gameID}}
+	}
+
+	state, err := artwork.LoadGridState(gridDir)
+	if err != nil {
+		return err
+	}
+	entry := state.Entry(game.ID, artStyle)
+
+	fmt.Printf("File:      %v\n", path)
+	fmt.Printf("User:      %v\n", user.SteamID32)
+	if game.Custom {
+		fmt.Printf("Game:      %v (non-Steam shortcut, id %v)\n", game.Name, game.ID)
+	} else {
+		if entry != nil && entry.Name != "" {
+			game.Name = entry.Name
+		} else {
+			if artwork.SteamInstallationDir == "" {
+				artwork.SteamInstallationDir = filepath.Dir(filepath.Dir(user.Dir))
+			}
+			game.Name = artwork.GetGameName(game.ID)
+		}
+		fmt.Printf("Game:      %v (Steam appID %v)\n", game.Name, game.ID)
+	}
+	fmt.Printf("Art style: %v\n", artStyle)
+	if isLegacyCopy {
+		fmt.Println("Kind:      copy of the banner for Big Picture mode")
+	} else if isBackup {
+		fmt.Println("Kind:      backup of the original image, without overlays")
+	}
+
+	if entry == nil {
+		fmt.Println("Source:    unknown, not written by SteamGrid (or before it kept " + artwork.StateFileName + ")")
+		return nil
+	}
+
+	fmt.Printf("Source:    %v\n", entry.Source)
+	if entry.URL != "" {
+		fmt.Printf("URL:       %v\n", entry.URL)
+	}
+	if entry.SteamGridDBID != 0 {
+		fmt.Printf("SteamGridDB asset: %v ("+steamGridDBAssetPages[artStyle]+")\n", entry.SteamGridDBID, entry.SteamGridDBID)
+	}
+	fmt.Printf("Written:   %v\n", entry.Updated.Format("2006-01-02 15:04:05"))
+
+	hash := artwork.ImageHash(imageBytes)
+	if !isBackup && hash != entry.Hash {
+		fmt.Println("Changed:   the file was modified or replaced after SteamGrid wrote it")
+	} else if !isBackup {
+		backups, _ := filepath.Glob(filepath.Join(gridDir, "originals", game.ID+artStyles[artStyle][0]+" "+hash+".*"))
+		if len(backups) > 0 {
+			backupBytes, err := ioutil.ReadFile(backups[0])
+			if err == nil && !bytes.Equal(backupBytes, imageBytes) {
+				fmt.Println("Overlay:   applied, original in " + backups[0])
+			}
+		}
+	}
+	return nil
+}
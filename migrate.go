@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// A non-Steam shortcut as far as telling it apart in another account goes.
+type migratedShortcut struct {
+	Name     string
+	Exe      string
+	ID       string
+	LegacyID uint64
+}
+
+// Reads the shortcuts of a user, hidden ones included.
+func readShortcuts(userDir string) ([]migratedShortcut, error) {
+	shortcutBytes, err := ioutil.ReadFile(filepath.Join(userDir, "config", "shortcuts.vdf"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	root, err := steam.ParseBinaryVDF(shortcutBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var shortcuts []migratedShortcut
+	for _, shortcut := range root.Child("shortcuts").Children {
+		id, legacyID := artwork.ShortcutID(shortcut)
+		shortcuts = append(shortcuts, migratedShortcut{shortcut.ChildString("AppName"), shortcut.ChildString("Exe"), id, legacyID})
+	}
+	return shortcuts, nil
+}
+
+// Maps the IDs and legacy IDs of the shortcuts of one account to the ones of
+// the same shortcuts in another: same name and target, or else the only one
+// with the same name. Returns the names of the shortcuts that have no match.
+func matchShortcuts(from []migratedShortcut, to []migratedShortcut) (map[string]string, map[uint64]uint64, []string) {
+	ids := make(map[string]string)
+	legacyIDs := make(map[uint64]uint64)
+	var unmatched []string
+
+	for _, shortcut := range from {
+		var match *migratedShortcut
+		var sameName []*migratedShortcut
+		for i, other := range to {
+			if artwork.NameFingerprint(other.Name) != artwork.NameFingerprint(shortcut.Name) {
+				continue
+			}
+			sameName = append(sameName, &to[i])
+			if artwork.NameFingerprint(other.Exe) == artwork.NameFingerprint(shortcut.Exe) {
+				match = &to[i]
+			}
+		}
+		if match == nil && len(sameName) == 1 {
+			match = sameName[0]
+		}
+		if match == nil {
+			unmatched = append(unmatched, shortcut.Name)
+			continue
+		}
+		ids[shortcut.ID] = match.ID
+		legacyIDs[shortcut.LegacyID] = match.LegacyID
+	}
+	return ids, legacyIDs, unmatched
+}
+
+// Returns the name a grid file of one account gets in another, or false for
+// shortcuts the other account doesn't have. Steam games keep their name.
+func migrateGridName(fileName string, artStyles map[string][]string, ids map[string]string, legacyIDs map[uint64]uint64) (string, bool) {
+	stem := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	if legacyID, ok := parseLegacyBannerID(stem); ok {
+		if !artwork.IsShortcutID(strconv.FormatUint(legacyID, 10)) {
+			return fileName, true
+		}
+		newLegacyID, ok := legacyIDs[legacyID]
+		if !ok {
+			return "", false
+		}
+		return strconv.FormatUint(newLegacyID<<32|0x02000000, 10) + filepath.Ext(fileName), true
+	}
+
+	gameID, _, ok := artwork.ParseGridFileName(fileName, artStyles)
+	if !ok && filepath.Ext(fileName) == ".json" {
+		// Positions of logos, <id>.json
+		gameID, ok = stem, true
+	}
+	if !ok || !artwork.IsShortcutID(gameID) {
+		return fileName, ok
+	}
+	newID, ok := ids[gameID]
+	if !ok {
+		return "", false
+	}
+	return newID + strings.TrimPrefix(fileName, gameID), true
+}
+
+// Moves a state key, "<id>/<style>" or "<id>", to the IDs of another account.
+func migrateStateKey(key string, ids map[string]string) (string, bool) {
+	gameID := strings.SplitN(key, "/", 2)[0]
+	if !artwork.IsShortcutID(gameID) {
+		return key, true
+	}
+	newID, ok := ids[gameID]
+	if !ok {
+		return "", false
+	}
+	return newID + strings.TrimPrefix(key, gameID), true
+}
+
+// Finds a user by account ID or name.
+func findUser(users []artwork.User, name string) (artwork.User, error) {
+	for _, user := range users {
+		if user.SteamID32 == name || strings.EqualFold(user.Name, name) {
+			return user, nil
+		}
+	}
+	return artwork.User{}, errors.New("no user " + name + " in the Steam installation")
+}
+
+// Copies the artwork, backups, logo positions and state of one account to
+// another one, giving the shortcuts the IDs they have there.
+func migrateArtwork(from artwork.User, to artwork.User, force bool, dryRun bool) error {
+	fromShortcuts, err := readShortcuts(from.Dir)
+	if err != nil {
+		return err
+	}
+	toShortcuts, err := readShortcuts(to.Dir)
+	if err != nil {
+		return err
+	}
+	ids, legacyIDs, unmatched := matchShortcuts(fromShortcuts, toShortcuts)
+	artStyles := artwork.MakeArtStyles("", "", "", "", "")
+
+	fromGridDir := filepath.Join(from.Dir, "config", "grid")
+	toGridDir := filepath.Join(to.Dir, "config", "grid")
+	if !dryRun {
+		err = os.MkdirAll(filepath.Join(toGridDir, "originals"), 0777)
+		if err != nil {
+			return err
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(fromGridDir, "*.*"))
+	if err != nil {
+		return err
+	}
+	backups, err := artwork.GridBackups(fromGridDir)
+	if err != nil {
+		return err
+	}
+	nCopied, nExisting := 0, 0
+	for _, path := range append(files, backups...) {
+		fileName := filepath.Base(path)
+		if fileName == artwork.StateFileName || fileName == artwork.RetryQueueFileName {
+			continue
+		}
+
+		newName, ok := "", false
+		if filepath.Base(filepath.Dir(path)) == "originals" {
+			gridName, hash, isBackup := artwork.ParseBackupFileName(fileName)
+			if isBackup {
+				newName, ok = migrateGridName(gridName+filepath.Ext(fileName), artStyles, ids, legacyIDs)
+				newName = filepath.Join("originals", strings.TrimSuffix(newName, filepath.Ext(newName))+" "+hash+filepath.Ext(fileName))
+			}
+		} else {
+			newName, ok = migrateGridName(fileName, artStyles, ids, legacyIDs)
+		}
+		if !ok {
+			continue
+		}
+
+		newPath := filepath.Join(toGridDir, newName)
+		if _, err := os.Stat(newPath); err == nil && !force {
+			nExisting++
+			continue
+		}
+		nCopied++
+		if dryRun {
+			fmt.Printf("Would copy %v to %v\n", fileName, newName)
+			continue
+		}
+		fileBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(newPath, fileBytes, 0666)
+		if err != nil {
+			return err
+		}
+	}
+
+	fromState, err := artwork.LoadGridState(fromGridDir)
+	if err != nil {
+		return err
+	}
+	toState, err := artwork.LoadGridState(toGridDir)
+	if err != nil {
+		return err
+	}
+	for key, entry := range fromState.Images {
+		newKey, ok := migrateStateKey(key, ids)
+		if _, exists := toState.Images[newKey]; !ok || (exists && !force) {
+			continue
+		}
+		newEntry := *entry
+		newEntry.GameID = strings.SplitN(newKey, "/", 2)[0]
+		newEntry.File = newEntry.GameID + strings.TrimPrefix(entry.File, entry.GameID)
+		toState.Images[newKey] = &newEntry
+	}
+	for key, choice := range fromState.Choices {
+		newKey, ok := migrateStateKey(key, ids)
+		if _, exists := toState.Choices[newKey]; ok && (!exists || force) {
+			toState.Choices[newKey] = choice
+		}
+	}
+	for key, locked := range fromState.Locked {
+		if newKey, ok := migrateStateKey(key, ids); ok && locked {
+			toState.Locked[newKey] = true
+		}
+	}
+	if !dryRun {
+		err = toState.Save()
+		if err != nil {
+			return err
+		}
+	}
+
+	verb := "Copied"
+	if dryRun {
+		verb = "Would copy"
+	}
+	fmt.Printf("%v %v files from %v to %v, with %v of %v shortcuts. %v files already there were left alone.\n", verb, nCopied, from.Name, to.Name, len(ids), len(fromShortcuts), nExisting)
+	if len(unmatched) > 0 {
+		fmt.Printf("These shortcuts are not in %v, add them and run migrate again: %v\n", to.Name, strings.Join(unmatched, ", "))
+	}
+	return nil
+}
+
+// Copies the artwork of one Steam account to another, for a new account or
+// one in another region.
+func migrateCommand(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	options := &artwork.Options{}
+	registerInstallationFlags(flags, options)
+	fromName := flags.String("from", "", "Account ID or name of the user to copy the artwork from, required")
+	toName := flags.String("to", "", "Account ID or name of the user to copy the artwork to, required")
+	force := flags.Bool("force", false, "Overwrite the artwork the other user already has")
+	dryRun := flags.Bool("dryrun", false, "Only list what would be copied")
+	parseOptions(flags, options, args)
+	if *fromName == "" || *toName == "" {
+		fmt.Fprintln(os.Stderr, "Both users are needed, use -from and -to.")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	users := loadUsers(options)
+	from, err := findUser(users, *fromName)
+	if err != nil {
+		errorAndExit(err)
+	}
+	to, err := findUser(users, *toName)
+	if err != nil {
+		errorAndExit(err)
+	}
+	if from.Dir == to.Dir {
+		errorAndExit(errors.New("the artwork can't be copied to the same user"))
+	}
+
+	err = migrateArtwork(from, to, *force, *dryRun)
+	if err != nil {
+		errorAndExit(err)
+	}
+}
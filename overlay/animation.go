@@ -0,0 +1,337 @@
+package overlay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"time"
+
+	"github.com/kmicki/apng"
+	"github.com/kmicki/webpanimation"
+)
+
+// How many SteamGridDB images are tried before keeping an animation that is
+// over the -maxloop or -maxfps limits anyway, or giving up on finding one of
+// the right shape.
+const MaxAnimationAttempts = 3
+
+// AnimationTiming reads the number of frames and the length of one loop of an
+// APNG or animated WEBP from its chunks, without decoding it. ok is false for
+// still images.
+func AnimationTiming(imageBytes []byte) (frames int, loop time.Duration, ok bool) {
+	if IsAnimatedPNG(imageBytes) {
+		for i := 8; i+8 <= len(imageBytes); {
+			length := int(binary.BigEndian.Uint32(imageBytes[i:]))
+			if string(imageBytes[i+4:i+8]) == "fcTL" && i+8+26 <= len(imageBytes) {
+				// The delay is a fraction of a second, 1/100 when the
+				// denominator is 0.
+				delayNum := binary.BigEndian.Uint16(imageBytes[i+8+20:])
+				delayDen := binary.BigEndian.Uint16(imageBytes[i+8+22:])
+				if delayDen == 0 {
+					delayDen = 100
+				}
+				loop += time.Duration(float64(delayNum) / float64(delayDen) * float64(time.Second))
+				frames++
+			}
+			i += 12 + length
+		}
+		return frames, loop, true
+	}
+
+	if IsAnimatedWebp(imageBytes) {
+		for i := 12; i+8 <= len(imageBytes); {
+			length := int(binary.LittleEndian.Uint32(imageBytes[i+4:]))
+			if string(imageBytes[i:i+4]) == "ANMF" && i+8+16 <= len(imageBytes) {
+				// The duration is 24 bits of milliseconds, after the position
+				// and size of the frame.
+				duration := imageBytes[i+8+12 : i+8+15]
+				loop += time.Duration(int(duration[0])|int(duration[1])<<8|int(duration[2])<<16) * time.Millisecond
+				frames++
+			}
+			// Chunks are padded to an even size.
+			i += 8 + length + length%2
+		}
+		return frames, loop, true
+	}
+	return 0, 0, false
+}
+
+// ExceedsAnimationLimits tells if an image is an animation longer than maxLoop
+// or faster than maxFPS. Zero limits are ignored.
+func ExceedsAnimationLimits(imageBytes []byte, maxLoop time.Duration, maxFPS float64) bool {
+	frames, loop, ok := AnimationTiming(imageBytes)
+	if !ok || loop <= 0 {
+		return false
+	}
+	fps := float64(frames) / loop.Seconds()
+	return (maxLoop > 0 && loop > maxLoop) || (maxFPS > 0 && fps > maxFPS)
+}
+
+// A decoded frame of an animation, shown for delay milliseconds.
+type animationFrame struct {
+	Image *image.RGBA
+	delay int
+}
+
+// DecodeAnimationFrames decodes every frame of an animated WEBP or APNG, and
+// the number of times it loops. APNG frames are drawn over the previous ones,
+// as they're usually only what changed.
+func DecodeAnimationFrames(imageBytes []byte, isWebp bool) ([]animationFrame, int, error) {
+	var frames []animationFrame
+	if isWebp {
+		webpImage, err := newWebpDecoder(imageBytes)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer releaseWebpDecoder(webpImage)
+		bounds := image.Rect(0, 0, webpImage.Width, webpImage.Height)
+		lastTimestamp := 0
+		frame, ok := webpanimation.GetNextFrame(webpImage)
+		for ok {
+			// Decoded frames are reused by the decoder.
+			result := image.NewRGBA(bounds)
+			draw.Draw(result, bounds, frame.Image, image.Point{}, draw.Src)
+			frames = append(frames, animationFrame{result, frame.Timestamp - lastTimestamp})
+			lastTimestamp = frame.Timestamp
+			frame, ok = webpanimation.GetNextFrame(webpImage)
+		}
+		if len(frames) == 0 {
+			return nil, 0, errors.New("WEBP animation has no frames")
+		}
+		return frames, webpImage.LoopCount, nil
+	}
+
+	animation, err := apng.DecodeAll(bytes.NewReader(imageBytes))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(animation.Frames) == 0 {
+		return nil, 0, errors.New("APNG has no frames")
+	}
+	bounds := animation.Frames[0].Image.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for _, frame := range animation.Frames {
+		op := draw.Over
+		if frame.BlendOp == apng.BLEND_OP_SOURCE {
+			op = draw.Src
+		}
+		frameBounds := frame.Image.Bounds()
+		target := image.Rect(frame.XOffset, frame.YOffset, frame.XOffset+frameBounds.Dx(), frame.YOffset+frameBounds.Dy())
+		draw.Draw(canvas, target, frame.Image, frameBounds.Min, op)
+		result := image.NewRGBA(canvas.Bounds())
+		copy(result.Pix, canvas.Pix)
+		denominator := int(frame.DelayDenominator)
+		if denominator == 0 {
+			denominator = 100
+		}
+		frames = append(frames, animationFrame{result, int(frame.DelayNumerator) * 1000 / denominator})
+	}
+	return frames, int(animation.LoopCount), nil
+}
+
+// KeepEveryFrames keeps one in every keepEvery frames, each shown as long as
+// those it replaces.
+func KeepEveryFrames(frames []animationFrame, keepEvery int) []animationFrame {
+	var kept []animationFrame
+	for i := 0; i < len(frames); i += keepEvery {
+		frame := frames[i]
+		for j := i + 1; j < i+keepEvery && j < len(frames); j++ {
+			frame.delay += frames[j].delay
+		}
+		kept = append(kept, frame)
+	}
+	return kept
+}
+
+// CapFrameRate merges frames shown for less than minDelay milliseconds with
+// the ones after them, so that the animation plays at most 1000/minDelay
+// frames a second.
+func CapFrameRate(frames []animationFrame, minDelay int) []animationFrame {
+	var kept []animationFrame
+	for _, frame := range frames {
+		if len(kept) > 0 && kept[len(kept)-1].delay < minDelay {
+			kept[len(kept)-1].delay += frame.delay
+		} else {
+			kept = append(kept, frame)
+		}
+	}
+	return kept
+}
+
+// EncodeAnimationFrames encodes frames as a WEBP of the given quality,
+// lossless with 0, or as an APNG.
+func EncodeAnimationFrames(kept []animationFrame, loopCount int, quality float32, toWebp bool) ([]byte, error) {
+	bounds := kept[0].Image.Bounds()
+	if !toWebp {
+		encoder, err := NewAnimationEncoder(bounds.Dx(), bounds.Dy(), len(kept), loopCount, true, false)
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Release()
+		for _, frame := range kept {
+			err := encoder.Add(frame.Image, frame.delay)
+			if err != nil {
+				return nil, err
+			}
+		}
+		shrunk, _, err := encoder.Encode()
+		return shrunk, err
+	}
+
+	webpanim := newWebpEncoder(bounds.Dx(), bounds.Dy(), loopCount)
+	defer releaseWebpEncoder(webpanim)
+	webpConfig := webpanimation.NewWebpConfig()
+	if quality > 0 {
+		webpConfig.SetLossless(0)
+		webpConfig.SetQuality(quality)
+	} else {
+		webpConfig.SetLossless(1)
+	}
+	timestamp := 0
+	for _, frame := range kept {
+		err := webpanim.AddFrame(frame.Image, timestamp, webpConfig)
+		if err != nil {
+			return nil, err
+		}
+		timestamp += frame.delay
+	}
+	// The end of the last frame.
+	err := webpanim.AddFrame(nil, timestamp, webpConfig)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	err = webpanim.Encode(buf)
+	return buf.Bytes(), err
+}
+
+// Encodes frames converted from other formats, given one at a time, as an
+// animated WEBP or an APNG. Neither keeps the frames once added.
+type animationEncoder struct {
+	apng       *apng.FrameByFrameEncoder
+	apngOutput *apngOutput
+	webp       *webpanimation.WebpAnimation
+	webpConfig webpanimation.WebPConfig
+	timestamp  int
+	frames     int
+}
+
+// NewAnimationEncoder starts an animation of the given number of frames, which
+// APNGs need up front. Spooled ones are written to a temporary file as they're
+// encoded.
+func NewAnimationEncoder(width int, height int, frames int, loopCount int, toApng bool, spooled bool) (*animationEncoder, error) {
+	if toApng {
+		output, err := newApngOutput(spooled)
+		if err != nil {
+			return nil, err
+		}
+		return &animationEncoder{apng: apng.InitializeEncoding(output, uint32(frames), uint(loopCount)), apngOutput: output}, nil
+	}
+	encoder := &animationEncoder{webp: newWebpEncoder(width, height, loopCount), webpConfig: webpanimation.NewWebpConfig()}
+	encoder.webp.WebPAnimEncoderOptions.SetKmin(9)
+	encoder.webp.WebPAnimEncoderOptions.SetKmax(17)
+	encoder.webpConfig.SetLossless(1)
+	return encoder, nil
+}
+
+// Add adds a frame shown for delay milliseconds.
+func (encoder *animationEncoder) Add(frame *image.RGBA, delay int) error {
+	encoder.frames++
+	if encoder.apngOutput != nil {
+		return encoder.apng.EncodeFrame(apng.Frame{
+			Image:            frame,
+			DisposeOp:        apng.DISPOSE_OP_NONE,
+			BlendOp:          apng.BLEND_OP_SOURCE,
+			DelayNumerator:   uint16(delay),
+			DelayDenominator: 1000,
+		})
+	}
+	err := encoder.webp.AddFrame(frame, encoder.timestamp, encoder.webpConfig)
+	encoder.timestamp += delay
+	return err
+}
+
+// Encode returns the animation and its extension, .png or .webp, and releases
+// the encoder.
+func (encoder *animationEncoder) Encode() ([]byte, string, error) {
+	defer encoder.Release()
+	if encoder.apngOutput != nil {
+		err := encoder.apng.Finish()
+		if err != nil {
+			return nil, ".png", err
+		}
+		converted, err := encoder.apngOutput.bytes()
+		return converted, ".png", err
+	}
+	buf := new(bytes.Buffer)
+	// The end of the last frame.
+	err := encoder.webp.AddFrame(nil, encoder.timestamp, encoder.webpConfig)
+	if err == nil {
+		err = encoder.webp.Encode(buf)
+	}
+	return buf.Bytes(), ".webp", err
+}
+
+// Release frees the WEBP encoder and removes the temporary file of the APNG.
+// Safe to call more than once.
+func (encoder *animationEncoder) Release() {
+	if encoder.apngOutput != nil {
+		encoder.apngOutput.release()
+	}
+	if encoder.webp != nil {
+		releaseWebpEncoder(encoder.webp)
+		encoder.webp = nil
+	}
+}
+
+// ImageSize returns the size of a WEBP, APNG, PNG or JPEG image without
+// decoding it all.
+func ImageSize(imageBytes []byte, isWebp bool) (image.Point, error) {
+	if isWebp {
+		webpImage, err := newWebpDecoder(imageBytes)
+		if err != nil {
+			return image.Point{}, err
+		}
+		defer releaseWebpDecoder(webpImage)
+		return image.Point{X: webpImage.Width, Y: webpImage.Height}, nil
+	}
+
+	// try APNG
+	apngConfig, err := apng.DecodeConfig(bytes.NewBuffer(imageBytes))
+	if err == nil {
+		return image.Point{X: apngConfig.Width, Y: apngConfig.Height}, nil
+	}
+	imgConfig, _, err := image.DecodeConfig(bytes.NewBuffer(imageBytes))
+	if err != nil {
+		return image.Point{}, err
+	}
+	return image.Point{X: imgConfig.Width, Y: imgConfig.Height}, nil
+}
+
+// IsAnimatedPNG tells if PNG bytes are an APNG, which has its animation
+// control chunk before the image data.
+func IsAnimatedPNG(imageBytes []byte) bool {
+	if len(imageBytes) < 8 || string(imageBytes[1:4]) != "PNG" {
+		return false
+	}
+	for i := 8; i+8 <= len(imageBytes); {
+		length := int(binary.BigEndian.Uint32(imageBytes[i:]))
+		chunkType := string(imageBytes[i+4 : i+8])
+		if chunkType == "acTL" {
+			return true
+		} else if chunkType == "IDAT" {
+			return false
+		}
+		i += 12 + length
+	}
+	return false
+}
+
+// IsAnimatedWebp tells if WEBP bytes are animated. WEBP images are written
+// with a .png extension when they are not converted.
+func IsAnimatedWebp(imageBytes []byte) bool {
+	// Animations have an extended header with the animation flag set.
+	return len(imageBytes) > 20 && string(imageBytes[8:12]) == "WEBP" && string(imageBytes[12:16]) == "VP8X" && imageBytes[20]&0x02 != 0
+}
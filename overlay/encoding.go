@@ -0,0 +1,32 @@
+package overlay
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// StillEncoding is how still JPEGs and PNGs SteamGrid draws on, like with
+// overlays, are encoded again.
+type StillEncoding struct {
+	JpegQuality    int
+	PNGCompression png.CompressionLevel
+}
+
+// PNG compression levels of -pngcompression.
+var PNGCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"fast":    png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// Encode encodes a still image as a JPEG or as a PNG.
+func (encoding StillEncoding) Encode(w io.Writer, img image.Image, asJpeg bool) error {
+	if asJpeg {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: encoding.JpegQuality})
+	}
+	encoder := png.Encoder{CompressionLevel: encoding.PNGCompression}
+	return encoder.Encode(w, img)
+}
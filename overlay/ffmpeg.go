@@ -0,0 +1,40 @@
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// With -useffmpeg, conversions to APNG are left to ffmpeg when it's on the
+// PATH: it's much faster than the encoder in SteamGrid and keeps the frames
+// out of its memory. Whatever ffmpeg can't convert, like animated WEBP for
+// older versions of it, goes through the built-in conversion.
+
+// HasFFmpeg tells if ffmpeg is on the PATH.
+func HasFFmpeg() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
+
+// FFmpegToApng converts an animation to an APNG played loopCount times, 0 for
+// ever, with ffmpeg. inputArgs go before the input, like the decoder to use.
+func FFmpegToApng(imageBytes []byte, inputArgs []string, loopCount int) ([]byte, error) {
+	args := append([]string{"-hide_banner", "-loglevel", "error"}, inputArgs...)
+	args = append(args, "-i", "pipe:0", "-f", "apng", "-plays", strconv.Itoa(loopCount), "-pix_fmt", "rgba", "pipe:1")
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdin = bytes.NewReader(imageBytes)
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, errors.New("ffmpeg could not convert to APNG: " + strings.TrimSpace(stderr.String()))
+	} else if !IsAnimatedPNG(stdout.Bytes()) {
+		return nil, errors.New("ffmpeg gave no APNG")
+	}
+	return stdout.Bytes(), nil
+}
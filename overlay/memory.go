@@ -0,0 +1,133 @@
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Units -maxmem accepts, largest first so "MB" isn't read as "B".
+var byteSizeUnits = []struct {
+	suffix string
+	bytes  uint64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseByteSize parses a size like "1500MB" or "2GB". A number alone is in
+// megabytes.
+func ParseByteSize(given string) (uint64, error) {
+	size := strings.ToUpper(strings.TrimSpace(given))
+	multiplier := uint64(1 << 20)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(size, unit.suffix) {
+			size = strings.TrimSpace(strings.TrimSuffix(size, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(size, 64)
+	if err != nil || value <= 0 {
+		return 0, errors.New("invalid size " + given + ", expected something like 1500MB or 2GB")
+	}
+	return uint64(value * float64(multiplier)), nil
+}
+
+// How many overlays are placed at once before the run.
+var overlayWorkers = runtime.NumCPU()
+
+// ApplyMemoryLimit keeps the run under a memory limit, for devices with little
+// of it: the garbage collector works harder as the limit gets near, overlays
+// are placed one at a time and fewer of them are kept, and animations too big
+// to convert within half of it are converted through temporary files.
+func ApplyMemoryLimit(limit uint64) {
+	if limit == 0 {
+		return
+	}
+	debug.SetMemoryLimit(int64(limit))
+	overlayWorkers = 1
+	if overlayCacheMaxBytes > int(limit/8) {
+		overlayCacheMaxBytes = int(limit / 8)
+	}
+}
+
+// Estimates the memory a conversion to APNG needs, frame by frame: the frame
+// being decoded, the one being drawn and encoded, and the APNG written so far,
+// which deflate usually keeps under a third of the raw frames.
+func apngConversionMemory(width int, height int, frames int) uint64 {
+	frameBytes := uint64(width) * uint64(height) * 4
+	return 2*frameBytes + frameBytes*uint64(frames)/3
+}
+
+// Estimates the memory a conversion to APNG needs when the APNG is written to
+// a temporary file as it's encoded: the frames being decoded and drawn, and
+// at the end the APNG alone, read back once they are gone.
+func apngSpooledConversionMemory(width int, height int, frames int) uint64 {
+	frameBytes := uint64(width) * uint64(height) * 4
+	if encoded := frameBytes * uint64(frames) / 3; encoded > 2*frameBytes {
+		return encoded
+	}
+	return 2 * frameBytes
+}
+
+// ApngConversionFits tells whether a conversion to APNG fits in maxMem, and
+// whether it only fits through a temporary file. Everything fits without a
+// limit.
+func ApngConversionFits(width int, height int, frames int, maxMem uint64) (fits bool, spooled bool) {
+	if maxMem == 0 || apngConversionMemory(width, height, frames) <= maxMem {
+		return true, false
+	}
+	return apngSpooledConversionMemory(width, height, frames) <= maxMem, true
+}
+
+// Where a conversion to APNG writes the animation: memory, or a temporary
+// file for animations too big to convert in memory, read back at the end.
+type apngOutput struct {
+	buf  *bytes.Buffer
+	file *os.File
+}
+
+func newApngOutput(spooled bool) (*apngOutput, error) {
+	if !spooled {
+		return &apngOutput{buf: new(bytes.Buffer)}, nil
+	}
+	file, err := ioutil.TempFile("", "steamgrid-*.png")
+	if err != nil {
+		return nil, err
+	}
+	return &apngOutput{file: file}, nil
+}
+
+func (output *apngOutput) Write(p []byte) (int, error) {
+	if output.file != nil {
+		return output.file.Write(p)
+	}
+	return output.buf.Write(p)
+}
+
+// Returns the APNG written and removes the temporary file.
+func (output *apngOutput) bytes() ([]byte, error) {
+	if output.buf != nil {
+		return output.buf.Bytes(), nil
+	}
+	defer output.release()
+	return ioutil.ReadFile(output.file.Name())
+}
+
+// Removes the temporary file, if any. Safe to call more than once.
+func (output *apngOutput) release() {
+	if output.file != nil {
+		output.file.Close()
+		os.Remove(output.file.Name())
+		output.file = nil
+	}
+}
@@ -0,0 +1,63 @@
+package overlay
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestApngConversionFits(t *testing.T) {
+	// A 600x900 cover takes 2160000 bytes a frame.
+	tests := []struct {
+		frames      int
+		maxMem      uint64
+		wantFits    bool
+		wantSpooled bool
+	}{
+		{100, 0, true, false},
+		{100, 1 << 30, true, false},
+		// 4320000 for two frames, and a third of 100 of them for the APNG.
+		{100, 4320000 + 72000000, true, false},
+		{100, 4320000 + 72000000 - 1, true, true},
+		{100, 72000000, true, true},
+		{100, 72000000 - 1, false, true},
+		// Few frames need little more than the two being drawn.
+		{3, 4320000, true, true},
+		{3, 4320000 - 1, false, true},
+	}
+	for _, test := range tests {
+		fits, spooled := ApngConversionFits(600, 900, test.frames, test.maxMem)
+		if fits != test.wantFits || spooled != test.wantSpooled {
+			t.Errorf("apngConversionFits(600, 900, %v, %v) = %v, %v, want %v, %v", test.frames, test.maxMem, fits, spooled, test.wantFits, test.wantSpooled)
+		}
+	}
+}
+
+func TestApngOutput(t *testing.T) {
+	for _, spooled := range []bool{false, true} {
+		output, err := newApngOutput(spooled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var path string
+		if output.file != nil {
+			path = output.file.Name()
+		}
+		want := bytes.Repeat([]byte("frame"), 1000)
+		for i := 0; i < 1000; i++ {
+			output.Write(want[i*5 : i*5+5])
+		}
+
+		got, err := output.bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("spooled %v: got %v bytes back, want %v", spooled, len(got), len(want))
+		}
+		if _, err := os.Stat(path); spooled && !os.IsNotExist(err) {
+			t.Errorf("temporary file %v left behind", path)
+		}
+		output.release()
+	}
+}
@@ -0,0 +1,170 @@
+package overlay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// Overlays placed on images of some size, and stacks of overlays, shared by
+// every game and user of the run. Without them the same overlay is scaled
+// again for every game. Placed overlays are also kept on disk for the next
+// runs.
+var overlayCache struct {
+	sync.Mutex
+	placed  map[string]*image.RGBA
+	stacked map[string]*CategoryOverlay
+	nBytes  int
+}
+
+// Placed overlays stop being kept in memory past this size. Images of
+// unusual sizes each get their own.
+var overlayCacheMaxBytes = 1 << 30
+
+// Sizes the overlays of each art style are scaled to before the run, the ones
+// Steam shows. Logos and icons come in any size.
+var prescaleSizes = map[string]image.Point{
+	"Banner": {920, 430},
+	"Cover":  {600, 900},
+	"Hero":   {1920, 620},
+}
+
+// Directory placed overlays are kept in for the next runs. Nothing is kept on
+// disk when it's empty.
+var PlacedOverlayDir string
+
+// Identifies the contents of an overlay file.
+func overlayHash(imageBytes []byte) string {
+	hash := sha256.Sum256(imageBytes)
+	return hex.EncodeToString(hash[:])
+}
+
+// Placed overlays are stored by the hash of the overlay, the frame, the
+// placement and the size of the image.
+func placedOverlayPath(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(PlacedOverlayDir, hex.EncodeToString(hash[:])+".png")
+}
+
+// Reads a placed overlay from disk, or returns nil.
+func loadPlacedOverlay(path string, size image.Point) *image.RGBA {
+	placedBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	decoded, err := png.Decode(bytes.NewReader(placedBytes))
+	if err != nil || decoded.Bounds().Size() != size {
+		return nil
+	}
+	layer := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	draw.Draw(layer, layer.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+	return layer
+}
+
+// Returns a frame of an overlay (-1 for a still one) placed on a transparent
+// image of the given size, from the cache when it was placed before. The
+// layer is shared and mustn't be changed.
+func placedLayer(overlay *CategoryOverlay, frame int, placement OverlayPlacement, size image.Point) *image.RGBA {
+	key := fmt.Sprintf("%v %v %+v %vx%v", overlay.hash, frame, placement, size.X, size.Y)
+	if overlay.hash != "" {
+		overlayCache.Lock()
+		layer, ok := overlayCache.placed[key]
+		overlayCache.Unlock()
+		if ok {
+			return layer
+		}
+	}
+
+	img := overlay.image
+	if frame >= 0 {
+		img = overlay.frames[frame]
+	}
+	if overlay.hash == "" {
+		return placement.layer(img, size)
+	}
+	var layer *image.RGBA
+	if PlacedOverlayDir == "" {
+		layer = placement.layer(img, size)
+	} else {
+		path := placedOverlayPath(key)
+		layer = loadPlacedOverlay(path, size)
+		if layer == nil {
+			layer = placement.layer(img, size)
+			buf := new(bytes.Buffer)
+			// Failing to store it only means placing it again next time.
+			if png.Encode(buf, layer) == nil && os.MkdirAll(filepath.Dir(path), 0777) == nil {
+				ioutil.WriteFile(path, buf.Bytes(), 0666)
+			}
+		}
+	}
+
+	overlayCache.Lock()
+	defer overlayCache.Unlock()
+	if overlayCache.placed == nil {
+		overlayCache.placed = make(map[string]*image.RGBA)
+	}
+	if overlayCache.nBytes+len(layer.Pix) <= overlayCacheMaxBytes {
+		overlayCache.placed[key] = layer
+		overlayCache.nBytes += len(layer.Pix)
+	}
+	return layer
+}
+
+// Returns the stack of overlays with the given key, making it the first time.
+func cachedStack(key string, stack func() *CategoryOverlay) *CategoryOverlay {
+	overlayCache.Lock()
+	stacked, ok := overlayCache.stacked[key]
+	overlayCache.Unlock()
+	if ok {
+		return stacked
+	}
+	stacked = stack()
+	overlayCache.Lock()
+	defer overlayCache.Unlock()
+	if overlayCache.stacked == nil {
+		overlayCache.stacked = make(map[string]*CategoryOverlay)
+	}
+	overlayCache.stacked[key] = stacked
+	return stacked
+}
+
+// PrescaleOverlays places the still overlays of the art styles of fixed size
+// before the run, overlayWorkers at a time, so the games only reuse them.
+// Overlays stretched over the whole image are drawn as they are and need
+// nothing.
+func PrescaleOverlays(overlays map[string]*CategoryOverlay, artStyles map[string][]string, placement OverlayPlacement) {
+	if placement.isStretch() {
+		return
+	}
+	var wg sync.WaitGroup
+	workers := make(chan bool, overlayWorkers)
+	for name, overlay := range overlays {
+		if len(overlay.frames) > 0 {
+			continue
+		}
+		for artStyle, artStyleExtensions := range artStyles {
+			size, ok := prescaleSizes[artStyle]
+			if !ok || !strings.HasSuffix(name, artStyleExtensions[1]) {
+				continue
+			}
+			wg.Add(1)
+			workers <- true
+			go func(overlay *CategoryOverlay, size image.Point) {
+				defer wg.Done()
+				placedLayer(overlay, -1, placement, size)
+				<-workers
+			}(overlay, size)
+		}
+	}
+	wg.Wait()
+}
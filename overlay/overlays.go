@@ -0,0 +1,704 @@
+// Package overlay puts category overlays on artwork, still or animated, with
+// what that takes: decoding and encoding APNG and WEBP animations, converting
+// WEBM videos with ffmpeg and keeping big animations within memory limits.
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"runtime/debug"
+
+	// "image/draw"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/apng"
+	"github.com/kmicki/webpanimation"
+	"golang.org/x/image/draw"
+)
+
+// CategoryOverlay is an overlay image, with the layer it's stacked in when a
+// game is in several categories with overlays. Animated overlays also have
+// every frame, drawn over the whole canvas, and their delays in milliseconds.
+// The hash identifies the overlay in the cache of placed overlays.
+type CategoryOverlay struct {
+	image  image.Image
+	layer  int
+	frames []image.Image
+	delays []int
+	hash   string
+}
+
+// Returns the index of the frame of an animated overlay shown at a time since
+// the animation started, which loops, or -1 if not animated.
+func (overlay *CategoryOverlay) frameIndexAt(ms int) int {
+	if len(overlay.frames) == 0 {
+		return -1
+	}
+	total := 0
+	for _, delay := range overlay.delays {
+		total += delay
+	}
+	if total == 0 {
+		return 0
+	}
+	ms %= total
+	for i, delay := range overlay.delays {
+		if ms < delay {
+			return i
+		}
+		ms -= delay
+	}
+	return len(overlay.frames) - 1
+}
+
+// Returns the frame of an animated overlay shown at a time since the
+// animation started, or the overlay itself if not animated.
+func (overlay *CategoryOverlay) frameAt(ms int) image.Image {
+	if i := overlay.frameIndexAt(ms); i >= 0 {
+		return overlay.frames[i]
+	}
+	return overlay.image
+}
+
+// Decodes the frames of an APNG overlay, or returns nil for still images.
+func decodeAnimatedOverlay(imageBytes []byte) ([]image.Image, []int) {
+	apngImage, err := apng.DecodeAll(bytes.NewBuffer(imageBytes))
+	if err != nil || len(apngImage.Frames) <= 1 {
+		return nil, nil
+	}
+
+	// Frames after the first may only cover part of the canvas.
+	size := apngImage.Frames[0].Image.Bounds().Max
+	canvas := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	var frames []image.Image
+	var delays []int
+	for _, frame := range apngImage.Frames {
+		if frame.BlendOp == apng.BLEND_OP_SOURCE {
+			draw.Draw(canvas, frame.Image.Bounds().Add(image.Point{frame.XOffset, frame.YOffset}), image.Transparent, image.Point{}, draw.Src)
+		}
+		draw.Draw(canvas, canvas.Bounds(), frame.Image, image.Point{0 - frame.XOffset, 0 - frame.YOffset}, draw.Over)
+		frames = append(frames, image.Image(copyRGBA(canvas)))
+
+		if frame.DelayDenominator == 0 {
+			// Per the APNG spec a zero denominator means hundredths.
+			frame.DelayDenominator = 100
+		}
+		delays = append(delays, int(frame.DelayNumerator)*1000/int(frame.DelayDenominator))
+	}
+	return frames, delays
+}
+
+func copyRGBA(img *image.RGBA) *image.RGBA {
+	copied := image.NewRGBA(img.Bounds())
+	copy(copied.Pix, img.Pix)
+	return copied
+}
+
+// Overlay files can start with their layer, like "10-favorites.png".
+var overlayLayerPattern = regexp.MustCompile(`^(\d+)[-_ ]`)
+
+// OverlayLayer splits the layer from the start of an overlay file name.
+func OverlayLayer(name string) (int, string) {
+	groups := overlayLayerPattern.FindStringSubmatch(name)
+	if groups == nil {
+		return 0, name
+	}
+	layer, _ := strconv.Atoi(groups[1])
+	return layer, name[len(groups[0]):]
+}
+
+// LoadOverlays from the given dir, returning a map of name -> overlay.
+func LoadOverlays(dir string, artStyles map[string][]string) (overlays map[string]*CategoryOverlay, err error) {
+	overlays = make(map[string]*CategoryOverlay, 0)
+
+	if _, err = os.Stat(dir); err != nil {
+		return overlays, nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	imageExtensions := []string{"png", "jpg", "jpeg", "gif"}
+
+	for _, file := range files {
+		isImage := false
+		for _, extension := range imageExtensions {
+			isImage = isImage || strings.HasSuffix(file.Name(), extension)
+		}
+		if !isImage {
+			continue
+		}
+
+		imageBytes, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		img, _, err := image.Decode(bytes.NewBuffer(imageBytes))
+		if err != nil {
+			return overlays, err
+		}
+		frames, delays := decodeAnimatedOverlay(imageBytes)
+
+		layer, name := OverlayLayer(strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())))
+		// Normalize overlay name.
+		for _, artStyleExtensions := range artStyles {
+			if strings.HasSuffix(name, artStyleExtensions[1]) {
+				name = strings.TrimSuffix(name, artStyleExtensions[1])
+				name = strings.TrimRight(strings.ToLower(name), "s")
+				name = name + artStyleExtensions[1]
+			}
+		}
+
+		overlays[name] = &CategoryOverlay{img, layer, frames, delays, overlayHash(imageBytes)}
+	}
+
+	return
+}
+
+// OverlayPlacement is how overlays are put on the images: stretched over the
+// whole image, fit inside it, tiled, or as a small badge. Fit overlays and
+// badges are placed at the anchor, away from the edges by the margin (in
+// percent of the shortest side). Badges are scale percent of the width of the
+// image. Opacity goes from 1 to 100.
+type OverlayPlacement struct {
+	Mode    string
+	Anchor  string
+	Margin  float64
+	Scale   float64
+	Opacity float64
+}
+
+// OverlayModes and OverlayAnchors are the values of -overlaymode and
+// -overlayanchor.
+var OverlayModes = []string{"stretch", "fit", "tile", "badge"}
+var OverlayAnchors = []string{"topleft", "top", "topright", "left", "center", "right", "bottomleft", "bottom", "bottomright"}
+
+// Whether the overlay covers the whole image as it is, the way overlays have
+// always been applied.
+func (placement OverlayPlacement) isStretch() bool {
+	return (placement.Mode == "" || placement.Mode == "stretch") && placement.Opacity >= 100
+}
+
+// Returns the overlay placed on a transparent image of the given size.
+func (placement OverlayPlacement) layer(overlay image.Image, size image.Point) *image.RGBA {
+	layer := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	overlaySize := overlay.Bounds().Size()
+	margin := int(placement.Margin / 100 * float64(size.X))
+	if size.Y < size.X {
+		margin = int(placement.Margin / 100 * float64(size.Y))
+	}
+
+	switch placement.Mode {
+	case "tile":
+		for y := 0; y < size.Y; y += overlaySize.Y {
+			for x := 0; x < size.X; x += overlaySize.X {
+				draw.Draw(layer, image.Rect(x, y, x+overlaySize.X, y+overlaySize.Y), overlay, overlay.Bounds().Min, draw.Over)
+			}
+		}
+	case "fit", "badge":
+		scale := float64(size.X-2*margin) / float64(overlaySize.X)
+		if heightScale := float64(size.Y-2*margin) / float64(overlaySize.Y); heightScale < scale {
+			scale = heightScale
+		}
+		if placement.Mode == "badge" {
+			scale = placement.Scale / 100 * float64(size.X) / float64(overlaySize.X)
+		}
+		width, height := int(float64(overlaySize.X)*scale), int(float64(overlaySize.Y)*scale)
+
+		x, y := (size.X-width)/2, (size.Y-height)/2
+		anchor := strings.ToLower(placement.Anchor)
+		if strings.Contains(anchor, "left") {
+			x = margin
+		} else if strings.Contains(anchor, "right") {
+			x = size.X - width - margin
+		}
+		if strings.HasPrefix(anchor, "top") {
+			y = margin
+		} else if strings.HasPrefix(anchor, "bottom") {
+			y = size.Y - height - margin
+		}
+		draw.ApproxBiLinear.Scale(layer, image.Rect(x, y, x+width, y+height), overlay, overlay.Bounds(), draw.Over, nil)
+	default:
+		if overlaySize.X != size.X && overlaySize.Y != size.Y {
+			// https://godoc.org/golang.org/x/image/draw#Kernel.Scale
+			draw.ApproxBiLinear.Scale(layer, layer.Bounds(), overlay, overlay.Bounds(), draw.Over, nil)
+		} else {
+			draw.Draw(layer, layer.Bounds(), overlay, overlay.Bounds().Min, draw.Src)
+		}
+	}
+
+	// The colors are premultiplied, so everything fades.
+	if placement.Opacity < 100 {
+		opacity := placement.Opacity / 100
+		for i := range layer.Pix {
+			layer.Pix[i] = uint8(float64(layer.Pix[i]) * opacity)
+		}
+	}
+	return layer
+}
+
+// Returns the overlays of the categories of a game, stacked into one overlay
+// the size of the bottom one, or nil if there's none. Overlays go from the
+// lowest layer to the highest, then the categories in order (from bottom to
+// top) go over them. Only the top maxOverlays are kept, if not 0. Stacks with
+// animated overlays are animated, with the frames of the longest one.
+func stackOverlays(tags []string, overlays map[string]*CategoryOverlay, artStyleExtension string, order []string, maxOverlays int) *CategoryOverlay {
+	type layer struct {
+		name    string
+		overlay *CategoryOverlay
+		rank    int
+	}
+	var layers []layer
+	for _, tag := range tags {
+		// Normalize tag name by lower-casing it and remove trailing "s" from
+		// plurals. Also, <, > and / are replaced with - because you can't have
+		// them in Windows paths.
+		tagName := strings.TrimRight(strings.ToLower(tag), "s")
+		tagName = strings.Replace(tagName, "<", "-", -1)
+		tagName = strings.Replace(tagName, ">", "-", -1)
+		tagName = strings.Replace(tagName, "/", "-", -1)
+
+		overlay, ok := overlays[tagName+artStyleExtension]
+		if !ok {
+			continue
+		}
+		rank := 0
+		for i, category := range order {
+			if strings.TrimRight(strings.ToLower(strings.TrimSpace(category)), "s") == tagName {
+				rank = i + 1
+			}
+		}
+		layers = append(layers, layer{tagName, overlay, rank})
+	}
+	if len(layers) == 0 {
+		return nil
+	}
+
+	sort.Slice(layers, func(i, j int) bool {
+		if layers[i].rank != layers[j].rank {
+			return layers[i].rank < layers[j].rank
+		}
+		if layers[i].overlay.layer != layers[j].overlay.layer {
+			return layers[i].overlay.layer < layers[j].overlay.layer
+		}
+		return layers[i].name < layers[j].name
+	})
+	if maxOverlays > 0 && len(layers) > maxOverlays {
+		layers = layers[len(layers)-maxOverlays:]
+	}
+	if len(layers) == 1 {
+		return layers[0].overlay
+	}
+
+	var stackedOverlays []*CategoryOverlay
+	var hashes []string
+	for _, layer := range layers {
+		stackedOverlays = append(stackedOverlays, layer.overlay)
+		hashes = append(hashes, layer.overlay.hash)
+	}
+	key := strings.Join(hashes, " ")
+	return cachedStack(key, func() *CategoryOverlay {
+		return stackLayers(stackedOverlays, overlayHash([]byte(key)))
+	})
+}
+
+// Stacks overlays, from bottom to top, into one the size of the bottom one.
+func stackLayers(overlays []*CategoryOverlay, hash string) *CategoryOverlay {
+	bottom := overlays[0].image
+	stack := func(ms int) image.Image {
+		stacked := image.NewRGBA(image.Rect(0, 0, bottom.Bounds().Dx(), bottom.Bounds().Dy()))
+		for _, overlay := range overlays {
+			frame := overlay.frameAt(ms)
+			draw.ApproxBiLinear.Scale(stacked, stacked.Bounds(), frame, frame.Bounds(), draw.Over, nil)
+		}
+		return stacked
+	}
+
+	stacked := &CategoryOverlay{image: stack(0), hash: hash}
+	for _, overlay := range overlays {
+		if len(overlay.frames) > len(stacked.frames) {
+			stacked.delays = overlay.delays
+			stacked.frames = make([]image.Image, len(overlay.frames))
+		}
+	}
+	ms := 0
+	for i := range stacked.frames {
+		stacked.frames[i] = stack(ms)
+		ms += stacked.delays[i]
+	}
+	return stacked
+}
+
+// Puts the frame of an overlay shown at some time on an image of the given
+// size, reusing the ones placed before.
+type placedOverlay struct {
+	overlay   *CategoryOverlay
+	placement OverlayPlacement
+	size      image.Point
+}
+
+func (placed *placedOverlay) at(ms int) *image.RGBA {
+	return placedLayer(placed.overlay, placed.overlay.frameIndexAt(ms), placed.placement, placed.size)
+}
+
+// Draws a frame of an overlay (-1 for a still one) over a still image.
+// Overlays stretched over the whole image set its size, others are placed on
+// it as it is.
+func overlayStill(gameImage image.Image, overlay *CategoryOverlay, frame int, placement OverlayPlacement) *image.RGBA {
+	overlayImage := overlay.image
+	if frame >= 0 {
+		overlayImage = overlay.frames[frame]
+	}
+	originalSize := gameImage.Bounds().Size()
+	overlaySize := overlayImage.Bounds().Size()
+	var result *image.RGBA
+	if placement.isStretch() {
+		// We expect overlays in the correct format so we have to scale the image if it doesn't fit
+		result = image.NewRGBA(image.Rect(0, 0, overlaySize.X, overlaySize.Y))
+		if originalSize.X != overlaySize.X && originalSize.Y != overlaySize.Y {
+			// scale to fit overlay
+			// https://godoc.org/golang.org/x/image/draw#Kernel.Scale
+			draw.ApproxBiLinear.Scale(result, result.Bounds(), gameImage, gameImage.Bounds(), draw.Over, nil)
+		} else {
+			draw.Draw(result, result.Bounds(), gameImage, gameImage.Bounds().Min, draw.Src)
+		}
+		draw.Draw(result, result.Bounds(), overlayImage, overlayImage.Bounds().Min, draw.Over)
+	} else {
+		// Placed overlays go over the image as it is.
+		result = image.NewRGBA(image.Rect(0, 0, originalSize.X, originalSize.Y))
+		draw.Draw(result, result.Bounds(), gameImage, gameImage.Bounds().Min, draw.Src)
+		draw.Draw(result, result.Bounds(), placedLayer(overlay, frame, placement, originalSize), image.Point{}, draw.Over)
+	}
+	return result
+}
+
+// ApplyOverlay puts the overlays of the categories in tags on an image with
+// the extension ext, printing to log. Overlays of later tags go on top.
+// Returns the resulting image and its extension, or nil when there was nothing
+// to apply. WEBP animations are converted to APNG a frame at a time, or by
+// ffmpeg with useFFmpeg when there are no overlays, and left as they are when
+// even that would take more than maxMem.
+func ApplyOverlay(imageBytes []byte, ext string, tags []string, overlays map[string]*CategoryOverlay, artStyleExtensions []string, overlayOrder []string, maxOverlays int, placement OverlayPlacement, convertWebpToApng bool, convertWebpToApngCoversBanners bool, maxMem uint64, useFFmpeg bool, encoding StillEncoding, log io.Writer) ([]byte, string, error) {
+	if imageBytes == nil || len(tags) == 0 {
+		return nil, ext, nil
+	}
+	// ICO files can't be decoded, icons in that format are left as they are.
+	if ext == ".ico" {
+		return nil, ext, nil
+	}
+
+	buf := new(bytes.Buffer)
+	bufReady := false
+	var errBuff error
+	errBuff = nil
+
+	convertWebpToApng = convertWebpToApng || (convertWebpToApngCoversBanners &&
+		(strings.Contains(artStyleExtensions[1], "cover")) || (strings.Contains(artStyleExtensions[1], "banner")))
+
+	isApng := false
+	isWebp := false
+	formatFound := false
+
+	var err error
+	var webpImage *webpanimation.WebpAnimationDecoded
+	defer func() {
+		releaseWebpDecoder(webpImage)
+	}()
+	// Conversions to APNG too big for memory are written to a temporary file.
+	spooled := false
+	var apngOut *apngOutput
+	defer func() {
+		if apngOut != nil {
+			apngOut.release()
+		}
+	}()
+
+	// Try WEBP
+	var gameImage image.Image
+	webpImage, err = newWebpDecoder(imageBytes)
+	if err == nil {
+		formatFound = true
+		if err != nil {
+			formatFound = false
+		} else if webpImage.FrameCnt <= 1 {
+			webpFrame, ok := webpanimation.GetNextFrame(webpImage)
+			if ok {
+				gameImage = webpFrame.Image
+			} else {
+				err = errors.New("can't get the first frame of single-frame WEBP image")
+			}
+		} else {
+			isWebp = true
+			if convertWebpToApng && maxMem > 0 {
+				convertWebpToApng, spooled = ApngConversionFits(webpImage.Width, webpImage.Height, webpImage.FrameCnt, maxMem)
+				if !convertWebpToApng {
+					fmt.Fprintln(log, "WEBP animation too big to convert to APNG. Leaving WEBP.")
+				} else if spooled {
+					fmt.Fprintln(log, "WEBP animation too big to convert to APNG in memory. Converting through a temporary file.")
+				}
+				if spooled || apngConversionMemory(webpImage.Width, webpImage.Height, webpImage.FrameCnt) > maxMem/2 {
+					// free up memory for big conversion
+					debug.FreeOSMemory()
+				}
+			}
+		}
+	}
+
+	// Try APNG
+	var apngImage apng.APNG
+	if !formatFound {
+		apngImage, err = apng.DecodeAll(bytes.NewBuffer(imageBytes))
+		if err == nil {
+			if len(apngImage.Frames) > 1 {
+				isApng = true
+			} else {
+				gameImage = apngImage.Frames[0].Image
+			}
+		} else {
+			gameImage, _, err = image.Decode(bytes.NewBuffer(imageBytes))
+			if err != nil {
+				return nil, ext, err
+			}
+		}
+	}
+
+	applied := false
+	var webpanim *webpanimation.WebpAnimation
+	defer func() {
+		releaseWebpEncoder(webpanim)
+	}()
+	// Every overlay is stacked first, so animations are only gone through
+	// once.
+	if overlay := stackOverlays(tags, overlays, artStyleExtensions[1], overlayOrder, maxOverlays); overlay != nil {
+		if isApng {
+			fmt.Fprintf(log, "Apply Overlay to APNG.")
+			originalSize := apngImage.Frames[0].Image.Bounds().Max
+
+			// Scale overlay to imageSize so the images won't get that huge…
+			overlayScaled := &placedOverlay{overlay: overlay, placement: placement, size: originalSize}
+
+			ms := 0
+			for i, frame := range apngImage.Frames {
+				result := image.NewRGBA(image.Rect(0, 0, originalSize.X, originalSize.Y))
+				// No idea why these offsets are negative:
+				draw.Draw(result, result.Bounds(), frame.Image, image.Point{0 - frame.XOffset, 0 - frame.YOffset}, draw.Over)
+				draw.Draw(result, result.Bounds(), overlayScaled.at(ms), image.Point{0, 0}, draw.Over)
+				if frame.DelayDenominator == 0 {
+					frame.DelayDenominator = 100
+				}
+				ms += int(frame.DelayNumerator) * 1000 / int(frame.DelayDenominator)
+				apngImage.Frames[i].Image = result
+				apngImage.Frames[i].XOffset = 0
+				apngImage.Frames[i].YOffset = 0
+				apngImage.Frames[i].BlendOp = apng.BLEND_OP_OVER
+				fmt.Fprintf(log, "\rApply Overlay to APNG. Overlayed frame %8d/%d", i, len(apngImage.Frames))
+			}
+			applied = true
+			fmt.Fprintf(log, "\rOverlay applied to %v frames of APNG                                              \n", len(apngImage.Frames))
+		} else if isWebp {
+			fmt.Fprintf(log, "Apply Overlay to WEBP.")
+			if webpImage == nil {
+				fmt.Fprintf(log, "\rWebPImage not initialized.\n")
+				return nil, ext, nil
+			}
+			originalSize := image.Point{webpImage.Width, webpImage.Height}
+			var webpConfig webpanimation.WebPConfig
+			var encoder *apng.FrameByFrameEncoder
+			if convertWebpToApng {
+				bufReady = true
+				apngOut, err = newApngOutput(spooled)
+				if err != nil {
+					return nil, ext, err
+				}
+				encoder = apng.InitializeEncoding(apngOut, uint32(webpImage.FrameCnt), uint(webpImage.LoopCount))
+			} else {
+				webpanim = newWebpEncoder(webpImage.Width, webpImage.Height, webpImage.LoopCount)
+				webpanim.WebPAnimEncoderOptions.SetKmin(9)
+				webpanim.WebPAnimEncoderOptions.SetKmax(17)
+				webpConfig = webpanimation.NewWebpConfig()
+				webpConfig.SetLossless(1)
+			}
+
+			// Scale overlay to imageSize so the images won't get that huge…
+			overlayScaled := &placedOverlay{overlay: overlay, placement: placement, size: originalSize}
+			var result *image.RGBA
+
+			i := 0
+			var lastTimestamp int
+			frame, ok := webpanimation.GetNextFrame(webpImage)
+			for ok {
+				if v, o := frame.Image.(*image.RGBA); o {
+					result = v
+				} else {
+					result = image.NewRGBA(image.Rect(0, 0, originalSize.X, originalSize.Y))
+					draw.Draw(result, result.Bounds(), frame.Image, image.Point{0, 0}, draw.Over)
+				}
+				draw.Draw(result, result.Bounds(), overlayScaled.at(lastTimestamp), image.Point{0, 0}, draw.Over)
+
+				var delay uint16
+				if i == 0 {
+					delay = 0
+				} else {
+					delay = uint16(frame.Timestamp - lastTimestamp)
+				}
+				lastTimestamp = frame.Timestamp
+
+				if convertWebpToApng {
+					apngFrame := apng.Frame{
+						Image:            result,
+						IsDefault:        false,
+						XOffset:          0,
+						YOffset:          0,
+						DisposeOp:        apng.DISPOSE_OP_NONE,
+						BlendOp:          apng.BLEND_OP_OVER,
+						DelayNumerator:   delay,
+						DelayDenominator: 1000,
+					}
+					encoder.EncodeFrame(apngFrame)
+
+					fmt.Fprintf(log, "\rApply Overlay to WEBP as APNG. Overlayed frame %8d/%d", i, webpImage.FrameCnt)
+				} else {
+					err = webpanim.AddFrame(result, frame.Timestamp, webpConfig)
+					fmt.Fprintf(log, "\rApply Overlay to WEBP. Overlayed frame %8d/%d", i, webpImage.FrameCnt)
+				}
+				i++
+				frame, ok = webpanimation.GetNextFrame(webpImage)
+			}
+			applied = true
+			if convertWebpToApng {
+				errBuff = encoder.Finish()
+				fmt.Fprintf(log, "\rOverlay applied to %v frames of WEBP as APNG                                                             \n", webpImage.FrameCnt)
+			} else {
+				fmt.Fprintf(log, "\rOverlay applied to %v frames of WEBP                                                              \n", webpImage.FrameCnt)
+			}
+		} else if len(overlay.frames) > 0 {
+			// Animated overlays make still images animated, written as APNG.
+			fmt.Fprintf(log, "Apply Animated Overlay to Single Image.")
+			animated := apng.APNG{}
+			for i := range overlay.frames {
+				animated.Frames = append(animated.Frames, apng.Frame{
+					Image:            overlayStill(gameImage, overlay, i, placement),
+					DisposeOp:        apng.DISPOSE_OP_NONE,
+					BlendOp:          apng.BLEND_OP_SOURCE,
+					DelayNumerator:   uint16(overlay.delays[i]),
+					DelayDenominator: 1000,
+				})
+			}
+			bufReady = true
+			errBuff = apng.Encode(buf, animated)
+			ext = ".png"
+			applied = true
+			fmt.Fprintf(log, "\rApplied Animated Overlay to Single Image, %v frames.\n", len(animated.Frames))
+		} else {
+			fmt.Fprintf(log, "Apply Overlay to Single Image.")
+			gameImage = overlayStill(gameImage, overlay, -1, placement)
+			applied = true
+			fmt.Fprintf(log, "\rApplied Overlay to Single Image.\n")
+		}
+	}
+
+	if !applied && isWebp && convertWebpToApng && useFFmpeg {
+		converted, err := FFmpegToApng(imageBytes, nil, webpImage.LoopCount)
+		if err == nil {
+			fmt.Fprintln(log, "Converted WEBP to APNG with ffmpeg")
+			return converted, ext, nil
+		}
+		fmt.Fprintln(log, err.Error()+", converting without it")
+	}
+	if !applied {
+		if isWebp && convertWebpToApng {
+			bufReady = true
+
+			// Convert to APNG without overlay
+			fmt.Fprintf(log, "Convert WEBP to APNG.")
+			if webpImage == nil {
+				fmt.Fprintf(log, "\rWebPImage not initialized.\n")
+				return nil, ext, nil
+			}
+			originalSize := image.Point{webpImage.Width, webpImage.Height}
+			apngOut, err = newApngOutput(spooled)
+			if err != nil {
+				return nil, ext, err
+			}
+			encoder := apng.InitializeEncoding(apngOut, uint32(webpImage.FrameCnt), uint(webpImage.LoopCount))
+
+			i := 0
+			var lastTimestamp int
+			frame, ok := webpanimation.GetNextFrame(webpImage)
+			var result *image.RGBA
+			for ok {
+				if v, o := frame.Image.(*image.RGBA); o {
+					result = v
+				} else {
+					result = image.NewRGBA(image.Rect(0, 0, originalSize.X, originalSize.Y))
+					draw.Draw(result, result.Bounds(), frame.Image, image.Point{0, 0}, draw.Over)
+				}
+
+				var delay uint16
+				if i == 0 {
+					delay = 0
+				} else {
+					delay = uint16(frame.Timestamp - lastTimestamp)
+				}
+				lastTimestamp = frame.Timestamp
+
+				apngFrame := apng.Frame{
+					Image:            result,
+					IsDefault:        false,
+					XOffset:          0,
+					YOffset:          0,
+					DisposeOp:        apng.DISPOSE_OP_NONE,
+					BlendOp:          apng.BLEND_OP_OVER,
+					DelayNumerator:   delay,
+					DelayDenominator: 1000,
+				}
+				encoder.EncodeFrame(apngFrame)
+
+				fmt.Fprintf(log, "\rConvert to WEBP as APNG. Frame %8d/%d", i, webpImage.FrameCnt)
+				i++
+				frame, ok = webpanimation.GetNextFrame(webpImage)
+			}
+
+			errBuff = encoder.Finish()
+			applied = true
+			fmt.Fprintf(log, "\rConverted %v frames from WEBP to APNG                                                             \n", webpImage.FrameCnt)
+		} else {
+			return nil, ext, nil
+		}
+	}
+
+	if bufReady {
+		err = errBuff
+	} else {
+		if ext == ".jpg" || ext == ".jpeg" {
+			err = encoding.Encode(buf, gameImage, true)
+		} else if (ext == ".png" && isApng) || (isWebp && convertWebpToApng) {
+			err = apng.Encode(buf, apngImage)
+		} else if (ext == ".png" && !isWebp) || (formatFound && !isWebp) {
+			err = encoding.Encode(buf, gameImage, false)
+		} else if isWebp {
+			err = webpanim.Encode(buf)
+		}
+	}
+
+	if err != nil {
+		return nil, ext, err
+	}
+	if apngOut != nil {
+		overlaid, err := apngOut.bytes()
+		return overlaid, ext, err
+	}
+	return buf.Bytes(), ext, nil
+}
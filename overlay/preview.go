@@ -0,0 +1,154 @@
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"time"
+
+	"github.com/kmicki/apng"
+	"github.com/kmicki/webpanimation"
+	"golang.org/x/image/draw"
+)
+
+// Layout of the contact sheets made from animations.
+const (
+	previewFrames  = 12
+	previewColumns = 4
+	previewWidth   = 320
+)
+
+// What an animation costs to show and convert.
+type animationInfo struct {
+	Width    int
+	Height   int
+	Frames   int
+	Duration time.Duration
+}
+
+// Memory needed to hold every frame decoded, as estimated in ApplyOverlay.
+func (info animationInfo) memory() uint64 {
+	return uint64(info.Width) * uint64(info.Height) * 4 * uint64(info.Frames)
+}
+
+func (info animationInfo) String() string {
+	fps := 0.0
+	if info.Duration > 0 {
+		fps = float64(info.Frames) / info.Duration.Seconds()
+	}
+	return fmt.Sprintf("%vx%v, %v frames, %.1fs, %.0f fps, %v MiB decoded", info.Width, info.Height, info.Frames, info.Duration.Seconds(), fps, info.memory()/1024/1024)
+}
+
+// Picks which of frameCount frames go in a contact sheet, evenly spread.
+func previewFrameIndexes(frameCount int) map[int]bool {
+	indexes := make(map[int]bool)
+	n := previewFrames
+	if frameCount < n {
+		n = frameCount
+	}
+	for i := 0; i < n; i++ {
+		indexes[i*frameCount/n] = true
+	}
+	return indexes
+}
+
+// Lays out the thumbnails of the picked frames in a grid.
+func makeContactSheet(thumbs []image.Image) image.Image {
+	thumbSize := thumbs[0].Bounds().Size()
+	columns := previewColumns
+	if len(thumbs) < columns {
+		columns = len(thumbs)
+	}
+	rows := (len(thumbs) + columns - 1) / columns
+
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*thumbSize.X, rows*thumbSize.Y))
+	for i, thumb := range thumbs {
+		offset := image.Point{X: (i % columns) * thumbSize.X, Y: (i / columns) * thumbSize.Y}
+		draw.Draw(sheet, thumb.Bounds().Add(offset), thumb, image.Point{}, draw.Over)
+	}
+	return sheet
+}
+
+// Scales a frame down to the width of a contact sheet thumbnail. The frame is
+// copied, so decoders are free to reuse their buffers.
+func previewThumb(frame image.Image, width int, height int) image.Image {
+	thumbHeight := height * previewWidth / width
+	thumb := image.NewRGBA(image.Rect(0, 0, previewWidth, thumbHeight))
+	draw.ApproxBiLinear.Scale(thumb, thumb.Bounds(), frame, frame.Bounds(), draw.Over, nil)
+	return thumb
+}
+
+// Builds a contact sheet of frames spread over an animated WEBP or APNG, so
+// an animation can be judged without applying it. Returns the sheet and the
+// size, length and memory needs of the animation.
+func previewAnimation(imageBytes []byte) (image.Image, animationInfo, error) {
+	var info animationInfo
+	var thumbs []image.Image
+
+	webpImage, err := newWebpDecoder(imageBytes)
+	if err == nil {
+		defer releaseWebpDecoder(webpImage)
+		info = animationInfo{Width: webpImage.Width, Height: webpImage.Height, Frames: webpImage.FrameCnt}
+		indexes := previewFrameIndexes(webpImage.FrameCnt)
+
+		i := 0
+		frame, ok := webpanimation.GetNextFrame(webpImage)
+		for ok {
+			if indexes[i] {
+				thumbs = append(thumbs, previewThumb(frame.Image, info.Width, info.Height))
+			}
+			info.Duration = time.Duration(frame.Timestamp) * time.Millisecond
+			i++
+			frame, ok = webpanimation.GetNextFrame(webpImage)
+		}
+	} else {
+		apngImage, err := apng.DecodeAll(bytes.NewBuffer(imageBytes))
+		if err != nil {
+			return nil, info, errors.New("not an animated WEBP or APNG image")
+		}
+		if len(apngImage.Frames) == 0 {
+			return nil, info, errors.New("APNG without frames")
+		}
+		size := apngImage.Frames[0].Image.Bounds().Max
+		info = animationInfo{Width: size.X, Height: size.Y, Frames: len(apngImage.Frames)}
+		indexes := previewFrameIndexes(len(apngImage.Frames))
+
+		// Frames after the first may only cover part of the canvas.
+		canvas := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+		for i, frame := range apngImage.Frames {
+			draw.Draw(canvas, canvas.Bounds(), frame.Image, image.Point{0 - frame.XOffset, 0 - frame.YOffset}, draw.Over)
+			if indexes[i] {
+				thumbs = append(thumbs, previewThumb(canvas, size.X, size.Y))
+			}
+			if frame.DelayDenominator == 0 {
+				// Per the APNG spec a zero denominator means hundredths.
+				frame.DelayDenominator = 100
+			}
+			info.Duration += time.Second * time.Duration(frame.DelayNumerator) / time.Duration(frame.DelayDenominator)
+		}
+	}
+
+	if len(thumbs) == 0 {
+		return nil, info, errors.New("no frames to preview")
+	}
+	return makeContactSheet(thumbs), info, nil
+}
+
+// WriteAnimationPreview writes the contact sheet of an animation as a PNG
+// file.
+func WriteAnimationPreview(imageBytes []byte, path string) (animationInfo, error) {
+	sheet, info, err := previewAnimation(imageBytes)
+	if err != nil {
+		return info, err
+	}
+
+	buf := new(bytes.Buffer)
+	err = png.Encode(buf, sheet)
+	if err != nil {
+		return info, err
+	}
+	return info, ioutil.WriteFile(path, buf.Bytes(), 0666)
+}
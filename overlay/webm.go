@@ -0,0 +1,244 @@
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Some animations are only on SteamGridDB as WEBM videos, which Steam can't
+// show. They are decoded by ffmpeg, when it's on the PATH, and encoded again
+// as animated WEBP, or APNG with -webpasapng.
+
+// Frame rate of videos ffmpeg doesn't give one for.
+const defaultWebmFPS = 25
+
+var (
+	webmCodecPattern    = regexp.MustCompile(`Video: (\w+)`)
+	webmSizePattern     = regexp.MustCompile(`, (\d+)x(\d+)`)
+	webmFPSPattern      = regexp.MustCompile(`([\d.]+) fps`)
+	webmDurationPattern = regexp.MustCompile(`Duration: (\d+):(\d+):([\d.]+)`)
+)
+
+// IsWebm tells if bytes are a WEBM video, or any Matroska file, by their EBML
+// header.
+func IsWebm(imageBytes []byte) bool {
+	return bytes.HasPrefix(imageBytes, []byte("\x1a\x45\xdf\xa3"))
+}
+
+// CanConvertWebm tells if WEBM videos can be converted, that is if ffmpeg is
+// on the PATH.
+func CanConvertWebm() bool {
+	return HasFFmpeg()
+}
+
+// What ffmpeg tells of a video.
+type webmInfo struct {
+	codec    string
+	width    int
+	height   int
+	fps      float64
+	duration float64
+}
+
+// Reads the codec, size, frame rate and length of a video from what ffmpeg
+// prints of it when given no output.
+func probeWebm(videoBytes []byte) (webmInfo, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-i", "pipe:0")
+	cmd.Stdin = bytes.NewReader(videoBytes)
+	// ffmpeg fails for want of an output, the description is all that's
+	// needed.
+	output, _ := cmd.CombinedOutput()
+
+	info := webmInfo{fps: defaultWebmFPS}
+	line := ""
+	for _, l := range strings.Split(string(output), "\n") {
+		if strings.Contains(l, "Video:") {
+			line = l
+			break
+		}
+	}
+	codec := webmCodecPattern.FindStringSubmatch(line)
+	size := webmSizePattern.FindStringSubmatch(line)
+	if codec == nil || size == nil {
+		return info, errors.New("ffmpeg found no video in the WEBM")
+	}
+	info.codec = codec[1]
+	info.width, _ = strconv.Atoi(size[1])
+	info.height, _ = strconv.Atoi(size[2])
+	if fps := webmFPSPattern.FindStringSubmatch(line); fps != nil {
+		if value, err := strconv.ParseFloat(fps[1], 64); err == nil && value > 0 {
+			info.fps = value
+		}
+	}
+	if duration := webmDurationPattern.FindStringSubmatch(string(output)); duration != nil {
+		hours, _ := strconv.Atoi(duration[1])
+		minutes, _ := strconv.Atoi(duration[2])
+		seconds, _ := strconv.ParseFloat(duration[3], 64)
+		info.duration = float64(hours*3600+minutes*60) + seconds
+	}
+	if info.width <= 0 || info.height <= 0 {
+		return info, errors.New("ffmpeg found no size for the WEBM")
+	}
+	return info, nil
+}
+
+// Decoders of a codec of WEBM to try in turn. Transparency is only decoded by
+// libvpx, which is tried first; "" is ffmpeg's own.
+func webmDecoders(codec string) []string {
+	switch codec {
+	case "vp9":
+		return []string{"libvpx-vp9", ""}
+	case "vp8":
+		return []string{"libvpx", ""}
+	}
+	return []string{""}
+}
+
+// Decodes a video with ffmpeg at a constant frame rate, calling frame for
+// every frame, one at a time.
+func decodeWebm(videoBytes []byte, info webmInfo, frame func(*image.RGBA) error) error {
+	var err error
+	for _, decoder := range webmDecoders(info.codec) {
+		args := []string{"-hide_banner", "-loglevel", "error"}
+		if decoder != "" {
+			args = append(args, "-c:v", decoder)
+		}
+		args = append(args, "-i", "pipe:0", "-vf", fmt.Sprintf("fps=%v", info.fps), "-f", "rawvideo", "-pix_fmt", "rgba", "pipe:1")
+		var frames int
+		frames, err = runWebmDecoder(args, videoBytes, info, frame)
+		// Only retried when libvpx failed before the first frame.
+		if err == nil || frames > 0 {
+			break
+		}
+	}
+	return err
+}
+
+// Runs ffmpeg, reading its raw frames from its output. Returns how many
+// frames were read.
+func runWebmDecoder(args []string, videoBytes []byte, info webmInfo, frame func(*image.RGBA) error) (int, error) {
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdin = bytes.NewReader(videoBytes)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return 0, err
+	}
+
+	frames := 0
+	for {
+		result := image.NewRGBA(image.Rect(0, 0, info.width, info.height))
+		_, err = io.ReadFull(stdout, result.Pix)
+		if err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			break
+		}
+		err = frame(result)
+		if err != nil {
+			break
+		}
+		frames++
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return frames, err
+	}
+	if err := cmd.Wait(); err != nil {
+		return frames, errors.New("ffmpeg could not decode the WEBM: " + strings.TrimSpace(stderr.String()))
+	} else if frames == 0 {
+		return 0, errors.New("ffmpeg decoded no frames of the WEBM")
+	}
+	return frames, nil
+}
+
+// ConvertWebm converts a WEBM video to an animated WEBP, or to an APNG when
+// asked to and it fits in maxMem (0 for no limit), in one go with useFFmpeg.
+// Returns the image and its extension.
+func ConvertWebm(videoBytes []byte, toApng bool, maxMem uint64, useFFmpeg bool) ([]byte, string, error) {
+	if !CanConvertWebm() {
+		return nil, "", errors.New("WEBM animations need ffmpeg on the PATH to be converted")
+	}
+	info, err := probeWebm(videoBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	delay := int(1000/info.fps + 0.5)
+
+	if toApng && useFFmpeg {
+		for _, decoder := range webmDecoders(info.codec) {
+			var inputArgs []string
+			if decoder != "" {
+				inputArgs = []string{"-c:v", decoder}
+			}
+			converted, err := FFmpegToApng(videoBytes, inputArgs, 0)
+			if err == nil {
+				fmt.Println("Converted WEBM to APNG with ffmpeg")
+				return converted, ".png", nil
+			}
+		}
+	}
+
+	// APNGs need the number of frames before the first one, which takes
+	// decoding the video once more.
+	frames := int(info.duration*info.fps + 0.5)
+	if toApng {
+		frames = 0
+		err = decodeWebm(videoBytes, info, func(frame *image.RGBA) error {
+			frames++
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	spooled := false
+	if toApng {
+		toApng, spooled = ApngConversionFits(info.width, info.height, frames, maxMem)
+		if !toApng {
+			fmt.Println("WEBM animation too big to convert to APNG. Converting to WEBP.")
+		} else if spooled {
+			fmt.Println("WEBM animation too big to convert to APNG in memory. Converting through a temporary file.")
+		}
+	}
+
+	format := "WEBP"
+	if toApng {
+		format = "APNG"
+	}
+	fmt.Printf("Convert WEBM to %v.", format)
+	encoder, err := NewAnimationEncoder(info.width, info.height, frames, 0, toApng, spooled)
+	if err != nil {
+		return nil, "", err
+	}
+	defer encoder.Release()
+	err = decodeWebm(videoBytes, info, func(frame *image.RGBA) error {
+		fmt.Printf("\rConvert WEBM to %v. Frame %8d", format, encoder.frames+1)
+		return encoder.Add(frame, delay)
+	})
+	if err != nil {
+		fmt.Println()
+		return nil, "", err
+	}
+	converted, ext, err := encoder.Encode()
+	if err != nil {
+		fmt.Println()
+		return nil, "", err
+	}
+	fmt.Printf("\rConverted %v frames from WEBM to %v                    \n", encoder.frames, format)
+	return converted, ext, nil
+}
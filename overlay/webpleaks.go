@@ -0,0 +1,92 @@
+package overlay
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/kmicki/webpanimation"
+)
+
+// A WEBP decoder or encoder, whose memory lives outside of Go and has to be
+// released by hand.
+type webpResource struct {
+	kind    string
+	site    string
+	release func()
+}
+
+// WEBP decoders and encoders not released yet. Between two images there
+// should be none, so whatever is left then was leaked on some error path.
+var webpResources = struct {
+	sync.Mutex
+	live map[interface{}]webpResource
+}{live: make(map[interface{}]webpResource)}
+
+func trackWebpResource(resource interface{}, kind string, release func()) {
+	site := "unknown"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		site = fmt.Sprintf("%v:%v", filepath.Base(file), line)
+	}
+	webpResources.Lock()
+	webpResources.live[resource] = webpResource{kind: kind, site: site, release: release}
+	webpResources.Unlock()
+}
+
+func untrackWebpResource(resource interface{}) {
+	webpResources.Lock()
+	delete(webpResources.live, resource)
+	webpResources.Unlock()
+}
+
+// Like webpanimation.GetInfo, but tracked until releaseWebpDecoder.
+func newWebpDecoder(imageBytes []byte) (*webpanimation.WebpAnimationDecoded, error) {
+	decoder, err := webpanimation.GetInfo(bytes.NewBuffer(imageBytes))
+	if err == nil && decoder != nil {
+		trackWebpResource(decoder, "decoder", func() { webpanimation.ReleaseDecoder(decoder) })
+	}
+	return decoder, err
+}
+
+// Releases a decoder from newWebpDecoder. Nil decoders are ignored.
+func releaseWebpDecoder(decoder *webpanimation.WebpAnimationDecoded) {
+	if decoder == nil {
+		return
+	}
+	untrackWebpResource(decoder)
+	webpanimation.ReleaseDecoder(decoder)
+}
+
+// Like webpanimation.NewWebpAnimation, but tracked until releaseWebpEncoder.
+func newWebpEncoder(width int, height int, loopCount int) *webpanimation.WebpAnimation {
+	encoder := webpanimation.NewWebpAnimation(width, height, loopCount)
+	trackWebpResource(encoder, "encoder", encoder.ReleaseMemory)
+	return encoder
+}
+
+// Releases an encoder from newWebpEncoder. Nil encoders are ignored.
+func releaseWebpEncoder(encoder *webpanimation.WebpAnimation) {
+	if encoder == nil {
+		return
+	}
+	untrackWebpResource(encoder)
+	encoder.ReleaseMemory()
+}
+
+// ReleaseLeakedWebpResources is the watchdog run between images: it reports
+// every WEBP decoder and encoder still alive, with where it was created, and
+// releases it. Returns how many there were.
+func ReleaseLeakedWebpResources() int {
+	webpResources.Lock()
+	leaked := webpResources.live
+	webpResources.live = make(map[interface{}]webpResource)
+	webpResources.Unlock()
+
+	for _, resource := range leaked {
+		fmt.Printf("Released a leaked WEBP %v created at %v\n", resource.kind, resource.site)
+		resource.release()
+	}
+	return len(leaked)
+}
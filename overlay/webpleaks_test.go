@@ -0,0 +1,106 @@
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"testing"
+)
+
+// Encodes an animated WEBP of solid frames, each shown for 100ms.
+func syntheticWebpAnimation(t *testing.T, width int, height int, frames int) []byte {
+	encoder, err := NewAnimationEncoder(width, height, frames, 0, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < frames; i++ {
+		frame := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(frame, frame.Bounds(), &image.Uniform{color.RGBA{uint8(i * 40), 80, 160, 255}}, image.Point{}, draw.Src)
+		err = encoder.Add(frame, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	animation, _, err := encoder.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return animation
+}
+
+// An overlay covering the top half of the image in a translucent color.
+func syntheticOverlay(c color.RGBA, layer int) *CategoryOverlay {
+	img := image.NewRGBA(image.Rect(0, 0, 60, 90))
+	draw.Draw(img, image.Rect(0, 0, 60, 45), &image.Uniform{c}, image.Point{}, draw.Src)
+	return &CategoryOverlay{image: img, layer: layer, hash: fmt.Sprint(c)}
+}
+
+func TestApplyOverlayReleasesWebpResources(t *testing.T) {
+	ReleaseLeakedWebpResources()
+	artStyleExtensions := []string{"p", ".cover", "library_600x900_2x.jpg", ""}
+	overlays := map[string]*CategoryOverlay{
+		"favorite" + artStyleExtensions[1]:    syntheticOverlay(color.RGBA{200, 0, 0, 128}, 0),
+		"multiplayer" + artStyleExtensions[1]: syntheticOverlay(color.RGBA{0, 200, 0, 128}, 1),
+		"vr" + artStyleExtensions[1]:          syntheticOverlay(color.RGBA{0, 0, 200, 128}, 2),
+	}
+	animation := syntheticWebpAnimation(t, 60, 90, 4)
+
+	tests := []struct {
+		name    string
+		tags    []string
+		toApng  bool
+		wantErr bool
+		bytes   []byte
+	}{
+		{"one overlay", []string{"Favorites"}, false, false, animation},
+		{"stacked overlays", []string{"Favorites", "Multiplayer", "VR"}, false, false, animation},
+		{"stacked overlays to APNG", []string{"Favorites", "Multiplayer", "VR"}, true, false, animation},
+		{"no matching overlay", []string{"Strategy"}, false, false, animation},
+		{"no overlay to APNG", []string{"Strategy"}, true, false, animation},
+		{"truncated animation", []string{"Favorites"}, false, true, animation[:len(animation)/2]},
+	}
+	for _, test := range tests {
+		_, _, err := ApplyOverlay(test.bytes, ".webp", test.tags, overlays, artStyleExtensions, nil, 0, OverlayPlacement{Opacity: 100}, test.toApng, false, 0, false, StillEncoding{JpegQuality: 95}, ioutil.Discard)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: ApplyOverlay error %v, want error %v", test.name, err, test.wantErr)
+		}
+		if n := ReleaseLeakedWebpResources(); n != 0 {
+			t.Errorf("%v: %v WEBP decoders and encoders leaked", test.name, n)
+		}
+	}
+}
+
+func TestReleaseLeakedWebpResources(t *testing.T) {
+	ReleaseLeakedWebpResources()
+	animation := syntheticWebpAnimation(t, 8, 8, 2)
+
+	decoder, err := newWebpDecoder(animation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseWebpDecoder(decoder)
+	if n := ReleaseLeakedWebpResources(); n != 0 {
+		t.Errorf("%v leaked after releasing the decoder, want 0", n)
+	}
+
+	// Left behind on purpose.
+	_, err = newWebpDecoder(animation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := ReleaseLeakedWebpResources(); n != 1 {
+		t.Errorf("%v leaked after leaking a decoder, want 1", n)
+	}
+	if n := ReleaseLeakedWebpResources(); n != 0 {
+		t.Errorf("%v leaked after the watchdog released them, want 0", n)
+	}
+
+	newWebpDecoder(animation)
+	newWebpDecoder(animation)
+	newWebpEncoder(8, 8, 0)
+	if n := ReleaseLeakedWebpResources(); n != 3 {
+		t.Errorf("%v leaked after leaking two decoders and an encoder, want 3", n)
+	}
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// The serve command answers HTTP requests instead of running once, so a web
+// page or a Steam Deck plugin can drive SteamGrid: list the users and their
+// games, download the artwork of a game, list the SteamGridDB candidates of an
+// image and apply the one picked. Answers are JSON.
+//
+//	GET  /api/users
+//	GET  /api/users/<user>/games
+//	GET  /api/users/<user>/games/<appid>/image?style=cover
+//	GET  /api/users/<user>/games/<appid>/candidates?style=cover
+//	POST /api/users/<user>/games/<appid>/download[?style=cover][&force=true]
+//	POST /api/users/<user>/games/<appid>/apply?style=cover&steamgriddbid=<id>
+//	POST /api/users/<user>/games/<appid>/restore[?force=true]
+//
+// Users are given by account ID or name. Applying an image pins it, like the
+// pins file does, so the next runs keep it. Everything else is the web page
+// of webui.go, unless -noui is given.
+//
+// Every API request needs the token, made up at start unless -token is given.
+// Web pages the user opens can send requests to the server too, so requests
+// from other origins are refused, and so are other host names, which could be
+// names of such pages made to resolve to the server.
+
+// Address the serve command listens on by default, only reachable from this
+// computer.
+const defaultServeAddress = "127.0.0.1:8642"
+
+type artworkServer struct {
+	options *artwork.Options
+	token   string
+	// Port the server listens on, and its host, empty when listening on
+	// every address.
+	port      string
+	host      string
+	web       http.Handler
+	pipeline  *artwork.Pipeline
+	artStyles map[string][]string
+	// Requests are handled one at a time, as the caches of SteamGrid are
+	// shared, and downloads hold the lock of the installation.
+	mutex sync.Mutex
+}
+
+// A user in the answer of /api/users.
+type servedUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// A game in the answer of /api/users/<user>/games, with the status of each
+// art style: missing, original or overlay.
+type servedGame struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Custom     bool              `json:"custom"`
+	Categories []string          `json:"categories"`
+	Artwork    map[string]string `json:"artwork"`
+}
+
+// A SteamGridDB image that can be applied to a game.
+type servedCandidate struct {
+	SteamGridDBID int    `json:"steamGridDBId"`
+	URL           string `json:"url"`
+	Thumb         string `json:"thumb"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	Style         string `json:"style"`
+	Score         int    `json:"score"`
+	Upvotes       int    `json:"upvotes"`
+	Downvotes     int    `json:"downvotes"`
+	Author        string `json:"author"`
+	Animated      bool   `json:"animated"`
+}
+
+// An error of a request, answered with its status code.
+type serveError struct {
+	status  int
+	message string
+}
+
+func (err *serveError) Error() string {
+	return err.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &serveError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &serveError{http.StatusNotFound, fmt.Sprintf(format, args...)}
+}
+
+// Serves the API until SteamGrid is stopped.
+func serveCommand(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	options := &artwork.Options{}
+	registerRunFlags(flags, options)
+	address := flags.String("listen", defaultServeAddress, "Address to listen on, like :8642 to be reachable from other computers")
+	token := flags.String("token", "", "Token requests need, in an \"Authorization: Bearer <token>\" header or a token parameter. A random one by default")
+	noUI := flags.Bool("noui", false, "Only answer API requests, without the web page")
+	parseOptions(flags, options, args)
+
+	host, port, err := net.SplitHostPort(*address)
+	if err != nil {
+		errorAndExit(errors.New("invalid -listen address " + *address + ": " + err.Error()))
+	}
+	if *token == "" {
+		*token, err = randomToken()
+		if err != nil {
+			errorAndExit(err)
+		}
+	}
+
+	server := newArtworkServer(options)
+	server.token = *token
+	server.host = host
+	server.port = port
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		server.host = ""
+	}
+	if !*noUI {
+		server.web = webHandler()
+	}
+
+	pageAddress := *address
+	if server.host == "" {
+		pageAddress = net.JoinHostPort("127.0.0.1", port)
+	}
+	if server.web != nil {
+		fmt.Printf("Serving the artwork of %v on http://%v/?token=%v\n", artwork.SteamInstallationDir, pageAddress, *token)
+	} else {
+		fmt.Printf("Serving the artwork of %v on http://%v/api/users with the token %v\n", artwork.SteamInstallationDir, pageAddress, *token)
+	}
+	err = http.ListenAndServe(*address, server)
+	errorAndExit(err)
+}
+
+// Makes up a token nobody can guess.
+func randomToken() (string, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// Tells if a request was sent to the address the server listens on, by a
+// page of the server itself if by a page at all. Other hosts can only be a
+// name made to resolve to the server, and other origins other web pages. When
+// listening on every address, the server is reached by IP address or by the
+// name of the computer.
+func (server *artworkServer) sameOrigin(r *http.Request) bool {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil || port != server.port {
+		return false
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	ip := net.ParseIP(host)
+	switch {
+	case server.host != "" && strings.EqualFold(host, server.host):
+	case host == "localhost" && (server.host == "" || isLoopback(server.host)):
+	case ip != nil && (server.host == "" || ip.IsLoopback() && isLoopback(server.host)):
+	case server.host == "" && isComputerName(host):
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	return origin == "" || origin == "http://"+r.Host
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "localhost" || ip != nil && ip.IsLoopback()
+}
+
+// Tells if a host name is the name of this computer, like steamdeck or
+// steamdeck.local.
+func isComputerName(host string) bool {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return false
+	}
+	name = strings.ToLower(name)
+	return host == name || host == name+".local"
+}
+
+// Loads what every request needs: the art styles, overlays and overlay rules,
+// and the Steam installation. Quits when something is wrong with them.
+func newArtworkServer(options *artwork.Options) *artworkServer {
+	pipeline, err := artwork.NewPipeline(options, true, true)
+	if err != nil {
+		errorAndExit(err)
+	}
+	artwork.SteamInstallationDir, err = steam.GetSteamInstallation(options.SteamDir)
+	if err != nil {
+		errorAndExit(err)
+	}
+	return &artworkServer{options: options, pipeline: pipeline, artStyles: pipeline.ArtStyles()}
+}
+
+// Checks the origin and the token, and routes a request.
+func (server *artworkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !server.sameOrigin(r) {
+		http.Error(w, "requests from other sites are not answered", http.StatusForbidden)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/api/") && server.web != nil {
+		// The page has nothing to protect, it asks for the token itself.
+		server.web.ServeHTTP(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+server.token && r.URL.Query().Get("token") != server.token {
+		http.Error(w, "wrong or missing token", http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "api" || parts[1] != "users" {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	method := "GET"
+	var handle func() (interface{}, error)
+	switch {
+	case len(parts) == 2:
+		handle = func() (interface{}, error) { return server.users() }
+	case len(parts) == 4 && parts[3] == "games":
+		handle = func() (interface{}, error) { return server.games(parts[2]) }
+	case len(parts) == 6 && parts[5] == "image":
+		handle = func() (interface{}, error) { return nil, server.image(w, r, parts[2], parts[4]) }
+	case len(parts) == 6 && parts[5] == "candidates":
+		handle = func() (interface{}, error) { return server.candidates(parts[2], parts[4], query.Get("style")) }
+	case len(parts) == 6 && parts[5] == "download":
+		method = "POST"
+		handle = func() (interface{}, error) {
+			return server.download(parts[2], parts[4], query.Get("style"), query.Get("force") == "true")
+		}
+	case len(parts) == 6 && parts[5] == "apply":
+		method = "POST"
+		handle = func() (interface{}, error) {
+			return server.apply(parts[2], parts[4], query.Get("style"), query.Get("steamgriddbid"))
+		}
+	case len(parts) == 6 && parts[5] == "restore":
+		method = "POST"
+		handle = func() (interface{}, error) { return server.restore(parts[2], parts[4], query.Get("force") == "true") }
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != method {
+		http.Error(w, "use "+method, http.StatusMethodNotAllowed)
+		return
+	}
+
+	server.mutex.Lock()
+	answer, err := handle()
+	server.mutex.Unlock()
+	if err != nil {
+		status := http.StatusInternalServerError
+		var requestErr *serveError
+		if errors.As(err, &requestErr) {
+			status = requestErr.status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if answer == nil {
+		// Already answered, like images.
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(answer)
+}
+
+func (server *artworkServer) user(name string) (artwork.User, error) {
+	users, err := steam.GetUsers(artwork.SteamInstallationDir)
+	if err != nil {
+		return artwork.User{}, err
+	}
+	user, err := findUser(users, name)
+	if err != nil {
+		return artwork.User{}, notFound("%v", err.Error())
+	}
+	return user, nil
+}
+
+// Loads a single game of a user, or shortcut, whatever the filters of the
+// library flags.
+func (server *artworkServer) game(user artwork.User, gameID string) (*artwork.Game, error) {
+	games := artwork.GetGames(user, artwork.GameFilter{IncludePrivate: true, IncludeHidden: true, IncludeShared: true}, server.options.SteamApiKey)
+	game, ok := games[gameID]
+	if !ok {
+		return nil, notFound("no game %v for %v", gameID, user.Name)
+	}
+	artwork.ResolveGameName(game)
+	return game, nil
+}
+
+// Finds the art style of a style parameter, all of them when it's empty.
+func (server *artworkServer) styles(style string) ([]string, error) {
+	var styles []string
+	for artStyle := range server.artStyles {
+		if style == "" || strings.EqualFold(artStyle, style) {
+			styles = append(styles, artStyle)
+		}
+	}
+	if len(styles) == 0 {
+		return nil, badRequest("unknown or skipped art style %v", style)
+	}
+	sort.Strings(styles)
+	return styles, nil
+}
+
+func (server *artworkServer) users() ([]servedUser, error) {
+	users, err := steam.GetUsers(artwork.SteamInstallationDir)
+	if err != nil {
+		return nil, err
+	}
+	served := []servedUser{}
+	for _, user := range users {
+		served = append(served, servedUser{user.SteamID32, user.Name})
+	}
+	return served, nil
+}
+
+func (server *artworkServer) games(userName string) ([]servedGame, error) {
+	user, err := server.user(userName)
+	if err != nil {
+		return nil, err
+	}
+	games := server.pipeline.Games(user)
+
+	gridDir := filepath.Join(user.Dir, "config", "grid")
+	served := []servedGame{}
+	for _, game := range games {
+		status := map[string]string{}
+		for artStyle, artStyleExtensions := range server.artStyles {
+			if artStyle == "Icon" && !game.Custom {
+				continue
+			}
+			status[artStyle] = artwork.GridImageStatus(gridDir, game.ID, artStyleExtensions)
+		}
+		served = append(served, servedGame{game.ID, game.Name, game.Custom, game.Tags, status})
+	}
+	sort.Slice(served, func(i, j int) bool {
+		return strings.ToLower(served[i].Name) < strings.ToLower(served[j].Name)
+	})
+	return served, nil
+}
+
+// Sends the image of a game in the grid directory, as Steam shows it.
+func (server *artworkServer) image(w http.ResponseWriter, r *http.Request, userName string, gameID string) error {
+	user, err := server.user(userName)
+	if err != nil {
+		return err
+	}
+	styles, err := server.styles(r.URL.Query().Get("style"))
+	if err != nil {
+		return err
+	} else if len(styles) > 1 {
+		return badRequest("no style given")
+	}
+	images, _ := filepath.Glob(filepath.Join(user.Dir, "config", "grid", gameID+server.artStyles[styles[0]][0]+".*"))
+	images = artwork.FilterForImages(images)
+	if len(images) == 0 {
+		return notFound("no %v for %v", strings.ToLower(styles[0]), gameID)
+	}
+	http.ServeFile(w, r, images[0])
+	return nil
+}
+
+func (server *artworkServer) candidates(userName string, gameID string, style string) ([]servedCandidate, error) {
+	options := server.options
+	if options.SteamGridDBApiKey == "" {
+		return nil, badRequest("no SteamGridDB api key, give one with -steamgriddb")
+	}
+	styles, err := server.styles(style)
+	if err != nil {
+		return nil, err
+	} else if len(styles) > 1 {
+		return nil, badRequest("no style given")
+	}
+	user, err := server.user(userName)
+	if err != nil {
+		return nil, err
+	}
+	game, err := server.game(user, gameID)
+	if err != nil {
+		return nil, err
+	}
+	artwork.ApplyNameOverride(game, options)
+
+	images, err := options.SteamGridDB().Images(&game.Game, server.artStyles[styles[0]])
+	if err != nil {
+		return nil, err
+	}
+	served := []servedCandidate{}
+	for _, image := range images {
+		served = append(served, servedCandidate{image.ID, image.URL, image.Thumb, image.Width, image.Height, image.Style, image.Score, image.Upvotes, image.Downvotes, image.Author.Name, image.IsAnimated()})
+	}
+	return served, nil
+}
+
+// Downloads the artwork of a game, or of one art style of it, and applies
+// the overlays, as a run would. Returns what happened to each image.
+func (server *artworkServer) download(userName string, gameID string, style string, force bool) ([]*artwork.ReportEntry, error) {
+	styles, err := server.styles(style)
+	if err != nil {
+		return nil, err
+	}
+	user, err := server.user(userName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = artwork.LockInstallation(artwork.SteamInstallationDir, server.options.Wait)
+	if err != nil {
+		return nil, &serveError{http.StatusConflict, err.Error()}
+	}
+	defer artwork.UnlockInstallation()
+
+	game, err := server.game(user, gameID)
+	if err != nil {
+		return nil, err
+	}
+	return server.pipeline.ProcessGame(user, game, styles, force)
+}
+
+// Pins a SteamGridDB image to an art style of a game and downloads it.
+func (server *artworkServer) apply(userName string, gameID string, style string, steamGridDBID string) ([]*artwork.ReportEntry, error) {
+	if server.options.SteamGridDBApiKey == "" {
+		return nil, badRequest("no SteamGridDB api key, give one with -steamgriddb")
+	}
+	styles, err := server.styles(style)
+	if err != nil {
+		return nil, err
+	} else if len(styles) > 1 {
+		return nil, badRequest("no style given")
+	}
+	id, err := strconv.Atoi(steamGridDBID)
+	if err != nil || id <= 0 {
+		return nil, badRequest("invalid steamgriddbid %v", steamGridDBID)
+	}
+
+	err = artwork.PinSteamGridDBAsset(gameID, styles[0], id, server.options)
+	if err != nil {
+		return nil, err
+	}
+	return server.download(userName, gameID, styles[0], true)
+}
+
+// What the restore request did.
+type servedRestore struct {
+	Restored int `json:"restored"`
+}
+
+// Puts the original artwork of a game back, without the overlays, like the
+// restore command.
+func (server *artworkServer) restore(userName string, gameID string, force bool) (*servedRestore, error) {
+	user, err := server.user(userName)
+	if err != nil {
+		return nil, err
+	}
+	err = artwork.LockInstallation(artwork.SteamInstallationDir, server.options.Wait)
+	if err != nil {
+		return nil, &serveError{http.StatusConflict, err.Error()}
+	}
+	defer artwork.UnlockInstallation()
+
+	nRestored, err := artwork.RestoreBackups(filepath.Join(user.Dir, "config", "grid"), []string{gameID}, force || server.options.Force)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("%v images restored for %v\n", nRestored, gameID)
+	return &servedRestore{nRestored}, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSameOrigin(t *testing.T) {
+	name, _ := os.Hostname()
+	tests := []struct {
+		listenHost string
+		host       string
+		origin     string
+		want       bool
+	}{
+		{"127.0.0.1", "127.0.0.1:8642", "", true},
+		{"127.0.0.1", "localhost:8642", "", true},
+		{"127.0.0.1", "localhost:8642", "http://localhost:8642", true},
+		{"127.0.0.1", "[::1]:8642", "", true},
+		{"127.0.0.1", "127.0.0.1:8643", "", false},
+		{"127.0.0.1", "evil.example:8642", "", false},
+		{"127.0.0.1", "127.0.0.1:8642", "http://evil.example", false},
+		{"127.0.0.1", "127.0.0.1:8642", "null", false},
+		{"127.0.0.1", "192.168.1.2:8642", "", false},
+		{"192.168.1.2", "192.168.1.2:8642", "", true},
+		{"192.168.1.2", "localhost:8642", "", false},
+		{"", "192.168.1.2:8642", "http://192.168.1.2:8642", true},
+		{"", name + ":8642", "", true},
+		{"", "evil.example:8642", "", false},
+		{"", "192.168.1.2", "", false},
+	}
+	for _, test := range tests {
+		server := &artworkServer{host: test.listenHost, port: "8642"}
+		r := httptest.NewRequest("POST", "/api/users", nil)
+		r.Host = test.host
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if got := server.sameOrigin(r); got != test.want {
+			t.Errorf("listening on %q, sameOrigin(Host %q, Origin %q) = %v, want %v", test.listenHost, test.host, test.origin, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/kmicki/steamgrid/artwork"
+)
+
+var handleInterruptsOnce sync.Once
+
+// Starts stopping runs on Ctrl+C and SIGTERM instead of quitting.
+func handleInterrupts() {
+	handleInterruptsOnce.Do(func() {
+		signals := make(chan os.Signal, 2)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			artwork.Stop()
+			fmt.Println("\nStopping after the current image, press Ctrl+C again to quit right away...")
+			<-signals
+			artwork.UnlockInstallation()
+			os.Exit(1)
+		}()
+	})
+}
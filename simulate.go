@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/overlay"
+	"github.com/kmicki/steamgrid/sources"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// ID of the user of simulated Steam installations.
+const simulatedUserID = "12345678"
+
+var simulatedWords = [][]string{
+	{"Crimson", "Silent", "Lost", "Eternal", "Broken", "Hidden", "Iron", "Neon", "Frozen", "Wild"},
+	{"Kingdom", "Frontier", "Legacy", "Odyssey", "Harbor", "Machine", "Garden", "Empire", "Signal", "Voyage"},
+}
+
+// Returns a number from 0 to 99 for a key, the same every time for the same
+// seed.
+func simulatedRoll(seed int64, key string) int {
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%v/%v", seed, key)
+	return int(hash.Sum32() % 100)
+}
+
+func simulatedGameName(seed int64, i int) string {
+	first := simulatedWords[0][simulatedRoll(seed, "first"+strconv.Itoa(i))%len(simulatedWords[0])]
+	second := simulatedWords[1][simulatedRoll(seed, "second"+strconv.Itoa(i))%len(simulatedWords[1])]
+	return fmt.Sprintf("%v %v %v", first, second, i)
+}
+
+// Categories of the overlays next to the executable, so the simulation
+// shows them, with "favorite" as Steam's own.
+func simulatedCategories() []string {
+	categories := []string{"favorite"}
+	paths, _ := filepath.Glob(filepath.Join(filepath.Dir(os.Args[0]), "overlays by category", "*.*"))
+	for _, overlayPath := range paths {
+		_, name := overlay.OverlayLayer(strings.SplitN(filepath.Base(overlayPath), ".", 2)[0])
+		if !artwork.ContainsTag(categories, name) {
+			categories = append(categories, name)
+		}
+	}
+	return categories
+}
+
+// Generates the userdata of a Steam installation with a user owning games
+// (served by the simulated profile), shortcuts and categories.
+func generateSimulatedSteam(dir string, nGames int, nShortcuts int, seed int64) error {
+	userDir := filepath.Join(dir, "userdata", simulatedUserID)
+	for _, subDir := range []string{filepath.Join(userDir, "config", "grid"), filepath.Join(userDir, "7", "remote")} {
+		err := os.MkdirAll(subDir, 0777)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := ioutil.WriteFile(filepath.Join(userDir, "config", "localconfig.vdf"), []byte("\"UserLocalConfigStore\"\n{\n\t\"friends\"\n\t{\n\t\t\"PersonaName\"\t\t\"Simulated user\"\n\t}\n}\n"), 0666)
+	if err != nil {
+		return err
+	}
+
+	categories := simulatedCategories()
+	apps := new(bytes.Buffer)
+	for i := 1; i <= nGames; i++ {
+		if roll := simulatedRoll(seed, "category"+strconv.Itoa(i)); roll < 50 {
+			fmt.Fprintf(apps, "\t\t\t\t\t\"%v\"\n\t\t\t\t\t{\n\t\t\t\t\t\t\"tags\"\n\t\t\t\t\t\t{\n\t\t\t\t\t\t\t\"0\"\t\t\"%v\"\n\t\t\t\t\t\t}\n\t\t\t\t\t}\n", simulatedAppID(i), categories[roll%len(categories)])
+		}
+	}
+	sharedConfig := "\"UserRoamingConfigStore\"\n{\n\t\"Software\"\n\t{\n\t\t\"Valve\"\n\t\t{\n\t\t\t\"Steam\"\n\t\t\t{\n\t\t\t\t\"apps\"\n\t\t\t\t{\n" + apps.String() + "\t\t\t\t}\n\t\t\t}\n\t\t}\n\t}\n}\n"
+	err = ioutil.WriteFile(filepath.Join(userDir, "7", "remote", "sharedconfig.vdf"), []byte(sharedConfig), 0666)
+	if err != nil {
+		return err
+	}
+
+	shortcuts := &steam.VDFNode{Key: "shortcuts", Type: steam.VDFMap}
+	for i := 1; i <= nShortcuts; i++ {
+		name := simulatedGameName(seed, nGames+i)
+		exe := `"/opt/simulated/` + strings.ToLower(strings.Replace(name, " ", "-", -1)) + `"`
+		appID := uint64(crc32.ChecksumIEEE([]byte(exe+name))) | 0x80000000
+		shortcut := &steam.VDFNode{Key: strconv.Itoa(i - 1), Type: steam.VDFMap, Children: []*steam.VDFNode{
+			{Key: "appid", Type: steam.VDFInt32, Int: appID},
+			{Key: "AppName", Type: steam.VDFString, String: name},
+			{Key: "Exe", Type: steam.VDFString, String: exe},
+			{Key: "StartDir", Type: steam.VDFString, String: `"/opt/simulated/"`},
+			{Key: "icon", Type: steam.VDFString},
+			{Key: "LaunchOptions", Type: steam.VDFString},
+			{Key: "IsHidden", Type: steam.VDFInt32},
+			{Key: "tags", Type: steam.VDFMap},
+		}}
+		shortcuts.Children = append(shortcuts.Children, shortcut)
+	}
+	root := &steam.VDFNode{Type: steam.VDFMap, Children: []*steam.VDFNode{shortcuts}}
+	return ioutil.WriteFile(filepath.Join(userDir, "config", "shortcuts.vdf"), steam.WriteBinaryVDF(root), 0666)
+}
+
+// Simulated Steam games have made up IDs, far from real ones.
+func simulatedAppID(i int) string {
+	return strconv.Itoa(9000000 + i)
+}
+
+// Stands in for every server SteamGrid talks to during a simulation:
+// Steam profiles, servers and store, and the SteamGridDB API and CDN. Artwork
+// exists for most games, decided by the seed, and is generated on the fly.
+// Everything else is not found.
+type simulatedSources struct {
+	seed   int64
+	nGames int
+}
+
+func (sources *simulatedSources) RoundTrip(req *http.Request) (*http.Response, error) {
+	host, reqPath := req.URL.Host, req.URL.Path
+	parts := strings.Split(strings.Trim(reqPath, "/"), "/")
+
+	switch {
+	case host == "steamcommunity.com":
+		profile := new(bytes.Buffer)
+		for i := 1; i <= sources.nGames; i++ {
+			fmt.Fprintf(profile, `{"appid": %v, "name": "%v"},`, simulatedAppID(i), simulatedGameName(sources.seed, i))
+		}
+		return simulatedResponse(req, "text/html", profile.Bytes()), nil
+
+	case strings.Contains(reqPath, "/steam/apps/") && len(parts) == 4:
+		// Official artwork: /steam/apps/<appID>/<asset>
+		if simulatedRoll(sources.seed, parts[2]+parts[3]) >= 70 {
+			break
+		}
+		sizes := map[string][2]int{"header.jpg": {460, 215}, "library_600x900_2x.jpg": {600, 900}, "library_hero.jpg": {1920, 620}, "logo.png": {640, 360}}
+		if size, ok := sizes[parts[3]]; ok {
+			return simulatedImage(req, size[0], size[1], parts[2]+parts[3], parts[3] == "logo.png"), nil
+		}
+
+	case host == "store.steampowered.com" && reqPath == "/api/appdetails":
+		return sources.storeAppDetails(req, req.URL.Query().Get("appids")), nil
+
+	case host == "www.steamgriddb.com" && len(parts) >= 4 && parts[0] == "api":
+		return sources.steamGridDB(req, parts[2:]), nil
+
+	case host == "cdn2.steamgriddb.com" && len(parts) == 4:
+		// Images linked from the API: /simulated/<kind>/<width>x<height>/<id>.png
+		var width, height int
+		fmt.Sscanf(parts[2], "%dx%d", &width, &height)
+		if width > 0 && height > 0 {
+			return simulatedImage(req, width, height, parts[3], parts[1] == "logos"), nil
+		}
+	}
+	return simulatedResponse(req, "text/plain", nil), nil
+}
+
+// Answers the SteamGridDB API: /search/autocomplete/<name>, and
+// /<kind>/steam/<appID> or /<kind>/game/<id>.
+func (sources *simulatedSources) steamGridDB(req *http.Request, parts []string) *http.Response {
+	if parts[0] == "search" && len(parts) == 3 {
+		name, _ := url.PathUnescape(parts[2])
+		hash := fnv.New32a()
+		hash.Write([]byte(name))
+		result := map[string]interface{}{"success": true, "data": []map[string]interface{}{
+			{"id": 5000000 + hash.Sum32()%1000000, "name": name, "types": []string{"steam"}, "verified": true},
+		}}
+		body, _ := json.Marshal(result)
+		return simulatedResponse(req, "application/json", body)
+	}
+	if len(parts) != 3 || simulatedRoll(sources.seed, "sgdb"+strings.Join(parts, "/")) >= 80 {
+		return simulatedResponse(req, "text/plain", nil)
+	}
+
+	kind := parts[0]
+	width, height := map[string]int{"heroes": 1920, "logos": 512, "icons": 256}[kind], map[string]int{"heroes": 620, "logos": 256, "icons": 256}[kind]
+	if kind == "grids" {
+		width, height = 600, 900
+		// The dimensions asked for tell covers from banners.
+		if dimensions := req.URL.Query().Get("dimensions"); dimensions != "" {
+			fmt.Sscanf(strings.Split(dimensions, ",")[0], "%dx%d", &width, &height)
+		}
+	}
+	id := 1000000 + simulatedRoll(sources.seed, strings.Join(parts, "/"))*1000 + len(parts[2])
+	imageURL := fmt.Sprintf("https://cdn2.steamgriddb.com/simulated/%v/%vx%v/%v.png", kind, width, height, id)
+	result := map[string]interface{}{"success": true, "data": []map[string]interface{}{
+		{"id": id, "score": 10, "width": width, "height": height, "style": "alternate", "mime": "image/png", "url": imageURL, "thumb": imageURL},
+	}}
+	body, _ := json.Marshal(result)
+	return simulatedResponse(req, "application/json", body)
+}
+
+// Answers the store API for a simulated game. Most are games, the rest DLC
+// and soundtracks, decided by the seed.
+func (sources *simulatedSources) storeAppDetails(req *http.Request, appID string) *http.Response {
+	i, err := strconv.Atoi(appID)
+	if err != nil || i <= 9000000 || i > 9000000+sources.nGames {
+		return simulatedResponse(req, "text/plain", nil)
+	}
+	appType := "game"
+	if roll := simulatedRoll(sources.seed, "type"+appID); roll >= 95 {
+		appType = "music"
+	} else if roll >= 85 {
+		appType = "dlc"
+	}
+	genres := []map[string]string{{"description": "Action"}}
+	if simulatedRoll(sources.seed, "genre"+appID) < 20 {
+		genres = append(genres, map[string]string{"description": "Early Access"})
+	}
+	result := map[string]interface{}{appID: map[string]interface{}{"success": true, "data": map[string]interface{}{
+		"type":         appType,
+		"name":         simulatedGameName(sources.seed, i-9000000),
+		"genres":       genres,
+		"categories":   []map[string]string{{"description": "Single-player"}},
+		"release_date": map[string]string{"date": fmt.Sprintf("1 Jan, %v", 1990+simulatedRoll(sources.seed, "year"+appID)%35)},
+	}}}
+	body, _ := json.Marshal(result)
+	return simulatedResponse(req, "application/json", body)
+}
+
+// A response with a body, or a 404 without one.
+func simulatedResponse(req *http.Request, contentType string, body []byte) *http.Response {
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+	if body == nil {
+		response.StatusCode, response.Status = http.StatusNotFound, "404 Not Found"
+	}
+	return response
+}
+
+// Draws an image of a color picked from the key, with a band across it.
+// Logos are transparent around the band.
+func simulatedImage(req *http.Request, width int, height int, key string, transparent bool) *http.Response {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	sum := hash.Sum32()
+	fill := color.RGBA{uint8(sum), uint8(sum >> 8), uint8(sum >> 16), 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if !transparent {
+		draw.Draw(img, img.Bounds(), &image.Uniform{fill}, image.ZP, draw.Src)
+		fill = color.RGBA{fill.R / 2, fill.G / 2, fill.B / 2, 255}
+	}
+	draw.Draw(img, image.Rect(0, height/3, width, height*2/3), &image.Uniform{fill}, image.ZP, draw.Src)
+
+	buf := new(bytes.Buffer)
+	if path.Ext(req.URL.Path) == ".jpg" {
+		jpeg.Encode(buf, img, &jpeg.Options{Quality: 90})
+		return simulatedResponse(req, "image/jpeg", buf.Bytes())
+	}
+	png.Encode(buf, img)
+	return simulatedResponse(req, "image/png", buf.Bytes())
+}
+
+// Runs the whole pipeline on a generated Steam installation, with every
+// server simulated, to try options, overlays and themes safely and the same
+// way every time.
+func simulateCommand(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	options := &artwork.Options{}
+	registerRunFlags(flags, options)
+	dir := flags.String("dir", "", "Directory to generate the Steam installation in, a new temporary one by default. Running again on it keeps its artwork")
+	nGames := flags.Int("games", 20, "Number of Steam games of the simulated user")
+	nShortcuts := flags.Int("shortcuts", 5, "Number of non-Steam shortcuts of the simulated user")
+	seed := flags.Int64("seed", 1, "Decides the names, categories and which artwork exists, change it for another library")
+	parseOptions(flags, options, args)
+
+	if *dir == "" {
+		var err error
+		*dir, err = ioutil.TempDir("", "steamgrid-simulation")
+		if err != nil {
+			errorAndExit(err)
+		}
+	}
+	err := generateSimulatedSteam(*dir, *nGames, *nShortcuts, *seed)
+	if err != nil {
+		errorAndExit(err)
+	}
+
+	// Nothing leaves the computer, and nothing simulated ends up in the
+	// real cache.
+	http.DefaultClient.Transport = &simulatedSources{seed: *seed, nGames: *nGames}
+	sources.CacheDirOverride = filepath.Join(*dir, "cache")
+	sources.APICacheTTL = 0
+	sources.OfflineMode = false
+	options.SteamDir = *dir
+	if options.SteamGridDBApiKey == "" {
+		options.SteamGridDBApiKey = "simulated"
+	}
+	options.IGDBClient, options.IGDBSecret = "", ""
+
+	runPipeline(options, true, true)
+	fmt.Printf("\nSimulated Steam installation in %v, the artwork is in %v\n", *dir, filepath.Join(*dir, "userdata", simulatedUserID, "config", "grid"))
+}
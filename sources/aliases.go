@@ -0,0 +1,107 @@
+package sources
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Names that searches get wrong, mostly abbreviations used for shortcuts,
+// with the game they stand for. Consulted after the name overrides file,
+// which can add to or replace them with the same format. Keys are compared
+// as cleaned up by aliasKey.
+var builtinAliases = map[string]NameOverride{
+	"csgo":                    {Name: "Counter-Strike: Global Offensive"},
+	"cs go":                   {Name: "Counter-Strike: Global Offensive"},
+	"cs2":                     {Name: "Counter-Strike 2"},
+	"gta v":                   {Name: "Grand Theft Auto V"},
+	"gta 5":                   {Name: "Grand Theft Auto V"},
+	"gta iv":                  {Name: "Grand Theft Auto IV"},
+	"gta san andreas":         {Name: "Grand Theft Auto: San Andreas"},
+	"gta vice city":           {Name: "Grand Theft Auto: Vice City"},
+	"rdr2":                    {Name: "Red Dead Redemption 2"},
+	"rdr":                     {Name: "Red Dead Redemption"},
+	"ff7r intergrade":         {Name: "Final Fantasy VII Remake"},
+	"ffvii remake intergrade": {Name: "Final Fantasy VII Remake"},
+	"ffxiv":                   {Name: "Final Fantasy XIV Online"},
+	"ff14":                    {Name: "Final Fantasy XIV Online"},
+	"botw":                    {Name: "The Legend of Zelda: Breath of the Wild"},
+	"totk":                    {Name: "The Legend of Zelda: Tears of the Kingdom"},
+	"oot":                     {Name: "The Legend of Zelda: Ocarina of Time"},
+	"alttp":                   {Name: "The Legend of Zelda: A Link to the Past"},
+	"smb":                     {Name: "Super Mario Bros."},
+	"smb3":                    {Name: "Super Mario Bros. 3"},
+	"sm64":                    {Name: "Super Mario 64"},
+	"smw":                     {Name: "Super Mario World"},
+	"mk8 deluxe":              {Name: "Mario Kart 8 Deluxe"},
+	"mk8dx":                   {Name: "Mario Kart 8 Deluxe"},
+	"ssbm":                    {Name: "Super Smash Bros. Melee"},
+	"ssbu":                    {Name: "Super Smash Bros. Ultimate"},
+	"sotn":                    {Name: "Castlevania: Symphony of the Night"},
+	"mgs":                     {Name: "Metal Gear Solid"},
+	"mgs2":                    {Name: "Metal Gear Solid 2: Sons of Liberty"},
+	"mgs3":                    {Name: "Metal Gear Solid 3: Snake Eater"},
+	"ffx":                     {Name: "Final Fantasy X"},
+	"ff7":                     {Name: "Final Fantasy VII"},
+	"ffvii":                   {Name: "Final Fantasy VII"},
+	"ff6":                     {Name: "Final Fantasy VI"},
+	"kh2":                     {Name: "Kingdom Hearts II"},
+	"wow":                     {Name: "World of Warcraft"},
+	"lol":                     {Name: "League of Legends"},
+	"ow2":                     {Name: "Overwatch 2"},
+	"mario kart double dash":  {Name: "Mario Kart: Double Dash!!"},
+	"metroid prime trilogy":   {Name: "Metroid Prime: Trilogy"},
+}
+
+var (
+	// Region, revision and dump tags of ROM names, like (USA), (Rev 1) or [!].
+	romTagsPattern = regexp.MustCompile(`\s*(\([^)]*\)|\[[^\]]*\])`)
+	// ROM sets move leading articles to the end of the title:
+	// "Legend of Zelda, The - A Link to the Past".
+	romArticlePattern = regexp.MustCompile(`^(.+?), (The|A|An)( - .*)?$`)
+	// Anything but letters and numbers.
+	aliasSeparatorPattern = regexp.MustCompile(`[^\pL\pN]+`)
+)
+
+// CleanGameName turns a ROM or shortcut name into the name of the game, as
+// searches expect it: "Legend of Zelda, The - A Link to the Past (USA) [!]"
+// becomes "The Legend of Zelda: A Link to the Past".
+func CleanGameName(name string) string {
+	cleaned := strings.TrimSpace(romTagsPattern.ReplaceAllString(name, ""))
+	if groups := romArticlePattern.FindStringSubmatch(cleaned); groups != nil {
+		cleaned = groups[2] + " " + groups[1] + groups[3]
+	}
+	return strings.Replace(cleaned, " - ", ": ", 1)
+}
+
+// Key of a name in builtinAliases: cleaned up, in lower case, with words
+// separated by single spaces.
+func aliasKey(name string) string {
+	key := strings.ToLower(CleanGameName(name))
+	key = strings.Replace(key, "é", "e", -1)
+	return strings.TrimSpace(aliasSeparatorPattern.ReplaceAllString(key, " "))
+}
+
+// LookupAlias returns the alias of a game name in the built-in database, if
+// any.
+func LookupAlias(name string) (NameOverride, bool) {
+	alias, ok := builtinAliases[aliasKey(name)]
+	return alias, ok
+}
+
+// NameOverride is how to search for a game whose name finds the wrong one:
+// another name to search for, or the game on SteamGridDB or IGDB. A plain
+// string in the file is a name.
+type NameOverride struct {
+	Name              string `json:"name,omitempty"`
+	SteamGridDBGameID int    `json:"steamGridDBGameId,omitempty"`
+	IGDBGameID        int    `json:"igdbGameId,omitempty"`
+}
+
+func (override *NameOverride) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &override.Name)
+	}
+	type plain NameOverride
+	return json.Unmarshal(data, (*plain)(override))
+}
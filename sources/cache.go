@@ -0,0 +1,110 @@
+package sources
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How long API responses are reused before asking again, set by -cachettl.
+// Zero turns the cache off.
+var APICacheTTL = 24 * time.Hour
+
+// An API response kept on disk.
+type cachedResponse struct {
+	Status int    `json:"status"`
+	Body   []byte `json:"body,omitempty"`
+}
+
+// Responses are stored by the hash of their key: the method, URL (which
+// includes the SteamGridDB filters) and body of the request.
+func apiCachePath(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(CacheDir(), "api", hex.EncodeToString(hash[:])+".json")
+}
+
+// Returns the response stored for a key, if it's younger than the TTL.
+func loadCachedResponse(key string) (*cachedResponse, bool) {
+	if APICacheTTL <= 0 {
+		return nil, false
+	}
+	path := apiCachePath(key)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > APICacheTTL {
+		return nil, false
+	}
+	cachedBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var response cachedResponse
+	if json.Unmarshal(cachedBytes, &response) != nil {
+		return nil, false
+	}
+	return &response, true
+}
+
+// Stores a response for the next runs. Failing to store it only means
+// asking again.
+func storeCachedResponse(key string, status int, body []byte) {
+	if APICacheTTL <= 0 {
+		return
+	}
+	cachedBytes, err := json.Marshal(cachedResponse{Status: status, Body: body})
+	if err != nil {
+		return
+	}
+	path := apiCachePath(key)
+	if os.MkdirAll(filepath.Dir(path), 0777) == nil {
+		ioutil.WriteFile(path, cachedBytes, 0666)
+	}
+}
+
+// CachedGet gets a URL through the cache, where both the answer and its
+// absence are kept. Returns the status, 200 or 404, and the body.
+func CachedGet(url string) (int, []byte, error) {
+	if cached, ok := loadCachedResponse("GET " + url); ok {
+		return cached.Status, cached.Body, nil
+	}
+	response, err := HTTPGet(url)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		storeCachedResponse("GET "+url, http.StatusNotFound, nil)
+		return http.StatusNotFound, nil, nil
+	} else if response.StatusCode != http.StatusOK {
+		return 0, nil, errors.New("answered " + response.Status)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	storeCachedResponse("GET "+url, http.StatusOK, body)
+	return http.StatusOK, body, nil
+}
+
+// Set by -cachedir.
+var CacheDirOverride string
+
+// CacheDir returns the directory for files SteamGrid keeps between runs that
+// can be lost without harm: -cachedir, or the user cache directory. Containers
+// often have no home directory and a read-only executable directory, so
+// without either the temporary directory is used.
+func CacheDir() string {
+	if CacheDirOverride != "" {
+		return CacheDirOverride
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "steamgrid")
+}
@@ -0,0 +1,111 @@
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GOG's catalog search, the one its website embeds, and the game data the
+// GOG Galaxy client shows.
+const (
+	gogSearchURL = "https://embed.gog.com/games/ajax/filtered?mediaType=game&search=%v"
+	gogGameURL   = "https://api.gog.com/v2/games/%v"
+)
+
+// Returns the GOG ID of a shortcut: the one it launches through GOG Galaxy
+// or Heroic, or else the closest title of a catalog search, if close enough.
+// Returns "" when GOG doesn't have the game.
+func gogGameID(game *Game, minMatch float64) (string, error) {
+	if game.Platform == "gog" {
+		return game.PlatformID, nil
+	}
+	status, body, err := CachedGet(fmt.Sprintf(gogSearchURL, url.QueryEscape(game.SearchTerm())))
+	if err != nil {
+		return "", errors.New("GOG " + err.Error())
+	} else if status != http.StatusOK {
+		return "", nil
+	}
+	var response struct {
+		Products []struct {
+			ID    int    `json:"id"`
+			Title string `json:"title"`
+		} `json:"products"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", err
+	}
+	bestID, bestSimilarity := "", minMatch
+	for _, product := range response.Products {
+		if similarity := nameSimilarity(product.Title, game.SearchTerm()); similarity >= bestSimilarity {
+			bestID, bestSimilarity = strconv.Itoa(product.ID), similarity
+		}
+	}
+	return bestID, nil
+}
+
+// Returns the URL of the vertical cover or background GOG has for a
+// non-Steam game, or "" when it has none. GOG has no art for Steam games
+// beyond what Steam has.
+func getGOGImage(game *Game, artStyle string, minMatch float64) (string, error) {
+	if !game.Custom {
+		return "", nil
+	}
+	id, err := gogGameID(game, minMatch)
+	if id == "" || err != nil {
+		return "", err
+	}
+	status, body, err := CachedGet(fmt.Sprintf(gogGameURL, id))
+	if err != nil {
+		return "", errors.New("GOG " + err.Error())
+	} else if status != http.StatusOK {
+		return "", nil
+	}
+
+	type link struct {
+		Href string `json:"href"`
+	}
+	var response struct {
+		Links struct {
+			BoxArtImage           link `json:"boxArtImage"`
+			BackgroundImage       link `json:"backgroundImage"`
+			GalaxyBackgroundImage link `json:"galaxyBackgroundImage"`
+		} `json:"_links"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", err
+	}
+	links := response.Links
+	imageURL := ""
+	switch artStyle {
+	case "Cover":
+		imageURL = links.BoxArtImage.Href
+	case "Hero":
+		imageURL = links.GalaxyBackgroundImage.Href
+		if imageURL == "" {
+			imageURL = links.BackgroundImage.Href
+		}
+	}
+	// Some links leave the scheme out.
+	if strings.HasPrefix(imageURL, "//") {
+		imageURL = "https:" + imageURL
+	}
+	return imageURL, nil
+}
+
+// GOG is GOG, with covers and backgrounds of non-Steam games.
+type GOG struct {
+	// Games found by name less similar than this, from 0 to 1, count as not
+	// found
+	MinMatch float64
+}
+
+func (source GOG) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	return downloadFound(getGOGImage(game, artStyle, source.MinMatch))
+}
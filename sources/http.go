@@ -0,0 +1,270 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Print the per-host request statistics at the end, set by -stats.
+var ShowHTTPStats bool
+
+// How the requests to one host went.
+type hostStats struct {
+	requests  int
+	errors    int
+	failures  int
+	latencies []time.Duration
+}
+
+// Requests made to each host. Safe to update from several goroutines.
+var httpStats = struct {
+	sync.Mutex
+	hosts map[string]*hostStats
+}{hosts: make(map[string]*hostStats)}
+
+// Records one request. Errors are requests that got no response at all,
+// failures are responses with a 5xx status or 429 Too Many Requests. Plain
+// 404s are expected for missing artwork, so they don't count as failures.
+func recordRequest(host string, latency time.Duration, response *http.Response, err error) {
+	httpStats.Lock()
+	defer httpStats.Unlock()
+
+	stats, ok := httpStats.hosts[host]
+	if !ok {
+		stats = &hostStats{}
+		httpStats.hosts[host] = stats
+	}
+	stats.requests++
+	stats.latencies = append(stats.latencies, latency)
+	if err != nil {
+		stats.errors++
+	} else if response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests {
+		stats.failures++
+	}
+}
+
+// How many times to try again after a transient failure, and how long to
+// wait before the first retry. Set by -retries and -retrydelay.
+var (
+	HTTPRetries    = 3
+	HTTPRetryDelay = time.Second
+)
+
+// Longest wait between two retries, however many there are.
+const maxRetryDelay = 5 * time.Minute
+
+// Tells if a request that got no response may work when tried again:
+// timeouts and connections closed by the other end. Unknown hosts and bad
+// URLs won't get better.
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Waits before retry number attempt (from 0), doubling every time up to
+// maxRetryDelay, with up to 50% jitter so parallel requests don't come back
+// all at once.
+func retryBackoff(attempt int) time.Duration {
+	delay := HTTPRetryDelay
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	} else if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)+1))
+}
+
+// DoRequest sends a request, recording how long the host took to answer, and
+// retries with exponential backoff on 5xx responses and transient errors.
+// Every source goes through here.
+func DoRequest(req *http.Request) (*http.Response, error) {
+	if OfflineMode {
+		return nil, errOffline
+	}
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		response, err := http.DefaultClient.Do(req)
+		recordRequest(req.URL.Host, time.Since(start), response, err)
+
+		retry := (err != nil && isTransientError(err)) || (err == nil && response.StatusCode >= 500)
+		if !retry || attempt >= HTTPRetries || (req.Body != nil && req.GetBody == nil) {
+			return response, err
+		}
+
+		if err == nil {
+			response.Body.Close()
+		}
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		time.Sleep(retryBackoff(attempt))
+	}
+}
+
+// HTTPGet is like http.Get, but recorded.
+func HTTPGet(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return DoRequest(req)
+}
+
+// Spaces out requests to a server so they stay under a number per second.
+// Safe to use from several goroutines.
+type rateLimiter struct {
+	sync.Mutex
+	PerSecond float64
+	next      time.Time
+}
+
+// Blocks until the next request is allowed. A limit of zero or less means no
+// limit.
+func (limiter *rateLimiter) wait() {
+	if limiter.PerSecond <= 0 {
+		return
+	}
+	limiter.Lock()
+	now := time.Now()
+	if limiter.next.Before(now) {
+		limiter.next = now
+	}
+	delay := limiter.next.Sub(now)
+	limiter.next = limiter.next.Add(time.Duration(float64(time.Second) / limiter.PerSecond))
+	limiter.Unlock()
+	time.Sleep(delay)
+}
+
+// Reads how long a server asked us to wait before trying again, from the
+// Retry-After header (seconds or a date) or the X-RateLimit-Reset header (a
+// Unix time). Returns false when there's no such header.
+func retryAfter(response *http.Response) (time.Duration, bool) {
+	if value := response.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date), true
+		}
+	}
+	if value := response.Header.Get("X-RateLimit-Reset"); value != "" {
+		if reset, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0)), true
+		}
+	}
+	return 0, false
+}
+
+// Returns the latency below which the given fraction of the requests were.
+func percentile(sorted []time.Duration, fraction float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(fraction*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// Prints the requests, error rates and latency percentiles of every host,
+// busiest first.
+func printHTTPStats() {
+	httpStats.Lock()
+	defer httpStats.Unlock()
+
+	var hosts []string
+	for host := range httpStats.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return httpStats.hosts[hosts[i]].requests > httpStats.hosts[hosts[j]].requests
+	})
+
+	fmt.Printf("\nRequests by host:\n")
+	fmt.Printf("%-36v %8v %8v %8v %8v %8v %8v\n", "host", "requests", "errors", "failed", "p50", "p90", "p99")
+	for _, host := range hosts {
+		stats := httpStats.hosts[host]
+		sorted := append([]time.Duration(nil), stats.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		fmt.Printf("%-36v %8v %7.1f%% %7.1f%% %8v %8v %8v\n", host, stats.requests,
+			100*float64(stats.errors)/float64(stats.requests),
+			100*float64(stats.failures)/float64(stats.requests),
+			percentile(sorted, 0.5).Round(time.Millisecond),
+			percentile(sorted, 0.9).Round(time.Millisecond),
+			percentile(sorted, 0.99).Round(time.Millisecond))
+	}
+	fmt.Println()
+}
+
+var printHTTPStatsOnce sync.Once
+
+// FinishHTTPStats prints the statistics if they were asked for, only the first
+// time it's called.
+func FinishHTTPStats() {
+	if ShowHTTPStats {
+		printHTTPStatsOnce.Do(printHTTPStats)
+	}
+}
+
+// TryDownload tries to fetch a URL, returning the response only if it was
+// positive.
+func TryDownload(url string) (*http.Response, error) {
+	// Images found are not cached, but knowing which ones are missing saves
+	// most of the requests to Steam when running again.
+	cacheKey := "GET " + url
+	if cached, ok := loadCachedResponse(cacheKey); ok && cached.Status == 404 {
+		return nil, nil
+	}
+
+	response, err := HTTPGet(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == 404 {
+		// Some apps don't have an image and there's nothing we can do.
+		response.Body.Close()
+		storeCachedResponse(cacheKey, 404, nil)
+		return nil, nil
+	} else if response.StatusCode >= 400 {
+		// Other errors should be reported, though.
+		return nil, errors.New("Failed to download image " + url + ": " + response.Status)
+	}
+
+	return response, nil
+}
+
+// Set by -offline: nothing is downloaded, artwork only comes from the
+// backups, the games/ directory, packs already downloaded and the mirror.
+var OfflineMode bool
+
+var errOffline = errors.New("not downloading in offline mode")
+
+// Escapes a game name to be used as one segment of an URL path, so slashes,
+// question marks and non-ASCII characters reach the server as part of the
+// name.
+func escapePathSegment(name string) string {
+	return url.PathEscape(name)
+}
@@ -0,0 +1,25 @@
+package sources
+
+import "testing"
+
+func TestEscapePathSegment(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Portal", "Portal"},
+		{"Half-Life 2", "Half-Life%202"},
+		{"AC/DC", "AC%2FDC"},
+		{"What?", "What%3F"},
+		{"100%", "100%25"},
+		{"50# Games", "50%23%20Games"},
+		{"英雄", "%E8%8B%B1%E9%9B%84"},
+		{"🎮", "%F0%9F%8E%AE"},
+		{`C:\Games`, "C:%5CGames"},
+	}
+	for _, test := range tests {
+		if got := escapePathSegment(test.name); got != test.want {
+			t.Errorf("escapePathSegment(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where Twitch hands out the OAuth tokens for the IGDB API.
+const twitchTokenURL = "https://id.twitch.tv/oauth2/token"
+
+// Tokens are renewed this long before they expire, so one doesn't run out in
+// the middle of a request.
+const igdbTokenMargin = time.Minute
+
+// An IGDB access token and when it stops working.
+type igdbToken struct {
+	ClientID    string    `json:"clientId"`
+	AccessToken string    `json:"accessToken"`
+	Expires     time.Time `json:"expires"`
+}
+
+func (token *igdbToken) valid(clientID string) bool {
+	return token != nil && token.ClientID == clientID && token.AccessToken != "" && time.Now().Add(igdbTokenMargin).Before(token.Expires)
+}
+
+// The token in use, shared by every IGDB request of the run and kept on disk
+// for the next runs. Tokens last about two months.
+var igdbTokens struct {
+	sync.Mutex
+	current *igdbToken
+}
+
+func igdbTokenPath() string {
+	return filepath.Join(CacheDir(), "igdb-token.json")
+}
+
+// GetIGDBToken returns a valid access token for the client: the one already in
+// use, the one saved by a previous run, or a new one from Twitch.
+func GetIGDBToken(IGDBSecret string, IGDBClient string) (string, error) {
+	igdbTokens.Lock()
+	defer igdbTokens.Unlock()
+
+	if igdbTokens.current.valid(IGDBClient) {
+		return igdbTokens.current.AccessToken, nil
+	}
+
+	saved := &igdbToken{}
+	tokenBytes, err := ioutil.ReadFile(igdbTokenPath())
+	if err == nil && json.Unmarshal(tokenBytes, saved) == nil && saved.valid(IGDBClient) {
+		igdbTokens.current = saved
+		return saved.AccessToken, nil
+	}
+
+	token, err := requestIGDBToken(IGDBSecret, IGDBClient)
+	if err != nil {
+		return "", err
+	}
+	igdbTokens.current = token
+
+	// Failing to save only means asking for a new token next run.
+	tokenBytes, err = json.Marshal(token)
+	if err == nil && os.MkdirAll(CacheDir(), 0700) == nil {
+		ioutil.WriteFile(igdbTokenPath(), tokenBytes, 0600)
+	}
+	return token.AccessToken, nil
+}
+
+// Forgets a token IGDB refused, so the next request gets a new one.
+func invalidateIGDBToken(accessToken string) {
+	igdbTokens.Lock()
+	defer igdbTokens.Unlock()
+	if igdbTokens.current != nil && igdbTokens.current.AccessToken == accessToken {
+		igdbTokens.current = nil
+		os.Remove(igdbTokenPath())
+	}
+}
+
+// Asks Twitch for a new token with the client credentials.
+func requestIGDBToken(IGDBSecret string, IGDBClient string) (*igdbToken, error) {
+	query := url.Values{"client_id": {IGDBClient}, "client_secret": {IGDBSecret}, "grant_type": {"client_credentials"}}
+	req, err := http.NewRequest("POST", twitchTokenURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := DoRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("IGDB client ID or secret refused: " + response.Status)
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&tokenResponse)
+	if err != nil {
+		return nil, err
+	}
+	return &igdbToken{
+		ClientID:    IGDBClient,
+		AccessToken: tokenResponse.AccessToken,
+		Expires:     time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second),
+	}, nil
+}
+
+const igdbImageURL = "https://images.igdb.com/igdb/image/upload/t_720p/%v.jpg"
+
+const igdbGameURL = "https://api.igdb.com/v4/games"
+
+const igdbCoverURL = "https://api.igdb.com/v4/covers"
+
+const igdbGameBody = `fields name,cover; search "%v";`
+
+const igdbGameByIDBody = `fields name,cover; where id = %v;`
+
+const igdbCoverBody = `fields image_id; where id = %v;`
+
+type igdbGame struct {
+	ID    int
+	Cover int
+	Name  string
+}
+
+type igdbCover struct {
+	ID       int
+	Image_ID string
+}
+
+func igdbPostRequest(url string, body string, IGDBSecret string, IGDBClient string) ([]byte, error) {
+	cacheKey := "POST " + url + "\n" + body
+	if cached, ok := loadCachedResponse(cacheKey); ok {
+		return cached.Body, nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		accessToken, err := GetIGDBToken(IGDBSecret, IGDBClient)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest("POST", url, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("Client-ID", IGDBClient)
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+		req.Header.Add("Accept", "application/json")
+
+		response, err := DoRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			// The token was revoked or expired early, get a new one.
+			response.Body.Close()
+			invalidateIGDBToken(accessToken)
+			continue
+		}
+
+		responseBytes, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode == http.StatusOK {
+			storeCachedResponse(cacheKey, response.StatusCode, responseBytes)
+		}
+		return responseBytes, nil
+	}
+}
+
+func getIGDBImage(gameName string, gameID int, IGDBSecret string, IGDBClient string) (string, error) {
+	body := fmt.Sprintf(igdbGameBody, escapeIGDBString(gameName))
+	if gameID != 0 {
+		body = fmt.Sprintf(igdbGameByIDBody, gameID)
+	}
+	responseBytes, err := igdbPostRequest(igdbGameURL, body, IGDBSecret, IGDBClient)
+	if err != nil {
+		return "", err
+	}
+
+	var jsonGameResponse []igdbGame
+	err = json.Unmarshal(responseBytes, &jsonGameResponse)
+	if err != nil {
+		return "", nil
+	}
+
+	if len(jsonGameResponse) < 1 || jsonGameResponse[0].Cover == 0 {
+		return "", nil
+	}
+
+	responseBytes, err = igdbPostRequest(igdbCoverURL, fmt.Sprintf(igdbCoverBody, jsonGameResponse[0].Cover), IGDBSecret, IGDBClient)
+	if err != nil {
+		return "", err
+	}
+
+	var jsonCoverResponse []igdbCover
+	err = json.Unmarshal(responseBytes, &jsonCoverResponse)
+	if err != nil {
+		return "", nil
+	}
+
+	if len(jsonCoverResponse) >= 1 {
+		return fmt.Sprintf(igdbImageURL, jsonCoverResponse[0].Image_ID), nil
+	}
+
+	return "", nil
+}
+
+// IGDB is IGDB, with covers of games, through the API keys of a Twitch
+// application.
+type IGDB struct {
+	Client string
+	Secret string
+	// Applications non-Steam shortcuts launch aren't looked for, as IGDB
+	// only knows games
+	SkipApplications bool
+}
+
+func (source IGDB) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	if source.SkipApplications && game.ApplicationName != "" {
+		return nil, nil
+	}
+	return downloadFound(getIGDBImage(game.SearchTerm(), game.IGDBGameID, source.Secret, source.Client))
+}
+
+// Escapes a game name to be quoted in an IGDB query.
+func escapeIGDBString(name string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name)
+}
@@ -0,0 +1,22 @@
+package sources
+
+import "testing"
+
+func TestEscapeIGDBString(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Portal", "Portal"},
+		{`The "Quoted" sourceGame`, `The \"Quoted\" sourceGame`},
+		{`Back\slash`, `Back\\slash`},
+		{`\"`, `\\\"`},
+		{"AC/DC; fields *", "AC/DC; fields *"},
+		{"英雄联盟 🎮", "英雄联盟 🎮"},
+	}
+	for _, test := range tests {
+		if got := escapeIGDBString(test.name); got != test.want {
+			t.Errorf("escapeIGDBString(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package sources
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The itch app's installer, butler, leaves a receipt of what it installed in
+// every install folder, with the game as the itch.io API describes it.
+const itchReceiptPath = ".itch/receipt.json.gz"
+
+// How far above its executable an install folder is looked for.
+const itchMaxDepth = 4
+
+// A game of itch.io.
+type itchGame struct {
+	ID            int    `json:"id"`
+	Title         string `json:"title"`
+	URL           string `json:"url"`
+	CoverURL      string `json:"coverUrl"`
+	StillCoverURL string `json:"stillCoverUrl"`
+}
+
+// Returns the executable of a shortcut target, which Steam puts in quotes.
+func targetExecutable(target string) string {
+	if strings.HasPrefix(target, `"`) {
+		if end := strings.Index(target[1:], `"`); end >= 0 {
+			return target[1 : end+1]
+		}
+	}
+	return strings.Fields(target + " ")[0]
+}
+
+// Returns the itch.io game installed where a shortcut's executable is, or nil
+// when it wasn't installed by the itch app.
+func itchInstalledGame(target string) *itchGame {
+	exe := targetExecutable(target)
+	if exe == "" {
+		return nil
+	}
+	dir := filepath.Dir(exe)
+	for i := 0; i < itchMaxDepth; i++ {
+		file, err := os.Open(filepath.Join(dir, filepath.FromSlash(itchReceiptPath)))
+		if err == nil {
+			defer file.Close()
+			reader, err := gzip.NewReader(file)
+			if err != nil {
+				return nil
+			}
+			var receipt struct {
+				Game *itchGame `json:"game"`
+			}
+			if json.NewDecoder(reader).Decode(&receipt) != nil {
+				return nil
+			}
+			return receipt.Game
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return nil
+}
+
+// Returns the URL of the itch.io cover of a shortcut of a game installed by
+// the itch app, or "" when it's not one. Receipts of older installs have no
+// cover, which the game's public data on itch.io then gives. Still covers
+// are preferred, as covers may be animated GIFs.
+func getItchImage(game *Game) (string, error) {
+	if !game.Custom {
+		return "", nil
+	}
+	installed := itchInstalledGame(game.Target)
+	if installed == nil {
+		return "", nil
+	}
+	if installed.StillCoverURL != "" {
+		return installed.StillCoverURL, nil
+	} else if installed.CoverURL != "" || installed.URL == "" {
+		return installed.CoverURL, nil
+	}
+
+	status, body, err := CachedGet(strings.TrimSuffix(installed.URL, "/") + "/data.json")
+	if err != nil {
+		return "", errors.New("itch.io " + err.Error())
+	} else if status != http.StatusOK {
+		return "", nil
+	}
+	var data struct {
+		CoverImage string `json:"cover_image"`
+	}
+	err = json.Unmarshal(body, &data)
+	return data.CoverImage, err
+}
+
+// Itch is the itch.io covers of the shortcuts of games installed by the itch
+// app.
+type Itch struct{}
+
+func (source Itch) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	return downloadFound(getItchImage(game))
+}
@@ -0,0 +1,46 @@
+package sources
+
+import "strings"
+
+// Steam's names of languages, by ISO 639-1 code, as used in the names of
+// localized assets: library_600x900_schinese.jpg. English is the default.
+var SteamLanguages = map[string]string{
+	"ar":     "arabic",
+	"bg":     "bulgarian",
+	"cs":     "czech",
+	"da":     "danish",
+	"de":     "german",
+	"el":     "greek",
+	"en":     "",
+	"es":     "spanish",
+	"es-419": "latam",
+	"fi":     "finnish",
+	"fr":     "french",
+	"hu":     "hungarian",
+	"id":     "indonesian",
+	"it":     "italian",
+	"ja":     "japanese",
+	"ko":     "koreana",
+	"nl":     "dutch",
+	"no":     "norwegian",
+	"pl":     "polish",
+	"pt":     "portuguese",
+	"pt-br":  "brazilian",
+	"ro":     "romanian",
+	"ru":     "russian",
+	"sv":     "swedish",
+	"th":     "thai",
+	"tr":     "turkish",
+	"uk":     "ukrainian",
+	"vi":     "vietnamese",
+	"zh":     "schinese",
+	"zh-cn":  "schinese",
+	"zh-tw":  "tchinese",
+}
+
+// Whether the language of a SteamGridDB image, like "ja" or "zh-CN", is the
+// one of -language.
+func matchesLanguage(imageLanguage string, code string) bool {
+	imageLanguage = strings.ToLower(imageLanguage)
+	return imageLanguage == code || strings.SplitN(imageLanguage, "-", 2)[0] == strings.SplitN(code, "-", 2)[0]
+}
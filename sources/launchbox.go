@@ -0,0 +1,200 @@
+package sources
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The LaunchBox Games Database comes as one metadata dump, whose images are
+// served by name from another host.
+const (
+	launchBoxMetadataURL = "https://gamesdb.launchbox-app.com/Metadata.zip"
+	launchBoxImagesURL   = "https://images.launchbox-app.com/"
+)
+
+// The dump is over 100 MB, so it's kept in the cache directory and only
+// downloaded again once this old. Delete it to get a newer one sooner.
+const launchBoxDumpMaxAge = 7 * 24 * time.Hour
+
+// Types of LaunchBox images for each art style.
+var launchBoxImageTypes = map[string]string{
+	"Cover": "Box - Front",
+	"Logo":  "Clear Logo",
+}
+
+// The games of the dump by aliasKey of their name, with their images by
+// type, read once per run.
+var launchBoxDatabase struct {
+	sync.Once
+	err    error
+	ids    map[string][]string
+	images map[string]map[string]string
+}
+
+func launchBoxDumpPath() string {
+	return filepath.Join(CacheDir(), "launchbox", "Metadata.zip")
+}
+
+// Downloads the metadata dump, unless the cached one is recent enough.
+func downloadLaunchBoxDump() (string, error) {
+	dumpPath := launchBoxDumpPath()
+	if info, err := os.Stat(dumpPath); err == nil && (time.Since(info.ModTime()) < launchBoxDumpMaxAge || OfflineMode) {
+		return dumpPath, nil
+	}
+
+	fmt.Println("Downloading the LaunchBox Games Database")
+	response, err := TryDownload(launchBoxMetadataURL)
+	if err != nil {
+		return "", err
+	} else if response == nil {
+		return "", errors.New("LaunchBox Games Database not found")
+	}
+	defer response.Body.Close()
+
+	err = os.MkdirAll(filepath.Dir(dumpPath), 0777)
+	if err != nil {
+		return "", err
+	}
+	// Streamed to disk, and only replacing the old dump once complete.
+	tmpPath := dumpPath + ".download"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, response.Body)
+	file.Close()
+	if err == nil {
+		err = os.Rename(tmpPath, dumpPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return dumpPath, nil
+}
+
+// Reads the games and the images SteamGrid uses out of Metadata.xml,
+// element by element, as the whole file doesn't fit in memory comfortably.
+func loadLaunchBoxDatabase() (map[string][]string, map[string]map[string]string, error) {
+	dumpPath, err := downloadLaunchBoxDump()
+	if err != nil {
+		return nil, nil, err
+	}
+	archive, err := zip.OpenReader(dumpPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer archive.Close()
+	var metadata *zip.File
+	for _, file := range archive.File {
+		if strings.EqualFold(file.Name, "Metadata.xml") {
+			metadata = file
+		}
+	}
+	if metadata == nil {
+		return nil, nil, errors.New("Metadata.xml not found in the LaunchBox Games Database")
+	}
+	reader, err := metadata.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	ids := make(map[string][]string)
+	images := make(map[string]map[string]string)
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "Game":
+			var game struct {
+				Name       string
+				DatabaseID string
+			}
+			if decoder.DecodeElement(&game, &start) == nil && game.Name != "" {
+				key := aliasKey(game.Name)
+				ids[key] = append(ids[key], game.DatabaseID)
+			}
+		case "GameImage":
+			var image struct {
+				DatabaseID string
+				FileName   string
+				Type       string
+				Region     string
+			}
+			if decoder.DecodeElement(&image, &start) != nil {
+				continue
+			}
+			byType, ok := images[image.DatabaseID]
+			if !ok {
+				byType = make(map[string]string)
+				images[image.DatabaseID] = byType
+			}
+			// Images without a region are the ones for every region.
+			if _, ok := byType[image.Type]; !ok || image.Region == "" {
+				byType[image.Type] = image.FileName
+			}
+		}
+	}
+
+	// Only the images of the types used are kept.
+	for id, byType := range images {
+		for imageType := range byType {
+			if imageType != launchBoxImageTypes["Cover"] && imageType != launchBoxImageTypes["Logo"] {
+				delete(byType, imageType)
+			}
+		}
+		if len(byType) == 0 {
+			delete(images, id)
+		}
+	}
+	return ids, images, nil
+}
+
+// Returns the URL of the box art or clear logo of a game in the LaunchBox
+// Games Database, found by name, or "" when it has none.
+func getLaunchBoxImage(game *Game, artStyle string) (string, error) {
+	imageType, ok := launchBoxImageTypes[artStyle]
+	if !ok || game.SearchTerm() == "" {
+		return "", nil
+	}
+	launchBoxDatabase.Do(func() {
+		launchBoxDatabase.ids, launchBoxDatabase.images, launchBoxDatabase.err = loadLaunchBoxDatabase()
+		if launchBoxDatabase.err != nil {
+			fmt.Println("Could not load the LaunchBox Games Database: " + launchBoxDatabase.err.Error())
+		}
+	})
+	// The same game on several platforms has several entries.
+	for _, id := range launchBoxDatabase.ids[aliasKey(game.SearchTerm())] {
+		if fileName, ok := launchBoxDatabase.images[id][imageType]; ok {
+			return launchBoxImagesURL + fileName, nil
+		}
+	}
+	return "", nil
+}
+
+// LaunchBox is the LaunchBox Games Database, with covers and logos of retro
+// games, from its metadata dump.
+type LaunchBox struct{}
+
+func (source LaunchBox) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	return downloadFound(getLaunchBoxImage(game, artStyle))
+}
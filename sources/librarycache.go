@@ -0,0 +1,70 @@
+package sources
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Names of the official artwork Steam keeps in appcache/librarycache, by art
+// style, best first. Older clients prefix them with the appID, newer ones
+// put them in a folder per appID, sometimes in a subfolder of it.
+var libraryCacheNames = map[string][]string{
+	"Banner": {"header.jpg", "library_header.jpg"},
+	"Cover":  {"library_600x900_2x.jpg", "library_600x900.jpg", "library_capsule.jpg"},
+	"Hero":   {"library_hero.jpg"},
+	"Logo":   {"logo.png"},
+}
+
+// Path returns the path of the official artwork of a Steam game in Steam's
+// library cache, or "" when Steam hasn't cached it.
+func (source LibraryCache) Path(game *Game, artStyle string) string {
+	if game.Custom || source.InstallationDir == "" {
+		return ""
+	}
+	dir := filepath.Join(source.InstallationDir, "appcache", "librarycache")
+	for _, name := range libraryCacheNames[artStyle] {
+		candidates := []string{filepath.Join(dir, game.ID+"_"+name), filepath.Join(dir, game.ID, name)}
+		nested, _ := filepath.Glob(filepath.Join(dir, game.ID, "*", name))
+		for _, path := range append(candidates, nested...) {
+			if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// Opens an image of the library cache as if it had been downloaded.
+func openLibraryCacheImage(path string) (*http.Response, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {mime.TypeByExtension(filepath.Ext(path))}},
+		Body:       file,
+		Request:    &http.Request{URL: &url.URL{Scheme: "file", Path: filepath.ToSlash(path)}},
+	}, nil
+}
+
+// LibraryCache is the official artwork Steam already downloaded, in its
+// library cache.
+type LibraryCache struct {
+	InstallationDir string
+}
+
+func (source LibraryCache) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	path := source.Path(game, artStyle)
+	if path == "" {
+		return nil, nil
+	}
+	response, err := openLibraryCacheImage(path)
+	if err != nil {
+		return nil, nil
+	}
+	return response, nil
+}
@@ -0,0 +1,66 @@
+package sources
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// How shortcuts of other launchers start their games, with the platform
+// SteamGridDB knows the ID as.
+var platformLaunchPatterns = []struct {
+	platform string
+	pattern  *regexp.Regexp
+}{
+	// GalaxyClient.exe /command=runGame /gameId=1207658924
+	{"gog", regexp.MustCompile(`(?i)/gameId=(\d+)`)},
+	{"gog", regexp.MustCompile(`(?i)heroic://launch/gog/(\d+)`)},
+	// com.epicgames.launcher://apps/Fortnite?action=launch
+	{"egs", regexp.MustCompile(`(?i)com\.epicgames\.launcher://apps/([^?/\s"]+)`)},
+	{"egs", regexp.MustCompile(`(?i)heroic://launch/legendary/([^?/\s"]+)`)},
+	// origin://launchgame/OFB-EAST:109552153 or origin2://game/launch?offerIds=...
+	{"origin", regexp.MustCompile(`(?i)origin2?://launchgame/([^?/\s"]+)`)},
+	{"origin", regexp.MustCompile(`(?i)origin2?://game/launch\?offerIds=([^&\s"]+)`)},
+	{"uplay", regexp.MustCompile(`(?i)uplay://launch/(\d+)`)},
+	{"bnet", regexp.MustCompile(`(?i)battlenet://([^/\s"]+)`)},
+}
+
+// PlatformGameID returns the platform and ID of the game a shortcut of another
+// launcher starts, or "" when it's not one.
+func PlatformGameID(target string) (string, string) {
+	for _, launch := range platformLaunchPatterns {
+		if groups := launch.pattern.FindStringSubmatch(target); groups != nil {
+			return launch.platform, groups[1]
+		}
+	}
+	return "", ""
+}
+
+// Returns the SteamGridDB game of a game of another platform, or 0 when
+// SteamGridDB doesn't know it.
+func steamGridDBGameByPlatform(platform string, id string, steamGridDBApiKey string) int {
+	responseBytes, err := SteamGridDBGetRequest(SteamGridDBBaseURL+"/games/"+platform+"/"+escapePathSegment(id), steamGridDBApiKey)
+	if err != nil {
+		return 0
+	}
+	var response struct {
+		Success bool
+		Data    struct {
+			ID   int
+			Name string
+		}
+	}
+	if json.Unmarshal(responseBytes, &response) != nil || !response.Success {
+		return 0
+	}
+	return response.Data.ID
+}
+
+// Looks up the SteamGridDB game of shortcuts of other launchers by their ID
+// there, which is far more accurate than searching by name. Games given in
+// the name overrides keep theirs.
+func resolvePlatformGame(game *Game, steamGridDBApiKey string) {
+	if game.SteamGridDBGameID != 0 || game.Platform == "" {
+		return
+	}
+	game.SteamGridDBGameID = steamGridDBGameByPlatform(game.Platform, game.PlatformID, steamGridDBApiKey)
+}
@@ -0,0 +1,54 @@
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RAWG's game search, whose results come with a wide background image.
+const rawgSearchURL = "https://api.rawg.io/api/games?key=%v&search=%v&page_size=10"
+
+// GetRAWGImage returns the URL of the background RAWG has for the game closest
+// to a game's name, used as a hero, or "" when there's none close enough.
+func GetRAWGImage(game *Game, apiKey string, minMatch float64) (string, error) {
+	if game.SearchTerm() == "" {
+		return "", nil
+	}
+	status, body, err := CachedGet(fmt.Sprintf(rawgSearchURL, url.QueryEscape(apiKey), url.QueryEscape(game.SearchTerm())))
+	if err != nil {
+		return "", errors.New("RAWG " + strings.Replace(err.Error(), apiKey, "…", -1))
+	} else if status != http.StatusOK {
+		return "", nil
+	}
+	var response struct {
+		Results []struct {
+			Name            string `json:"name"`
+			BackgroundImage string `json:"background_image"`
+		} `json:"results"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", err
+	}
+	imageURL, bestSimilarity := "", minMatch
+	for _, result := range response.Results {
+		if similarity := nameSimilarity(result.Name, game.SearchTerm()); result.BackgroundImage != "" && similarity >= bestSimilarity {
+			imageURL, bestSimilarity = result.BackgroundImage, similarity
+		}
+	}
+	return imageURL, nil
+}
+
+// RAWG is RAWG, with backgrounds of games.
+type RAWG struct {
+	APIKey   string
+	MinMatch float64
+}
+
+func (source RAWG) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	return downloadFound(GetRAWGImage(game, source.APIKey, source.MinMatch))
+}
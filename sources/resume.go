@@ -0,0 +1,195 @@
+package sources
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// What identifies the version of a file being downloaded, so a partial
+// download is only resumed from the same file.
+type partialDownload struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// Returns the validator to send in If-Range, preferring the ETag.
+func (partial partialDownload) validator() string {
+	if partial.ETag != "" {
+		return partial.ETag
+	}
+	return partial.LastModified
+}
+
+// Partial downloads are kept in the cache directory by the hash of the URL:
+// the bytes received so far and a JSON file with their validators.
+func partialDownloadPaths(url string) (string, string) {
+	hash := sha256.Sum256([]byte(url))
+	base := filepath.Join(CacheDir(), "partial", hex.EncodeToString(hash[:]))
+	return base + ".part", base + ".json"
+}
+
+var removeStalePartialDownloadsOnce sync.Once
+
+// Removes the partial downloads older than -cachettl, like the ones of images
+// nobody asks for anymore, once per run.
+func removeStalePartialDownloads() {
+	removeStalePartialDownloadsOnce.Do(func() {
+		files, _ := filepath.Glob(filepath.Join(CacheDir(), "partial", "*"))
+		for _, file := range files {
+			info, err := os.Stat(file)
+			if err == nil && time.Since(info.ModTime()) > APICacheTTL {
+				os.Remove(file)
+			}
+		}
+	})
+}
+
+func savePartialDownload(partial partialDownload, received []byte) {
+	removeStalePartialDownloads()
+	partPath, infoPath := partialDownloadPaths(partial.URL)
+	infoBytes, err := json.Marshal(partial)
+	if err != nil || os.MkdirAll(filepath.Dir(partPath), 0777) != nil {
+		return
+	}
+	if ioutil.WriteFile(partPath, received, 0666) == nil {
+		ioutil.WriteFile(infoPath, infoBytes, 0666)
+	}
+}
+
+// Returns the bytes received by an earlier run for the same version of the
+// file, if any.
+func loadPartialDownload(partial partialDownload) []byte {
+	if partial.validator() == "" {
+		return nil
+	}
+	removeStalePartialDownloads()
+	partPath, infoPath := partialDownloadPaths(partial.URL)
+	infoBytes, err := ioutil.ReadFile(infoPath)
+	if err != nil {
+		return nil
+	}
+	var saved partialDownload
+	if json.Unmarshal(infoBytes, &saved) != nil || saved != partial {
+		return nil
+	}
+	received, _ := ioutil.ReadFile(partPath)
+	return received
+}
+
+func removePartialDownload(url string) {
+	partPath, infoPath := partialDownloadPaths(url)
+	os.Remove(partPath)
+	os.Remove(infoPath)
+}
+
+// Asks for the rest of a file, from byte offset on. The answer is the whole
+// file again, with the status 200, when the server sends it instead, or sends
+// another part of it than asked for.
+func requestRemainder(partial partialDownload, offset int) (*http.Response, error) {
+	req, err := http.NewRequest("GET", partial.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%v-", offset))
+	if validator := partial.validator(); validator != "" {
+		req.Header.Set("If-Range", validator)
+	}
+	response, err := DoRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusPartialContent && contentRangeStart(response) != offset {
+		// Appended, it would give a broken image.
+		response.Body.Close()
+		req.Header.Del("Range")
+		req.Header.Del("If-Range")
+		response, err = DoRequest(req)
+		if err != nil {
+			return nil, err
+		} else if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return nil, errors.New("Failed to download " + partial.URL + " again: " + response.Status)
+		}
+	}
+	if response.StatusCode != http.StatusPartialContent && response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, errors.New("Failed to resume download " + partial.URL + ": " + response.Status)
+	}
+	return response, nil
+}
+
+// Returns the first byte of a partial answer, from its Content-Range like
+// "bytes 1000-1999/2000", or -1 without one.
+func contentRangeStart(response *http.Response) int {
+	var start int
+	_, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-", &start)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+// ReadDownload reads the whole body of a download. When the connection drops
+// midway and the server supports ranges, only the remainder is asked for, up
+// to -retries times. What was received is kept on disk if it still fails, so
+// the next run can resume from there too.
+func ReadDownload(response *http.Response) ([]byte, error) {
+	partial := partialDownload{
+		URL:          response.Request.URL.String(),
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+	}
+	resumable := response.Header.Get("Accept-Ranges") == "bytes" && partial.validator() != ""
+
+	var received []byte
+	if earlier := loadPartialDownload(partial); resumable && len(earlier) > 0 {
+		fmt.Printf("Resuming download of %v from %v KiB\n", partial.URL, len(earlier)/1024)
+		response.Body.Close()
+		var err error
+		response, err = requestRemainder(partial, len(earlier))
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode == http.StatusPartialContent {
+			received = earlier
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		chunk, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		received = append(received, chunk...)
+		if err == nil {
+			removePartialDownload(partial.URL)
+			return received, nil
+		}
+
+		if !resumable || len(received) == 0 || attempt >= HTTPRetries {
+			if resumable && len(received) > 0 {
+				savePartialDownload(partial, received)
+			}
+			return nil, err
+		}
+
+		fmt.Printf("Download of %v interrupted at %v KiB, resuming\n", partial.URL, len(received)/1024)
+		response, err = requestRemainder(partial, len(received))
+		if err != nil {
+			savePartialDownload(partial, received)
+			return nil, err
+		}
+		if response.StatusCode == http.StatusOK {
+			// The server sent the whole file again.
+			received = nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package sources
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Serves a file that can be resumed, answering ranges from skew bytes off
+// the one asked for.
+func resumableServer(file []byte, skew int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("ETag", `"v1"`)
+		var start int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start); err == nil {
+			start += skew
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v", start, len(file)-1, len(file)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(file[start:])
+			return
+		}
+		w.Write(file)
+	}))
+}
+
+func TestReadDownloadResumes(t *testing.T) {
+	CacheDirOverride = t.TempDir()
+	defer func() { CacheDirOverride = "" }()
+	file := bytes.Repeat([]byte("0123456789"), 100)
+
+	tests := []struct {
+		name string
+		skew int
+	}{
+		{"range as asked", 0},
+		{"other range", -100},
+	}
+	for _, test := range tests {
+		server := resumableServer(file, test.skew)
+		url := server.URL + "/image.png"
+		savePartialDownload(partialDownload{URL: url, ETag: `"v1"`}, file[:400])
+
+		response, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadDownload(response)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		} else if !bytes.Equal(got, file) {
+			t.Errorf("%v: got %v bytes, not the file", test.name, len(got))
+		}
+		server.Close()
+	}
+}
+
+func TestRemoveStalePartialDownloads(t *testing.T) {
+	CacheDirOverride = t.TempDir()
+	defer func() { CacheDirOverride = "" }()
+	removeStalePartialDownloadsOnce = sync.Once{}
+
+	stale := partialDownload{URL: "http://example.com/stale.png", ETag: `"v1"`}
+	fresh := partialDownload{URL: "http://example.com/fresh.png", ETag: `"v1"`}
+	savePartialDownload(stale, []byte("stale"))
+	savePartialDownload(fresh, []byte("fresh"))
+	old := time.Now().Add(-APICacheTTL - time.Hour)
+	stalePart, staleInfo := partialDownloadPaths(stale.URL)
+	os.Chtimes(stalePart, old, old)
+	os.Chtimes(staleInfo, old, old)
+
+	removeStalePartialDownloadsOnce = sync.Once{}
+	if got := loadPartialDownload(fresh); string(got) != "fresh" {
+		t.Errorf("fresh partial download read as %q", got)
+	}
+	if files, _ := ioutil.ReadDir(filepath.Join(CacheDirOverride, "partial")); len(files) != 2 {
+		t.Errorf("%v files left, want the 2 of the fresh download", len(files))
+	}
+}
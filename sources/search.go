@@ -0,0 +1,253 @@
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Web image searches, the last resort for banners, by the name given with
+// -searchprovider. Each returns the URL of an image of the given size found
+// for a query, or "".
+var ImageSearchProviders = map[string]func(query string, width int, height int, search ImageSearch) (string, error){
+	"google":     searchGoogleImages,
+	"bing":       searchBingImages,
+	"duckduckgo": searchDuckDuckGoImages,
+	"searxng":    searchSearXNGImages,
+}
+
+// Bing's Image Search API, which needs a key of its own.
+const bingImageSearchURL = "https://api.bing.microsoft.com/v7.0/images/search?count=20&q=%v"
+
+// DuckDuckGo hands out a token on its search page that its image results
+// need.
+const (
+	duckDuckGoSearchURL = "https://duckduckgo.com/?iax=images&ia=images&q=%v"
+	duckDuckGoImagesURL = "https://duckduckgo.com/i.js?o=json&l=us-en&q=%v&vqd=%v"
+)
+
+var duckDuckGoTokenPattern = regexp.MustCompile(`vqd=["']?([\d-]+)`)
+
+// Images this much narrower or wider than the size asked for are skipped.
+const searchAspectTolerance = 0.05
+
+// A result of a web image search.
+type searchResult struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// Picks the first result of the exact size, or else the first of the same
+// aspect ratio, as Steam would crop other images badly.
+func pickSearchResult(results []searchResult, width int, height int) string {
+	for _, result := range results {
+		if result.Width == width && result.Height == height {
+			return result.URL
+		}
+	}
+	for _, result := range results {
+		if result.Width > 0 && result.Height > 0 && math.Abs(float64(result.Width*height)/float64(result.Height*width)-1) <= searchAspectTolerance {
+			return result.URL
+		}
+	}
+	return ""
+}
+
+// Searches the web for a banner of a game with the provider of
+// -searchprovider, Google by default.
+func getSearchImage(gameName string, search ImageSearch) (string, error) {
+	if gameName == "" {
+		return "", nil
+	}
+	provider := ImageSearchProviders[strings.ToLower(search.Provider)]
+	if provider == nil {
+		provider = searchGoogleImages
+	}
+	// Format is hardcoded to old banner format here, we're using searches
+	// only for banners anyway.
+	return provider(gameName, 460, 215, search)
+}
+
+// Google filters by exact size, the other providers are helped by asking
+// for banners.
+const searchQuerySuffix = " steam banner"
+
+func searchGoogleImages(query string, width int, height int, search ImageSearch) (string, error) {
+	return getGoogleImage(query, nil)
+}
+
+func searchBingImages(query string, width int, height int, search ImageSearch) (string, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf(bingImageSearchURL, url.QueryEscape(query+searchQuerySuffix)), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", search.BingKey)
+	var response struct {
+		Value []struct {
+			ContentURL string `json:"contentUrl"`
+			Width      int    `json:"width"`
+			Height     int    `json:"height"`
+		} `json:"value"`
+	}
+	err = searchJSON(req, &response)
+	if err != nil {
+		return "", errors.New("Bing " + err.Error())
+	}
+	var results []searchResult
+	for _, value := range response.Value {
+		results = append(results, searchResult{value.ContentURL, value.Width, value.Height})
+	}
+	return pickSearchResult(results, width, height), nil
+}
+
+func searchDuckDuckGoImages(query string, width int, height int, search ImageSearch) (string, error) {
+	query += searchQuerySuffix
+	response, err := HTTPGet(fmt.Sprintf(duckDuckGoSearchURL, url.QueryEscape(query)))
+	if err != nil {
+		return "", err
+	}
+	page, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	token := duckDuckGoTokenPattern.FindSubmatch(page)
+	if token == nil {
+		return "", errors.New("DuckDuckGo gave no search token")
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf(duckDuckGoImagesURL, url.QueryEscape(query), string(token[1])), nil)
+	if err != nil {
+		return "", err
+	}
+	// The image results are only given to the search page.
+	req.Header.Set("Referer", "https://duckduckgo.com/")
+	var images struct {
+		Results []struct {
+			Image  string `json:"image"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+		} `json:"results"`
+	}
+	err = searchJSON(req, &images)
+	if err != nil {
+		return "", errors.New("DuckDuckGo " + err.Error())
+	}
+	var results []searchResult
+	for _, image := range images.Results {
+		results = append(results, searchResult{image.Image, image.Width, image.Height})
+	}
+	return pickSearchResult(results, width, height), nil
+}
+
+// Searches the SearXNG instance of -searxng, which has to allow the JSON
+// format in its settings.
+func searchSearXNGImages(query string, width int, height int, search ImageSearch) (string, error) {
+	searchURL := strings.TrimSuffix(search.SearXNG, "/") + "/search?format=json&categories=images&q=" + url.QueryEscape(query+searchQuerySuffix)
+	req, err := http.NewRequest("GET", searchURL, nil)
+	if err != nil {
+		return "", err
+	}
+	var response struct {
+		Results []struct {
+			ImgSrc     string `json:"img_src"`
+			Resolution string `json:"resolution"`
+		} `json:"results"`
+	}
+	err = searchJSON(req, &response)
+	if err != nil {
+		return "", errors.New("SearXNG " + err.Error())
+	}
+	var results []searchResult
+	for _, result := range response.Results {
+		found := searchResult{URL: result.ImgSrc}
+		// Like "460x215" or "460 x 215".
+		fmt.Sscanf(strings.Replace(result.Resolution, " ", "", -1), "%dx%d", &found.Width, &found.Height)
+		results = append(results, found)
+	}
+	return pickSearchResult(results, width, height), nil
+}
+
+// Does a search request and reads its JSON answer.
+func searchJSON(req *http.Request, v interface{}) error {
+	response, err := DoRequest(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return errors.New("answered " + response.Status)
+	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+// When all else fails, Google it. Uses the regular web interface. There are
+// two image search APIs, but one is deprecated and doesn't support exact size
+// matching, and the other requires an API key limited to 100 searches a day.
+const googleSearchFormat = `https://www.google.com.br/search?tbs=isz%%3Aex%%2Ciszw%%3A%v%%2Ciszh%%3A%v&tbm=isch&num=5&q=`
+
+// Possible Google result formats
+var googleSearchResultPatterns = []string{`imgurl=(.+?\.(jpeg|jpg|png))&amp;imgrefurl=`, `\"ou\":\"(.+?)\",\"`}
+
+// Returns the first steam grid image URL found by Google search of a given
+// game name.
+func getGoogleImage(gameName string, artStyleExtensions []string) (string, error) {
+	if gameName == "" {
+		return "", nil
+	}
+
+	// Format is hardcoded to old banner format here, we're using google only for banners anyway.
+	url := fmt.Sprintf(googleSearchFormat, 460, 215) + url.QueryEscape(gameName)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// If we don't set an user agent, Google will block us because we are a
+	// bot. If we set something like "SteamGrid Image Search" it'll work, but
+	// Google will serve a simple HTML page without direct image links.
+	// So we have to lie.
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 6.3; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/39.0.2171.71 Safari/537.36")
+	response, err := DoRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	response.Body.Close()
+
+	for _, googleSearchResultPattern := range googleSearchResultPatterns {
+		pattern := regexp.MustCompile(googleSearchResultPattern)
+		matches := pattern.FindStringSubmatch(string(responseBytes))
+
+		if len(matches) >= 1 {
+			return matches[1], nil
+		}
+	}
+	return "", nil
+}
+
+// ImageSearch is a web image search, the last resort for banners.
+type ImageSearch struct {
+	// google, bing, duckduckgo or searxng, Google by default
+	Provider string
+	// Key of Bing's Image Search API and URL of the SearXNG instance, for the
+	// providers that need them
+	BingKey string
+	SearXNG string
+}
+
+func (search ImageSearch) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	return downloadFound(getSearchImage(game.SearchTerm(), search))
+}
@@ -0,0 +1,48 @@
+package sources
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Similarity of two game names from 0 to 1, as the share of letter pairs
+// they have in common (Sørensen–Dice coefficient). Case, punctuation and
+// spaces are ignored, and word order matters little, so "Doom (1993)" is
+// still close to "DOOM" but "FF7R" is far from "Final Fantasy VII Remake".
+func nameSimilarity(a string, b string) float64 {
+	pairs := func(name string) map[string]int {
+		var runes []rune
+		for _, r := range strings.ToLower(name) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				runes = append(runes, r)
+			}
+		}
+		counts := make(map[string]int)
+		for i := 0; i+1 < len(runes); i++ {
+			counts[string(runes[i:i+2])]++
+		}
+		// Single letter names have no pairs, count the letter itself.
+		if len(runes) == 1 {
+			counts[string(runes)]++
+		}
+		return counts
+	}
+
+	pairsA, pairsB := pairs(a), pairs(b)
+	total, common := 0, 0
+	for pair, countA := range pairsA {
+		total += countA
+		if countB := pairsB[pair]; countB < countA {
+			common += countB
+		} else {
+			common += countA
+		}
+	}
+	for _, countB := range pairsB {
+		total += countB
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
@@ -0,0 +1,76 @@
+// Package sources finds the artwork of games where SteamGrid knows to look:
+// Steam's library cache and servers, SteamGridDB, IGDB, itch.io, GOG, RAWG,
+// TheGamesDB, the LaunchBox Games Database and web image searches. Each is a
+// Source that answers with the download of the image it found for a Game.
+package sources
+
+import "net/http"
+
+// Game is a game as the sources look for its artwork.
+type Game struct {
+	// Official appID or custom shortcut ID
+	ID string
+	// Warning, may contain Unicode characters.
+	Name string
+	// Is custom shortcut?
+	Custom bool
+	// Name to search for instead of Name, and the game on SteamGridDB and
+	// IGDB, from the name overrides file or the built-in aliases.
+	SearchName        string
+	SteamGridDBGameID int
+	IGDBGameID        int
+	// Name on SteamGridDB of the application a non-Steam shortcut launches,
+	// when it's not a game.
+	ApplicationName string
+	// Executable and launch options of a non-Steam shortcut.
+	Target string
+	// Launcher and ID of the game a non-Steam shortcut starts through
+	// another launcher, as SteamGridDB names them: gog, egs, origin, uplay
+	// or bnet.
+	Platform   string
+	PlatformID string
+	// ID of the image on SteamGridDB, if it came from there.
+	SteamGridDBID int
+	// SteamGridDB animations downloaded and found over the -maxloop or
+	// -maxfps limits, not to be picked again.
+	RejectedSteamGridDBIDs []int
+}
+
+// SearchTerm returns the name to search for the game with.
+func (game *Game) SearchTerm() string {
+	if game.SearchName != "" {
+		return game.SearchName
+	}
+	return game.Name
+}
+
+// Source is a place artwork is found, like SteamGridDB or Steam's servers.
+type Source interface {
+	// Find returns the best image of a game in an art style, with the
+	// extensions and SteamGridDB filter of the art style, or nil when the
+	// source has none. Errors are about looking for the image; failed
+	// downloads of the image found leave it to the next source.
+	Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error)
+}
+
+// Downloads the image a source found, if any.
+func downloadFound(url string, err error) (*http.Response, error) {
+	if url == "" || err != nil {
+		return nil, err
+	}
+	response, err := TryDownload(url)
+	if err != nil {
+		return nil, nil
+	}
+	return response, nil
+}
+
+// Tells if an image of SteamGridDB was rejected for the game before.
+func (game *Game) rejected(steamGridDBID int) bool {
+	for _, id := range game.RejectedSteamGridDBIDs {
+		if id == steamGridDBID {
+			return true
+		}
+	}
+	return false
+}
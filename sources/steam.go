@@ -0,0 +1,61 @@
+package sources
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Primary URL for downloading grid images.
+const AkamaiURLFormat = `https://steamcdn-a.akamaihd.net/steam/apps/%v/`
+
+// The subreddit mentions this as primary, but I've found Akamai to contain
+// more images and answer faster.
+const steamCdnURLFormat = `https://cdn.akamai.steamstatic.com/steam/apps/%v/`
+
+// Names of Steam's newer assets, tried after the usual one of each art style
+// (artStyleExtensions[2]) for games that only have those.
+var steamAssetFallbacks = map[string][]string{
+	"Banner": {"library_header.jpg"},
+	"Cover":  {"library_600x900.jpg", "library_capsule.jpg", "hero_capsule.jpg"},
+}
+
+// Returns the names of the Steam assets of an art style to try, in order.
+// With a language, like "japanese", its localized variants come first:
+// header_japanese.jpg before header.jpg.
+func steamAssetNames(artStyle string, artStyleExtensions []string, language string) []string {
+	if artStyleExtensions[2] == "" {
+		return nil
+	}
+	names := append([]string{artStyleExtensions[2]}, steamAssetFallbacks[artStyle]...)
+	if language == "" {
+		return names
+	}
+	var localized []string
+	for _, name := range names {
+		extension := filepath.Ext(name)
+		localized = append(localized, strings.TrimSuffix(name, extension)+"_"+language+extension)
+	}
+	return append(localized, names...)
+}
+
+// SteamCDN is Steam's servers, with the official artwork of Steam games.
+type SteamCDN struct {
+	// Steam's name of the language whose localized assets come first, like
+	// "japanese", or "" for English
+	Language string
+}
+
+func (source SteamCDN) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	for _, name := range steamAssetNames(artStyle, artStyleExtensions, source.Language) {
+		response, err := TryDownload(fmt.Sprintf(AkamaiURLFormat+name, game.ID))
+		if err != nil || response == nil {
+			response, err = TryDownload(fmt.Sprintf(steamCdnURLFormat+name, game.ID))
+		}
+		if err == nil && response != nil {
+			return response, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,399 @@
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.deanishe.net/fuzzy"
+)
+
+// https://www.steamgriddb.com/api/v2
+type steamGridDBResponse struct {
+	Success bool
+	Data    []SteamGridDBImage
+}
+
+// SteamGridDBImage is an image of a game on SteamGridDB.
+type SteamGridDBImage struct {
+	ID        int
+	Score     int
+	Upvotes   int
+	Downvotes int
+	Width     int
+	Height    int
+	Style     string
+	Mime      string
+	Language  string
+	URL       string
+	Thumb     string
+	Tags      []string
+	Author    struct {
+		Name    string
+		Steam64 string
+		Avatar  string
+	}
+}
+
+type steamGridDBSearchResponse struct {
+	Success bool
+	Data    []struct {
+		ID       int
+		Name     string
+		Types    []string
+		Verified bool
+	}
+}
+
+// Enable fuzzy sorting
+// Default sort.Interface methods
+func (results steamGridDBSearchResponse) Len() int { return len(results.Data) }
+
+func (results steamGridDBSearchResponse) Swap(i, j int) {
+	results.Data[i], results.Data[j] = results.Data[j], results.Data[i]
+}
+
+func (results steamGridDBSearchResponse) Less(i, j int) bool {
+	return strings.ToLower(results.Data[i].Name) < strings.ToLower(results.Data[j].Name)
+}
+
+// Keywords implements Sortable.
+// Comparisons are based on the the full name of the contact.
+func (results steamGridDBSearchResponse) Keywords(i int) string {
+	return strings.ToLower(results.Data[i].Name)
+}
+
+// Search SteamGridDB for cover image
+const SteamGridDBBaseURL = "https://www.steamgriddb.com/api/v2"
+
+// Keeps SteamGridDB API requests under the number per second set by
+// -sgdbrate, so large libraries don't run into its rate limit.
+var SteamGridDBLimiter = &rateLimiter{PerSecond: 4}
+
+// SteamGridDBGetRequest sends a GET request to the SteamGridDB API, through
+// the cache, and returns the body of the answer.
+func SteamGridDBGetRequest(url string, steamGridDBApiKey string) ([]byte, error) {
+	cacheKey := "GET " + url
+	if cached, ok := loadCachedResponse(cacheKey); ok {
+		if cached.Status == 404 {
+			return nil, errors.New("404")
+		}
+		return cached.Body, nil
+	}
+	if steamGridDBDown() {
+		return nil, ErrSteamGridDBUnavailable
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+steamGridDBApiKey)
+
+	var response *http.Response
+	for attempt := 0; ; attempt++ {
+		SteamGridDBLimiter.wait()
+		response, err = DoRequest(req)
+		if err != nil && isTransientError(err) {
+			markSteamGridDBDown()
+			return nil, fmt.Errorf("%w: %v", ErrSteamGridDBUnavailable, err)
+		} else if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusTooManyRequests || attempt >= HTTPRetries {
+			break
+		}
+
+		// Rate limited, wait as long as SteamGridDB asks before trying again.
+		response.Body.Close()
+		delay, ok := retryAfter(response)
+		if !ok || delay < 0 {
+			delay = retryBackoff(attempt)
+		}
+		fmt.Printf("SteamGridDB rate limit reached, waiting %v\n", delay.Round(time.Second))
+		time.Sleep(delay)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == 401 {
+		// Authorization token is missing or invalid
+		return nil, errors.New("401")
+	} else if response.StatusCode == 404 {
+		// Could not find game with that id
+		storeCachedResponse(cacheKey, 404, nil)
+		return nil, errors.New("404")
+	} else if response.StatusCode == http.StatusTooManyRequests {
+		return nil, errors.New("SteamGridDB rate limit reached, try again later or lower -sgdbrate")
+	} else if response.StatusCode >= 500 {
+		markSteamGridDBDown()
+		return nil, fmt.Errorf("%w: %v", ErrSteamGridDBUnavailable, response.Status)
+	}
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusOK {
+		storeCachedResponse(cacheKey, response.StatusCode, responseBytes)
+	}
+	return responseBytes, nil
+}
+
+// SteamGridDB is SteamGridDB, with the artwork its community made for any
+// game.
+type SteamGridDB struct {
+	APIKey string
+	// Images with a lower score or fewer upvotes are skipped, when set
+	MinScore   OptionalInt
+	MinUpvotes OptionalInt
+	// Games found by name less similar than this, from 0 to 1, count as not
+	// found
+	MinMatch float64
+	// ISO code of the language whose images come first, like ja
+	Language string
+	// WEBM videos can be converted, and aren't skipped
+	Webm bool
+	// Picks the image to use, like by asking, returning its URL and ID,
+	// instead of the best one
+	Choose func(game *Game, artStyle string, artStyleExtensions []string) (string, int, error)
+}
+
+// Finds the best image for a game: the first one, by score, with enough
+// score and upvotes, or the one Choose picks. Its ID goes to
+// game.SteamGridDBID.
+func (source SteamGridDB) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	if source.Choose != nil {
+		url, id, err := source.Choose(game, artStyle, artStyleExtensions)
+		game.SteamGridDBID = id
+		return downloadFound(url, err)
+	}
+
+	game.SteamGridDBID = 0
+	images, err := source.Images(game, artStyleExtensions)
+	if err != nil {
+		return nil, err
+	}
+	var allowed []SteamGridDBImage
+	for _, candidate := range images {
+		// WEBM videos are skipped when they can't be converted.
+		if !game.rejected(candidate.ID) && (!candidate.isWebm() || source.Webm) {
+			allowed = append(allowed, candidate)
+		}
+	}
+	images = allowed
+	if len(images) == 0 {
+		return nil, nil
+	}
+
+	best := images[0]
+	if strings.Contains(artStyleExtensions[3], "animated,static") {
+		for _, candidate := range images {
+			if strings.Contains(candidate.Thumb, "webm") {
+				best = candidate
+				break
+			}
+		}
+	}
+	game.SteamGridDBID = best.ID
+	return downloadFound(best.URL, nil)
+}
+
+// Images returns the images of a game in an art style with enough score and
+// upvotes, sorted by score, keeping SteamGridDB's order for equal scores.
+// Images in the language of the source come first.
+func (source SteamGridDB) Images(game *Game, artStyleExtensions []string) ([]SteamGridDBImage, error) {
+	images, err := GetSteamGridDBImages(game, artStyleExtensions, source.APIKey, source.MinMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []SteamGridDBImage
+	for _, candidate := range images {
+		if source.MinScore.set && candidate.Score < source.MinScore.value {
+			continue
+		}
+		if source.MinUpvotes.set && candidate.Upvotes < source.MinUpvotes.value {
+			continue
+		}
+		filtered = append(filtered, candidate)
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].Score > filtered[j].Score
+	})
+	if code := source.Language; code != "" && code != "en" {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return matchesLanguage(filtered[i].Language, code) && !matchesLanguage(filtered[j].Language, code)
+		})
+	}
+	return filtered, nil
+}
+
+// GetSteamGridDBImages returns the images SteamGridDB has for a game in the
+// requested art style, looking the game up by appID or, for custom games, by
+// their ID in another launcher or by name.
+func GetSteamGridDBImages(game *Game, artStyleExtensions []string, steamGridDBApiKey string, minMatch float64) ([]SteamGridDBImage, error) {
+	// Try for HQ, then for LQ
+	// It's possible to request both dimensions in one go but that'll give us scrambled results with no indicator which result has which size.
+	for i := 0; i < 3; i += 2 {
+
+		// Try with game.ID which is probably steams appID
+		var baseURL string
+		switch artStyleExtensions[1] {
+		case ".banner":
+			baseURL = SteamGridDBBaseURL + "/grids"
+		case ".cover":
+			baseURL = SteamGridDBBaseURL + "/grids"
+		case ".hero":
+			baseURL = SteamGridDBBaseURL + "/heroes"
+		case ".logo":
+			baseURL = SteamGridDBBaseURL + "/logos"
+		case ".icon":
+			baseURL = SteamGridDBBaseURL + "/icons"
+		}
+		url := baseURL + "/steam/" + game.ID + artStyleExtensions[3]
+
+		var jsonResponse steamGridDBResponse
+		var responseBytes []byte
+		var err error
+
+		// Skip requests with appID for custom games
+		resolvePlatformGame(game, steamGridDBApiKey)
+		if game.SteamGridDBGameID != 0 {
+			responseBytes, err = SteamGridDBGetRequest(baseURL+"/game/"+strconv.Itoa(game.SteamGridDBGameID)+artStyleExtensions[3], steamGridDBApiKey)
+		} else if !game.Custom {
+			responseBytes, err = SteamGridDBGetRequest(url, steamGridDBApiKey)
+		} else {
+			err = errors.New("404")
+		}
+
+		// Authorization token is missing or invalid
+		if err != nil && err.Error() == "401" {
+			return nil, errors.New(" SteamGridDB authorization token is missing or invalid")
+			// Could not find game with that id
+		} else if err != nil && err.Error() == "404" {
+			// Try searching for the name…
+			url = SteamGridDBBaseURL + "/search/autocomplete/" + escapePathSegment(game.SearchTerm()) + artStyleExtensions[3]
+			responseBytes, err = SteamGridDBGetRequest(url, steamGridDBApiKey)
+			if err != nil && err.Error() == "401" {
+				return nil, errors.New(" SteamGridDB authorization token is missing or invalid")
+			} else if err != nil {
+				return nil, err
+			}
+
+			var jsonSearchResponse steamGridDBSearchResponse
+			err = json.Unmarshal(responseBytes, &jsonSearchResponse)
+			if err != nil {
+				return nil, errors.New("best search match doesn't has a requested type or style")
+			}
+
+			SteamGridDBGameID := -1
+			if jsonSearchResponse.Success && len(jsonSearchResponse.Data) >= 1 {
+				fuzzy.Sort(jsonSearchResponse, strings.ToLower(game.SearchTerm()))
+				SteamGridDBGameID = jsonSearchResponse.Data[0].ID
+
+				// Better nothing than the art of another game.
+				best := jsonSearchResponse.Data[0].Name
+				if similarity := nameSimilarity(best, game.SearchTerm()); similarity < minMatch {
+					fmt.Printf("Best SteamGridDB match for %v is %v, too different (%.2f < -minmatch %v)\n", game.SearchTerm(), best, similarity, minMatch)
+					return nil, nil
+				}
+			}
+
+			if SteamGridDBGameID == -1 {
+				return nil, nil
+			}
+
+			// …and get the url of the top result.
+			url = baseURL + "/game/" + strconv.Itoa(SteamGridDBGameID) + artStyleExtensions[3]
+			responseBytes, err = SteamGridDBGetRequest(url, steamGridDBApiKey)
+			if err != nil {
+				return nil, err
+			}
+		} else if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(responseBytes, &jsonResponse)
+		if err != nil {
+			return nil, err
+		}
+
+		if jsonResponse.Success && len(jsonResponse.Data) >= 1 {
+			return jsonResponse.Data, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Tells if a SteamGridDB image is only a WEBM video.
+func (candidate SteamGridDBImage) isWebm() bool {
+	return candidate.Mime == "video/webm" || strings.HasSuffix(strings.ToLower(candidate.URL), ".webm")
+}
+
+// IsAnimated tells if an image is animated, which SteamGridDB shows with a
+// WEBM thumbnail.
+func (candidate SteamGridDBImage) IsAnimated() bool {
+	return strings.Contains(candidate.Thumb, "webm")
+}
+
+// One line describing a candidate in the interactive list.
+func (candidate SteamGridDBImage) String() string {
+	description := fmt.Sprintf("%vx%v %v, score %v (+%v/-%v) by %v", candidate.Width, candidate.Height, candidate.Style, candidate.Score, candidate.Upvotes, candidate.Downvotes, candidate.Author.Name)
+	if candidate.IsAnimated() {
+		description += " [animated]"
+	}
+	return description
+}
+
+// ErrSteamGridDBUnavailable is returned for SteamGridDB requests while it's
+// down: server errors and connections that failed even after -retries.
+var ErrSteamGridDBUnavailable = errors.New("SteamGridDB is unavailable")
+
+// When SteamGridDB was last found down. It's left alone for a while, so the
+// next games don't wait for it again and again, and asked again after that,
+// so long runs of watch and serve don't give up on it for good.
+var steamGridDBDownAt time.Time
+
+// How long SteamGridDB is left alone once found down.
+const steamGridDBDownFor = 5 * time.Minute
+
+func markSteamGridDBDown() {
+	steamGridDBDownAt = time.Now()
+}
+
+// Tells if SteamGridDB was found down recently enough not to ask it.
+func steamGridDBDown() bool {
+	return !steamGridDBDownAt.IsZero() && time.Since(steamGridDBDownAt) < steamGridDBDownFor
+}
+
+// OptionalInt is an integer flag that can tell whether it was given at all,
+// for limits where any value, including 0, is meaningful.
+type OptionalInt struct {
+	value int
+	set   bool
+}
+
+func (o *OptionalInt) String() string {
+	if o == nil || !o.set {
+		return ""
+	}
+	return strconv.Itoa(o.value)
+}
+
+func (o *OptionalInt) Set(value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	o.value = v
+	o.set = true
+	return nil
+}
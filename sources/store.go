@@ -0,0 +1,77 @@
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// Store page data of apps, the same the Steam client shows.
+const storeAppDetailsURL = "https://store.steampowered.com/api/appdetails?appids=%v"
+
+// StoreAppDetails is what the Steam store knows about an app.
+type StoreAppDetails struct {
+	Name string
+	// game, dlc, music, demo, video, mod, advertising...
+	Type string
+	// Like "Action" or "Early Access".
+	Genres []string
+	// Features like "Single-player" or "VR Support".
+	Categories  []string
+	ReleaseYear int
+}
+
+var releaseYearPattern = regexp.MustCompile(`\d{4}`)
+
+// GetStoreAppDetails returns the store data of an app, or nil when the store
+// doesn't have it. Data is kept in the API cache, like SteamGridDB answers.
+func GetStoreAppDetails(appID string) (*StoreAppDetails, error) {
+	status, body, err := CachedGet(fmt.Sprintf(storeAppDetailsURL, appID))
+	if err != nil {
+		return nil, errors.New("Steam store " + err.Error())
+	} else if status == http.StatusNotFound {
+		return nil, nil
+	}
+
+	var response map[string]struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Type   string `json:"type"`
+			Name   string `json:"name"`
+			Genres []struct {
+				Description string `json:"description"`
+			} `json:"genres"`
+			Categories []struct {
+				Description string `json:"description"`
+			} `json:"categories"`
+			ReleaseDate struct {
+				Date string `json:"date"`
+			} `json:"release_date"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	app, ok := response[appID]
+	if !ok || !app.Success {
+		return nil, nil
+	}
+
+	details := &StoreAppDetails{Name: app.Data.Name, Type: app.Data.Type}
+	for _, genre := range app.Data.Genres {
+		details.Genres = append(details.Genres, genre.Description)
+	}
+	for _, category := range app.Data.Categories {
+		details.Categories = append(details.Categories, category.Description)
+	}
+	// Dates are written in the language of the store, but the year is
+	// always there as four digits.
+	if year := releaseYearPattern.FindString(app.Data.ReleaseDate.Date); year != "" {
+		details.ReleaseYear, _ = strconv.Atoi(year)
+	}
+	return details, nil
+}
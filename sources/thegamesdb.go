@@ -0,0 +1,115 @@
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// TheGamesDB API, which covers consoles and old computers better than the
+// other sources. Both the search and the images count against the monthly
+// allowance of the key, so answers are cached like SteamGridDB's.
+const (
+	theGamesDBSearchURL = "https://api.thegamesdb.net/v1.1/Games/ByGameName?apikey=%v&name=%v"
+	theGamesDBImagesURL = "https://api.thegamesdb.net/v1/Games/Images?apikey=%v&games_id=%v&filter%%5Btype%%5D=%v"
+)
+
+// Types of TheGamesDB images for each art style.
+var theGamesDBImageTypes = map[string]string{
+	"Banner": "banner",
+	"Cover":  "boxart",
+	"Hero":   "fanart",
+	"Logo":   "clearlogo",
+}
+
+// Gets a TheGamesDB URL through the cache, keeping the key out of errors.
+func theGamesDBGet(url string, apiKey string) ([]byte, error) {
+	status, body, err := CachedGet(url)
+	if err != nil {
+		return nil, errors.New("TheGamesDB " + strings.Replace(err.Error(), apiKey, "…", -1))
+	} else if status != http.StatusOK {
+		return nil, nil
+	}
+	return body, nil
+}
+
+// TheGamesDBGameID returns the TheGamesDB ID of the game closest to a name, if
+// close enough, or 0.
+func TheGamesDBGameID(name string, apiKey string, minMatch float64) (int, error) {
+	body, err := theGamesDBGet(fmt.Sprintf(theGamesDBSearchURL, url.QueryEscape(apiKey), url.QueryEscape(name)), apiKey)
+	if body == nil || err != nil {
+		return 0, err
+	}
+	var response struct {
+		Data struct {
+			Games []struct {
+				ID    int    `json:"id"`
+				Title string `json:"game_title"`
+			} `json:"games"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return 0, err
+	}
+	bestID, bestSimilarity := 0, minMatch
+	for _, game := range response.Data.Games {
+		if similarity := nameSimilarity(game.Title, name); similarity >= bestSimilarity {
+			bestID, bestSimilarity = game.ID, similarity
+		}
+	}
+	return bestID, nil
+}
+
+// Returns the URL of the TheGamesDB image of a game in an art style, the
+// front of the box for covers, or "" when there's none.
+func getTheGamesDBImage(game *Game, artStyle string, apiKey string, minMatch float64) (string, error) {
+	imageType, ok := theGamesDBImageTypes[artStyle]
+	if !ok || game.SearchTerm() == "" {
+		return "", nil
+	}
+	id, err := TheGamesDBGameID(game.SearchTerm(), apiKey, minMatch)
+	if id == 0 || err != nil {
+		return "", err
+	}
+	body, err := theGamesDBGet(fmt.Sprintf(theGamesDBImagesURL, url.QueryEscape(apiKey), id, imageType), apiKey)
+	if body == nil || err != nil {
+		return "", err
+	}
+	var response struct {
+		Data struct {
+			BaseURL struct {
+				Original string `json:"original"`
+			} `json:"base_url"`
+			Images map[string][]struct {
+				Type     string `json:"type"`
+				Side     string `json:"side"`
+				Filename string `json:"filename"`
+			} `json:"images"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", err
+	}
+	for _, image := range response.Data.Images[strconv.Itoa(id)] {
+		if image.Type == imageType && (image.Type != "boxart" || image.Side == "front") {
+			return response.Data.BaseURL.Original + image.Filename, nil
+		}
+	}
+	return "", nil
+}
+
+// TheGamesDB is TheGamesDB, with everything but icons of retro games.
+type TheGamesDB struct {
+	APIKey   string
+	MinMatch float64
+}
+
+func (source TheGamesDB) Find(game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	return downloadFound(getTheGamesDBImage(game, artStyle, source.APIKey, source.MinMatch))
+}
@@ -0,0 +1,102 @@
+package steam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Versions of appcache/appinfo.vdf: 28 added a second hash to every app, 29
+// moved the keys to a table at the end of the file.
+const (
+	appInfoVersion27 = 0x07564427
+	appInfoVersion28 = 0x07564428
+	appInfoVersion29 = 0x07564429
+)
+
+// AppInfo is what Steam's local cache of the store knows about an app.
+type AppInfo struct {
+	Name string
+	// Game, DLC, Music, Tool, Application, Demo, Config...
+	Type string
+}
+
+// Reads the key table of a version 29 file: a count, then null terminated
+// strings.
+func readAppInfoKeys(data []byte, offset int64) ([]string, error) {
+	if offset < 0 || offset+4 > int64(len(data)) {
+		return nil, errors.New("appinfo.vdf key table out of the file")
+	}
+	reader := bytes.NewReader(data[offset:])
+	var count uint32
+	binary.Read(reader, binary.LittleEndian, &count)
+	var keys []string
+	for i := uint32(0); i < count; i++ {
+		key, err := readVDFString(reader)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ParseAppInfo reads the name and type of every app in an appinfo.vdf file,
+// from the appcache directory of a Steam installation, by appID.
+func ParseAppInfo(data []byte) (map[string]AppInfo, error) {
+	reader := bytes.NewReader(data)
+	var header struct {
+		Magic    uint32
+		Universe uint32
+	}
+	err := binary.Read(reader, binary.LittleEndian, &header)
+	if err != nil {
+		return nil, errors.New("appinfo.vdf is too short")
+	}
+	var keys []string
+	switch header.Magic {
+	case appInfoVersion27, appInfoVersion28:
+	case appInfoVersion29:
+		var keysOffset int64
+		binary.Read(reader, binary.LittleEndian, &keysOffset)
+		keys, err = readAppInfoKeys(data, keysOffset)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unknown appinfo.vdf version")
+	}
+
+	// Every app starts with its ID and size, then the state, update time,
+	// token and hashes, then its data as binary VDF.
+	skipped := int64(4 + 4 + 8 + 20 + 4)
+	if header.Magic != appInfoVersion27 {
+		skipped += 20
+	}
+	apps := make(map[string]AppInfo)
+	for {
+		var appID, size uint32
+		if binary.Read(reader, binary.LittleEndian, &appID) != nil || appID == 0 {
+			break
+		}
+		err = binary.Read(reader, binary.LittleEndian, &size)
+		if err != nil || int64(size) < skipped || int64(size) > int64(reader.Len()) {
+			return apps, errors.New("truncated appinfo.vdf")
+		}
+		entry := make([]byte, size)
+		io.ReadFull(reader, entry)
+
+		root := &VDFNode{Type: VDFMap}
+		if readVDFChildren(bytes.NewReader(entry[skipped:]), root, keys) != nil {
+			continue
+		}
+		common := root.Child("appinfo").Child("common")
+		if common == nil {
+			continue
+		}
+		apps[strconv.FormatUint(uint64(appID), 10)] = AppInfo{common.ChildString("name"), common.ChildString("type")}
+	}
+	return apps, nil
+}
@@ -0,0 +1,133 @@
+package steam
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LibraryDirs returns the steamapps directories of every Steam library of the
+// installation a user belongs to: the installation itself and the folders
+// listed in its libraryfolders.vdf.
+func LibraryDirs(user User) ([]string, error) {
+	installationDir := filepath.Dir(filepath.Dir(user.Dir))
+	steamappsDir := filepath.Join(installationDir, "steamapps")
+	if _, err := os.Stat(steamappsDir); err != nil {
+		return nil, errors.New("no steamapps directory at " + installationDir)
+	}
+	dirs := []string{steamappsDir}
+
+	foldersBytes, err := ioutil.ReadFile(filepath.Join(steamappsDir, "libraryfolders.vdf"))
+	if os.IsNotExist(err) {
+		return dirs, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Newer files give each folder as "path" "D:\\Games", older ones as
+	// "1" "D:\\Games". The apps in the newer ones are numbers, not paths.
+	pattern := regexp.MustCompile(`"(?:path|\d+)"\s*"([^"]*[^\d"][^"]*)"`)
+	for _, groups := range pattern.FindAllStringSubmatch(string(foldersBytes), -1) {
+		path := strings.Replace(groups[1], `\\`, `\`, -1)
+		libraryDir := filepath.Join(path, "steamapps")
+		if libraryDir != steamappsDir {
+			dirs = append(dirs, libraryDir)
+		}
+	}
+	return dirs, nil
+}
+
+// InstalledGames returns the appIDs of the games installed in any library, the
+// ones with an appmanifest_<appID>.acf file.
+func InstalledGames(user User) (map[string]bool, error) {
+	dirs, err := LibraryDirs(user)
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]bool)
+	for _, dir := range dirs {
+		manifests, _ := filepath.Glob(filepath.Join(dir, "appmanifest_*.acf"))
+		for _, manifest := range manifests {
+			name := filepath.Base(manifest)
+			installed[strings.TrimSuffix(strings.TrimPrefix(name, "appmanifest_"), ".acf")] = true
+		}
+	}
+	return installed, nil
+}
+
+// SharedGame is a game installed from the library of another account, with
+// Steam Family Sharing.
+type SharedGame struct {
+	AppID string
+	Name  string
+	// SteamID64 of the account that owns it
+	Owner string
+}
+
+// Lenders returns the SteamID64s of the accounts that authorized the
+// installation of a user for Family Library Sharing, from the
+// AuthorizedDevice section of its config/config.vdf. The user isn't one.
+func Lenders(user User) (map[string]bool, error) {
+	installationDir := filepath.Dir(filepath.Dir(user.Dir))
+	configBytes, err := ioutil.ReadFile(filepath.Join(installationDir, "config", "config.vdf"))
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	root, err := ParseTextVDF(configBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	lenders := make(map[string]bool)
+	for _, device := range root.Child("InstallConfigStore").Child("AuthorizedDevice").Children {
+		// Accounts are given by their SteamID32.
+		steamID32, err := strconv.ParseInt(device.Key, 10, 64)
+		if err != nil {
+			continue
+		}
+		steamID64 := strconv.FormatInt(steamID32+idConversionConstant, 10)
+		if steamID64 != user.SteamID64 {
+			lenders[steamID64] = true
+		}
+	}
+	return lenders, nil
+}
+
+// SharedGames returns the installed games lent to the user, the ones whose
+// appmanifest_<appID>.acf names one of its Lenders as their LastOwner. Games
+// other accounts of the computer installed for themselves aren't lent.
+func SharedGames(user User) ([]SharedGame, error) {
+	lenders, err := Lenders(user)
+	if err != nil || len(lenders) == 0 {
+		return nil, err
+	}
+	dirs, err := LibraryDirs(user)
+	if err != nil {
+		return nil, err
+	}
+	var shared []SharedGame
+	for _, dir := range dirs {
+		manifests, _ := filepath.Glob(filepath.Join(dir, "appmanifest_*.acf"))
+		for _, manifest := range manifests {
+			manifestBytes, err := ioutil.ReadFile(manifest)
+			if err != nil {
+				continue
+			}
+			root, err := ParseTextVDF(manifestBytes)
+			if err != nil {
+				continue
+			}
+			app := root.Child("AppState")
+			if owner := app.ChildString("LastOwner"); lenders[owner] {
+				shared = append(shared, SharedGame{AppID: app.ChildString("appid"), Name: app.ChildString("name"), Owner: owner})
+			}
+		}
+	}
+	return shared, nil
+}
@@ -1,19 +1,20 @@
-package main
+// Package steam reads what SteamGrid needs of a local Steam installation: where
+// it is, its users, its libraries and installed games, and the VDF files
+// Steam keeps them in, like shortcuts.vdf and appinfo.vdf. It only reads and
+// writes local files, so other tools can use it on their own.
+package steam
 
 import (
 	"errors"
-	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/user"
 	"path/filepath"
 	"regexp"
 	"strconv"
-	"strings"
 )
 
-// User in the local steam installation.
+// User is a user of the local Steam installation.
 type User struct {
 	Name      string
 	SteamID32 string
@@ -24,33 +25,27 @@ type User struct {
 // Used to convert between SteamId32 and SteamId64.
 const idConversionConstant = 0x110000100000000
 
-// GetUsers given the Steam installation dir (NOT the library!), returns all users in
-// this computer.
+// GetUsers, given the Steam installation dir (NOT the library!), returns all
+// users in this computer. A copy of the userdata directory, or of a single user
+// directory in it, works too, so artwork can be curated on a backup or a
+// mounted Steam Deck image.
 func GetUsers(installationDir string) ([]User, error) {
-	userdataDir := filepath.Join(installationDir, "userdata")
-	files, err := ioutil.ReadDir(userdataDir)
+	userDirs, err := FindUserDirs(installationDir)
 	if err != nil {
 		return nil, err
 	}
 
 	var users []User
 
-	for _, userDir := range files {
-		userID := userDir.Name()
-		userDir := filepath.Join(userdataDir, userID)
+	for _, userDir := range userDirs {
+		userID := filepath.Base(userDir)
 
-		configFile := filepath.Join(userDir, "config", "localconfig.vdf")
-		// Malformed user directory. Without the localconfig file we can't get
-		// the username and the game list, so we skip it.
-		if _, err := os.Stat(configFile); err != nil {
+		// Malformed user directory. Without the config directory there's no
+		// grid to write to, so we skip it.
+		if info, err := os.Stat(filepath.Join(userDir, "config")); err != nil || !info.IsDir() {
 			continue
 		}
 
-		configBytes, err := ioutil.ReadFile(configFile)
-		if err != nil {
-			return nil, err
-		}
-
 		// Makes sure the grid directory exists.
 		gridDir := filepath.Join(userDir, "config", "grid")
 		err = os.MkdirAll(gridDir, 0777)
@@ -61,11 +56,20 @@ func GetUsers(installationDir string) ([]User, error) {
 		// The Linux version of Steam ships with the "grid" dir without executable bit.
 		// This in turn denies permission to everything inside the folder. This line is
 		// here to ensure we have the correct permission.
-		fmt.Println("Setting permission...")
 		os.Chmod(gridDir, 0777)
 
-		pattern := regexp.MustCompile(`"PersonaName"\s*"(.+?)"`)
-		username := pattern.FindStringSubmatch(string(configBytes))[1]
+		// Snapshots may lack the localconfig file, the user is then named by
+		// its ID.
+		username := userID
+		configBytes, err := ioutil.ReadFile(filepath.Join(userDir, "config", "localconfig.vdf"))
+		if err == nil {
+			pattern := regexp.MustCompile(`"PersonaName"\s*"(.+?)"`)
+			if match := pattern.FindStringSubmatch(string(configBytes)); match != nil {
+				username = match[1]
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
 
 		steamID32, _ := strconv.ParseInt(userID, 10, 64)
 		steamID64 := steamID32 + idConversionConstant
@@ -76,41 +80,33 @@ func GetUsers(installationDir string) ([]User, error) {
 	return users, nil
 }
 
-// URL to get the game list from the SteamId64.
-const profilePermalinkFormat = `http://steamcommunity.com/profiles/%v/games?tab=all`
-
-// The Steam website has the terrible habit of returning 200 OK when requests
-// fail, and signaling the error in HTML. So we have to parse the request to
-// check if it has failed, and cross our fingers that they don't change the
-// message.
-const steamProfileErrorMessage = `The specified profile could not be found.`
-
-// GetProfile returns the HTML profile from a user from their SteamId32.
-func GetProfile(user User) (string, error) {
-	response, err := http.Get(fmt.Sprintf(profilePermalinkFormat, user.SteamID64))
-	if err != nil {
-		return "", err
-	}
-
-	if response.StatusCode >= 400 {
-		return "", errors.New("profile not found. Make sure you have a public Steam profile")
+// FindUserDirs returns the user directories under dir, which is a Steam
+// installation, a userdata directory or a single user directory.
+func FindUserDirs(dir string) ([]string, error) {
+	// Steam installations have a config directory too, so userdata is
+	// checked first.
+	userdataDir := filepath.Join(dir, "userdata")
+	if _, err := os.Stat(userdataDir); err != nil {
+		if _, err := os.Stat(filepath.Join(dir, "config")); err == nil {
+			return []string{dir}, nil
+		}
+		userdataDir = dir
 	}
-
-	contentBytes, err := ioutil.ReadAll(response.Body)
-	response.Body.Close()
+	files, err := ioutil.ReadDir(userdataDir)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	profile := string(contentBytes)
-	if strings.Contains(profile, steamProfileErrorMessage) {
-		return "", errors.New("profile not found")
+	var userDirs []string
+	for _, file := range files {
+		if file.IsDir() {
+			userDirs = append(userDirs, filepath.Join(userdataDir, file.Name()))
+		}
 	}
-
-	return profile, nil
+	return userDirs, nil
 }
 
-// GetSteamInstallation Returns the Steam installation directory in Windows. Should work for
+// GetSteamInstallation returns the Steam installation directory in Windows. Should work for
 // internationalized systems, 32 and 64 bits and users that moved their
 // ProgramFiles folder. If a folder is given by program parameter, uses that.
 func GetSteamInstallation(steamDir string) (path string, err error) {
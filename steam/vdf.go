@@ -0,0 +1,279 @@
+package steam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+)
+
+// Types of values in Valve's binary KeyValues format, used by shortcuts.vdf
+// and appinfo.vdf, as in VDFNode.Type.
+const (
+	VDFMap    = 0x00
+	VDFString = 0x01
+	VDFInt32  = 0x02
+	VDFFloat  = 0x03
+	VDFUint64 = 0x07
+	VDFEnd    = 0x08
+	VDFInt64  = 0x0A
+)
+
+// VDFNode is a key in a VDF file, with either a value or children.
+type VDFNode struct {
+	Key      string
+	Type     byte
+	String   string
+	Int      uint64
+	Float    float32
+	Children []*VDFNode
+}
+
+// Child returns the child with the given key, ignoring case as Steam does,
+// or nil.
+func (node *VDFNode) Child(key string) *VDFNode {
+	if node == nil {
+		return nil
+	}
+	for _, child := range node.Children {
+		if strings.EqualFold(child.Key, key) {
+			return child
+		}
+	}
+	return nil
+}
+
+// ChildString returns the string value of a child, or "" when it's missing.
+func (node *VDFNode) ChildString(key string) string {
+	if child := node.Child(key); child != nil {
+		return child.String
+	}
+	return ""
+}
+
+// ChildInt returns the integer value of a child and whether it's there.
+func (node *VDFNode) ChildInt(key string) (uint64, bool) {
+	child := node.Child(key)
+	if child == nil || (child.Type != VDFInt32 && child.Type != VDFUint64 && child.Type != VDFInt64) {
+		return 0, false
+	}
+	return child.Int, true
+}
+
+// ParseBinaryVDF reads a binary VDF file, like shortcuts.vdf, into a root
+// node whose children are its top level keys.
+func ParseBinaryVDF(data []byte) (*VDFNode, error) {
+	reader := bytes.NewReader(data)
+	root := &VDFNode{Type: VDFMap}
+	err := readVDFChildren(reader, root, nil)
+	return root, err
+}
+
+func readVDFString(reader *bytes.Reader) (string, error) {
+	var buf []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", errors.New("unterminated string in VDF file")
+		}
+		if b == 0 {
+			return string(buf), nil
+		}
+		buf = append(buf, b)
+	}
+}
+
+// Reads the children of a map up to its end. Keys are written in place,
+// or with a table of keys as their index in it, like in newer appinfo.vdf
+// files.
+func readVDFChildren(reader *bytes.Reader, parent *VDFNode, keys []string) error {
+	for {
+		kind, err := reader.ReadByte()
+		if err != nil {
+			// Files may end without closing the root.
+			if parent.Key == "" {
+				return nil
+			}
+			return errors.New("truncated VDF file")
+		}
+		if kind == VDFEnd {
+			return nil
+		}
+
+		var key string
+		if keys == nil {
+			key, err = readVDFString(reader)
+		} else {
+			var index uint32
+			err = binary.Read(reader, binary.LittleEndian, &index)
+			if err == nil && int(index) >= len(keys) {
+				err = errors.New("unknown key in VDF file")
+			} else if err == nil {
+				key = keys[index]
+			}
+		}
+		if err != nil {
+			return err
+		}
+		node := &VDFNode{Key: key, Type: kind}
+		parent.Children = append(parent.Children, node)
+
+		switch kind {
+		case VDFMap:
+			err = readVDFChildren(reader, node, keys)
+		case VDFString:
+			node.String, err = readVDFString(reader)
+		case VDFInt32:
+			var value uint32
+			err = binary.Read(reader, binary.LittleEndian, &value)
+			node.Int = uint64(value)
+		case VDFFloat:
+			var value uint32
+			err = binary.Read(reader, binary.LittleEndian, &value)
+			node.Float = math.Float32frombits(value)
+		case VDFUint64, VDFInt64:
+			err = binary.Read(reader, binary.LittleEndian, &node.Int)
+		default:
+			return errors.New("unknown value type in VDF file")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ParseTextVDF reads a text VDF file, like localconfig.vdf or
+// libraryfolders.vdf, into a root node whose children are its top level keys.
+// Values are strings.
+func ParseTextVDF(data []byte) (*VDFNode, error) {
+	text := string(data)
+	i := 0
+	// Returns the next string, "{" or "}", or "" at the end.
+	next := func() (string, bool, error) {
+		for i < len(text) {
+			if c := text[i]; c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+				i++
+			} else if strings.HasPrefix(text[i:], "//") {
+				for i < len(text) && text[i] != '\n' {
+					i++
+				}
+			} else {
+				break
+			}
+		}
+		if i >= len(text) {
+			return "", false, nil
+		}
+		switch text[i] {
+		case '{', '}':
+			i++
+			return text[i-1 : i], false, nil
+		case '"':
+			var token strings.Builder
+			for i++; i < len(text) && text[i] != '"'; i++ {
+				if text[i] == '\\' && i+1 < len(text) {
+					i++
+					switch text[i] {
+					case 'n':
+						token.WriteByte('\n')
+					case 't':
+						token.WriteByte('\t')
+					default:
+						token.WriteByte(text[i])
+					}
+					continue
+				}
+				token.WriteByte(text[i])
+			}
+			if i >= len(text) {
+				return "", false, errors.New("unterminated string in VDF file")
+			}
+			i++
+			return token.String(), true, nil
+		}
+		start := i
+		for i < len(text) && !strings.ContainsRune(" \t\r\n{}\"", rune(text[i])) {
+			i++
+		}
+		return text[start:i], true, nil
+	}
+
+	root := &VDFNode{Type: VDFMap}
+	stack := []*VDFNode{root}
+	for {
+		key, isString, err := next()
+		if err != nil {
+			return root, err
+		}
+		if key == "" && !isString {
+			if len(stack) > 1 {
+				return root, errors.New("unterminated block in VDF file")
+			}
+			return root, nil
+		}
+		parent := stack[len(stack)-1]
+		if key == "}" && !isString {
+			if len(stack) == 1 {
+				return root, errors.New("unexpected } in VDF file")
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		value, isString, err := next()
+		if err != nil {
+			return root, err
+		}
+		if value == "{" && !isString {
+			node := &VDFNode{Key: key, Type: VDFMap}
+			parent.Children = append(parent.Children, node)
+			stack = append(stack, node)
+		} else if isString {
+			parent.Children = append(parent.Children, &VDFNode{Key: key, Type: VDFString, String: value})
+		} else {
+			return root, errors.New("missing value for " + key + " in VDF file")
+		}
+	}
+}
+
+// SetString sets the string value of a child, adding it when it's missing.
+func (node *VDFNode) SetString(key string, value string) {
+	if child := node.Child(key); child != nil {
+		child.Type = VDFString
+		child.String = value
+		return
+	}
+	node.Children = append(node.Children, &VDFNode{Key: key, Type: VDFString, String: value})
+}
+
+// WriteBinaryVDF encodes a root node from ParseBinaryVDF back into the binary
+// format.
+func WriteBinaryVDF(root *VDFNode) []byte {
+	buf := new(bytes.Buffer)
+	writeVDFChildren(buf, root)
+	return buf.Bytes()
+}
+
+func writeVDFChildren(buf *bytes.Buffer, parent *VDFNode) {
+	for _, node := range parent.Children {
+		buf.WriteByte(node.Type)
+		buf.WriteString(node.Key)
+		buf.WriteByte(0)
+
+		switch node.Type {
+		case VDFMap:
+			writeVDFChildren(buf, node)
+		case VDFString:
+			buf.WriteString(node.String)
+			buf.WriteByte(0)
+		case VDFInt32:
+			binary.Write(buf, binary.LittleEndian, uint32(node.Int))
+		case VDFFloat:
+			binary.Write(buf, binary.LittleEndian, math.Float32bits(node.Float))
+		case VDFUint64, VDFInt64:
+			binary.Write(buf, binary.LittleEndian, node.Int)
+		}
+	}
+	buf.WriteByte(VDFEnd)
+}
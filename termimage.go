@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/png"
+	"os"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Protocols the tui command shows thumbnails with, by the name of -images.
+// Each encodes an image scaled to fit a number of columns and rows of text,
+// to be written where the cursor is.
+var terminalImageProtocols = map[string]func(img image.Image, cols int, rows int) string{
+	"kitty": kittyImage,
+	"iterm": itermImage,
+	"sixel": sixelImage,
+}
+
+// Size of a character cell in pixels, roughly, as terminals don't tell.
+const (
+	terminalCellWidth  = 8
+	terminalCellHeight = 16
+)
+
+// Guesses the image protocol of the terminal from its environment, or "" when
+// it probably has none.
+func detectTerminalImageProtocol() string {
+	term := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty" || program == "ghostty":
+		return "kitty"
+	case program == "iTerm.app" || program == "WezTerm":
+		return "iterm"
+	case strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm") || strings.Contains(term, "sixel"):
+		return "sixel"
+	}
+	return ""
+}
+
+// Scales an image down to fit in a number of columns and rows of text,
+// keeping its aspect ratio. Returns the columns and rows it takes.
+func fitImage(img image.Image, cols int, rows int) (*image.RGBA, int, int) {
+	size := img.Bounds().Size()
+	width, height := cols*terminalCellWidth, rows*terminalCellHeight
+	if size.X*height > size.Y*width {
+		height = size.Y * width / size.X
+	} else {
+		width = size.X * height / size.Y
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return scaled, (width + terminalCellWidth - 1) / terminalCellWidth, (height + terminalCellHeight - 1) / terminalCellHeight
+}
+
+func encodePNGBase64(img image.Image) string {
+	var buffer bytes.Buffer
+	png.Encode(&buffer, img)
+	return base64.StdEncoding.EncodeToString(buffer.Bytes())
+}
+
+// https://sw.kovidgoyal.net/kitty/graphics-protocol/, also understood by
+// Ghostty and WezTerm. The PNG is sent in chunks of at most 4096 bytes.
+func kittyImage(img image.Image, cols int, rows int) string {
+	scaled, cols, rows := fitImage(img, cols, rows)
+	data := encodePNGBase64(scaled)
+	var out strings.Builder
+	for start := 0; start < len(data); start += 4096 {
+		end := start + 4096
+		more := 1
+		if end >= len(data) {
+			end = len(data)
+			more = 0
+		}
+		if start == 0 {
+			fmt.Fprintf(&out, "\x1b_Ga=T,f=100,q=2,c=%v,r=%v,m=%v;%v\x1b\\", cols, rows, more, data[start:end])
+		} else {
+			fmt.Fprintf(&out, "\x1b_Gm=%v;%v\x1b\\", more, data[start:end])
+		}
+	}
+	return out.String()
+}
+
+// https://iterm2.com/documentation-images.html, also understood by WezTerm.
+func itermImage(img image.Image, cols int, rows int) string {
+	scaled, cols, rows := fitImage(img, cols, rows)
+	data := encodePNGBase64(scaled)
+	return fmt.Sprintf("\x1b]1337;File=inline=1;width=%v;height=%v;preserveAspectRatio=1:%v\a", cols, rows, data)
+}
+
+// Sixels, of xterm, foot and mlterm, with the 216 web safe colors: each line
+// of characters draws 6 rows of pixels, one color at a time.
+func sixelImage(img image.Image, cols int, rows int) string {
+	scaled, _, _ := fitImage(img, cols, rows)
+	bounds := scaled.Bounds()
+	paletted := image.NewPaletted(bounds, palette.WebSafe)
+	draw.FloydSteinberg.Draw(paletted, bounds, scaled, bounds.Min)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "\x1bPq\"1;1;%v;%v", bounds.Dx(), bounds.Dy())
+	for i, c := range palette.WebSafe {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(&out, "#%v;2;%v;%v;%v", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+	for top := 0; top < bounds.Dy(); top += 6 {
+		used := map[uint8]bool{}
+		for y := top; y < top+6 && y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				used[paletted.ColorIndexAt(x, y)] = true
+			}
+		}
+		for index := range used {
+			fmt.Fprintf(&out, "#%v", index)
+			// Runs of the same character are written as !<count><character>.
+			var last byte
+			count := 0
+			flush := func() {
+				if count > 3 {
+					fmt.Fprintf(&out, "!%v%c", count, last)
+				} else {
+					out.WriteString(strings.Repeat(string(last), count))
+				}
+			}
+			for x := 0; x < bounds.Dx(); x++ {
+				var bits byte
+				for bit := 0; bit < 6 && top+bit < bounds.Dy(); bit++ {
+					if paletted.ColorIndexAt(x, top+bit) == index {
+						bits |= 1 << uint(bit)
+					}
+				}
+				character := 63 + bits
+				if character == last {
+					count++
+					continue
+				}
+				flush()
+				last, count = character, 1
+			}
+			flush()
+			// Back to the start of the line, for the next color.
+			out.WriteByte('$')
+		}
+		out.WriteByte('-')
+	}
+	out.WriteString("\x1b\\")
+	return out.String()
+}
+
+// Removes the images shown with a protocol, as clearing the screen leaves
+// kitty's in place.
+func clearTerminalImages(protocol string) string {
+	if protocol == "kitty" {
+		return "\x1b_Ga=d,q=2\x1b\\"
+	}
+	return ""
+}
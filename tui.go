@@ -0,0 +1,808 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/sources"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// The tui command shows the library of a user in the terminal, with the
+// status of each art style, and downloads, restores or picks the artwork of
+// games with a few keys. It works through the same operations as the serve
+// command. What the runs print goes to the last line of the screen.
+
+// Art styles in the order they're shown, with the letter for each.
+var tuiArtStyles = []string{"Banner", "Cover", "Hero", "Logo", "Icon"}
+
+// Colors of the art style letters by status.
+var tuiStatusColors = map[string]string{
+	"original": "\x1b[32m",
+	"overlay":  "\x1b[33m",
+	"missing":  "\x1b[31m",
+}
+
+type terminalUI struct {
+	server *artworkServer
+	// The terminal, as os.Stdout is where the runs print to while the tui
+	// is open.
+	tty      *os.File
+	protocol string
+	rows     int
+	cols     int
+
+	users    []artwork.User
+	user     int
+	games    []servedGame
+	filter   string
+	editing  bool
+	selected int
+	top      int
+
+	// The game open in the picker, the art style picked and its SteamGridDB
+	// candidates, with their thumbnails encoded for the terminal.
+	picking    *servedGame
+	style      string
+	candidates []servedCandidate
+	candidate  int
+	thumbs     map[int]string
+
+	force bool
+	// What runs in the background, how far it got, and whether it was asked
+	// to stop.
+	busy      string
+	progress  int
+	total     int
+	cancelled int32
+	message   string
+	results   chan func()
+}
+
+func tuiCommand(args []string) {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	options := &artwork.Options{}
+	registerRunFlags(flags, options)
+	images := flags.String("images", "auto", "How thumbnails of candidates are shown: kitty, iterm, sixel, none, or auto to guess from the terminal")
+	parseOptions(flags, options, args)
+
+	protocol := strings.ToLower(*images)
+	if protocol == "auto" {
+		protocol = detectTerminalImageProtocol()
+	} else if protocol == "none" {
+		protocol = ""
+	} else if _, ok := terminalImageProtocols[protocol]; !ok {
+		errorAndExit(errors.New("unknown image protocol " + *images + ", expected kitty, iterm, sixel, none or auto"))
+	}
+	if runtime.GOOS == "windows" || !stdinIsTerminal() {
+		errorAndExit(errors.New("the tui command needs a Unix terminal, use serve for a web page instead"))
+	}
+
+	ui := &terminalUI{server: newArtworkServer(options), tty: os.Stdout, protocol: protocol, thumbs: map[int]string{}, results: make(chan func(), 16)}
+	var err error
+	ui.users, err = steam.GetUsers(artwork.SteamInstallationDir)
+	if err != nil {
+		errorAndExit(err)
+	}
+	if len(ui.users) == 0 {
+		errorAndExit(errors.New("no users found at Steam/userdata"))
+	}
+	err = ui.run()
+	if err != nil {
+		errorAndExit(err)
+	}
+}
+
+// Runs a stty command on the terminal, returning what it printed.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// Reads the keys pressed, naming the special ones like "up" or "enter".
+func readKeys(keys chan<- string) {
+	buffer := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buffer)
+		if err != nil {
+			close(keys)
+			return
+		}
+		input := string(buffer[:n])
+		switch input {
+		case "\x1b[A", "\x1bOA":
+			keys <- "up"
+		case "\x1b[B", "\x1bOB":
+			keys <- "down"
+		case "\x1b[C", "\x1bOC":
+			keys <- "right"
+		case "\x1b[D", "\x1bOD":
+			keys <- "left"
+		case "\x1b[5~":
+			keys <- "pgup"
+		case "\x1b[6~":
+			keys <- "pgdown"
+		case "\x1b":
+			keys <- "esc"
+		default:
+			if strings.HasPrefix(input, "\x1b") {
+				continue
+			}
+			for _, r := range input {
+				switch r {
+				case '\r', '\n':
+					keys <- "enter"
+				case 127, 8:
+					keys <- "backspace"
+				case 3:
+					keys <- "ctrl+c"
+				default:
+					keys <- string(r)
+				}
+			}
+		}
+	}
+}
+
+// Shows the interface until it's quit, with the terminal in raw mode and
+// what the runs print on the last line.
+func (ui *terminalUI) run() error {
+	saved, err := stty("-g")
+	if err != nil {
+		return errors.New("could not set up the terminal: " + err.Error())
+	}
+	_, err = stty("raw", "-echo")
+	if err != nil {
+		return errors.New("could not set up the terminal: " + err.Error())
+	}
+	logReader, logWriter, err := os.Pipe()
+	if err != nil {
+		stty(saved)
+		return err
+	}
+	// Alternate screen, without the cursor.
+	fmt.Fprint(ui.tty, "\x1b[?1049h\x1b[?25l")
+	os.Stdout = logWriter
+	defer func() {
+		os.Stdout = ui.tty
+		logWriter.Close()
+		fmt.Fprint(ui.tty, clearTerminalImages(ui.protocol)+"\x1b[?25h\x1b[?1049l")
+		stty(saved)
+	}()
+
+	logs := make(chan string, 64)
+	go func() {
+		scanner := bufio.NewScanner(logReader)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			// Runs print a lot, only the last line matters.
+			select {
+			case logs <- line:
+			default:
+				select {
+				case <-logs:
+				default:
+				}
+				select {
+				case logs <- line:
+				default:
+				}
+			}
+		}
+	}()
+	keys := make(chan string)
+	go readKeys(keys)
+
+	ui.loadGames()
+	for {
+		ui.draw()
+		select {
+		case key, ok := <-keys:
+			if !ok || !ui.handleKey(key) {
+				return nil
+			}
+		case line := <-logs:
+			// What's printed after an operation is done would hide its result.
+			if ui.busy != "" {
+				ui.message = line
+			}
+		case done := <-ui.results:
+			done()
+		}
+	}
+}
+
+// Runs an operation in the background, one at a time. It returns what to
+// change once it's done, which is run along with the key presses.
+func (ui *terminalUI) start(what string, operation func() func()) {
+	if ui.busy != "" {
+		ui.message = ui.busy + ", wait for it to finish"
+		return
+	}
+	ui.busy = what
+	ui.message = what + "..."
+	atomic.StoreInt32(&ui.cancelled, 0)
+	go func() {
+		done := operation()
+		ui.results <- func() {
+			ui.busy = ""
+			ui.progress, ui.total = 0, 0
+			if done != nil {
+				done()
+			}
+		}
+	}()
+}
+
+// Message of a failed operation.
+func (ui *terminalUI) failed(err error) func() {
+	return func() {
+		ui.message = "Failed: " + err.Error()
+	}
+}
+
+func (ui *terminalUI) loadGames() {
+	user := ui.users[ui.user]
+	ui.start("Loading the games of "+user.Name, func() func() {
+		games, err := ui.server.games(user.SteamID32)
+		if err != nil {
+			return ui.failed(err)
+		}
+		return func() {
+			ui.games = games
+			ui.selected, ui.top = 0, 0
+			ui.message = fmt.Sprintf("%v games", len(games))
+		}
+	})
+}
+
+// Games matching the filter.
+func (ui *terminalUI) visibleGames() []*servedGame {
+	var visible []*servedGame
+	filter := strings.ToLower(ui.filter)
+	for i := range ui.games {
+		if filter == "" || strings.Contains(strings.ToLower(ui.games[i].Name), filter) || strings.Contains(ui.games[i].ID, filter) {
+			visible = append(visible, &ui.games[i])
+		}
+	}
+	return visible
+}
+
+// Reads the statuses of the artwork of a game again, after it changed.
+func (ui *terminalUI) refreshGame(game *servedGame) {
+	gridDir := filepath.Join(ui.users[ui.user].Dir, "config", "grid")
+	for artStyle := range game.Artwork {
+		game.Artwork[artStyle] = artwork.GridImageStatus(gridDir, game.ID, ui.server.artStyles[artStyle])
+	}
+}
+
+// Describes what happened to the images of a game.
+func describeEntries(name string, entries []*artwork.ReportEntry) string {
+	var statuses []string
+	for _, entry := range entries {
+		statuses = append(statuses, entry.ArtStyle+" "+entry.Status)
+	}
+	return name + ": " + strings.Join(statuses, ", ")
+}
+
+func (ui *terminalUI) download(game *servedGame, style string) {
+	userID := ui.users[ui.user].SteamID32
+	force := ui.force
+	ui.start("Downloading the artwork of "+gameTitle(game), func() func() {
+		entries, err := ui.server.download(userID, game.ID, style, force)
+		if err != nil {
+			return ui.failed(err)
+		}
+		return func() {
+			ui.refreshGame(game)
+			ui.message = describeEntries(gameTitle(game), entries)
+		}
+	})
+}
+
+// Downloads the artwork of every game missing some, with a progress bar.
+func (ui *terminalUI) downloadMissing() {
+	var missing []*servedGame
+	for _, game := range ui.visibleGames() {
+		for _, status := range game.Artwork {
+			if status == "missing" {
+				missing = append(missing, game)
+				break
+			}
+		}
+	}
+	if len(missing) == 0 {
+		ui.message = "No artwork missing"
+		return
+	}
+	userID := ui.users[ui.user].SteamID32
+	force := ui.force
+	ui.start(fmt.Sprintf("Downloading the artwork of %v games", len(missing)), func() func() {
+		ui.results <- func() { ui.total = len(missing) }
+		nDownloaded := 0
+		for i, game := range missing {
+			if atomic.LoadInt32(&ui.cancelled) == 1 {
+				break
+			}
+			entries, err := ui.server.download(userID, game.ID, "", force)
+			if err != nil {
+				return ui.failed(err)
+			}
+			for _, entry := range entries {
+				if entry.Status == "downloaded" {
+					nDownloaded++
+				}
+			}
+			done := i + 1
+			ui.results <- func() {
+				ui.progress = done
+				ui.refreshGame(game)
+			}
+		}
+		return func() {
+			ui.message = fmt.Sprintf("%v images downloaded", nDownloaded)
+			if atomic.LoadInt32(&ui.cancelled) == 1 {
+				ui.message += ", stopped"
+			}
+		}
+	})
+}
+
+func (ui *terminalUI) restore(game *servedGame) {
+	userID := ui.users[ui.user].SteamID32
+	force := ui.force
+	ui.start("Restoring the artwork of "+gameTitle(game), func() func() {
+		restored, err := ui.server.restore(userID, game.ID, force)
+		if err != nil {
+			return ui.failed(err)
+		}
+		return func() {
+			ui.refreshGame(game)
+			ui.message = fmt.Sprintf("%v: %v images restored", gameTitle(game), restored.Restored)
+		}
+	})
+}
+
+// Opens the picker on an art style of a game and looks for its candidates.
+func (ui *terminalUI) pick(game *servedGame, style string) {
+	ui.picking, ui.style = game, style
+	ui.candidates, ui.candidate = nil, 0
+	userID := ui.users[ui.user].SteamID32
+	ui.start("Looking for "+strings.ToLower(style)+" candidates", func() func() {
+		candidates, err := ui.server.candidates(userID, game.ID, style)
+		if err != nil {
+			return ui.failed(err)
+		}
+		return func() {
+			if ui.picking != game || ui.style != style {
+				return
+			}
+			ui.candidates = candidates
+			ui.message = fmt.Sprintf("%v candidates on SteamGridDB", len(candidates))
+			ui.loadThumb()
+		}
+	})
+}
+
+// Downloads the thumbnail of the selected candidate, when the terminal can
+// show it.
+func (ui *terminalUI) loadThumb() {
+	if ui.protocol == "" || ui.candidate >= len(ui.candidates) || ui.busy != "" {
+		return
+	}
+	candidate := ui.candidates[ui.candidate]
+	if _, ok := ui.thumbs[candidate.SteamGridDBID]; ok {
+		return
+	}
+	if candidate.Animated {
+		ui.thumbs[candidate.SteamGridDBID] = ""
+		return
+	}
+	cols, rows := ui.thumbSize()
+	encode := terminalImageProtocols[ui.protocol]
+	ui.start("Loading the thumbnail", func() func() {
+		thumb := ""
+		response, err := sources.TryDownload(candidate.Thumb)
+		if err == nil && response != nil {
+			var imageBytes []byte
+			imageBytes, err = ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			if err == nil {
+				var img image.Image
+				img, _, err = image.Decode(bytes.NewReader(imageBytes))
+				if err == nil {
+					thumb = encode(img, cols, rows)
+				}
+			}
+		}
+		return func() {
+			ui.thumbs[candidate.SteamGridDBID] = thumb
+			ui.message = ""
+			if err != nil {
+				ui.message = "No thumbnail: " + err.Error()
+			}
+			// The selection may have moved on meanwhile.
+			ui.loadThumb()
+		}
+	})
+}
+
+func (ui *terminalUI) apply(game *servedGame, candidate servedCandidate) {
+	userID := ui.users[ui.user].SteamID32
+	style := ui.style
+	ui.start(fmt.Sprintf("Applying SteamGridDB image %v", candidate.SteamGridDBID), func() func() {
+		entries, err := ui.server.apply(userID, game.ID, style, fmt.Sprint(candidate.SteamGridDBID))
+		if err != nil {
+			return ui.failed(err)
+		}
+		return func() {
+			ui.refreshGame(game)
+			ui.message = describeEntries(gameTitle(game), entries)
+			ui.picking = nil
+		}
+	})
+}
+
+func gameTitle(game *servedGame) string {
+	if game.Name != "" {
+		return game.Name
+	}
+	return "game " + game.ID
+}
+
+// Art styles the picker can switch between for a game.
+func (ui *terminalUI) gameStyles(game *servedGame) []string {
+	var styles []string
+	if game == nil {
+		return nil
+	}
+	for _, artStyle := range tuiArtStyles {
+		if _, ok := game.Artwork[artStyle]; ok {
+			styles = append(styles, artStyle)
+		}
+	}
+	return styles
+}
+
+// Handles a key, returning false to quit.
+func (ui *terminalUI) handleKey(key string) bool {
+	if key == "ctrl+c" {
+		return false
+	}
+	if ui.editing {
+		switch key {
+		case "enter", "esc":
+			ui.editing = false
+		case "backspace":
+			if ui.filter != "" {
+				_, size := utf8.DecodeLastRuneInString(ui.filter)
+				ui.filter = ui.filter[:len(ui.filter)-size]
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				ui.filter += key
+			}
+		}
+		ui.selected, ui.top = 0, 0
+		return true
+	}
+	if ui.picking != nil {
+		return ui.handlePickerKey(key)
+	}
+
+	visible := ui.visibleGames()
+	var game *servedGame
+	if ui.selected < len(visible) {
+		game = visible[ui.selected]
+	}
+	switch key {
+	case "q":
+		if ui.busy != "" && ui.total > 0 && atomic.LoadInt32(&ui.cancelled) == 0 {
+			atomic.StoreInt32(&ui.cancelled, 1)
+			ui.message = "Stopping after the current game, q again to quit"
+			return true
+		}
+		return false
+	case "esc":
+		if ui.busy != "" && ui.total > 0 {
+			atomic.StoreInt32(&ui.cancelled, 1)
+			ui.message = "Stopping after the current game"
+		} else {
+			ui.filter = ""
+		}
+	case "up", "k":
+		ui.selected--
+	case "down", "j":
+		ui.selected++
+	case "pgup":
+		ui.selected -= ui.listRows()
+	case "pgdown":
+		ui.selected += ui.listRows()
+	case "/":
+		ui.editing = true
+	case "f":
+		ui.force = !ui.force
+	case "u":
+		if ui.busy == "" && len(ui.users) > 1 {
+			ui.user = (ui.user + 1) % len(ui.users)
+			ui.games = nil
+			ui.loadGames()
+		}
+	case "D":
+		ui.downloadMissing()
+	case "d":
+		if game != nil {
+			ui.download(game, "")
+		}
+	case "r":
+		if game != nil {
+			ui.restore(game)
+		}
+	case "enter":
+		if styles := ui.gameStyles(game); len(styles) > 0 && ui.busy == "" {
+			style := styles[0]
+			if _, ok := game.Artwork["Cover"]; ok {
+				style = "Cover"
+			}
+			ui.pick(game, style)
+		}
+	}
+	if ui.selected >= len(visible) {
+		ui.selected = len(visible) - 1
+	}
+	if ui.selected < 0 {
+		ui.selected = 0
+	}
+	return true
+}
+
+func (ui *terminalUI) handlePickerKey(key string) bool {
+	game := ui.picking
+	switch key {
+	case "q":
+		return false
+	case "esc", "backspace":
+		if ui.busy == "" {
+			ui.picking = nil
+		}
+	case "up", "k":
+		if ui.candidate > 0 {
+			ui.candidate--
+			ui.loadThumb()
+		}
+	case "down", "j":
+		if ui.candidate < len(ui.candidates)-1 {
+			ui.candidate++
+			ui.loadThumb()
+		}
+	case "left", "right", "h", "l":
+		if ui.busy != "" {
+			break
+		}
+		styles := ui.gameStyles(game)
+		i := 0
+		for j, artStyle := range styles {
+			if artStyle == ui.style {
+				i = j
+			}
+		}
+		if key == "left" || key == "h" {
+			i = (i + len(styles) - 1) % len(styles)
+		} else {
+			i = (i + 1) % len(styles)
+		}
+		ui.pick(game, styles[i])
+	case "f":
+		ui.force = !ui.force
+	case "d":
+		ui.download(game, ui.style)
+	case "r":
+		ui.restore(game)
+	case "enter":
+		if ui.candidate < len(ui.candidates) {
+			ui.apply(game, ui.candidates[ui.candidate])
+		}
+	}
+	return true
+}
+
+// Rows of the list of games, between the header and the footer.
+func (ui *terminalUI) listRows() int {
+	if ui.rows < 6 {
+		return 1
+	}
+	return ui.rows - 5
+}
+
+// Columns and rows thumbnails are shown in, right of the candidates.
+func (ui *terminalUI) thumbSize() (int, int) {
+	cols := ui.cols - ui.candidateWidth() - 2
+	if cols < 1 {
+		cols = 1
+	}
+	return cols, ui.listRows()
+}
+
+func (ui *terminalUI) candidateWidth() int {
+	if ui.protocol == "" {
+		return ui.cols
+	}
+	return ui.cols / 2
+}
+
+// Cuts or pads text to a number of columns.
+func fitText(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	if len(runes) > width {
+		if width == 1 {
+			return "…"
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	return text + strings.Repeat(" ", width-len(runes))
+}
+
+// A bar of a number of columns filled to a share of done.
+func progressBar(done int, total int, width int) string {
+	if total <= 0 || width <= 0 {
+		return ""
+	}
+	filled := width * done / total
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+// Draws the whole screen again.
+func (ui *terminalUI) draw() {
+	if size, err := stty("size"); err == nil {
+		fmt.Sscanf(size, "%d %d", &ui.rows, &ui.cols)
+	}
+	if ui.rows == 0 || ui.cols == 0 {
+		ui.rows, ui.cols = 24, 80
+	}
+
+	var screen strings.Builder
+	screen.WriteString(clearTerminalImages(ui.protocol) + "\x1b[H\x1b[2J")
+	line := func(row int, text string) {
+		fmt.Fprintf(&screen, "\x1b[%d;1H%v", row+1, text)
+	}
+
+	if ui.picking != nil {
+		ui.drawPicker(line, &screen)
+	} else {
+		ui.drawGames(line)
+	}
+
+	// Footer: progress, last message and keys.
+	status := ui.message
+	if ui.busy != "" && ui.total > 0 {
+		bar := progressBar(ui.progress, ui.total, 30)
+		line(ui.rows-3, fmt.Sprintf("%v %v/%v", bar, ui.progress, ui.total))
+	}
+	if ui.busy != "" && status == "" {
+		status = ui.busy + "..."
+	}
+	line(ui.rows-2, fitText(status, ui.cols))
+	keys := "↑↓ move  / filter  enter pick  d download  D download missing  r restore  u user  f force  q quit"
+	if ui.picking != nil {
+		keys = "↑↓ candidate  ←→ art style  enter apply  d download  r restore  f force  esc back  q quit"
+	}
+	if ui.force {
+		keys = "[force] " + keys
+	}
+	line(ui.rows-1, "\x1b[7m"+fitText(keys, ui.cols)+"\x1b[0m")
+	fmt.Fprint(ui.tty, screen.String())
+}
+
+func (ui *terminalUI) drawGames(line func(row int, text string)) {
+	user := ui.users[ui.user]
+	visible := ui.visibleGames()
+	nMissing := 0
+	for _, game := range visible {
+		for _, status := range game.Artwork {
+			if status == "missing" {
+				nMissing++
+				break
+			}
+		}
+	}
+	header := fmt.Sprintf("SteamGrid  %v (%v)  %v games, %v missing artwork", user.Name, user.SteamID32, len(visible), nMissing)
+	if ui.filter != "" || ui.editing {
+		header += "  filter: " + ui.filter
+		if ui.editing {
+			header += "_"
+		}
+	}
+	line(0, "\x1b[1m"+fitText(header, ui.cols)+"\x1b[0m")
+
+	rows := ui.listRows()
+	if ui.selected < ui.top {
+		ui.top = ui.selected
+	} else if ui.selected >= ui.top+rows {
+		ui.top = ui.selected - rows + 1
+	}
+	nameWidth := ui.cols - 2 - 2*len(tuiArtStyles) - 1
+	for i := ui.top; i < len(visible) && i < ui.top+rows; i++ {
+		game := visible[i]
+		var statuses strings.Builder
+		for _, artStyle := range tuiArtStyles {
+			status, ok := game.Artwork[artStyle]
+			if !ok {
+				statuses.WriteString("  ")
+				continue
+			}
+			fmt.Fprintf(&statuses, " %v%v\x1b[39m", tuiStatusColors[status], artStyle[:1])
+		}
+		text := fitText(gameTitle(game), nameWidth)
+		if i == ui.selected {
+			text = "\x1b[7m> " + text + "\x1b[27m"
+		} else {
+			text = "  " + text
+		}
+		line(1+i-ui.top, text+" "+statuses.String())
+	}
+}
+
+func (ui *terminalUI) drawPicker(line func(row int, text string), screen *strings.Builder) {
+	game := ui.picking
+	var tabs []string
+	for _, artStyle := range ui.gameStyles(game) {
+		tab := tuiStatusColors[game.Artwork[artStyle]] + artStyle + "\x1b[39m"
+		if artStyle == ui.style {
+			tab = "\x1b[7m" + tab + "\x1b[27m"
+		}
+		tabs = append(tabs, tab)
+	}
+	line(0, "\x1b[1m"+fitText(gameTitle(game), ui.cols/2)+"\x1b[0m  "+strings.Join(tabs, " "))
+
+	rows := ui.listRows()
+	width := ui.candidateWidth()
+	top := 0
+	if ui.candidate >= rows {
+		top = ui.candidate - rows + 1
+	}
+	for i := top; i < len(ui.candidates) && i < top+rows; i++ {
+		candidate := ui.candidates[i]
+		text := fmt.Sprintf("%vx%v %v, score %v", candidate.Width, candidate.Height, candidate.Style, candidate.Score)
+		if candidate.Animated {
+			text += ", animated"
+		}
+		if candidate.Author != "" {
+			text += ", by " + candidate.Author
+		}
+		text = fitText(text, width-2)
+		if i == ui.candidate {
+			text = "\x1b[7m> " + text + "\x1b[27m"
+		} else {
+			text = "  " + text
+		}
+		line(1+i-top, text)
+	}
+
+	if ui.candidate < len(ui.candidates) {
+		thumb := ui.thumbs[ui.candidates[ui.candidate].SteamGridDBID]
+		if thumb != "" {
+			fmt.Fprintf(screen, "\x1b[2;%dH%v", width+2, thumb)
+		}
+	}
+}
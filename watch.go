@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kmicki/steamgrid/artwork"
+	"github.com/kmicki/steamgrid/steam"
+)
+
+// With -watch, SteamGrid keeps running after the first run and looks at the
+// files that tell which games the users have every few seconds: their
+// shortcuts.vdf and the appmanifest files of the Steam libraries. When they
+// change, the new games get their artwork in an incremental run.
+
+// What the watched files looked like, by path: the modification time of
+// shortcuts.vdf files, and the zero time for appmanifest files, which Steam
+// rewrites as games update but only adds or removes as they're installed or
+// uninstalled.
+func watchedFiles(users []artwork.User) map[string]time.Time {
+	files := map[string]time.Time{}
+	libraries := map[string]bool{}
+	for _, user := range users {
+		shortcutsVdf := filepath.Join(user.Dir, "config", "shortcuts.vdf")
+		if info, err := os.Stat(shortcutsVdf); err == nil {
+			files[shortcutsVdf] = info.ModTime()
+		}
+		dirs, _ := steam.LibraryDirs(user)
+		for _, dir := range dirs {
+			libraries[dir] = true
+		}
+	}
+	for dir := range libraries {
+		manifests, _ := filepath.Glob(filepath.Join(dir, "appmanifest_*.acf"))
+		for _, manifest := range manifests {
+			files[manifest] = time.Time{}
+		}
+	}
+	return files
+}
+
+// Returns the names of the files added, removed or modified between two looks
+// at the watched files.
+func changedFiles(before map[string]time.Time, after map[string]time.Time) []string {
+	var changed []string
+	for path, modTime := range after {
+		if previous, ok := before[path]; !ok || !previous.Equal(modTime) {
+			changed = append(changed, filepath.Base(path))
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, filepath.Base(path))
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Prints a line of the watch log, with the time.
+func watchLog(format string, args ...interface{}) {
+	fmt.Printf("[%v] %v\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+// Runs again, incrementally, every time games are added to the library,
+// until SteamGrid is stopped. The installation is unlocked between runs.
+func watchLibrary(options *artwork.Options) {
+	users, err := steam.GetUsers(artwork.SteamInstallationDir)
+	if err != nil {
+		errorAndExit(err)
+	}
+	options.Incremental = true
+	// Another run working on the installation is waited for, not quit for.
+	if options.Wait < time.Hour {
+		options.Wait = time.Hour
+	}
+
+	files := watchedFiles(users)
+	watchLog("Watching %v files for new games every %v, press Ctrl+C to stop", len(files), options.WatchInterval)
+	for !artwork.Stopping() {
+		time.Sleep(options.WatchInterval)
+		current := watchedFiles(users)
+		changed := changedFiles(files, current)
+		if len(changed) == 0 || artwork.Stopping() {
+			continue
+		}
+		// Steam writes the files a few times as games are added, the run
+		// waits for it to be done.
+		for settled := false; !settled; {
+			time.Sleep(2 * time.Second)
+			next := watchedFiles(users)
+			settled = len(changedFiles(current, next)) == 0
+			current = next
+		}
+
+		if len(changed) > 3 {
+			changed = append(changed[:3], fmt.Sprintf("%v more", len(changed)-3))
+		}
+		watchLog("%v changed, looking for new games", strings.Join(changed, ", "))
+		runPipeline(options, true, true)
+		artwork.UnlockInstallation()
+		watchLog("Done, watching for new games")
+		// The run may have changed shortcuts.vdf itself, like with
+		// -normalizenames.
+		files = watchedFiles(users)
+	}
+}
@@ -0,0 +1,234 @@
+// The web page of steamgrid serve. Everything goes through the API of
+// serve.go, with the token of the page address, like ?token=<token>, or the
+// one asked for when the server wants it.
+"use strict";
+
+let token = new URLSearchParams(location.search).get("token") || "";
+let user = "";
+let games = [];
+// Art style of the picker, and the game it's open for.
+let picked = { game: null, style: "" };
+
+const $ = (id) => document.getElementById(id);
+
+function status(message, error) {
+	$("status").textContent = message;
+	$("status").className = error ? "error" : "";
+}
+
+function apiURL(path, params) {
+	const query = new URLSearchParams(params || {});
+	if (token) {
+		query.set("token", token);
+	}
+	const search = query.toString();
+	return "api/" + path + (search ? "?" + search : "");
+}
+
+async function api(path, params, method) {
+	const response = await fetch(apiURL(path, params), { method: method || "GET" });
+	if (response.status === 401) {
+		token = prompt("Token of steamgrid serve:") || "";
+		if (token) {
+			return api(path, params, method);
+		}
+	}
+	if (!response.ok) {
+		throw new Error((await response.text()).trim() || response.statusText);
+	}
+	return response.json();
+}
+
+function gamePath(game) {
+	return "users/" + encodeURIComponent(user) + "/games/" + encodeURIComponent(game.id);
+}
+
+// Address of the image Steam shows, changed after every change so the
+// browser doesn't keep the old one.
+function imageURL(game, style) {
+	return apiURL(gamePath(game) + "/image", { style: style, t: game.changed || 0 });
+}
+
+async function loadUsers() {
+	const users = await api("users");
+	const select = $("user");
+	select.innerHTML = "";
+	for (const u of users) {
+		select.add(new Option(u.name + " (" + u.id + ")", u.id));
+	}
+	if (users.length > 0) {
+		await loadGames(users[0].id);
+	}
+}
+
+async function loadGames(id) {
+	user = id;
+	status("Loading games...");
+	games = await api("users/" + encodeURIComponent(user) + "/games");
+	status(games.length + " games");
+	render();
+}
+
+function render() {
+	const filter = $("filter").value.toLowerCase();
+	const onlyMissing = $("missing").checked;
+	const list = $("games");
+	list.innerHTML = "";
+	for (const game of games) {
+		const name = game.name || "Game " + game.id;
+		if (filter && !name.toLowerCase().includes(filter)) {
+			continue;
+		}
+		if (onlyMissing && !Object.values(game.artwork).includes("missing")) {
+			continue;
+		}
+		list.appendChild(renderGame(game, name));
+	}
+}
+
+function renderGame(game, name) {
+	const card = $("game").content.firstElementChild.cloneNode(true);
+	const image = card.querySelector("img");
+	// Covers, or banners for the games without one.
+	const style = game.artwork.Cover && game.artwork.Cover !== "missing" ? "Cover" : "Banner";
+	if (game.artwork[style] && game.artwork[style] !== "missing") {
+		image.src = imageURL(game, style);
+		image.onerror = () => card.classList.add("nocover");
+	} else {
+		card.classList.add("nocover");
+	}
+	card.querySelector(".placeholder").textContent = name;
+	card.querySelector("h3").textContent = name;
+	card.querySelector("h3").title = name;
+
+	const artwork = card.querySelector(".artwork");
+	for (const style of Object.keys(game.artwork).sort()) {
+		const item = document.createElement("li");
+		item.textContent = style;
+		item.className = game.artwork[style];
+		item.title = style + ": " + game.artwork[style];
+		artwork.appendChild(item);
+	}
+	card.onclick = () => openPicker(game);
+	return card;
+}
+
+function openPicker(game) {
+	picked.game = game;
+	$("picker-title").textContent = game.name || "Game " + game.id;
+	$("picker").showModal();
+	showCandidates(game.artwork.Cover ? "Cover" : Object.keys(game.artwork).sort()[0]);
+}
+
+// A button for each art style of the game in the picker, with its status.
+function renderPickerStyles() {
+	const styles = $("picker-styles");
+	styles.innerHTML = "";
+	for (const style of Object.keys(picked.game.artwork).sort()) {
+		const button = document.createElement("button");
+		button.type = "button";
+		button.textContent = style + " (" + picked.game.artwork[style] + ")";
+		button.classList.toggle("selected", style === picked.style);
+		button.onclick = () => showCandidates(style);
+		styles.appendChild(button);
+	}
+}
+
+async function showCandidates(style) {
+	picked.style = style;
+	renderPickerStyles();
+	const list = $("candidates");
+	list.innerHTML = "Looking for " + style.toLowerCase() + " candidates...";
+	let candidates;
+	try {
+		candidates = await api(gamePath(picked.game) + "/candidates", { style: style });
+	} catch (err) {
+		list.textContent = err.message;
+		return;
+	}
+	if (picked.style !== style) {
+		return;
+	}
+	list.innerHTML = candidates.length ? "" : "No candidates on SteamGridDB.";
+	for (const candidate of candidates) {
+		const item = document.createElement("figure");
+		item.className = "candidate";
+		const thumb = document.createElement("img");
+		thumb.loading = "lazy";
+		thumb.src = candidate.thumb;
+		const caption = document.createElement("figcaption");
+		caption.textContent = candidate.width + "x" + candidate.height + " " + candidate.style +
+			", score " + candidate.score + (candidate.animated ? ", animated" : "") +
+			(candidate.author ? ", by " + candidate.author : "");
+		item.append(thumb, caption);
+		item.onclick = () => apply(candidate);
+		list.appendChild(item);
+	}
+}
+
+// Describes what happened to the images of a game in the status line.
+function showEntries(game, entries) {
+	const failed = entries.filter((entry) => entry.errors && entry.errors.length > 0);
+	status((game.name || game.id) + ": " + entries.map((entry) => entry.artStyle + " " + entry.status).join(", "),
+		failed.length > 0);
+}
+
+async function change(game, what, request) {
+	status(what + " " + (game.name || game.id) + "...");
+	try {
+		const answer = await request();
+		game.changed = Date.now();
+		// The statuses of the artwork changed too.
+		const current = games.findIndex((g) => g.id === game.id);
+		const updated = (await api("users/" + encodeURIComponent(user) + "/games")).find((g) => g.id === game.id);
+		if (current >= 0 && updated) {
+			updated.changed = game.changed;
+			games[current] = updated;
+			picked.game = updated;
+		}
+		render();
+		if ($("picker").open) {
+			renderPickerStyles();
+		}
+		return answer;
+	} catch (err) {
+		status(err.message, true);
+	}
+}
+
+async function apply(candidate) {
+	const game = picked.game;
+	const entries = await change(game, "Applying to", () =>
+		api(gamePath(game) + "/apply", { style: picked.style, steamgriddbid: candidate.steamGridDBId }, "POST"));
+	if (entries) {
+		showEntries(game, entries);
+		$("picker").close();
+	}
+}
+
+$("picker-download").onclick = async () => {
+	const game = picked.game;
+	const params = { style: picked.style };
+	if ($("picker-force").checked) {
+		params.force = "true";
+	}
+	const entries = await change(game, "Downloading", () => api(gamePath(game) + "/download", params, "POST"));
+	if (entries) {
+		showEntries(game, entries);
+	}
+};
+
+$("picker-restore").onclick = async () => {
+	const game = picked.game;
+	const params = $("picker-force").checked ? { force: "true" } : {};
+	const answer = await change(game, "Restoring", () => api(gamePath(game) + "/restore", params, "POST"));
+	if (answer) {
+		status((game.name || game.id) + ": " + answer.restored + " images restored");
+	}
+};
+
+$("user").onchange = () => loadGames($("user").value).catch((err) => status(err.message, true));
+$("filter").oninput = render;
+$("missing").onchange = render;
+
+loadUsers().catch((err) => status(err.message, true));
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// The web page of the serve command, an in-browser alternative to the command
+// line: the library of a user with its artwork, and buttons to download,
+// pick and restore the images of each game through the API.
+//
+//go:embed web
+var webFiles embed.FS
+
+func webHandler() http.Handler {
+	files, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(files))
+}